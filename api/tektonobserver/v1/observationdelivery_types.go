@@ -0,0 +1,106 @@
+/*
+Copyright 2024 kcloutie.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// ObservationDeliverySpec defines a delivery of a phase of a PipelineRun to a sink that failed and is retried from
+// the outbox
+type ObservationDeliverySpec struct {
+	// PipelineRun is the PipelineRun the delivery is for
+	PipelineRun PipelineRunReference `json:"pipelineRun"`
+	// Sink is the name of the sink, for example pubsub/<project>/<topic>
+	Sink  string `json:"sink"`
+	Phase Phase  `json:"phase"`
+	// TaskName is the name of the pipeline task that finished, it is only set for the task-completed phase
+	// +optional
+	TaskName string `json:"taskName,omitempty"`
+	// ReplayKey is the idempotency key of the replay the delivery is for
+	// +optional
+	ReplayKey string `json:"replayKey,omitempty"`
+	// DeliveryID is the deterministic ID of the delivery, it is the same for every attempt
+	DeliveryID string `json:"deliveryID"`
+	// Payload is the rendered PipelineRun data delivered to the sink
+	Payload []byte `json:"payload"`
+}
+
+type PipelineRunReference struct {
+	Name string    `json:"name"`
+	UID  types.UID `json:"uid,omitempty"`
+}
+
+// DeliveryState is the state of a delivery in the outbox
+// +kubebuilder:validation:Enum=Pending;DeadLettered
+type DeliveryState string
+
+const (
+	// DeliveryPending deliveries are retried with a backoff, they are deleted once delivered
+	DeliveryPending DeliveryState = "Pending"
+	// DeliveryDeadLettered deliveries exhausted their retries, they are kept until they are retried or deleted
+	DeliveryDeadLettered DeliveryState = "DeadLettered"
+)
+
+// ObservationDeliveryStatus defines the observed state of ObservationDelivery
+type ObservationDeliveryStatus struct {
+	// +optional
+	State DeliveryState `json:"state,omitempty"`
+	// Attempts is the number of failed attempts of the delivery, including the attempts made before it was added to
+	// the outbox
+	// +optional
+	Attempts int `json:"attempts,omitempty"`
+	// +optional
+	LastError string `json:"lastError,omitempty"`
+	// +optional
+	LastAttemptTime *metav1.Time `json:"lastAttemptTime,omitempty"`
+	// NextAttemptTime is the time of the next retry of a pending delivery
+	// +optional
+	NextAttemptTime *metav1.Time `json:"nextAttemptTime,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="PipelineRun",type=string,JSONPath=`.spec.pipelineRun.name`
+//+kubebuilder:printcolumn:name="Sink",type=string,JSONPath=`.spec.sink`
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.spec.phase`
+//+kubebuilder:printcolumn:name="State",type=string,JSONPath=`.status.state`
+//+kubebuilder:printcolumn:name="Attempts",type=integer,JSONPath=`.status.attempts`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// ObservationDelivery is the Schema for the observationdeliveries API
+type ObservationDelivery struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ObservationDeliverySpec   `json:"spec,omitempty"`
+	Status ObservationDeliveryStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ObservationDeliveryList contains a list of ObservationDelivery
+type ObservationDeliveryList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ObservationDelivery `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ObservationDelivery{}, &ObservationDeliveryList{})
+}
@@ -26,19 +26,521 @@ type TektonObservationSpec struct {
 	// Important: Run "make" to regenerate code after modifying this file
 
 	// PubSubTopics is a list of PubSub topics to which the controller will publish events
-	PubSubTopics []PubSubTopic `json:"pubSubTopics" yaml:"pubSubTopics"`
+	// +optional
+	PubSubTopics []PubSubTopic `json:"pubSubTopics,omitempty" yaml:"pubSubTopics,omitempty"`
+
+	// KafkaTopics is a list of Apache Kafka topics to which the controller will produce events, the messages have
+	// the payload of the Pub/Sub messages and their attributes as headers
+	// +optional
+	KafkaTopics []KafkaTopic `json:"kafkaTopics,omitempty" yaml:"kafkaTopics,omitempty"`
+
+	// NATSSubjects is a list of NATS JetStream subjects to which the controller will publish events, the messages
+	// have the payload of the Pub/Sub messages and their attributes as headers
+	// +optional
+	NATSSubjects []NATSSubject `json:"natsSubjects,omitempty" yaml:"natsSubjects,omitempty"`
+
+	// Onboarding controls how the PipelineRuns that finished before the observation was created are reported. They
+	// are all reported when it is not set
+	// +optional
+	Onboarding *OnboardingPolicy `json:"onboarding,omitempty" yaml:"onboarding,omitempty"`
+
+	// DeadLetter is where the deliveries that exhausted their retries are sent, they are logged by the controller
+	// when it is not set
+	// +optional
+	DeadLetter *DeadLetterDestination `json:"deadLetter,omitempty" yaml:"deadLetter,omitempty"`
+
+	// RateLimits limit the rate of the deliveries and configure the circuit breakers of the sinks by sink type. The
+	// deliveries are not limited when their sink type is not listed, Retry-After responses are always honoured.
+	// +optional
+	// +listType=map
+	// +listMapKey=sinkType
+	RateLimits []SinkRateLimit `json:"rateLimits,omitempty" yaml:"rateLimits,omitempty"`
+
+	// Flakiness tracks the tasks of the pipelines that fail and then pass on retry or when the same commit runs
+	// again, the tasks are not tracked when it is not set
+	// +optional
+	Flakiness *FlakinessPolicy `json:"flakiness,omitempty" yaml:"flakiness,omitempty"`
+
+	// Metrics configures the duration and outcome metrics of the PipelineRuns of the namespace, they are recorded
+	// with the default labels and buckets when it is not set
+	// +optional
+	Metrics *RunMetricsPolicy `json:"metrics,omitempty" yaml:"metrics,omitempty"`
+
+	// Dora computes the DORA metrics of the deployment pipelines of the namespace, they are not computed when it is
+	// not set
+	// +optional
+	Dora *DoraPolicy `json:"dora,omitempty" yaml:"dora,omitempty"`
+
+	// OTLPTraces export the finished PipelineRuns as traces to OTLP receivers, such as an OpenTelemetry collector,
+	// Jaeger or Tempo
+	// +optional
+	OTLPTraces []OTLPTraceExporter `json:"otlpTraces,omitempty" yaml:"otlpTraces,omitempty"`
+
+	// Log writes the PipelineRuns to the stdout of the controller as JSON lines, for the clusters already shipping the
+	// container logs to Loki, Splunk or Cloud Logging
+	// +optional
+	Log *LogSink `json:"log,omitempty" yaml:"log,omitempty"`
+}
+
+// LogSink writes a JSON record per PipelineRun through the pipelineruns logger of the controller. The record has the
+// schema of the Pub/Sub payload.
+type LogSink struct {
+	// Verbosity is the verbosity the records are written at, the records are only written when the log level of the
+	// controller is at least this verbosity. It defaults to 0, the records are always written.
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=10
+	Verbosity int32 `json:"verbosity,omitempty" yaml:"verbosity,omitempty"`
+	// Phases are the phases of the PipelineRuns written, only the finished phase is written when it is empty
+	// +optional
+	Phases []Phase `json:"phases,omitempty" yaml:"phases,omitempty"`
+	// Fields are the top-level fields of the payload written, such as pipelineRunName or status. Every field is
+	// written when it is empty
+	// +optional
+	// +listType=set
+	Fields []string `json:"fields,omitempty" yaml:"fields,omitempty"`
+	// Redact are the paths of the fields whose value is replaced by [REDACTED], such as variables.token. The path
+	// segments are separated by dots and * matches every key of an object
+	// +optional
+	// +listType=set
+	Redact []string `json:"redact,omitempty" yaml:"redact,omitempty"`
+}
+
+// OTLPProtocol is the transport of an OTLP exporter
+// +kubebuilder:validation:Enum=grpc;http
+type OTLPProtocol string
+
+const (
+	// OTLPProtocolGRPC exports with OTLP over gRPC, usually on port 4317
+	OTLPProtocolGRPC OTLPProtocol = "grpc"
+	// OTLPProtocolHTTP exports with OTLP over HTTP with protobuf payloads, usually on port 4318
+	OTLPProtocolHTTP OTLPProtocol = "http"
+)
+
+// OTLPTraceExporter exports the finished PipelineRuns as traces. The PipelineRun is the root span, its TaskRuns are
+// the child spans and their steps are the grandchild spans. The trace ID is derived from the PipelineRun UID so the
+// PipelineRuns exported again replace their trace.
+type OTLPTraceExporter struct {
+	// Endpoint is the host and port of the OTLP receiver, such as otel-collector.observability:4317
+	// +kubebuilder:validation:MinLength=1
+	Endpoint string `json:"endpoint" yaml:"endpoint"`
+	// Protocol is the transport of the exports, it defaults to grpc
+	// +optional
+	Protocol OTLPProtocol `json:"protocol,omitempty" yaml:"protocol,omitempty"`
+	// URLPath is the path the spans are posted to with the http protocol, it defaults to /v1/traces
+	// +optional
+	URLPath string `json:"urlPath,omitempty" yaml:"urlPath,omitempty"`
+	// Insecure exports without TLS
+	// +optional
+	Insecure bool `json:"insecure,omitempty" yaml:"insecure,omitempty"`
+	// Headers are sent with every export
+	// +optional
+	Headers map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	// HeadersSecret is the name of a Secret of the namespace whose keys and values are sent as headers with every
+	// export, such as the API key of the receiver. They take precedence over Headers.
+	// +optional
+	HeadersSecret string `json:"headersSecret,omitempty" yaml:"headersSecret,omitempty"`
+	// ServiceName is the service.name resource attribute of the spans, it defaults to tekton-pipelines
+	// +optional
+	ServiceName string `json:"serviceName,omitempty" yaml:"serviceName,omitempty"`
+}
+
+// DoraPolicy defines the deployment pipelines and how their DORA metrics are computed and reported
+type DoraPolicy struct {
+	// Deployments are the pipelines deploying to an environment. The PipelineRuns labelled with
+	// observer.tkn.dev/environment are deployments to the environment of the label too.
+	// +optional
+	// +listType=map
+	// +listMapKey=pipeline
+	Deployments []DeploymentPipeline `json:"deployments,omitempty" yaml:"deployments,omitempty"`
+	// Window is the period the metrics are computed over, it defaults to 7 days
+	// +optional
+	Window *metav1.Duration `json:"window,omitempty" yaml:"window,omitempty"`
+	// SummaryInterval is how often the summary of the metrics is delivered to the sinks subscribed to the
+	// dora-summary phase, it defaults to 24h
+	// +optional
+	SummaryInterval *metav1.Duration `json:"summaryInterval,omitempty" yaml:"summaryInterval,omitempty"`
+}
+
+// DeploymentPipeline is a pipeline deploying to an environment
+type DeploymentPipeline struct {
+	Pipeline    string `json:"pipeline" yaml:"pipeline"`
+	Environment string `json:"environment" yaml:"environment"`
+}
+
+// MetricLabel is an optional label of the duration and outcome metrics, its value is read from the
+// Pipelines-as-Code labels of the PipelineRuns
+// +kubebuilder:validation:Enum=repository;event_type;branch
+type MetricLabel string
+
+const (
+	// MetricLabelRepository is the Pipelines-as-Code repository of the PipelineRun
+	MetricLabelRepository MetricLabel = "repository"
+	// MetricLabelEventType is the Pipelines-as-Code event type of the PipelineRun, such as push or pull_request
+	MetricLabelEventType MetricLabel = "event_type"
+	// MetricLabelBranch is the Pipelines-as-Code branch of the PipelineRun
+	MetricLabelBranch MetricLabel = "branch"
+)
+
+// RunMetricsPolicy limits the cardinality of the duration and outcome metrics of the PipelineRuns and TaskRuns
+type RunMetricsPolicy struct {
+	// Disabled stops recording the metrics of the PipelineRuns of the namespace
+	// +optional
+	Disabled bool `json:"disabled,omitempty" yaml:"disabled,omitempty"`
+	// Labels are the optional labels set on the metrics, the labels that are not listed are left empty
+	// +optional
+	// +listType=set
+	Labels []MetricLabel `json:"labels,omitempty" yaml:"labels,omitempty"`
+	// Pipelines are the pipelines recorded under their name, the other pipelines are recorded under the "other"
+	// pipeline. Every pipeline is recorded under its name when it is not set
+	// +optional
+	// +listType=set
+	Pipelines []string `json:"pipelines,omitempty" yaml:"pipelines,omitempty"`
+	// TaskRuns records the metrics of the TaskRuns of the PipelineRuns too
+	// +optional
+	TaskRuns bool `json:"taskRuns,omitempty" yaml:"taskRuns,omitempty"`
+	// DurationBuckets are the upper bounds of the buckets of the duration histograms, they default to 10s, 30s, 1m,
+	// 2m, 5m, 10m, 20m, 30m, 1h and 2h
+	// +optional
+	// +kubebuilder:validation:MaxItems=30
+	DurationBuckets []metav1.Duration `json:"durationBuckets,omitempty" yaml:"durationBuckets,omitempty"`
+	// QueueBuckets are the upper bounds of the buckets of the queue time histograms, they default to 1s, 5s, 10s,
+	// 30s, 1m, 2m, 5m and 10m
+	// +optional
+	// +kubebuilder:validation:MaxItems=30
+	QueueBuckets []metav1.Duration `json:"queueBuckets,omitempty" yaml:"queueBuckets,omitempty"`
+}
+
+// FlakinessPolicy defines how the flakiness of the tasks is computed and reported
+type FlakinessPolicy struct {
+	// Window is the number of runs of a task the flakiness ratio is computed over, it defaults to 50
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=500
+	Window int32 `json:"window,omitempty" yaml:"window,omitempty"`
+	// ThresholdPercent is the flakiness ratio, in percent, from which a task is reported as flaky with the
+	// PipelineRuns, it defaults to 10
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	ThresholdPercent int32 `json:"thresholdPercent,omitempty" yaml:"thresholdPercent,omitempty"`
+	// MinRuns is the number of runs of a task needed before it is reported as flaky, it defaults to 10
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	MinRuns int32 `json:"minRuns,omitempty" yaml:"minRuns,omitempty"`
+}
+
+// SinkType is the type of a sink
+// +kubebuilder:validation:Enum=pubsub;kafka;nats;otlp;log
+type SinkType string
+
+const (
+	// SinkTypePubSub publishes to Pub/Sub topics
+	SinkTypePubSub SinkType = "pubsub"
+	// SinkTypeKafka produces to Apache Kafka topics
+	SinkTypeKafka SinkType = "kafka"
+	// SinkTypeNATS publishes to NATS JetStream subjects
+	SinkTypeNATS SinkType = "nats"
+	// SinkTypeOTLP exports traces to OTLP receivers
+	SinkTypeOTLP SinkType = "otlp"
+	// SinkTypeLog writes to the controller log
+	SinkTypeLog SinkType = "log"
+)
+
+// SinkRateLimit limits the deliveries to the sinks of a type with a token bucket shared by the sinks of the type
+type SinkRateLimit struct {
+	SinkType SinkType `json:"sinkType" yaml:"sinkType"`
+	// RequestsPerMinute is the rate the bucket is refilled at, the deliveries are not rate limited when it is not set
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	RequestsPerMinute int32 `json:"requestsPerMinute,omitempty" yaml:"requestsPerMinute,omitempty"`
+	// Burst is the size of the bucket, it defaults to 1
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	Burst int32 `json:"burst,omitempty" yaml:"burst,omitempty"`
+	// MaxWait is the longest a delivery waits for the bucket before it fails and is retried later, it defaults to 10s
+	// +optional
+	MaxWait *metav1.Duration `json:"maxWait,omitempty" yaml:"maxWait,omitempty"`
+	// CircuitBreaker stops calling a sink that keeps failing for a cool-off period, the sinks are only paused by
+	// Retry-After responses when it is not set
+	// +optional
+	CircuitBreaker *CircuitBreakerPolicy `json:"circuitBreaker,omitempty" yaml:"circuitBreaker,omitempty"`
+}
+
+type CircuitBreakerPolicy struct {
+	// FailureThreshold is the number of consecutive failures opening the circuit, it defaults to 5
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	FailureThreshold int32 `json:"failureThreshold,omitempty" yaml:"failureThreshold,omitempty"`
+	// CoolOff is how long the circuit stays open before a delivery is attempted again, it defaults to 1m
+	// +optional
+	CoolOff *metav1.Duration `json:"coolOff,omitempty" yaml:"coolOff,omitempty"`
+}
+
+// DeadLetterDestination defines where the dead-lettered deliveries are sent, every destination set is used
+type DeadLetterDestination struct {
+	// PubSubTopic publishes the dead-lettered deliveries to a Pub/Sub topic
+	// +optional
+	PubSubTopic *PubSubTopic `json:"pubSubTopic,omitempty" yaml:"pubSubTopic,omitempty"`
+	// ConfigMap stores every dead-lettered delivery in a ConfigMap of the namespace
+	// +optional
+	ConfigMap *ConfigMapDeadLetter `json:"configMap,omitempty" yaml:"configMap,omitempty"`
+	// Log writes the dead-lettered deliveries to the controller log
+	// +optional
+	Log bool `json:"log,omitempty" yaml:"log,omitempty"`
+}
+
+type ConfigMapDeadLetter struct {
+	// NamePrefix is the prefix of the names of the ConfigMaps, it defaults to tekton-observer-dead-letter
+	// +optional
+	NamePrefix string `json:"namePrefix,omitempty" yaml:"namePrefix,omitempty"`
+}
+
+// OnboardingMode defines what happens to the PipelineRuns that finished before the observation was created
+// +kubebuilder:validation:Enum=ignoreExisting;backfill;sinceTimestamp
+type OnboardingMode string
+
+const (
+	// OnboardingIgnoreExisting marks the existing PipelineRuns as complete without reporting them
+	OnboardingIgnoreExisting OnboardingMode = "ignoreExisting"
+	// OnboardingBackfill reports the existing PipelineRuns at a limited rate with the backfill=true attribute
+	OnboardingBackfill OnboardingMode = "backfill"
+	// OnboardingSinceTimestamp backfills the existing PipelineRuns that finished after SinceTimestamp and ignores the
+	// older ones
+	OnboardingSinceTimestamp OnboardingMode = "sinceTimestamp"
+)
+
+type OnboardingPolicy struct {
+	Mode OnboardingMode `json:"mode" yaml:"mode"`
+	// SinceTimestamp is required by the sinceTimestamp mode
+	// +optional
+	SinceTimestamp *metav1.Time `json:"sinceTimestamp,omitempty" yaml:"sinceTimestamp,omitempty"`
+	// BackfillPerMinute is the maximum number of PipelineRuns backfilled per minute, it defaults to 10
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	BackfillPerMinute int `json:"backfillPerMinute,omitempty" yaml:"backfillPerMinute,omitempty"`
 }
 type PubSubTopic struct {
 	// ProjectID is the GCP project ID where the PubSub topic is located
 	PubSubProjectID string `json:"pubSubProjectID" yaml:"pubSubProjectID"`
 	// PubSubTopicID is the ID of the PubSub topic
 	PubSubTopicID string `json:"pubSubTopicID" yaml:"pubSubTopicID"`
+	// Phases are the phases of the PipelineRuns published to the topic, only the finished phase is published when
+	// it is empty
+	// +optional
+	Phases []Phase `json:"phases,omitempty" yaml:"phases,omitempty"`
+	// OrderingKey sets the Pub/Sub ordering key of the messages, they are published without an ordering key when it
+	// is empty. Message ordering must be enabled on the subscriptions.
+	// +optional
+	OrderingKey PubSubOrderingKey `json:"orderingKey,omitempty" yaml:"orderingKey,omitempty"`
+	// Digest buffers the finished PipelineRuns and publishes a single summary per group instead of a message per
+	// PipelineRun
+	// +optional
+	Digest *DigestPolicy `json:"digest,omitempty" yaml:"digest,omitempty"`
+}
+
+// KafkaTopic is an Apache Kafka topic the PipelineRun events are produced to
+type KafkaTopic struct {
+	// Brokers are the host and port of the bootstrap brokers of the cluster
+	// +kubebuilder:validation:MinItems=1
+	Brokers []string `json:"brokers" yaml:"brokers"`
+	// Topic is the name of the topic
+	// +kubebuilder:validation:MinLength=1
+	Topic string `json:"topic" yaml:"topic"`
+	// Phases are the phases of the PipelineRuns produced to the topic, only the finished phase is produced when it
+	// is empty
+	// +optional
+	Phases []Phase `json:"phases,omitempty" yaml:"phases,omitempty"`
+	// Key sets the key of the messages, the messages with the same key are written to the same partition in order.
+	// It defaults to pipelineRun
+	// +optional
+	Key KafkaKey `json:"key,omitempty" yaml:"key,omitempty"`
+	// Compression is the compression codec of the messages, they are not compressed when it is not set
+	// +optional
+	Compression KafkaCompression `json:"compression,omitempty" yaml:"compression,omitempty"`
+	// SASL authenticates the producer to the brokers
+	// +optional
+	SASL *KafkaSASL `json:"sasl,omitempty" yaml:"sasl,omitempty"`
+	// TLS connects to the brokers with TLS, the connections are not encrypted when it is not set
+	// +optional
+	TLS *KafkaTLS `json:"tls,omitempty" yaml:"tls,omitempty"`
+	// Digest buffers the finished PipelineRuns and produces a single summary per group instead of a message per
+	// PipelineRun
+	// +optional
+	Digest *DigestPolicy `json:"digest,omitempty" yaml:"digest,omitempty"`
+}
+
+// KafkaKey defines the key of the Kafka messages
+// +kubebuilder:validation:Enum=pipelineRun;repository;none
+type KafkaKey string
+
+const (
+	// KafkaKeyPipelineRun keys the messages by PipelineRun UID, the messages of a PipelineRun are delivered in order
+	KafkaKeyPipelineRun KafkaKey = "pipelineRun"
+	// KafkaKeyRepository keys the messages by Pipelines-as-Code repository, the PipelineRuns without repository are
+	// keyed by namespace
+	KafkaKeyRepository KafkaKey = "repository"
+	// KafkaKeyNone produces the messages without key, they are spread over the partitions
+	KafkaKeyNone KafkaKey = "none"
+)
+
+// KafkaCompression is the compression codec of the Kafka messages
+// +kubebuilder:validation:Enum=none;gzip;snappy;lz4;zstd
+type KafkaCompression string
+
+const (
+	KafkaCompressionNone   KafkaCompression = "none"
+	KafkaCompressionGzip   KafkaCompression = "gzip"
+	KafkaCompressionSnappy KafkaCompression = "snappy"
+	KafkaCompressionLz4    KafkaCompression = "lz4"
+	KafkaCompressionZstd   KafkaCompression = "zstd"
+)
+
+// KafkaSASLMechanism is the SASL mechanism authenticating the producer
+// +kubebuilder:validation:Enum=plain;scram-sha-256;scram-sha-512
+type KafkaSASLMechanism string
+
+const (
+	KafkaSASLPlain       KafkaSASLMechanism = "plain"
+	KafkaSASLScramSHA256 KafkaSASLMechanism = "scram-sha-256"
+	KafkaSASLScramSHA512 KafkaSASLMechanism = "scram-sha-512"
+)
+
+// KafkaSASL authenticates the producer with the username and password keys of a Secret of the namespace
+type KafkaSASL struct {
+	Mechanism KafkaSASLMechanism `json:"mechanism" yaml:"mechanism"`
+	// Secret is the name of the Secret holding the username and password keys
+	// +kubebuilder:validation:MinLength=1
+	Secret string `json:"secret" yaml:"secret"`
+}
+
+// KafkaTLS configures the TLS connections to the brokers
+type KafkaTLS struct {
+	// Secret is the name of a Secret of the namespace holding the CA certificate of the brokers in ca.crt and the
+	// client certificate in tls.crt and tls.key, every key is optional. The system CAs are trusted when it is not set
+	// +optional
+	Secret string `json:"secret,omitempty" yaml:"secret,omitempty"`
+	// InsecureSkipVerify does not verify the certificates of the brokers
+	// +optional
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty" yaml:"insecureSkipVerify,omitempty"`
+}
+
+// NATSSubject is a NATS subject captured by a JetStream stream the PipelineRun events are published to
+type NATSSubject struct {
+	// URL is the URL of the NATS servers, several URLs are separated by commas. The tls:// URLs trust the system CAs
+	// +kubebuilder:validation:MinLength=1
+	URL string `json:"url" yaml:"url"`
+	// Subject is the go template rendering the subject of the messages, such as
+	// tekton.{{.Namespace}}.{{.PipelineName}}.{{.Status}}. The template is executed with the Namespace,
+	// PipelineRunName, PipelineName, Status, Reason, Phase, TaskName and PacLabels of the event
+	// +kubebuilder:validation:MinLength=1
+	Subject string `json:"subject" yaml:"subject"`
+	// Stream is the stream expected to capture the subject, the publishes fail when another stream captures it
+	// +optional
+	Stream string `json:"stream,omitempty" yaml:"stream,omitempty"`
+	// Phases are the phases of the PipelineRuns published to the subject, only the finished phase is published when
+	// it is empty
+	// +optional
+	Phases []Phase `json:"phases,omitempty" yaml:"phases,omitempty"`
+	// CredentialsSecret is the name of a Secret of the namespace holding the credentials of the connection, a user
+	// credentials file in creds, a token in token or a username and a password in username and password
+	// +optional
+	CredentialsSecret string `json:"credentialsSecret,omitempty" yaml:"credentialsSecret,omitempty"`
+}
+
+// DigestGroupBy defines which PipelineRuns are summarized together
+// +kubebuilder:validation:Enum=pipeline;repository;namespace
+type DigestGroupBy string
+
+const (
+	// DigestByPipeline summarizes the PipelineRuns of a pipeline
+	DigestByPipeline DigestGroupBy = "pipeline"
+	// DigestByRepository summarizes the PipelineRuns of a Pipelines-as-Code repository, the PipelineRuns without
+	// repository are grouped by namespace
+	DigestByRepository DigestGroupBy = "repository"
+	// DigestByNamespace summarizes the PipelineRuns of a namespace
+	DigestByNamespace DigestGroupBy = "namespace"
+)
+
+// DigestPolicy defines how the finished PipelineRuns are buffered and summarized
+type DigestPolicy struct {
+	// Window is how long the PipelineRuns are buffered after the first PipelineRun of a group, it defaults to 10m
+	// +optional
+	Window *metav1.Duration `json:"window,omitempty" yaml:"window,omitempty"`
+	// MaxRuns sends the digest as soon as it holds this number of PipelineRuns, it defaults to 50
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	MaxRuns int32 `json:"maxRuns,omitempty" yaml:"maxRuns,omitempty"`
+	// GroupBy defines which PipelineRuns are summarized together, it defaults to pipeline
+	// +optional
+	GroupBy DigestGroupBy `json:"groupBy,omitempty" yaml:"groupBy,omitempty"`
+	// Template is the go template rendering the text of the summary from the digest, the number of failed and
+	// succeeded PipelineRuns followed by the failed PipelineRuns is rendered when it is empty
+	// +optional
+	Template string `json:"template,omitempty" yaml:"template,omitempty"`
 }
 
+// PubSubOrderingKey defines which messages are delivered in order
+// +kubebuilder:validation:Enum=pipelineRun;pipeline;namespace
+type PubSubOrderingKey string
+
+const (
+	// OrderingKeyPipelineRun delivers the messages of a PipelineRun in order, it is keyed by the PipelineRun UID
+	OrderingKeyPipelineRun PubSubOrderingKey = "pipelineRun"
+	// OrderingKeyPipeline delivers the messages of the PipelineRuns of a pipeline in order
+	OrderingKeyPipeline PubSubOrderingKey = "pipeline"
+	// OrderingKeyNamespace delivers the messages of the PipelineRuns of a namespace in order
+	OrderingKeyNamespace PubSubOrderingKey = "namespace"
+)
+
+// OrderingKeys are the supported Pub/Sub ordering keys
+var OrderingKeys = []PubSubOrderingKey{OrderingKeyPipelineRun, OrderingKeyPipeline, OrderingKeyNamespace}
+
+// Phase is a step of the lifecycle of a PipelineRun the sinks can subscribe to
+// +kubebuilder:validation:Enum=queued;started;task-completed;finished;dora-summary
+type Phase string
+
+const (
+	// PhaseQueued is delivered when a PipelineRun is observed before it started
+	PhaseQueued Phase = "queued"
+	// PhaseStarted is delivered when a PipelineRun started
+	PhaseStarted Phase = "started"
+	// PhaseTaskCompleted is delivered every time a TaskRun of the PipelineRun finishes
+	PhaseTaskCompleted Phase = "task-completed"
+	// PhaseFinished is delivered when a PipelineRun finished or was deleted
+	PhaseFinished Phase = "finished"
+	// PhaseDoraSummary is not a phase of a PipelineRun, the summary of the DORA metrics of the namespace is
+	// delivered periodically to the sinks subscribed to it
+	PhaseDoraSummary Phase = "dora-summary"
+)
+
+// Phases lists every phase in the order they happen
+var Phases = []Phase{PhaseQueued, PhaseStarted, PhaseTaskCompleted, PhaseFinished, PhaseDoraSummary}
+
 // TektonObservationStatus defines the observed state of TektonObservation
 type TektonObservationStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
+
+	// DeadLetteredDeliveries is the number of deliveries of the namespace that were dead-lettered
+	// +optional
+	DeadLetteredDeliveries int64 `json:"deadLetteredDeliveries,omitempty"`
+	// LastDeadLetter describes the last dead-lettered delivery
+	// +optional
+	LastDeadLetter *DeadLetterRecord `json:"lastDeadLetter,omitempty"`
+}
+
+// DeadLetterRecord describes a dead-lettered delivery
+type DeadLetterRecord struct {
+	PipelineRun string `json:"pipelineRun"`
+	Sink        string `json:"sink"`
+	Phase       Phase  `json:"phase"`
+	DeliveryID  string `json:"deliveryID,omitempty"`
+	Reason      string `json:"reason,omitempty"`
+	// Destinations are the dead-letter destinations the delivery was sent to
+	// +optional
+	Destinations []string    `json:"destinations,omitempty"`
+	Time         metav1.Time `json:"time"`
 }
 
 //+kubebuilder:object:root=true
@@ -17,15 +17,20 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"flag"
 	"os"
+	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	"go.uber.org/zap/zapcore"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
@@ -35,6 +40,18 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	observerv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"github.com/kcloutie/tekton-observer/internal/cli"
+	"github.com/kcloutie/tekton-observer/internal/controller"
+	"github.com/kcloutie/tekton-observer/internal/namespacecache"
+	"github.com/kcloutie/tekton-observer/internal/sharding"
+	"github.com/kcloutie/tekton-observer/internal/tektonobserver"
+	"github.com/kcloutie/tekton-observer/pkg/events"
+	"github.com/kcloutie/tekton-observer/pkg/gcp"
+	"github.com/kcloutie/tekton-observer/pkg/metrics"
+	"github.com/kcloutie/tekton-observer/pkg/tracing"
+	tknv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 	//+kubebuilder:scaffold:imports
 )
@@ -47,16 +64,32 @@ var (
 func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 
+	utilruntime.Must(tknv1.AddToScheme(scheme))
 	utilruntime.Must(observerv1.AddToScheme(scheme))
 	//+kubebuilder:scaffold:scheme
 }
 
 func main() {
+	if len(os.Args) > 1 && cli.IsCommand(os.Args[1]) {
+		env := &cli.Env{Scheme: scheme, Stdout: os.Stdout, Stderr: os.Stderr}
+		os.Exit(cli.Run(ctrl.SetupSignalHandler(), env, os.Args[1:]))
+	}
+
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
 	var secureMetrics bool
 	var enableHTTP2 bool
+	var configFile string
+	var watchNamespaces string
+	var watchNamespaceSelector string
+	var maxConcurrentReconciles int
+	var clusterName string
+	var cacheTrimPipelineRuns bool
+	var cacheExcludeComplete bool
+	var shardMode string
+	var shardGroup string
+	var shardLeaseNamespace string
 
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
@@ -67,6 +100,28 @@ func main() {
 		"If set the metrics endpoint is served securely")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	flag.StringVar(&configFile, "config", "",
+		"The path to the controller configuration file. The file is reloaded automatically when it changes.")
+	flag.StringVar(&watchNamespaces, "watch-namespaces", "",
+		"A comma separated list of namespaces to watch. All namespaces are watched when it is empty.")
+	flag.StringVar(&watchNamespaceSelector, "watch-namespace-selector", "",
+		"A label selector of the namespaces to watch. Namespaces are added and removed as they are labelled. "+
+			"Cannot be combined with --watch-namespaces.")
+	flag.IntVar(&maxConcurrentReconciles, "max-concurrent-reconciles", 0,
+		"The maximum number of concurrent reconciles. Overrides the value of the controller configuration when set.")
+	flag.StringVar(&clusterName, "cluster-name", "",
+		"The name of the cluster added to every published event. Overrides the value of the controller configuration when set.")
+	flag.BoolVar(&cacheTrimPipelineRuns, "cache-trim-pipelineruns", true,
+		"If set the large fields of the PipelineRuns are not cached, they are read from the API server when processed.")
+	flag.BoolVar(&cacheExcludeComplete, "cache-exclude-complete", false,
+		"If set the PipelineRuns labelled as complete are not cached.")
+	flag.StringVar(&shardMode, "shard-mode", "",
+		"Split the PipelineRuns between the replicas by 'namespace' or 'uid'. Sharding is disabled when empty and "+
+			"cannot be combined with --leader-elect.")
+	flag.StringVar(&shardGroup, "shard-group", "tekton-observer",
+		"The name of the group of replicas sharing the PipelineRuns, used to label the shard Leases.")
+	flag.StringVar(&shardLeaseNamespace, "shard-lease-namespace", os.Getenv("POD_NAMESPACE"),
+		"The namespace of the shard Leases. Defaults to the namespace of the pod.")
 
 	opts := zap.Options{
 		TimeEncoder: zapcore.ISO8601TimeEncoder,
@@ -77,6 +132,26 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	var configWatcher *tektonobserver.ConfigWatcher
+	if configFile != "" {
+		configWatcher = tektonobserver.NewConfigWatcher(configFile, tektonobserver.ControllerConfiguration, ctrl.Log.WithName("config"))
+		if err := configWatcher.Reload(); err != nil {
+			setupLog.Error(err, "unable to load the controller configuration")
+			os.Exit(1)
+		}
+	}
+	if clusterName != "" {
+		tektonobserver.ControllerConfiguration.AddOverride(func(c *tektonobserver.ControllerConfig) {
+			c.ClusterName = clusterName
+		})
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background())
+	if err != nil {
+		setupLog.Error(err, "unable to set up the tracing of the controller")
+		os.Exit(1)
+	}
+
 	// if the enable-http2 flag is false (the default), http/2 should be disabled
 	// due to its vulnerabilities. More specifically, disabling http/2 will
 	// prevent from being vulnerable to the HTTP/2 Stream Cancelation and
@@ -97,8 +172,57 @@ func main() {
 		TLSOpts: tlsOpts,
 	})
 
+	mode, err := sharding.ParseMode(shardMode)
+	if err != nil {
+		setupLog.Error(err, "invalid --shard-mode")
+		os.Exit(1)
+	}
+	if mode != "" && enableLeaderElection {
+		setupLog.Error(nil, "--shard-mode and --leader-elect cannot be used together, the replicas are active-active when sharded")
+		os.Exit(1)
+	}
+	if mode != "" && shardLeaseNamespace == "" {
+		setupLog.Error(nil, "--shard-lease-namespace is required when POD_NAMESPACE is not set")
+		os.Exit(1)
+	}
+
+	if watchNamespaces != "" && watchNamespaceSelector != "" {
+		setupLog.Error(nil, "--watch-namespaces and --watch-namespace-selector cannot be used together")
+		os.Exit(1)
+	}
+	var newCache cache.NewCacheFunc
+	if watchNamespaceSelector != "" {
+		selector, err := labels.Parse(watchNamespaceSelector)
+		if err != nil {
+			setupLog.Error(err, "unable to parse the namespace selector", "selector", watchNamespaceSelector)
+			os.Exit(1)
+		}
+		newCache = namespacecache.NewCacheFunc(selector)
+		setupLog.Info("watching the namespaces matching a label selector", "selector", watchNamespaceSelector)
+	}
+
+	cacheOptions := cache.Options{
+		DefaultTransform: controller.StripManagedFields,
+		ByObject: map[client.Object]cache.ByObject{
+			&tknv1.PipelineRun{}: controller.PipelineRunCacheOptions(cacheTrimPipelineRuns, cacheExcludeComplete),
+			&tknv1.TaskRun{}:     controller.TaskRunCacheOptions(),
+			&corev1.ConfigMap{}:  controller.StateConfigMapCacheOptions(),
+		},
+	}
+	if watchNamespaces != "" {
+		cacheOptions.DefaultNamespaces = map[string]cache.Config{}
+		for _, namespace := range strings.Split(watchNamespaces, ",") {
+			if namespace = strings.TrimSpace(namespace); namespace != "" {
+				cacheOptions.DefaultNamespaces[namespace] = cache.Config{}
+			}
+		}
+		setupLog.Info("watching a restricted set of namespaces", "namespaces", watchNamespaces)
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme: scheme,
+		Scheme:   scheme,
+		Cache:    cacheOptions,
+		NewCache: newCache,
 		Metrics: metricsserver.Options{
 			BindAddress:   metricsAddr,
 			SecureServing: secureMetrics,
@@ -125,8 +249,57 @@ func main() {
 		os.Exit(1)
 	}
 
+	metrics.ConfigureBuckets(tektonobserver.ControllerConfiguration.Get().Metrics.Buckets)
+	metrics.InitMetrics()
+
+	// The pod name identifies this replica in the events it emits and in the shard Leases
+	controllerInstance := os.Getenv("POD_NAME")
+	if controllerInstance == "" {
+		controllerInstance, _ = os.Hostname()
+	}
+
+	var sharder *sharding.Sharder
+	if mode != "" {
+		membership := &sharding.Membership{
+			Client:    mgr.GetClient(),
+			Reader:    mgr.GetAPIReader(),
+			Namespace: shardLeaseNamespace,
+			Group:     shardGroup,
+			Identity:  controllerInstance,
+			Log:       ctrl.Log.WithName("sharding"),
+		}
+		if err := mgr.Add(membership); err != nil {
+			setupLog.Error(err, "unable to set up the shard membership")
+			os.Exit(1)
+		}
+		sharder = &sharding.Sharder{Mode: mode, Membership: membership}
+		setupLog.Info("sharding the PipelineRuns between the replicas", "mode", mode, "group", shardGroup, "identity", controllerInstance)
+	}
+	eventLogger := ctrl.Log.WithName("events")
+	eventEmitter := events.NewEventEmitter(mgr.GetClient(), &eventLogger, controllerInstance)
+
+	if err = (&controller.TektonObservationReconciler{
+		Client:                  metrics.InstrumentClient(mgr.GetClient()),
+		APIReader:               metrics.InstrumentReader(mgr.GetAPIReader(), mgr.GetScheme()),
+		Scheme:                  mgr.GetScheme(),
+		EventEmitter:            eventEmitter,
+		MaxConcurrentReconciles: maxConcurrentReconciles,
+		Sharder:                 sharder,
+		// The log sinks write JSON lines to stdout whatever the encoder of the controller logs
+		RunLogger: zap.New(zap.UseFlagOptions(&opts), zap.JSONEncoder(), zap.WriteTo(os.Stdout)).WithName("pipelineruns"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "TektonObservation")
+		os.Exit(1)
+	}
 	//+kubebuilder:scaffold:builder
 
+	if configWatcher != nil {
+		if err := mgr.Add(configWatcher); err != nil {
+			setupLog.Error(err, "unable to set up the controller configuration watcher")
+			os.Exit(1)
+		}
+	}
+
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
 		os.Exit(1)
@@ -136,9 +309,34 @@ func main() {
 		os.Exit(1)
 	}
 
+	selfCheck := &controller.SelfCheck{
+		Reader:                 mgr.GetClient(),
+		Mapper:                 mgr.GetRESTMapper(),
+		CheckPubSubCredentials: gcp.CheckPubSubCredentials,
+		Log:                    ctrl.Log.WithName("selfcheck"),
+	}
+	if err := mgr.AddReadyzCheck("tekton-crd", selfCheck.PipelineRunCRD); err != nil {
+		setupLog.Error(err, "unable to set up the tekton CRD ready check")
+		os.Exit(1)
+	}
+	if err := mgr.AddReadyzCheck("pubsub-credentials", selfCheck.PubSubCredentials); err != nil {
+		setupLog.Error(err, "unable to set up the pub/sub credentials ready check")
+		os.Exit(1)
+	}
+	if err := mgr.Add(selfCheck); err != nil {
+		setupLog.Error(err, "unable to set up the startup self check")
+		os.Exit(1)
+	}
+
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdownTracing(ctx); err != nil {
+		setupLog.Error(err, "unable to flush the spans of the controller")
+	}
 }
@@ -0,0 +1,57 @@
+#!/usr/bin/env bash
+# Generates the RoleBindings and the --watch-namespaces flag of the namespaced
+# tenant overlay from a comma separated list of namespaces. The RoleBindings
+# grant the manager ClusterRole generated by controller-gen in each namespace.
+set -euo pipefail
+
+namespaces="${1:?usage: $0 <namespace,...> <overlay directory>}"
+overlay="${2:?usage: $0 <namespace,...> <overlay directory>}"
+
+header="# Code generated by hack/tenant-manifests.sh. DO NOT EDIT."
+
+{
+  echo "${header}"
+  IFS=',' read -ra list <<< "${namespaces}"
+  for namespace in "${list[@]}"; do
+    cat <<YAML
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: RoleBinding
+metadata:
+  labels:
+    app.kubernetes.io/name: rolebinding
+    app.kubernetes.io/instance: tenant-manager-rolebinding
+    app.kubernetes.io/component: rbac
+    app.kubernetes.io/created-by: tekton-observer
+    app.kubernetes.io/part-of: tekton-observer
+    app.kubernetes.io/managed-by: kustomize
+  name: tekton-observer-manager-rolebinding
+  namespace: ${namespace}
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: tekton-observer-manager-role
+subjects:
+- kind: ServiceAccount
+  name: tekton-observer-controller-manager
+  namespace: tekton-observer-system
+YAML
+  done
+} > "${overlay}/role_bindings.yaml"
+
+cat > "${overlay}/manager_namespaces_patch.yaml" <<YAML
+${header}
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: tekton-observer-controller-manager
+  namespace: tekton-observer-system
+spec:
+  template:
+    spec:
+      containers:
+      - name: manager
+        args:
+        - "--config=/etc/tekton-observer/controller_manager_config.yaml"
+        - "--watch-namespaces=${namespaces}"
+YAML
@@ -0,0 +1,120 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// command is a subcommand of the tekton-observer binary
+type command struct {
+	description string
+	run         func(ctx context.Context, env *Env, args []string) error
+}
+
+var commands = map[string]command{
+	"flaky": {
+		description: "List the flaky tasks of the pipelines, the flakiest first",
+		run:         runFlaky,
+	},
+	"onboard": {
+		description: "Apply an onboarding policy to the PipelineRuns that finished before a namespace was observed",
+		run:         runOnboard,
+	},
+	"outbox": {
+		description: "List the deliveries of the outbox (outbox list) or retry the dead-lettered ones (outbox retry)",
+		run:         runOutbox,
+	},
+	"replay": {
+		description: "Deliver the selected PipelineRuns to the sinks again",
+		run:         runReplay,
+	},
+}
+
+// Env holds what the subcommands need to run
+type Env struct {
+	Scheme *runtime.Scheme
+	Stdout io.Writer
+	Stderr io.Writer
+	// NewClient creates the client used by the subcommands, a client for the current kubeconfig is created when it is
+	// not set
+	NewClient func() (client.Client, error)
+}
+
+func (e *Env) client() (client.Client, error) {
+	if e.NewClient != nil {
+		return e.NewClient()
+	}
+	config, err := ctrl.GetConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the kubeconfig - %w", err)
+	}
+	return client.New(config, client.Options{Scheme: e.Scheme})
+}
+
+// IsCommand returns true when name is a subcommand, the controller is started otherwise
+func IsCommand(name string) bool {
+	_, found := commands[name]
+	return found || name == "help"
+}
+
+// Run executes the subcommand named by the first argument and returns the exit code of the process
+func Run(ctx context.Context, env *Env, args []string) int {
+	if len(args) == 0 || args[0] == "help" {
+		usage(env.Stdout)
+		return 0
+	}
+	cmd, found := commands[args[0]]
+	if !found {
+		fmt.Fprintf(env.Stderr, "unknown command '%s'\n", args[0])
+		usage(env.Stderr)
+		return 2
+	}
+	if err := cmd.run(ctx, env, args[1:]); err != nil {
+		fmt.Fprintf(env.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+func usage(out io.Writer) {
+	fmt.Fprintln(out, "Usage: tekton-observer [flags]              start the controller")
+	fmt.Fprintln(out, "       tekton-observer <command> [flags]")
+	fmt.Fprintln(out, "\nCommands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(out, "  %-10s %s\n", name, commands[name].description)
+	}
+}
+
+// parseNamespaces parses the --namespaces and --all-namespaces flags
+func parseNamespaces(namespaces string, allNamespaces bool) ([]string, error) {
+	switch {
+	case allNamespaces && namespaces != "":
+		return nil, errors.New("--namespaces and --all-namespaces cannot be used together")
+	case allNamespaces:
+		return []string{metav1.NamespaceAll}, nil
+	}
+	result := []string{}
+	for _, namespace := range strings.Split(namespaces, ",") {
+		if namespace = strings.TrimSpace(namespace); namespace != "" {
+			result = append(result, namespace)
+		}
+	}
+	if len(result) == 0 {
+		return nil, errors.New("--namespaces or --all-namespaces is required")
+	}
+	return result, nil
+}
@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/kcloutie/tekton-observer/internal/flakiness"
+	"github.com/kcloutie/tekton-observer/internal/tektonobserver"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type flakyOptions struct {
+	namespaces []string
+	pipeline   string
+	minRatio   float64
+}
+
+// runFlaky lists the flakiness of the tasks recorded by the controller, the flakiest first
+func runFlaky(ctx context.Context, env *Env, args []string) error {
+	fs := flag.NewFlagSet("flaky", flag.ContinueOnError)
+	fs.SetOutput(env.Stderr)
+	namespaces := fs.String("namespaces", "", "A comma separated list of the namespaces of the pipelines")
+	allNamespaces := fs.Bool("all-namespaces", false, "Select the pipelines of every namespace")
+	pipeline := fs.String("pipeline", "", "Only list the tasks of this pipeline")
+	minRatio := fs.Float64("min-ratio", 0, "Only list the tasks whose flakiness ratio is at least this ratio, between 0 and 1. The tasks that were never flaky are not listed.")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	opts := flakyOptions{pipeline: *pipeline, minRatio: *minRatio}
+	var err error
+	if opts.namespaces, err = parseNamespaces(*namespaces, *allNamespaces); err != nil {
+		return err
+	}
+	if opts.minRatio < 0 || opts.minRatio > 1 {
+		return fmt.Errorf("invalid --min-ratio %v, it must be between 0 and 1", opts.minRatio)
+	}
+
+	c, err := env.client()
+	if err != nil {
+		return err
+	}
+	return listFlakyTasks(ctx, c, env, opts)
+}
+
+func listFlakyTasks(ctx context.Context, c client.Client, env *Env, opts flakyOptions) error {
+	records := []flakiness.Record{}
+	for _, namespace := range opts.namespaces {
+		configMaps := &corev1.ConfigMapList{}
+		if err := c.List(ctx, configMaps, client.InNamespace(namespace), client.MatchingLabels{tektonobserver.StateConfigMapLabel: flakiness.State}); err != nil {
+			return fmt.Errorf("failed to list the flakiness histories - %w", err)
+		}
+		for i := range configMaps.Items {
+			namespaceRecords, err := flakiness.Records(&configMaps.Items[i])
+			if err != nil {
+				return err
+			}
+			for _, record := range namespaceRecords {
+				if record.Flaky() > 0 && record.Ratio() >= opts.minRatio && (opts.pipeline == "" || record.Pipeline == opts.pipeline) {
+					records = append(records, record)
+				}
+			}
+		}
+	}
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Ratio() > records[j].Ratio() })
+
+	w := tabwriter.NewWriter(env.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAMESPACE\tPIPELINE\tTASK\tFLAKINESS\tFLAKY\tRUNS")
+	for _, record := range records {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d%%\t%d\t%d\n", record.Namespace, record.Pipeline, record.Task, int(math.Round(record.Ratio()*100)), record.Flaky(), len(record.Outcomes))
+	}
+	return w.Flush()
+}
@@ -0,0 +1,101 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/kcloutie/tekton-observer/internal/flakiness"
+	"github.com/kcloutie/tekton-observer/internal/tektonobserver"
+	"github.com/kcloutie/tekton-observer/test/utils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestRun_flaky(t *testing.T) {
+	newHistory := func(namespace string, records ...flakiness.Record) *corev1.ConfigMap {
+		configMap := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: namespace,
+				Name:      flakiness.ConfigMapName,
+				Labels:    map[string]string{tektonobserver.StateConfigMapLabel: flakiness.State},
+			},
+			Data: map[string]string{},
+		}
+		for _, record := range records {
+			raw, err := json.Marshal(record)
+			if err != nil {
+				t.Fatal(err)
+			}
+			configMap.Data[flakiness.Key(record.Namespace, record.Pipeline, record.Task)] = string(raw)
+		}
+		return configMap
+	}
+	tests := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantOut    []string
+		notWantOut []string
+	}{
+		{
+			name:       "Test with a namespace",
+			args:       []string{"flaky", "--namespaces", "team-a"},
+			wantOut:    []string{"unit-tests  50%        2      4", "e2e-tests   25%        1      4"},
+			notWantOut: []string{"lint", "team-b"},
+		},
+		{
+			name:       "Test with a minimum ratio",
+			args:       []string{"flaky", "--all-namespaces", "--min-ratio", "0.3"},
+			wantOut:    []string{"unit-tests", "team-b"},
+			notWantOut: []string{"e2e-tests"},
+		},
+		{
+			name:       "Test with a pipeline",
+			args:       []string{"flaky", "--all-namespaces", "--pipeline", "deploy"},
+			wantOut:    []string{"team-b"},
+			notWantOut: []string{"unit-tests"},
+		},
+		{
+			name:     "Test with an invalid ratio",
+			args:     []string{"flaky", "--all-namespaces", "--min-ratio", "2"},
+			wantCode: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			fakeClient := utils.NewFakeClient(
+				newHistory("team-a",
+					flakiness.Record{Namespace: "team-a", Pipeline: "build", Task: "e2e-tests", Outcomes: "PPFR"},
+					flakiness.Record{Namespace: "team-a", Pipeline: "build", Task: "unit-tests", Outcomes: "RPRP"},
+					flakiness.Record{Namespace: "team-a", Pipeline: "build", Task: "lint", Outcomes: "PPPP"},
+				),
+				newHistory("team-b", flakiness.Record{Namespace: "team-b", Pipeline: "deploy", Task: "smoke", Outcomes: "RR"}),
+			)
+			stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+			env := &Env{
+				Stdout:    stdout,
+				Stderr:    stderr,
+				NewClient: func() (client.Client, error) { return fakeClient, nil },
+			}
+
+			if got := Run(ctx, env, tt.args); got != tt.wantCode {
+				t.Fatalf("Run() = %v, want %v, stderr: %s", got, tt.wantCode, stderr.String())
+			}
+			for _, want := range tt.wantOut {
+				if !strings.Contains(stdout.String(), want) {
+					t.Errorf("Run() output = %s, want %s", stdout.String(), want)
+				}
+			}
+			for _, notWant := range tt.notWantOut {
+				if strings.Contains(stdout.String(), notWant) {
+					t.Errorf("Run() output = %s, do not want %s", stdout.String(), notWant)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"github.com/kcloutie/tekton-observer/internal/onboarding"
+	tknv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type onboardOptions struct {
+	namespaces []string
+	policy     *obsv1.OnboardingPolicy
+	dryRun     bool
+}
+
+// runOnboard stamps the PipelineRuns that finished before now according to the onboarding policy. Ignored
+// PipelineRuns are marked as complete, backfilled PipelineRuns are flagged so the controller reports them at the
+// backfill rate of the TektonObservation.
+func runOnboard(ctx context.Context, env *Env, args []string) error {
+	fs := flag.NewFlagSet("onboard", flag.ContinueOnError)
+	fs.SetOutput(env.Stderr)
+	namespaces := fs.String("namespaces", "", "A comma separated list of the namespaces to onboard")
+	allNamespaces := fs.Bool("all-namespaces", false, "Onboard every namespace")
+	mode := fs.String("mode", string(obsv1.OnboardingIgnoreExisting), "The onboarding mode: ignoreExisting, backfill or sinceTimestamp")
+	since := fs.String("since", "", "The RFC3339 timestamp used by the sinceTimestamp mode")
+	dryRun := fs.Bool("dry-run", false, "Print the decisions without stamping the PipelineRuns")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	opts := onboardOptions{
+		policy: &obsv1.OnboardingPolicy{Mode: obsv1.OnboardingMode(*mode)},
+		dryRun: *dryRun,
+	}
+	if *since != "" {
+		sinceTime, err := time.Parse(time.RFC3339, *since)
+		if err != nil {
+			return fmt.Errorf("invalid --since - %w", err)
+		}
+		opts.policy.SinceTimestamp = &metav1.Time{Time: sinceTime}
+	}
+	if err := onboarding.Validate(opts.policy); err != nil {
+		return err
+	}
+	var err error
+	if opts.namespaces, err = parseNamespaces(*namespaces, *allNamespaces); err != nil {
+		return err
+	}
+
+	c, err := env.client()
+	if err != nil {
+		return err
+	}
+	return onboard(ctx, c, env, opts, time.Now())
+}
+
+func onboard(ctx context.Context, c client.Client, env *Env, opts onboardOptions, now time.Time) error {
+	counts := map[onboarding.Decision]int{}
+	for _, namespace := range opts.namespaces {
+		pipelineRuns := &tknv1.PipelineRunList{}
+		if err := c.List(ctx, pipelineRuns, client.InNamespace(namespace)); err != nil {
+			return fmt.Errorf("failed to list the PipelineRuns - %w", err)
+		}
+		for i := range pipelineRuns.Items {
+			pipelineRun := &pipelineRuns.Items[i]
+			decision := onboarding.Classify(opts.policy, pipelineRun, now)
+			counts[decision]++
+			if decision == onboarding.DecisionDeliver {
+				continue
+			}
+			fmt.Fprintf(env.Stdout, "%s/%s %s\n", pipelineRun.Namespace, pipelineRun.Name, decision)
+			if opts.dryRun {
+				continue
+			}
+			if err := onboarding.Stamp(ctx, c, pipelineRun, decision); err != nil {
+				return err
+			}
+		}
+	}
+	fmt.Fprintf(env.Stdout, "%d ignored, %d backfilled, %d left to the controller\n", counts[onboarding.DecisionIgnore], counts[onboarding.DecisionBackfill], counts[onboarding.DecisionDeliver])
+	return nil
+}
@@ -0,0 +1,108 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kcloutie/tekton-observer/internal/tektonobserver"
+	"github.com/kcloutie/tekton-observer/test/utils"
+	tknv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestRun_onboard(t *testing.T) {
+	finished := func(name string, completion time.Time) *tknv1.PipelineRun {
+		pr := utils.NewPipelineRun("team-a", name, nil, true)
+		pr.Status.CompletionTime = &metav1.Time{Time: completion}
+		return pr
+	}
+	tests := []struct {
+		name      string
+		args      []string
+		wantCode  int
+		wantOut   string
+		wantState map[string]string
+	}{
+		{
+			name:     "Test with ignoreExisting",
+			args:     []string{"onboard", "--namespaces", "team-a"},
+			wantOut:  "2 ignored, 0 backfilled, 1 left to the controller",
+			wantCode: 0,
+			wantState: map[string]string{
+				"old":     tektonobserver.ProcessingCompleteState,
+				"older":   tektonobserver.ProcessingCompleteState,
+				"running": "",
+			},
+		},
+		{
+			name:     "Test with sinceTimestamp",
+			args:     []string{"onboard", "--namespaces", "team-a", "--mode", "sinceTimestamp", "--since", time.Now().Add(-36 * time.Hour).Format(time.RFC3339)},
+			wantOut:  "1 ignored, 1 backfilled, 1 left to the controller",
+			wantCode: 0,
+			wantState: map[string]string{
+				"old":   "",
+				"older": tektonobserver.ProcessingCompleteState,
+			},
+		},
+		{
+			name:     "Test with dry run",
+			args:     []string{"onboard", "--all-namespaces", "--dry-run"},
+			wantOut:  "team-a/old ignore",
+			wantCode: 0,
+			wantState: map[string]string{
+				"old": "",
+			},
+		},
+		{
+			name:     "Test without namespaces",
+			args:     []string{"onboard"},
+			wantCode: 1,
+		},
+		{
+			name:     "Test with sinceTimestamp without timestamp",
+			args:     []string{"onboard", "--namespaces", "team-a", "--mode", "sinceTimestamp"},
+			wantCode: 1,
+		},
+		{
+			name:     "Test with unknown command",
+			args:     []string{"unknown"},
+			wantCode: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			fakeClient := utils.NewFakeClient(
+				finished("old", time.Now().Add(-24*time.Hour)),
+				finished("older", time.Now().Add(-48*time.Hour)),
+				utils.NewPipelineRun("team-a", "running", nil, false),
+			)
+			stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+			env := &Env{
+				Stdout:    stdout,
+				Stderr:    stderr,
+				NewClient: func() (client.Client, error) { return fakeClient, nil },
+			}
+
+			if got := Run(ctx, env, tt.args); got != tt.wantCode {
+				t.Fatalf("Run() = %v, want %v, stderr: %s", got, tt.wantCode, stderr.String())
+			}
+			if !strings.Contains(stdout.String(), tt.wantOut) {
+				t.Errorf("Run() output = %s, want %s", stdout.String(), tt.wantOut)
+			}
+			for name, want := range tt.wantState {
+				pr := &tknv1.PipelineRun{}
+				if err := fakeClient.Get(ctx, client.ObjectKey{Namespace: "team-a", Name: name}, pr); err != nil {
+					t.Fatal(err)
+				}
+				if got := pr.Annotations[tektonobserver.PipelineProcessingStateAnnotation]; got != want {
+					t.Errorf("Run() state of %s = %v, want %v", name, got, want)
+				}
+			}
+		})
+	}
+}
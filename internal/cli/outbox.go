@@ -0,0 +1,140 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type outboxOptions struct {
+	namespaces []string
+	state      obsv1.DeliveryState
+	names      []string
+}
+
+// runOutbox lists the deliveries of the outbox, or retries the dead-lettered ones
+func runOutbox(ctx context.Context, env *Env, args []string) error {
+	if len(args) == 0 || (args[0] != "list" && args[0] != "retry") {
+		return errors.New("usage: tekton-observer outbox list|retry [flags]")
+	}
+	action := args[0]
+
+	fs := flag.NewFlagSet("outbox "+action, flag.ContinueOnError)
+	fs.SetOutput(env.Stderr)
+	namespaces := fs.String("namespaces", "", "A comma separated list of the namespaces of the deliveries")
+	allNamespaces := fs.Bool("all-namespaces", false, "Select the deliveries of every namespace")
+	state := fs.String("state", "", "Only list the deliveries in this state: Pending or DeadLettered")
+	names := fs.String("names", "", "A comma separated list of the names of the deliveries to retry, every dead-lettered delivery is retried when it is not set")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	opts := outboxOptions{state: obsv1.DeliveryState(*state)}
+	var err error
+	if opts.namespaces, err = parseNamespaces(*namespaces, *allNamespaces); err != nil {
+		return err
+	}
+	if opts.state != "" && opts.state != obsv1.DeliveryPending && opts.state != obsv1.DeliveryDeadLettered {
+		return fmt.Errorf("invalid --state '%s', the supported states are %s and %s", opts.state, obsv1.DeliveryPending, obsv1.DeliveryDeadLettered)
+	}
+	for _, name := range strings.Split(*names, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			opts.names = append(opts.names, name)
+		}
+	}
+
+	c, err := env.client()
+	if err != nil {
+		return err
+	}
+	if action == "retry" {
+		return retryDeliveries(ctx, c, env, opts, time.Now())
+	}
+	return listDeliveries(ctx, c, env, opts)
+}
+
+func getDeliveries(ctx context.Context, c client.Client, namespaces []string) ([]obsv1.ObservationDelivery, error) {
+	result := []obsv1.ObservationDelivery{}
+	for _, namespace := range namespaces {
+		deliveries := &obsv1.ObservationDeliveryList{}
+		if err := c.List(ctx, deliveries, client.InNamespace(namespace)); err != nil {
+			return nil, fmt.Errorf("failed to list the deliveries - %w", err)
+		}
+		result = append(result, deliveries.Items...)
+	}
+	return result, nil
+}
+
+func deliveryState(outboxDelivery *obsv1.ObservationDelivery) obsv1.DeliveryState {
+	if outboxDelivery.Status.State == "" {
+		return obsv1.DeliveryPending
+	}
+	return outboxDelivery.Status.State
+}
+
+func listDeliveries(ctx context.Context, c client.Client, env *Env, opts outboxOptions) error {
+	deliveries, err := getDeliveries(ctx, c, opts.namespaces)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(env.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "NAMESPACE\tNAME\tPIPELINERUN\tSINK\tPHASE\tSTATE\tATTEMPTS\tLAST ERROR")
+	for i := range deliveries {
+		outboxDelivery := &deliveries[i]
+		state := deliveryState(outboxDelivery)
+		if opts.state != "" && state != opts.state {
+			continue
+		}
+		phase := string(outboxDelivery.Spec.Phase)
+		if outboxDelivery.Spec.TaskName != "" {
+			phase = fmt.Sprintf("%s/%s", phase, outboxDelivery.Spec.TaskName)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%d\t%s\n", outboxDelivery.Namespace, outboxDelivery.Name, outboxDelivery.Spec.PipelineRun.Name,
+			outboxDelivery.Spec.Sink, phase, state, outboxDelivery.Status.Attempts, outboxDelivery.Status.LastError)
+	}
+	return w.Flush()
+}
+
+// retryDeliveries moves the dead-lettered deliveries back to pending, the controller attempts them once more
+func retryDeliveries(ctx context.Context, c client.Client, env *Env, opts outboxOptions, now time.Time) error {
+	deliveries, err := getDeliveries(ctx, c, opts.namespaces)
+	if err != nil {
+		return err
+	}
+	retried := 0
+	for i := range deliveries {
+		outboxDelivery := &deliveries[i]
+		if deliveryState(outboxDelivery) != obsv1.DeliveryDeadLettered {
+			continue
+		}
+		if len(opts.names) > 0 && !contains(opts.names, outboxDelivery.Name) {
+			continue
+		}
+		outboxDelivery.Status.State = obsv1.DeliveryPending
+		outboxDelivery.Status.NextAttemptTime = &metav1.Time{Time: now}
+		if err := c.Status().Update(ctx, outboxDelivery); err != nil {
+			return fmt.Errorf("failed to retry the delivery '%s' - %w", outboxDelivery.Name, err)
+		}
+		retried++
+		fmt.Fprintf(env.Stdout, "%s/%s\n", outboxDelivery.Namespace, outboxDelivery.Name)
+	}
+	fmt.Fprintf(env.Stdout, "%d deliveries retried\n", retried)
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"github.com/kcloutie/tekton-observer/test/utils"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestRun_outbox(t *testing.T) {
+	newDelivery := func(name string, state obsv1.DeliveryState) *obsv1.ObservationDelivery {
+		return &obsv1.ObservationDelivery{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: name},
+			Spec: obsv1.ObservationDeliverySpec{
+				PipelineRun: obsv1.PipelineRunReference{Name: "build-1"},
+				Sink:        "pubsub/project/topic",
+				Phase:       obsv1.PhaseFinished,
+			},
+			Status: obsv1.ObservationDeliveryStatus{State: state, Attempts: 6, LastError: "boom"},
+		}
+	}
+	tests := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantOut    []string
+		notWantOut []string
+		wantState  map[string]obsv1.DeliveryState
+	}{
+		{
+			name:     "Test with list",
+			args:     []string{"outbox", "list", "--namespaces", "team-a"},
+			wantCode: 0,
+			wantOut:  []string{"delivery-1", "delivery-2", "DeadLettered", "pubsub/project/topic"},
+		},
+		{
+			name:       "Test with list by state",
+			args:       []string{"outbox", "list", "--all-namespaces", "--state", "Pending"},
+			wantCode:   0,
+			wantOut:    []string{"delivery-1"},
+			notWantOut: []string{"delivery-2"},
+		},
+		{
+			name:     "Test with retry",
+			args:     []string{"outbox", "retry", "--namespaces", "team-a"},
+			wantCode: 0,
+			wantOut:  []string{"1 deliveries retried"},
+			wantState: map[string]obsv1.DeliveryState{
+				"delivery-2": obsv1.DeliveryPending,
+			},
+		},
+		{
+			name:     "Test with an unknown state",
+			args:     []string{"outbox", "list", "--namespaces", "team-a", "--state", "Done"},
+			wantCode: 1,
+		},
+		{
+			name:     "Test without action",
+			args:     []string{"outbox"},
+			wantCode: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			fakeClient := utils.NewFakeClient(newDelivery("delivery-1", obsv1.DeliveryPending), newDelivery("delivery-2", obsv1.DeliveryDeadLettered))
+			stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+			env := &Env{
+				Stdout:    stdout,
+				Stderr:    stderr,
+				NewClient: func() (client.Client, error) { return fakeClient, nil },
+			}
+
+			if got := Run(ctx, env, tt.args); got != tt.wantCode {
+				t.Fatalf("Run() = %v, want %v, stderr: %s", got, tt.wantCode, stderr.String())
+			}
+			for _, want := range tt.wantOut {
+				if !strings.Contains(stdout.String(), want) {
+					t.Errorf("Run() output = %s, want %s", stdout.String(), want)
+				}
+			}
+			for _, notWant := range tt.notWantOut {
+				if strings.Contains(stdout.String(), notWant) {
+					t.Errorf("Run() output = %s, do not want %s", stdout.String(), notWant)
+				}
+			}
+			for name, want := range tt.wantState {
+				outboxDelivery := &obsv1.ObservationDelivery{}
+				if err := fakeClient.Get(ctx, client.ObjectKey{Namespace: "team-a", Name: name}, outboxDelivery); err != nil {
+					t.Fatal(err)
+				}
+				if outboxDelivery.Status.State != want {
+					t.Errorf("Run() state of %s = %v, want %v", name, outboxDelivery.Status.State, want)
+				}
+			}
+		})
+	}
+}
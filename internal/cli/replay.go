@@ -0,0 +1,108 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kcloutie/tekton-observer/internal/replay"
+	"github.com/kcloutie/tekton-observer/internal/tektonobserver"
+	tknv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type replayOptions struct {
+	namespaces []string
+	selection  replay.Selection
+	sink       string
+	key        string
+	dryRun     bool
+}
+
+// runReplay requests the controller to deliver the selected PipelineRuns again
+func runReplay(ctx context.Context, env *Env, args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	fs.SetOutput(env.Stderr)
+	namespaces := fs.String("namespaces", "", "A comma separated list of the namespaces of the PipelineRuns")
+	allNamespaces := fs.Bool("all-namespaces", false, "Select the PipelineRuns of every namespace")
+	selector := fs.String("selector", "", "The label selector of the PipelineRuns")
+	names := fs.String("names", "", "A comma separated list of the names of the PipelineRuns")
+	since := fs.String("since", "", "Only select the PipelineRuns completed after this RFC3339 timestamp")
+	until := fs.String("until", "", "Only select the PipelineRuns completed before this RFC3339 timestamp")
+	sink := fs.String("sink", "", "Only replay to the named sink, for example pubsub/<project>/<topic>")
+	key := fs.String("key", "", "The idempotency key of the replay, generated when it is not set")
+	dryRun := fs.Bool("dry-run", false, "Print the selected PipelineRuns without requesting the replay")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	opts := replayOptions{sink: *sink, key: *key, dryRun: *dryRun}
+	var err error
+	if opts.namespaces, err = parseNamespaces(*namespaces, *allNamespaces); err != nil {
+		return err
+	}
+	if opts.selection.Selector, err = labels.Parse(*selector); err != nil {
+		return fmt.Errorf("invalid --selector - %w", err)
+	}
+	for _, name := range strings.Split(*names, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			opts.selection.Names = append(opts.selection.Names, name)
+		}
+	}
+	if opts.selection.Since, err = parseTime(*since); err != nil {
+		return fmt.Errorf("invalid --since - %w", err)
+	}
+	if opts.selection.Until, err = parseTime(*until); err != nil {
+		return fmt.Errorf("invalid --until - %w", err)
+	}
+	if opts.key == "" {
+		opts.key = fmt.Sprintf("replay-%d", time.Now().Unix())
+	}
+
+	c, err := env.client()
+	if err != nil {
+		return err
+	}
+	return requestReplay(ctx, c, env, opts)
+}
+
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+func requestReplay(ctx context.Context, c client.Client, env *Env, opts replayOptions) error {
+	requested, pending := 0, 0
+	for _, namespace := range opts.namespaces {
+		pipelineRuns := &tknv1.PipelineRunList{}
+		if err := c.List(ctx, pipelineRuns, client.InNamespace(namespace), client.MatchingLabelsSelector{Selector: opts.selection.Selector}); err != nil {
+			return fmt.Errorf("failed to list the PipelineRuns - %w", err)
+		}
+		for i := range pipelineRuns.Items {
+			pipelineRun := &pipelineRuns.Items[i]
+			if !opts.selection.Matches(pipelineRun) {
+				continue
+			}
+			// The PipelineRuns not delivered yet are delivered by the regular processing
+			if pipelineRun.Annotations[tektonobserver.PipelineProcessingStateAnnotation] != tektonobserver.ProcessingCompleteState {
+				pending++
+				continue
+			}
+			requested++
+			fmt.Fprintf(env.Stdout, "%s/%s\n", pipelineRun.Namespace, pipelineRun.Name)
+			if opts.dryRun {
+				continue
+			}
+			if err := replay.Request(ctx, c, pipelineRun, opts.key, opts.sink); err != nil {
+				return err
+			}
+		}
+	}
+	fmt.Fprintf(env.Stdout, "%d replays requested with the key '%s', %d not delivered yet\n", requested, opts.key, pending)
+	return nil
+}
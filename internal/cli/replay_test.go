@@ -0,0 +1,92 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/kcloutie/tekton-observer/internal/tektonobserver"
+	"github.com/kcloutie/tekton-observer/test/utils"
+	tknv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestRun_replay(t *testing.T) {
+	complete := map[string]string{tektonobserver.PipelineProcessingStateAnnotation: tektonobserver.ProcessingCompleteState}
+	tests := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantOut    string
+		wantReplay map[string]string
+	}{
+		{
+			name:     "Test with a namespace",
+			args:     []string{"replay", "--namespaces", "team-a", "--key", "outage-42"},
+			wantOut:  "2 replays requested with the key 'outage-42', 1 not delivered yet",
+			wantCode: 0,
+			wantReplay: map[string]string{
+				"build-1": "outage-42",
+				"build-2": "outage-42",
+				"build-3": "",
+			},
+		},
+		{
+			name:     "Test with names",
+			args:     []string{"replay", "--all-namespaces", "--names", "build-2", "--key", "outage-42"},
+			wantOut:  "1 replays requested",
+			wantCode: 0,
+			wantReplay: map[string]string{
+				"build-1": "",
+				"build-2": "outage-42",
+			},
+		},
+		{
+			name:     "Test with dry run",
+			args:     []string{"replay", "--namespaces", "team-a", "--dry-run"},
+			wantOut:  "team-a/build-1",
+			wantCode: 0,
+			wantReplay: map[string]string{
+				"build-1": "",
+			},
+		},
+		{
+			name:     "Test with an invalid time window",
+			args:     []string{"replay", "--namespaces", "team-a", "--since", "yesterday"},
+			wantCode: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			fakeClient := utils.NewFakeClient(
+				utils.NewPipelineRun("team-a", "build-1", complete, true),
+				utils.NewPipelineRun("team-a", "build-2", complete, true),
+				utils.NewPipelineRun("team-a", "build-3", nil, true),
+			)
+			stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+			env := &Env{
+				Stdout:    stdout,
+				Stderr:    stderr,
+				NewClient: func() (client.Client, error) { return fakeClient, nil },
+			}
+
+			if got := Run(ctx, env, tt.args); got != tt.wantCode {
+				t.Fatalf("Run() = %v, want %v, stderr: %s", got, tt.wantCode, stderr.String())
+			}
+			if !strings.Contains(stdout.String(), tt.wantOut) {
+				t.Errorf("Run() output = %s, want %s", stdout.String(), tt.wantOut)
+			}
+			for name, want := range tt.wantReplay {
+				pr := &tknv1.PipelineRun{}
+				if err := fakeClient.Get(ctx, client.ObjectKey{Namespace: "team-a", Name: name}, pr); err != nil {
+					t.Fatal(err)
+				}
+				if got := pr.Annotations[tektonobserver.ReplayAnnotation]; got != want {
+					t.Errorf("Run() replay of %s = %v, want %v", name, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package controller
+
+import (
+	"fmt"
+
+	"github.com/kcloutie/tekton-observer/internal/tektonobserver"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline"
+	tknv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const lastAppliedConfigAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+// StripManagedFields is a cache transform removing the managed fields of the cached objects
+func StripManagedFields(obj interface{}) (interface{}, error) {
+	if o, ok := obj.(client.Object); ok {
+		o.SetManagedFields(nil)
+	}
+	return obj, nil
+}
+
+// TrimPipelineRun is a cache transform removing the managed fields and the large fields of the cached PipelineRuns.
+// The fields are only needed once the PipelineRun is processed, the full PipelineRun is then read from the API server.
+func TrimPipelineRun(obj interface{}) (interface{}, error) {
+	// Deleted objects are wrapped in a tombstone which does not need trimming
+	pipelineRun, ok := obj.(*tknv1.PipelineRun)
+	if !ok {
+		return obj, nil
+	}
+	pipelineRun.ManagedFields = nil
+	delete(pipelineRun.Annotations, lastAppliedConfigAnnotation)
+	pipelineRun.Spec.PipelineSpec = nil
+	pipelineRun.Status.PipelineSpec = nil
+	pipelineRun.Status.Provenance = nil
+	return pipelineRun, nil
+}
+
+// TrimTaskRun is a cache transform keeping only the fields of the TaskRuns read by the progress events and the
+// TaskRun watch: the metadata without the annotations and the managed fields, and the conditions. The other readers of
+// the TaskRuns read them from the API server.
+func TrimTaskRun(obj interface{}) (interface{}, error) {
+	// Deleted objects are wrapped in a tombstone which does not need trimming
+	taskRun, ok := obj.(*tknv1.TaskRun)
+	if !ok {
+		return obj, nil
+	}
+	trimmed := &tknv1.TaskRun{TypeMeta: taskRun.TypeMeta, ObjectMeta: taskRun.ObjectMeta}
+	trimmed.ManagedFields = nil
+	trimmed.Annotations = nil
+	trimmed.Status.Conditions = taskRun.Status.Conditions
+	trimmed.Status.ObservedGeneration = taskRun.Status.ObservedGeneration
+	return trimmed, nil
+}
+
+// TaskRunCacheOptions returns the cache options of the TaskRuns, only the TaskRuns of a PipelineRun are cached and
+// they are trimmed by TrimTaskRun
+func TaskRunCacheOptions() cache.ByObject {
+	requirement, err := labels.NewRequirement(pipeline.PipelineRunLabelKey, selection.Exists, nil)
+	if err != nil {
+		panic(fmt.Sprintf("invalid TaskRun cache selector - %v", err))
+	}
+	return cache.ByObject{Label: labels.NewSelector().Add(*requirement), Transform: TrimTaskRun}
+}
+
+// PipelineRunCacheSelector selects the PipelineRuns that have not been processed yet, PipelineRuns without the
+// processing state label are selected too
+func PipelineRunCacheSelector() labels.Selector {
+	requirement, err := labels.NewRequirement(tektonobserver.PipelineProcessingStateLabel, selection.NotEquals, []string{tektonobserver.ProcessingCompleteState})
+	if err != nil {
+		panic(fmt.Sprintf("invalid PipelineRun cache selector - %v", err))
+	}
+	return labels.NewSelector().Add(*requirement)
+}
+
+// PipelineRunCacheOptions returns the cache options of the PipelineRuns. When trim is set the large fields are
+// removed from the cached PipelineRuns, when excludeComplete is set the processed PipelineRuns are not cached.
+func PipelineRunCacheOptions(trim, excludeComplete bool) cache.ByObject {
+	byObject := cache.ByObject{}
+	if trim {
+		byObject.Transform = TrimPipelineRun
+	} else {
+		byObject.Transform = StripManagedFields
+	}
+	if excludeComplete {
+		byObject.Label = PipelineRunCacheSelector()
+	}
+	return byObject
+}
+
+// StateConfigMapCacheOptions returns the cache options of the ConfigMaps, only the ConfigMaps holding the state of
+// the controller are cached
+func StateConfigMapCacheOptions() cache.ByObject {
+	requirement, err := labels.NewRequirement(tektonobserver.StateConfigMapLabel, selection.Exists, nil)
+	if err != nil {
+		panic(fmt.Sprintf("invalid ConfigMap cache selector - %v", err))
+	}
+	return cache.ByObject{Label: labels.NewSelector().Add(*requirement)}
+}
+
+var _ toolscache.TransformFunc = TrimPipelineRun
+var _ toolscache.TransformFunc = TrimTaskRun
+var _ toolscache.TransformFunc = StripManagedFields
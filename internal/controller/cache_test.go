@@ -0,0 +1,142 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/kcloutie/tekton-observer/internal/tektonobserver"
+	"github.com/kcloutie/tekton-observer/test/utils"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline"
+	tknv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	toolscache "k8s.io/client-go/tools/cache"
+	"k8s.io/utils/ptr"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+)
+
+func TestTrimPipelineRun(t *testing.T) {
+	pipelineRun := utils.NewPipelineRun("test-namespace", "test-name", map[string]string{
+		lastAppliedConfigAnnotation: "{}",
+	}, true)
+	pipelineRun.ManagedFields = []metav1.ManagedFieldsEntry{{Manager: "kubectl"}}
+	pipelineRun.Spec.PipelineSpec = &tknv1.PipelineSpec{Description: "spec"}
+	pipelineRun.Status.PipelineSpec = &tknv1.PipelineSpec{Description: "status"}
+	pipelineRun.Status.Provenance = &tknv1.Provenance{}
+
+	got, err := TrimPipelineRun(pipelineRun)
+	if err != nil {
+		t.Fatalf("TrimPipelineRun() error = %v", err)
+	}
+	trimmed := got.(*tknv1.PipelineRun)
+	if trimmed.ManagedFields != nil || trimmed.Spec.PipelineSpec != nil || trimmed.Status.PipelineSpec != nil || trimmed.Status.Provenance != nil {
+		t.Errorf("TrimPipelineRun() did not remove the large fields")
+	}
+	if _, found := trimmed.Annotations[lastAppliedConfigAnnotation]; found {
+		t.Errorf("TrimPipelineRun() did not remove the %s annotation", lastAppliedConfigAnnotation)
+	}
+	if !trimmed.IsDone() || trimmed.Annotations["tekton.dev/pipeline"] != "test-pipeline" {
+		t.Errorf("TrimPipelineRun() removed fields used by the watcher")
+	}
+
+	tombstone := toolscache.DeletedFinalStateUnknown{Key: "test-namespace/test-name"}
+	if got, err := TrimPipelineRun(tombstone); err != nil || got != tombstone {
+		t.Errorf("TrimPipelineRun() = %v, %v, want the tombstone unchanged", got, err)
+	}
+}
+
+func TestTrimTaskRun(t *testing.T) {
+	taskRun := &tknv1.TaskRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       "test-namespace",
+			Name:            "test-name-build",
+			Labels:          map[string]string{pipeline.PipelineRunLabelKey: "test-name", pipeline.PipelineTaskLabelKey: "build"},
+			Annotations:     map[string]string{lastAppliedConfigAnnotation: "{}"},
+			OwnerReferences: []metav1.OwnerReference{{Kind: "PipelineRun", Name: "test-name", Controller: ptr.To(true)}},
+			ManagedFields:   []metav1.ManagedFieldsEntry{{Manager: "kubectl"}},
+		},
+		Spec: tknv1.TaskRunSpec{TaskSpec: &tknv1.TaskSpec{Description: "spec"}},
+		Status: tknv1.TaskRunStatus{
+			Status: duckv1.Status{Conditions: duckv1.Conditions{{Type: apis.ConditionSucceeded, Status: corev1.ConditionTrue}}},
+			TaskRunStatusFields: tknv1.TaskRunStatusFields{
+				TaskSpec: &tknv1.TaskSpec{Description: "status"},
+				Steps:    []tknv1.StepState{{Name: "step"}},
+			},
+		},
+	}
+
+	got, err := TrimTaskRun(taskRun)
+	if err != nil {
+		t.Fatalf("TrimTaskRun() error = %v", err)
+	}
+	trimmed := got.(*tknv1.TaskRun)
+	if trimmed.ManagedFields != nil || trimmed.Annotations != nil || trimmed.Spec.TaskSpec != nil || trimmed.Status.TaskSpec != nil || trimmed.Status.Steps != nil {
+		t.Errorf("TrimTaskRun() = %+v, want only the metadata and the conditions", trimmed)
+	}
+	if !trimmed.IsDone() || trimmed.Labels[pipeline.PipelineTaskLabelKey] != "build" || metav1.GetControllerOf(trimmed) == nil {
+		t.Errorf("TrimTaskRun() removed fields used by the progress events")
+	}
+
+	tombstone := toolscache.DeletedFinalStateUnknown{Key: "test-namespace/test-name-build"}
+	if got, err := TrimTaskRun(tombstone); err != nil || got != tombstone {
+		t.Errorf("TrimTaskRun() = %v, %v, want the tombstone unchanged", got, err)
+	}
+}
+
+func TestTaskRunCacheOptions(t *testing.T) {
+	tests := []struct {
+		name   string
+		labels map[string]string
+		want   bool
+	}{
+		{
+			name:   "Test with the TaskRun of a PipelineRun",
+			labels: map[string]string{pipeline.PipelineRunLabelKey: "test-name"},
+			want:   true,
+		},
+		{
+			name:   "Test with a standalone TaskRun",
+			labels: map[string]string{},
+			want:   false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TaskRunCacheOptions().Label.Matches(labels.Set(tt.labels)); got != tt.want {
+				t.Errorf("TaskRunCacheOptions().Label.Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPipelineRunCacheSelector(t *testing.T) {
+	tests := []struct {
+		name   string
+		labels map[string]string
+		want   bool
+	}{
+		{
+			name:   "Test without the processing state label",
+			labels: map[string]string{},
+			want:   true,
+		},
+		{
+			name:   "Test with a started PipelineRun",
+			labels: map[string]string{tektonobserver.PipelineProcessingStateLabel: tektonobserver.ProcessingStartState},
+			want:   true,
+		},
+		{
+			name:   "Test with a complete PipelineRun",
+			labels: map[string]string{tektonobserver.PipelineProcessingStateLabel: tektonobserver.ProcessingCompleteState},
+			want:   false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PipelineRunCacheSelector().Matches(labels.Set(tt.labels)); got != tt.want {
+				t.Errorf("PipelineRunCacheSelector().Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
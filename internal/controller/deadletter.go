@@ -0,0 +1,134 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"github.com/kcloutie/tekton-observer/internal/deadletter"
+	"github.com/kcloutie/tekton-observer/pkg/delivery"
+	"github.com/kcloutie/tekton-observer/pkg/metrics"
+	"github.com/kcloutie/tekton-observer/pkg/sinks"
+	"github.com/kcloutie/tekton-observer/pkg/tekton"
+	"go.uber.org/zap/zapcore"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// sinkError is the error of a failed delivery to a sink
+type sinkError struct {
+	sink string
+	err  error
+}
+
+func (e *sinkError) Error() string {
+	return e.err.Error()
+}
+
+func (e *sinkError) Unwrap() error {
+	return e.err
+}
+
+// failedSinks returns the errors of the sinks the delivery failed for by sink name
+func failedSinks(err error) map[string]error {
+	result := map[string]error{}
+	var walk func(err error)
+	walk = func(err error) {
+		if e, ok := err.(*sinkError); ok {
+			result[e.sink] = e.err
+			return
+		}
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			for _, e := range joined.Unwrap() {
+				walk(e)
+			}
+		}
+	}
+	walk(err)
+	return result
+}
+
+// deadLetterDelivery sends a delivery that exhausted its retries to the dead-letter destination of the observation.
+// Once sent, the delivery is recorded in the status of the observation and a Warning event is raised on it.
+func (r *TektonObservationReconciler) deadLetterDelivery(ctx context.Context, log logr.Logger, observation *obsv1.TektonObservation, letter deadletter.Letter) error {
+	sender := &deadletter.Sender{Client: r.Client, Publisher: r.pubSubPublisher(), Log: log.WithName("deadletter")}
+	destinations, err := sender.Send(ctx, observation.Spec.DeadLetter, letter)
+	if err != nil {
+		return err
+	}
+	metrics.DeliveriesDeadLetteredTotal.WithLabelValues(letter.Sink).Inc()
+
+	mess := fmt.Sprintf("The %s phase of the PipelineRun '%s' could not be delivered to the sink '%s' and was dead-lettered to %v. %s",
+		letter.Phase, letter.PipelineRun, letter.Sink, destinations, letter.Reason)
+	r.EventEmitter.EmitMessage(ctx, observation, zapcore.WarnLevel, "DeadLetter", mess)
+
+	record := &obsv1.DeadLetterRecord{
+		PipelineRun:  letter.PipelineRun,
+		Sink:         letter.Sink,
+		Phase:        letter.Phase,
+		DeliveryID:   letter.DeliveryID,
+		Reason:       letter.Reason,
+		Destinations: destinations,
+		Time:         letter.Time,
+	}
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		latest := &obsv1.TektonObservation{}
+		if err := r.Get(ctx, client.ObjectKeyFromObject(observation), latest); err != nil {
+			return err
+		}
+		latest.Status.DeadLetteredDeliveries++
+		latest.Status.LastDeadLetter = record
+		return r.Status().Update(ctx, latest)
+	})
+	if err != nil {
+		// The delivery was dead-lettered, only its record in the status is missing
+		log.Error(err, "Failed to record the dead-lettered delivery in the status of the TektonObservation")
+	}
+	return nil
+}
+
+// deadLetterFailedSinks dead-letters the finished phase of the PipelineRun for every sink it could not be delivered
+// to before the release deadline
+func (r *TektonObservationReconciler) deadLetterFailedSinks(ctx context.Context, log logr.Logger, observation *obsv1.TektonObservation, data *tekton.PipelineRunData, deliverErr error) error {
+	if data == nil {
+		return nil
+	}
+	event := sinks.Event{Phase: obsv1.PhaseFinished, Data: data}
+	var errs []error
+	for sink, sinkErr := range failedSinks(deliverErr) {
+		event.Delivery = delivery.Metadata{ID: delivery.ID(getPipelineRunUID(data), deliveryKey(event), sink)}
+		letter, err := newLetter(event, sink, sinkErr)
+		if err != nil {
+			return err
+		}
+		errs = append(errs, r.deadLetterDelivery(ctx, log, observation, letter))
+	}
+	return errors.Join(errs...)
+}
+
+// newLetter returns the dead letter of the delivery of the event to the sink, Delivery.Attempt is the number of
+// failed attempts
+func newLetter(event sinks.Event, sink string, reason error) (deadletter.Letter, error) {
+	payload, err := json.Marshal(event.Data)
+	if err != nil {
+		return deadletter.Letter{}, fmt.Errorf("failed to marshal the PipelineRun data - %w", err)
+	}
+	return deadletter.Letter{
+		Namespace:      event.Data.Namespace,
+		PipelineRun:    event.Data.PipelineRunName,
+		PipelineRunUID: getPipelineRunUID(event.Data),
+		Sink:           sink,
+		Phase:          event.Phase,
+		TaskName:       event.TaskName,
+		DeliveryID:     event.Delivery.ID,
+		Attempts:       event.Delivery.Attempt,
+		Reason:         reason.Error(),
+		Time:           metav1.Time{Time: time.Now()},
+		Payload:        payload,
+	}, nil
+}
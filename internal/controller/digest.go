@@ -0,0 +1,147 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"github.com/kcloutie/tekton-observer/internal/digest"
+	"github.com/kcloutie/tekton-observer/internal/tektonobserver"
+	"github.com/kcloutie/tekton-observer/pkg/delivery"
+	"github.com/kcloutie/tekton-observer/pkg/metrics"
+	"github.com/kcloutie/tekton-observer/pkg/sinks"
+	"github.com/kcloutie/tekton-observer/pkg/tekton"
+	"github.com/kcloutie/tekton-observer/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;update;delete
+
+// deliverToSink delivers the event to the sink, the finished PipelineRuns are buffered instead when the sink is in
+// digest mode. Replays are always delivered one by one.
+func (r *TektonObservationReconciler) deliverToSink(ctx context.Context, log logr.Logger, observation *obsv1.TektonObservation, sink sinks.Sink, event sinks.Event) (ref string, err error) {
+	ctx, span := tracing.Start(ctx, "deliver to sink",
+		attribute.String("sink.name", sink.Name()),
+		attribute.String("sink.type", string(sink.Type())),
+		attribute.String("tekton.phase", string(event.Phase)),
+		attribute.String("delivery.id", event.Delivery.ID),
+		attribute.Int("delivery.attempt", event.Delivery.Attempt),
+	)
+	if event.Data != nil {
+		span.SetAttributes(attribute.String("tekton.pipelinerun.name", event.Data.PipelineRunName))
+	}
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	if policy := sink.Digest(); policy != nil && event.Phase == obsv1.PhaseFinished && event.ReplayKey == "" && event.Digest == nil {
+		span.SetAttributes(attribute.Bool("digest.buffered", true))
+		return "", r.bufferDigest(ctx, log, observation, sink, policy, event.Data)
+	}
+	return sink.Deliver(ctx, event)
+}
+
+// bufferDigest adds the PipelineRun to the digest of its group, the digest is sent right away when it is full
+func (r *TektonObservationReconciler) bufferDigest(ctx context.Context, log logr.Logger, observation *obsv1.TektonObservation, sink sinks.Sink, policy *obsv1.DigestPolicy, data *tekton.PipelineRunData) error {
+	now := time.Now()
+	configMap, err := digest.Add(ctx, r.Client, observation, sink.Name(), policy, digest.NewRun(data), now)
+	if err != nil {
+		return err
+	}
+	metrics.DigestBufferedTotal.WithLabelValues(sink.Name()).Inc()
+	log.V(2).Info("PipelineRun buffered in the digest", "sink", sink.Name(), "digest", configMap.Name)
+
+	due, _, err := digest.Due(configMap, policy, now)
+	if err != nil || !due {
+		return err
+	}
+	// The PipelineRun is buffered, a digest that cannot be sent now is sent by the reconcile of the observation
+	if err := r.flushDigest(ctx, log, sink, policy, configMap, now); err != nil {
+		log.Error(err, "Failed to send the full digest, retrying later", "sink", sink.Name(), "digest", configMap.Name)
+	}
+	return nil
+}
+
+// flushDigests sends the digests of the observation that are due. The digests of the sinks that are no longer in
+// digest mode are sent right away, and the digests of the removed sinks are dropped. The time left before the next
+// digest is due is returned, it is 0 when no digest is buffered. With sharding, only the replica owning the namespace
+// flushes its digests.
+func (r *TektonObservationReconciler) flushDigests(ctx context.Context, log logr.Logger, observation *obsv1.TektonObservation) (time.Duration, error) {
+	if !r.ownsNamespace(observation.Namespace) {
+		log.V(2).Info("The digests of the namespace are flushed by another shard...skipping")
+		return 0, nil
+	}
+	configMaps := &corev1.ConfigMapList{}
+	if err := r.List(ctx, configMaps, client.InNamespace(observation.Namespace), client.MatchingLabels{tektonobserver.StateConfigMapLabel: digest.State}); err != nil {
+		return 0, fmt.Errorf("failed to list the digests - %w", err)
+	}
+
+	sinkList := r.getSinks(observation)
+	now := time.Now()
+	var next time.Duration
+	var errs []error
+	for i := range configMaps.Items {
+		configMap := &configMaps.Items[i]
+		matching := filterSinks(sinkList, configMap.Annotations[digest.SinkAnnotation])
+		if len(matching) == 0 {
+			log.Info("Dropping the digest of a removed sink", "sink", configMap.Annotations[digest.SinkAnnotation], "digest", configMap.Name)
+			if err := r.Delete(ctx, configMap); client.IgnoreNotFound(err) != nil {
+				errs = append(errs, fmt.Errorf("failed to delete the digest '%s' - %w", configMap.Name, err))
+			}
+			continue
+		}
+		sink := matching[0]
+		policy := sink.Digest()
+		due, left, err := digest.Due(configMap, policy, now)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !due && policy != nil {
+			if next == 0 || left < next {
+				next = left
+			}
+			continue
+		}
+		if err := r.flushDigest(ctx, log, sink, policy, configMap, now); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return next, errors.Join(errs...)
+}
+
+// flushDigest delivers the summary of the digest to its sink and removes the delivered PipelineRuns from the digest
+func (r *TektonObservationReconciler) flushDigest(ctx context.Context, log logr.Logger, sink sinks.Sink, policy *obsv1.DigestPolicy, configMap *corev1.ConfigMap, now time.Time) error {
+	summary, err := digest.Summarize(configMap, policy, now)
+	if err != nil {
+		return err
+	}
+	summary.Text, err = digest.Render(policy, summary)
+	if err != nil {
+		log.Error(err, "Failed to render the digest template, using the default template", "sink", sink.Name(), "digest", configMap.Name)
+		if summary.Text, err = digest.Render(nil, summary); err != nil {
+			return err
+		}
+	}
+
+	event := sinks.Event{
+		Phase:  obsv1.PhaseFinished,
+		Digest: &summary,
+		Delivery: delivery.Metadata{
+			ID:      delivery.ID(string(configMap.UID), "digest/"+configMap.Annotations[digest.WindowStartAnnotation], sink.Name()),
+			Attempt: 1,
+		},
+	}
+	_, err = sink.Deliver(ctx, event)
+	metrics.DigestsSentTotal.WithLabelValues(sink.Name(), fmt.Sprintf("%v", err == nil)).Inc()
+	if err != nil {
+		return fmt.Errorf("failed to deliver the digest '%s' to the sink '%s' - %w", configMap.Name, sink.Name(), err)
+	}
+	log.V(1).Info("Digest delivered", "sink", sink.Name(), "digest", configMap.Name, "group", summary.Group, "total", summary.Total, "failed", summary.Failed)
+	return digest.Remove(ctx, r.Client, configMap, summary.Runs, now)
+}
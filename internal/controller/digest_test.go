@@ -0,0 +1,189 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-logr/zapr"
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"github.com/kcloutie/tekton-observer/internal/digest"
+	"github.com/kcloutie/tekton-observer/internal/tektonobserver"
+	"github.com/kcloutie/tekton-observer/pkg/delivery"
+	"github.com/kcloutie/tekton-observer/pkg/sinks"
+	"github.com/kcloutie/tekton-observer/pkg/tekton"
+	"github.com/kcloutie/tekton-observer/test/utils"
+	"go.uber.org/zap/zaptest"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestTektonObservationReconciler_deliver_digest(t *testing.T) {
+	tests := []struct {
+		name           string
+		policy         *obsv1.DigestPolicy
+		runs           []string
+		removeSink     bool
+		wantDigests    []string
+		wantBuffered   int
+		wantRequeueMin time.Duration
+	}{
+		{
+			name:        "Test with a full digest",
+			policy:      &obsv1.DigestPolicy{MaxRuns: 3},
+			runs:        []string{tekton.StatusFailed, tekton.StatusSucceeded, tekton.StatusSucceeded},
+			wantDigests: []string{"1 failed, 2 succeeded\n- test-namespace/run-0 (Failed)"},
+		},
+		{
+			name:           "Test with a window that did not elapse",
+			policy:         &obsv1.DigestPolicy{MaxRuns: 3, Window: &metav1.Duration{Duration: time.Hour}},
+			runs:           []string{tekton.StatusFailed, tekton.StatusFailed},
+			wantBuffered:   1,
+			wantRequeueMin: 59 * time.Minute,
+		},
+		{
+			name:        "Test with an elapsed window",
+			policy:      &obsv1.DigestPolicy{MaxRuns: 3, Window: &metav1.Duration{Duration: time.Nanosecond}, Template: "{{.Failed}}/{{.Total}}"},
+			runs:        []string{tekton.StatusFailed},
+			wantDigests: []string{"1/1"},
+		},
+		{
+			name:       "Test with a removed sink",
+			policy:     &obsv1.DigestPolicy{MaxRuns: 3},
+			runs:       []string{tekton.StatusFailed},
+			removeSink: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			log := zapr.NewLogger(zaptest.NewLogger(t))
+			observation := &obsv1.TektonObservation{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: tektonobserver.ObservationCrdName, UID: "observation-uid"},
+				Spec: obsv1.TektonObservationSpec{
+					PubSubTopics: []obsv1.PubSubTopic{{PubSubProjectID: "project", PubSubTopicID: "topic", Digest: tt.policy}},
+				},
+			}
+			digests := []string{}
+			published := 0
+			r := &TektonObservationReconciler{
+				Client: utils.NewFakeClient(observation),
+				PubSubPublisher: func(ctx context.Context, projectID, topicID string, data []byte, attributes map[string]string, metadata delivery.Metadata) (string, error) {
+					published++
+					if attributes["phase"] != "digest" {
+						return "id", nil
+					}
+					summary := digest.Summary{}
+					if err := json.Unmarshal(data, &summary); err != nil {
+						t.Fatal(err)
+					}
+					digests = append(digests, summary.Text)
+					return "id", nil
+				},
+			}
+
+			for i, status := range tt.runs {
+				data := &tekton.PipelineRunData{Namespace: "test-namespace", PipelineRunName: fmt.Sprintf("run-%d", i), PipelineName: "nightly", Status: status, Reason: status}
+				if err := r.deliver(ctx, log, observation, r.getSinks(observation), sinks.Event{Phase: obsv1.PhaseFinished, Data: data}, map[string]string{}, map[string]int{}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.removeSink {
+				observation.Spec.PubSubTopics = nil
+			}
+			next, err := r.flushDigests(ctx, log, observation)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if published != len(tt.wantDigests) || len(digests) != len(tt.wantDigests) {
+				t.Fatalf("published %d messages and the digests %q, want %q", published, digests, tt.wantDigests)
+			}
+			for i := range digests {
+				if digests[i] != tt.wantDigests[i] {
+					t.Errorf("digest = %q, want %q", digests[i], tt.wantDigests[i])
+				}
+			}
+			configMaps := &corev1.ConfigMapList{}
+			if err := r.List(ctx, configMaps, client.InNamespace("test-namespace")); err != nil {
+				t.Fatal(err)
+			}
+			if len(configMaps.Items) != tt.wantBuffered {
+				t.Errorf("%d digests are buffered, want %d", len(configMaps.Items), tt.wantBuffered)
+			}
+			if next < tt.wantRequeueMin || (tt.wantRequeueMin == 0 && next != 0) {
+				t.Errorf("flushDigests() requeues after %v, want at least %v", next, tt.wantRequeueMin)
+			}
+		})
+	}
+}
+
+func TestTektonObservationReconciler_flushDigests_sharded(t *testing.T) {
+	tests := []struct {
+		name     string
+		replicas []string
+	}{
+		{
+			name:     "Test without sharding",
+			replicas: nil,
+		},
+		{
+			name:     "Test with two sharded replicas",
+			replicas: []string{"replica-a", "replica-b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			log := zapr.NewLogger(zaptest.NewLogger(t))
+			observation := &obsv1.TektonObservation{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: tektonobserver.ObservationCrdName, UID: "observation-uid"},
+				Spec: obsv1.TektonObservationSpec{
+					PubSubTopics: []obsv1.PubSubTopic{{PubSubProjectID: "project", PubSubTopicID: "topic", Digest: &obsv1.DigestPolicy{MaxRuns: 3, Window: &metav1.Duration{Duration: time.Hour}}}},
+				},
+			}
+			fakeClient := utils.NewFakeClient(observation)
+			var replicas []*TektonObservationReconciler
+			published, flushed := 0, 0
+			// The replicas flush in turn, a replica publishing a digest lets the next ones read it before it is removed
+			flush := func() {
+				for flushed < len(replicas) {
+					r := replicas[flushed]
+					flushed++
+					if _, err := r.flushDigests(ctx, log, observation); err != nil {
+						t.Error(err)
+					}
+				}
+			}
+			publisher := func(ctx context.Context, projectID, topicID string, data []byte, attributes map[string]string, metadata delivery.Metadata) (string, error) {
+				published++
+				if attributes["phase"] == "digest" {
+					flush()
+				}
+				return "id", nil
+			}
+			replicas = []*TektonObservationReconciler{{Client: fakeClient, PubSubPublisher: publisher}}
+			if len(tt.replicas) > 0 {
+				replicas = nil
+				for _, sharder := range newSharders(t, fakeClient, tt.replicas...) {
+					replicas = append(replicas, &TektonObservationReconciler{Client: fakeClient, PubSubPublisher: publisher, Sharder: sharder})
+				}
+			}
+
+			data := &tekton.PipelineRunData{Namespace: "test-namespace", PipelineRunName: "run-0", PipelineName: "nightly", Status: tekton.StatusFailed, Reason: tekton.StatusFailed}
+			if err := replicas[0].deliver(ctx, log, observation, replicas[0].getSinks(observation), sinks.Event{Phase: obsv1.PhaseFinished, Data: data}, map[string]string{}, map[string]int{}); err != nil {
+				t.Fatal(err)
+			}
+			// The sink left the digest mode, its digest is sent right away
+			observation.Spec.PubSubTopics[0].Digest = nil
+			flush()
+
+			if published != 1 {
+				t.Errorf("the replicas published the digest %d times, want 1", published)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"github.com/kcloutie/tekton-observer/internal/dora"
+	"github.com/kcloutie/tekton-observer/pkg/delivery"
+	"github.com/kcloutie/tekton-observer/pkg/metrics"
+	"github.com/kcloutie/tekton-observer/pkg/sinks"
+	"github.com/kcloutie/tekton-observer/pkg/tekton"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// recordDora records the finished PipelineRun in the deployment history of its repository and environment when it is
+// a deployment, and remembers its commit for the lead time of the deployments. Nothing is recorded when the
+// observation does not compute the DORA metrics, and the PipelineRun is delivered anyway when it cannot be recorded.
+func (r *TektonObservationReconciler) recordDora(ctx context.Context, log logr.Logger, observation *obsv1.TektonObservation, data *tekton.PipelineRunData) {
+	if observation.Spec.Dora == nil {
+		return
+	}
+	doraMetrics, err := dora.Update(ctx, r.Client, observation, data, getPipelineRunUID(data), time.Now())
+	if err != nil {
+		log.Error(err, "Failed to record the deployment")
+		return
+	}
+	if doraMetrics == nil {
+		return
+	}
+	metrics.DoraDeploymentsTotal.WithLabelValues(data.Namespace, doraMetrics.Repository, doraMetrics.Environment, data.Status).Inc()
+	setDoraMetrics(data.Namespace, *doraMetrics)
+	log.V(2).Info("Deployment recorded", "repository", doraMetrics.Repository, "environment", doraMetrics.Environment, "deployments", doraMetrics.Deployments)
+}
+
+func setDoraMetrics(namespace string, doraMetrics dora.Metrics) {
+	labels := []string{namespace, doraMetrics.Repository, doraMetrics.Environment}
+	metrics.DoraDeploymentFrequency.WithLabelValues(labels...).Set(doraMetrics.DeploymentsPerDay)
+	metrics.DoraLeadTime.WithLabelValues(labels...).Set(doraMetrics.LeadTimeSeconds)
+	metrics.DoraChangeFailureRate.WithLabelValues(labels...).Set(doraMetrics.ChangeFailureRate)
+	metrics.DoraTimeToRestore.WithLabelValues(labels...).Set(doraMetrics.TimeToRestoreSeconds)
+}
+
+// sendDoraSummary delivers the summary of the DORA metrics of the namespace to the sinks subscribed to the
+// dora-summary phase when it is due, and refreshes the metrics as the deployments leave the window. The summary is
+// sent again when a sink failed. The time left before the next summary is due is returned, it is 0 when the
+// observation does not compute the DORA metrics or nothing was recorded yet. With sharding, only the replica owning
+// the namespace sends its summary.
+func (r *TektonObservationReconciler) sendDoraSummary(ctx context.Context, log logr.Logger, observation *obsv1.TektonObservation) (time.Duration, error) {
+	policy := observation.Spec.Dora
+	if policy == nil {
+		return 0, nil
+	}
+	if !r.ownsNamespace(observation.Namespace) {
+		log.V(2).Info("The DORA summary of the namespace is sent by another shard...skipping")
+		return 0, nil
+	}
+	configMap := &corev1.ConfigMap{}
+	if err := r.Get(ctx, client.ObjectKey{Namespace: observation.Namespace, Name: dora.ConfigMapName}, configMap); err != nil {
+		if apierrors.IsNotFound(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to get the deployment histories - %w", err)
+	}
+
+	interval := dora.SummaryInterval(policy)
+	due, end, left, err := dora.Due(configMap, interval, time.Now())
+	if err != nil || !due {
+		return left, err
+	}
+	summary, err := dora.Summarize(configMap, dora.Window(policy), end)
+	if err != nil {
+		return 0, err
+	}
+	for _, environment := range summary.Environments {
+		setDoraMetrics(observation.Namespace, environment)
+	}
+
+	var errs []error
+	for _, sink := range r.getSinks(observation) {
+		if !sink.Subscribed(obsv1.PhaseDoraSummary) {
+			continue
+		}
+		event := sinks.Event{
+			Phase: obsv1.PhaseDoraSummary,
+			Dora:  &summary,
+			Delivery: delivery.Metadata{
+				ID:      delivery.ID(string(configMap.UID), "dora/"+end.UTC().Format(time.RFC3339), sink.Name()),
+				Attempt: 1,
+			},
+		}
+		_, err := sink.Deliver(ctx, event)
+		metrics.DoraSummariesSentTotal.WithLabelValues(sink.Name(), fmt.Sprintf("%v", err == nil)).Inc()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to deliver the DORA summary to the sink '%s' - %w", sink.Name(), err))
+		}
+	}
+	if len(errs) > 0 {
+		return 0, errors.Join(errs...)
+	}
+	if err := dora.MarkSummarized(ctx, r.Client, configMap, end); err != nil {
+		return 0, err
+	}
+	log.V(1).Info("DORA summary delivered", "windowEnd", end, "environments", len(summary.Environments))
+	return end.Add(interval).Sub(time.Now()), nil
+}
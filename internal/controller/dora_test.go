@@ -0,0 +1,222 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-logr/zapr"
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"github.com/kcloutie/tekton-observer/internal/dora"
+	"github.com/kcloutie/tekton-observer/internal/tektonobserver"
+	"github.com/kcloutie/tekton-observer/pkg/delivery"
+	"github.com/kcloutie/tekton-observer/pkg/events"
+	"github.com/kcloutie/tekton-observer/test/utils"
+	"go.uber.org/zap/zaptest"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestTektonObservationReconciler_sendDoraSummary(t *testing.T) {
+	log := zapr.NewLogger(zaptest.NewLogger(t))
+	tests := []struct {
+		name           string
+		lastSummary    time.Duration
+		phases         []obsv1.Phase
+		publishErr     error
+		wantErr        bool
+		wantPublished  int
+		wantSummarized bool
+		wantRequeueMin time.Duration
+		wantRequeueMax time.Duration
+	}{
+		{
+			name:           "Test with a summary due",
+			lastSummary:    25 * time.Hour,
+			phases:         []obsv1.Phase{obsv1.PhaseDoraSummary},
+			wantPublished:  1,
+			wantSummarized: true,
+			wantRequeueMin: 22 * time.Hour,
+			wantRequeueMax: 23 * time.Hour,
+		},
+		{
+			name:           "Test with a summary not due",
+			lastSummary:    time.Hour,
+			phases:         []obsv1.Phase{obsv1.PhaseDoraSummary},
+			wantRequeueMin: 22 * time.Hour,
+			wantRequeueMax: 23 * time.Hour,
+		},
+		{
+			name:           "Test with no sink subscribed",
+			lastSummary:    25 * time.Hour,
+			wantSummarized: true,
+			wantRequeueMin: 22 * time.Hour,
+			wantRequeueMax: 23 * time.Hour,
+		},
+		{
+			name:          "Test with a failed delivery",
+			lastSummary:   25 * time.Hour,
+			phases:        []obsv1.Phase{obsv1.PhaseDoraSummary},
+			publishErr:    errors.New("boom"),
+			wantErr:       true,
+			wantPublished: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			now := time.Now()
+			last := now.Add(-tt.lastSummary).UTC().Truncate(time.Second)
+			observation := &obsv1.TektonObservation{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: tektonobserver.ObservationCrdName},
+				Spec: obsv1.TektonObservationSpec{
+					PubSubTopics: []obsv1.PubSubTopic{{PubSubProjectID: "project", PubSubTopicID: "topic", Phases: tt.phases}},
+					Dora:         &obsv1.DoraPolicy{},
+				},
+			}
+			record, err := json.Marshal(dora.Record{
+				Repository:  "my-repo",
+				Environment: "production",
+				Deployments: []dora.Deployment{{UID: "1", Succeeded: true, CompletionTime: metav1.Time{Time: now.Add(-2 * time.Hour)}}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			configMap := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace:   "test-namespace",
+					Name:        dora.ConfigMapName,
+					Labels:      map[string]string{tektonobserver.StateConfigMapLabel: dora.State},
+					Annotations: map[string]string{dora.LastSummaryAnnotation: last.Format(time.RFC3339)},
+				},
+				Data: map[string]string{dora.Key("my-repo", "production"): string(record)},
+			}
+			fakeClient := utils.NewFakeClient(observation.DeepCopy(), configMap)
+
+			published := []map[string]string{}
+			r := &TektonObservationReconciler{
+				Client:       fakeClient,
+				Scheme:       scheme.Scheme,
+				EventEmitter: events.NewEventEmitter(fakeClient, &log, ""),
+				PubSubPublisher: func(ctx context.Context, projectID, topicID string, data []byte, attributes map[string]string, metadata delivery.Metadata) (string, error) {
+					published = append(published, attributes)
+					summary := dora.Summary{}
+					if err := json.Unmarshal(data, &summary); err != nil {
+						t.Fatal(err)
+					}
+					if len(summary.Environments) != 1 || summary.Environments[0].Deployments != 1 {
+						t.Errorf("unexpected summary %+v", summary)
+					}
+					return "id", tt.publishErr
+				},
+			}
+
+			next, err := r.sendDoraSummary(ctx, log, observation)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("sendDoraSummary() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(published) != tt.wantPublished {
+				t.Errorf("sendDoraSummary() published %d summaries, want %d", len(published), tt.wantPublished)
+			}
+			for _, attributes := range published {
+				if attributes["phase"] != string(obsv1.PhaseDoraSummary) {
+					t.Errorf("unexpected phase attribute %v", attributes["phase"])
+				}
+			}
+			if next < tt.wantRequeueMin || next > tt.wantRequeueMax {
+				t.Errorf("sendDoraSummary() next = %v, want between %v and %v", next, tt.wantRequeueMin, tt.wantRequeueMax)
+			}
+
+			got := &corev1.ConfigMap{}
+			if err := fakeClient.Get(ctx, client.ObjectKeyFromObject(configMap), got); err != nil {
+				t.Fatal(err)
+			}
+			summarized := got.Annotations[dora.LastSummaryAnnotation] != last.Format(time.RFC3339)
+			if summarized != tt.wantSummarized {
+				t.Errorf("sendDoraSummary() summarized = %v, want %v", summarized, tt.wantSummarized)
+			}
+		})
+	}
+}
+
+func TestTektonObservationReconciler_sendDoraSummary_sharded(t *testing.T) {
+	log := zapr.NewLogger(zaptest.NewLogger(t))
+	tests := []struct {
+		name     string
+		replicas []string
+	}{
+		{
+			name: "Test without sharding",
+		},
+		{
+			name:     "Test with two sharded replicas",
+			replicas: []string{"replica-a", "replica-b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			now := time.Now()
+			observation := &obsv1.TektonObservation{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: tektonobserver.ObservationCrdName},
+				Spec: obsv1.TektonObservationSpec{
+					PubSubTopics: []obsv1.PubSubTopic{{PubSubProjectID: "project", PubSubTopicID: "topic", Phases: []obsv1.Phase{obsv1.PhaseDoraSummary}}},
+					Dora:         &obsv1.DoraPolicy{},
+				},
+			}
+			record, err := json.Marshal(dora.Record{
+				Repository:  "my-repo",
+				Environment: "production",
+				Deployments: []dora.Deployment{{UID: "1", Succeeded: true, CompletionTime: metav1.Time{Time: now.Add(-2 * time.Hour)}}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			configMap := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace:   "test-namespace",
+					Name:        dora.ConfigMapName,
+					Labels:      map[string]string{tektonobserver.StateConfigMapLabel: dora.State},
+					Annotations: map[string]string{dora.LastSummaryAnnotation: now.Add(-25 * time.Hour).UTC().Format(time.RFC3339)},
+				},
+				Data: map[string]string{dora.Key("my-repo", "production"): string(record)},
+			}
+			fakeClient := utils.NewFakeClient(observation.DeepCopy(), configMap)
+
+			var replicas []*TektonObservationReconciler
+			published, sent := 0, 0
+			// The replicas send in turn, a replica publishing the summary lets the next ones read the deployment
+			// histories before they are marked as summarized
+			send := func() {
+				for sent < len(replicas) {
+					r := replicas[sent]
+					sent++
+					if _, err := r.sendDoraSummary(ctx, log, observation); err != nil {
+						t.Error(err)
+					}
+				}
+			}
+			publisher := func(ctx context.Context, projectID, topicID string, data []byte, attributes map[string]string, metadata delivery.Metadata) (string, error) {
+				published++
+				send()
+				return "id", nil
+			}
+			replicas = []*TektonObservationReconciler{{Client: fakeClient, PubSubPublisher: publisher}}
+			if len(tt.replicas) > 0 {
+				replicas = nil
+				for _, sharder := range newSharders(t, fakeClient, tt.replicas...) {
+					replicas = append(replicas, &TektonObservationReconciler{Client: fakeClient, PubSubPublisher: publisher, Sharder: sharder})
+				}
+			}
+
+			send()
+			if published != 1 {
+				t.Errorf("the replicas published the DORA summary %d times, want 1", published)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"github.com/kcloutie/tekton-observer/internal/flakiness"
+	"github.com/kcloutie/tekton-observer/pkg/metrics"
+	"github.com/kcloutie/tekton-observer/pkg/tekton"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline"
+	tknv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"knative.dev/pkg/apis"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// recordFlakiness records the TaskRuns of the finished PipelineRun in the flakiness history of their tasks, and adds
+// the flaky tasks to its data. Nothing is recorded when the observation does not track the flakiness, and the
+// PipelineRun is delivered without flaky tasks when they cannot be recorded.
+func (r *TektonObservationReconciler) recordFlakiness(ctx context.Context, log logr.Logger, observation *obsv1.TektonObservation, data *tekton.PipelineRunData) {
+	policy := observation.Spec.Flakiness
+	if policy == nil {
+		return
+	}
+	taskRuns, err := r.getTaskRunOutcomes(ctx, data)
+	if err != nil {
+		log.Error(err, "Failed to get the TaskRuns to record the flakiness of the tasks")
+		return
+	}
+	records, err := flakiness.Update(ctx, r.Client, observation, data, getPipelineRunUID(data), taskRuns, flakiness.Window(policy), time.Now())
+	if err != nil {
+		log.Error(err, "Failed to record the flakiness of the tasks")
+		return
+	}
+	for _, record := range records {
+		metrics.TaskFlakinessRatio.WithLabelValues(record.Namespace, record.Pipeline, record.Task).Set(record.Ratio())
+		if flakiness.IsFlaky(policy, record) {
+			data.FlakyTasks = append(data.FlakyTasks, tekton.FlakyTask{
+				Task:    record.Task,
+				Ratio:   record.Ratio(),
+				Flaky:   record.Flaky(),
+				Runs:    len(record.Outcomes),
+				Message: record.Message(),
+			})
+		}
+	}
+	log.V(2).Info("Flakiness of the tasks recorded", "tasks", len(records), "flakyTasks", len(data.FlakyTasks))
+}
+
+// getTaskRunOutcomes returns the outcomes of the TaskRuns of the PipelineRun that succeeded or failed, the cancelled
+// TaskRuns are left out
+func (r *TektonObservationReconciler) getTaskRunOutcomes(ctx context.Context, data *tekton.PipelineRunData) ([]flakiness.TaskRun, error) {
+	taskRuns, err := r.getTaskRuns(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+	outcomes := []flakiness.TaskRun{}
+	for _, taskRun := range taskRuns {
+		taskName := taskRun.Labels[pipeline.PipelineTaskLabelKey]
+		if taskName == "" || !taskRun.IsDone() || taskRun.IsCancelled() {
+			continue
+		}
+		outcomes = append(outcomes, flakiness.TaskRun{
+			Task:      taskName,
+			Succeeded: taskRun.Status.GetCondition(apis.ConditionSucceeded).IsTrue(),
+			Retries:   len(taskRun.Status.RetriesStatus),
+		})
+	}
+	return outcomes, nil
+}
+
+// getTaskRuns returns the TaskRuns of the PipelineRun. The TaskRuns are read from the API server when an API reader
+// is set, so they are not cached.
+func (r *TektonObservationReconciler) getTaskRuns(ctx context.Context, data *tekton.PipelineRunData) ([]tknv1.TaskRun, error) {
+	var reader client.Reader = r.Client
+	if r.APIReader != nil {
+		reader = r.APIReader
+	}
+	taskRuns := &tknv1.TaskRunList{}
+	if err := reader.List(ctx, taskRuns, client.InNamespace(data.Namespace), client.MatchingLabels{pipeline.PipelineRunLabelKey: data.PipelineRunName}); err != nil {
+		return nil, fmt.Errorf("failed to list the TaskRuns of the PipelineRun '%s' - %w", data.PipelineRunName, err)
+	}
+	return taskRuns.Items, nil
+}
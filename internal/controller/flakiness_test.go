@@ -0,0 +1,92 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-logr/zapr"
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"github.com/kcloutie/tekton-observer/internal/flakiness"
+	"github.com/kcloutie/tekton-observer/internal/tektonobserver"
+	"github.com/kcloutie/tekton-observer/pkg/delivery"
+	"github.com/kcloutie/tekton-observer/pkg/events"
+	"github.com/kcloutie/tekton-observer/test/utils"
+	tknv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"go.uber.org/zap/zaptest"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestTektonObservationReconciler_processPipelineRun_flakiness(t *testing.T) {
+	log := zapr.NewLogger(zaptest.NewLogger(t))
+	tests := []struct {
+		name           string
+		policy         *obsv1.FlakinessPolicy
+		wantFlakyTasks string
+		wantHistory    bool
+	}{
+		{
+			name:           "Test with the flakiness tracked",
+			policy:         &obsv1.FlakinessPolicy{MinRuns: 1},
+			wantFlakyTasks: "unit-tests",
+			wantHistory:    true,
+		},
+		{
+			name:        "Test with too few runs",
+			policy:      &obsv1.FlakinessPolicy{},
+			wantHistory: true,
+		},
+		{
+			name: "Test with the flakiness not tracked",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			observation := &obsv1.TektonObservation{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: tektonobserver.ObservationCrdName},
+				Spec: obsv1.TektonObservationSpec{
+					PubSubTopics: []obsv1.PubSubTopic{{PubSubProjectID: "project", PubSubTopicID: "topic"}},
+					Flakiness:    tt.policy,
+				},
+			}
+			pipelineRun := utils.NewPipelineRun("test-namespace", "test-name", map[string]string{
+				tektonobserver.PipelineProcessingStateAnnotation: tektonobserver.ProcessingStartState,
+			}, true)
+			pipelineRun.UID = "pipelinerun-uid"
+			flakyTaskRun := newTaskRun(pipelineRun, "unit-tests")
+			flakyTaskRun.Status.RetriesStatus = []tknv1.TaskRunStatus{{}}
+			fakeClient := utils.NewFakeClient(pipelineRun, observation.DeepCopy(), flakyTaskRun, newTaskRun(pipelineRun, "lint"))
+
+			flakyTasks := ""
+			r := &TektonObservationReconciler{
+				Client:       fakeClient,
+				APIReader:    fakeClient,
+				Scheme:       scheme.Scheme,
+				EventEmitter: events.NewEventEmitter(fakeClient, &log, ""),
+				PubSubPublisher: func(ctx context.Context, projectID, topicID string, data []byte, attributes map[string]string, metadata delivery.Metadata) (string, error) {
+					flakyTasks = attributes["flakyTasks"]
+					return "id", nil
+				},
+			}
+
+			if err := r.processPipelineRun(ctx, log, observation, pipelineRun); err != nil {
+				t.Fatal(err)
+			}
+			if flakyTasks != tt.wantFlakyTasks {
+				t.Errorf("processPipelineRun() flakyTasks attribute = %v, want %v", flakyTasks, tt.wantFlakyTasks)
+			}
+
+			configMap := &corev1.ConfigMap{}
+			err := fakeClient.Get(ctx, client.ObjectKey{Namespace: "test-namespace", Name: flakiness.ConfigMapName}, configMap)
+			if (err == nil) != tt.wantHistory {
+				t.Fatalf("processPipelineRun() history error = %v, want history %v", err, tt.wantHistory)
+			}
+			if tt.wantHistory && len(configMap.Data) != 2 {
+				t.Errorf("processPipelineRun() history = %v, want 2 tasks", configMap.Data)
+			}
+		})
+	}
+}
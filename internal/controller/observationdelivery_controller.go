@@ -0,0 +1,148 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"github.com/kcloutie/tekton-observer/internal/deadletter"
+	"github.com/kcloutie/tekton-observer/internal/outbox"
+	"github.com/kcloutie/tekton-observer/internal/tektonobserver"
+	"github.com/kcloutie/tekton-observer/pkg/metrics"
+	"github.com/kcloutie/tekton-observer/pkg/sinks"
+	"github.com/kcloutie/tekton-observer/pkg/throttle"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+//+kubebuilder:rbac:groups=observer.tkn.dev,resources=observationdeliveries,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=observer.tkn.dev,resources=observationdeliveries/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;create
+
+// ReconcileDelivery drains the outbox. A pending delivery is retried once its next attempt time is reached, it is
+// deleted once delivered and dead-lettered once it exhausted the retries of the retry policy.
+func (r *TektonObservationReconciler) ReconcileDelivery(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx).WithValues("clusterName", tektonobserver.ControllerConfiguration.GetClusterName())
+	defer r.updateOutboxMetrics(ctx, log)
+
+	outboxDelivery := &obsv1.ObservationDelivery{}
+	if err := r.Get(ctx, req.NamespacedName, outboxDelivery); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !r.ownsPipelineRun(outboxDelivery) || !outboxDelivery.DeletionTimestamp.IsZero() || outboxDelivery.Status.State == obsv1.DeliveryDeadLettered {
+		return ctrl.Result{}, nil
+	}
+	log = log.WithValues("delivery", outboxDelivery.Name, "sink", outboxDelivery.Spec.Sink, "PipelineRun", outboxDelivery.Spec.PipelineRun.Name)
+
+	now := time.Now()
+	if next := outboxDelivery.Status.NextAttemptTime; next != nil && next.After(now) {
+		return ctrl.Result{RequeueAfter: next.Sub(now)}, nil
+	}
+
+	observation := &obsv1.TektonObservation{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: outboxDelivery.Namespace, Name: tektonobserver.ObservationCrdName}, observation); err != nil {
+		// The deliveries are owned by the observation, they are garbage collected with it
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	sinkList := filterSinks(r.getSinks(observation), outboxDelivery.Spec.Sink)
+	if len(sinkList) == 0 {
+		return ctrl.Result{}, r.deadLetter(ctx, log, observation, outboxDelivery, fmt.Sprintf("the sink '%s' no longer exists", outboxDelivery.Spec.Sink))
+	}
+	event, err := outbox.Event(outboxDelivery)
+	if err != nil {
+		return ctrl.Result{}, r.deadLetter(ctx, log, observation, outboxDelivery, err.Error())
+	}
+
+	return r.attemptDelivery(ctx, log, observation, outboxDelivery, sinkList[0], event, now)
+}
+
+// attemptDelivery delivers the event of the outbox delivery to the sink
+func (r *TektonObservationReconciler) attemptDelivery(ctx context.Context, log logr.Logger, observation *obsv1.TektonObservation, outboxDelivery *obsv1.ObservationDelivery, sink sinks.Sink, event sinks.Event, now time.Time) (ctrl.Result, error) {
+	_, err := r.deliverToSink(ctx, log, observation, sink, event)
+	metrics.OutboxAttemptsTotal.WithLabelValues(sink.Name(), fmt.Sprintf("%v", err == nil)).Inc()
+	if err == nil {
+		log.V(1).Info("Delivery of the outbox delivered", "attempt", event.Delivery.Attempt)
+		return ctrl.Result{}, client.IgnoreNotFound(r.Delete(ctx, outboxDelivery))
+	}
+
+	policy := tektonobserver.ControllerConfiguration.GetRetryPolicy()
+	outboxDelivery.Status.Attempts = event.Delivery.Attempt
+	outboxDelivery.Status.LastError = err.Error()
+	outboxDelivery.Status.LastAttemptTime = &metav1.Time{Time: now}
+	if outbox.Exhausted(policy, outboxDelivery.Status.Attempts) {
+		trace.SpanFromContext(ctx).AddEvent("dead-lettered", trace.WithAttributes(attribute.Int("delivery.attempt", event.Delivery.Attempt)))
+		return ctrl.Result{}, r.deadLetter(ctx, log, observation, outboxDelivery, err.Error())
+	}
+
+	backoff := outbox.Backoff(policy, outboxDelivery.Status.Attempts)
+	if after, found := throttle.RetryAfter(err); found && after > backoff {
+		backoff = after
+	}
+	outboxDelivery.Status.NextAttemptTime = &metav1.Time{Time: now.Add(backoff)}
+	trace.SpanFromContext(ctx).AddEvent("retry scheduled", trace.WithAttributes(
+		attribute.Int("delivery.attempt", event.Delivery.Attempt),
+		attribute.String("backoff", backoff.String()),
+	))
+	log.V(1).Info("Delivery of the outbox failed, retrying later", "attempt", event.Delivery.Attempt, "backoff", backoff, "error", err.Error())
+	if err := r.Status().Update(ctx, outboxDelivery); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to update the status of the delivery '%s' - %w", outboxDelivery.Name, err)
+	}
+	return ctrl.Result{RequeueAfter: backoff}, nil
+}
+
+// deadLetter stops retrying the outbox delivery and sends it to the dead-letter destination of the observation. The
+// delivery is kept until it is retried or deleted.
+func (r *TektonObservationReconciler) deadLetter(ctx context.Context, log logr.Logger, observation *obsv1.TektonObservation, outboxDelivery *obsv1.ObservationDelivery, reason string) error {
+	letter := deadletter.Letter{
+		Namespace:      outboxDelivery.Namespace,
+		PipelineRun:    outboxDelivery.Spec.PipelineRun.Name,
+		PipelineRunUID: string(outboxDelivery.Spec.PipelineRun.UID),
+		Sink:           outboxDelivery.Spec.Sink,
+		Phase:          outboxDelivery.Spec.Phase,
+		TaskName:       outboxDelivery.Spec.TaskName,
+		DeliveryID:     outboxDelivery.Spec.DeliveryID,
+		Attempts:       outboxDelivery.Status.Attempts,
+		Reason:         reason,
+		Time:           metav1.Now(),
+		Payload:        outboxDelivery.Spec.Payload,
+	}
+	if err := r.deadLetterDelivery(ctx, log, observation, letter); err != nil {
+		return fmt.Errorf("failed to dead-letter the delivery '%s' - %w", outboxDelivery.Name, err)
+	}
+
+	outboxDelivery.Status.State = obsv1.DeliveryDeadLettered
+	outboxDelivery.Status.LastError = reason
+	outboxDelivery.Status.NextAttemptTime = nil
+	if err := r.Status().Update(ctx, outboxDelivery); err != nil {
+		return fmt.Errorf("failed to dead-letter the delivery '%s' - %w", outboxDelivery.Name, err)
+	}
+	log.Info("Delivery of the outbox dead-lettered", "attempts", outboxDelivery.Status.Attempts, "reason", reason)
+	return nil
+}
+
+// updateOutboxMetrics counts the deliveries of the outbox by state
+func (r *TektonObservationReconciler) updateOutboxMetrics(ctx context.Context, log logr.Logger) {
+	deliveries := &obsv1.ObservationDeliveryList{}
+	if err := r.List(ctx, deliveries); err != nil {
+		log.V(2).Info("Failed to count the deliveries of the outbox", "error", err.Error())
+		return
+	}
+	counts := map[obsv1.DeliveryState]int{obsv1.DeliveryPending: 0, obsv1.DeliveryDeadLettered: 0}
+	for _, outboxDelivery := range deliveries.Items {
+		state := outboxDelivery.Status.State
+		if state == "" {
+			state = obsv1.DeliveryPending
+		}
+		counts[state]++
+	}
+	for state, count := range counts {
+		metrics.OutboxDeliveries.WithLabelValues(string(state)).Set(float64(count))
+	}
+}
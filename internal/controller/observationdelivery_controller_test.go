@@ -0,0 +1,232 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-logr/zapr"
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"github.com/kcloutie/tekton-observer/internal/deadletter"
+	"github.com/kcloutie/tekton-observer/internal/outbox"
+	"github.com/kcloutie/tekton-observer/internal/tektonobserver"
+	"github.com/kcloutie/tekton-observer/pkg/delivery"
+	"github.com/kcloutie/tekton-observer/pkg/events"
+	"github.com/kcloutie/tekton-observer/pkg/sinks"
+	"github.com/kcloutie/tekton-observer/pkg/tekton"
+	"github.com/kcloutie/tekton-observer/test/utils"
+	tknv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"go.uber.org/zap/zaptest"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestTektonObservationReconciler_ReconcileDelivery(t *testing.T) {
+	log := zapr.NewLogger(zaptest.NewLogger(t))
+	observation := &obsv1.TektonObservation{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: tektonobserver.ObservationCrdName},
+		Spec: obsv1.TektonObservationSpec{
+			PubSubTopics: []obsv1.PubSubTopic{{PubSubProjectID: "project", PubSubTopicID: "topic"}},
+			DeadLetter:   &obsv1.DeadLetterDestination{ConfigMap: &obsv1.ConfigMapDeadLetter{}, Log: true},
+		},
+	}
+	tests := []struct {
+		name          string
+		sink          string
+		attempts      int
+		nextAttempt   time.Duration
+		publishErr    error
+		wantPublished int
+		wantDeleted   bool
+		wantState     obsv1.DeliveryState
+		wantAttempts  int
+		wantRequeue   bool
+	}{
+		{
+			name:          "Test with a successful delivery",
+			sink:          "pubsub/project/topic",
+			attempts:      1,
+			wantPublished: 1,
+			wantDeleted:   true,
+		},
+		{
+			name:          "Test with a failed delivery",
+			sink:          "pubsub/project/topic",
+			attempts:      1,
+			publishErr:    errors.New("boom"),
+			wantPublished: 1,
+			wantState:     obsv1.DeliveryPending,
+			wantAttempts:  2,
+			wantRequeue:   true,
+		},
+		{
+			name:          "Test with a failed delivery exhausting the retries",
+			sink:          "pubsub/project/topic",
+			attempts:      tektonobserver.DefaultMaxRetries,
+			publishErr:    errors.New("boom"),
+			wantPublished: 1,
+			wantState:     obsv1.DeliveryDeadLettered,
+			wantAttempts:  tektonobserver.DefaultMaxRetries + 1,
+		},
+		{
+			name:         "Test with a delivery not due yet",
+			sink:         "pubsub/project/topic",
+			attempts:     1,
+			nextAttempt:  time.Minute,
+			wantState:    obsv1.DeliveryPending,
+			wantAttempts: 1,
+			wantRequeue:  true,
+		},
+		{
+			name:         "Test with a sink that no longer exists",
+			sink:         "pubsub/project/removed",
+			attempts:     1,
+			wantState:    obsv1.DeliveryDeadLettered,
+			wantAttempts: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			fakeClient := utils.NewFakeClient(observation.DeepCopy())
+			event := sinks.Event{
+				Phase:    obsv1.PhaseFinished,
+				Data:     &tekton.PipelineRunData{Namespace: "test-namespace", PipelineRunName: "test-name"},
+				Delivery: delivery.Metadata{ID: "abc", Attempt: tt.attempts},
+			}
+			now := time.Now()
+			if err := outbox.Enqueue(ctx, fakeClient, observation, tt.sink, event, errors.New("failed"), now.Add(-time.Hour)); err != nil {
+				t.Fatal(err)
+			}
+			key := client.ObjectKey{Namespace: "test-namespace", Name: outbox.Name("abc")}
+			if tt.nextAttempt > 0 {
+				outboxDelivery := &obsv1.ObservationDelivery{}
+				if err := fakeClient.Get(ctx, key, outboxDelivery); err != nil {
+					t.Fatal(err)
+				}
+				outboxDelivery.Status.NextAttemptTime = &metav1.Time{Time: now.Add(tt.nextAttempt)}
+				if err := fakeClient.Status().Update(ctx, outboxDelivery); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			published := []delivery.Metadata{}
+			r := &TektonObservationReconciler{
+				Client:       fakeClient,
+				Scheme:       scheme.Scheme,
+				EventEmitter: events.NewEventEmitter(fakeClient, &log, ""),
+				PubSubPublisher: func(ctx context.Context, projectID, topicID string, data []byte, attributes map[string]string, metadata delivery.Metadata) (string, error) {
+					published = append(published, metadata)
+					return "id", tt.publishErr
+				},
+			}
+
+			result, err := r.ReconcileDelivery(ctx, ctrl.Request{NamespacedName: key})
+			if err != nil {
+				t.Fatalf("ReconcileDelivery() error = %v", err)
+			}
+			if len(published) != tt.wantPublished {
+				t.Fatalf("ReconcileDelivery() published %d times, want %d", len(published), tt.wantPublished)
+			}
+			if tt.wantPublished > 0 && published[0].Attempt != tt.attempts+1 {
+				t.Errorf("ReconcileDelivery() attempt = %d, want %d", published[0].Attempt, tt.attempts+1)
+			}
+			if got := result.RequeueAfter > 0; got != tt.wantRequeue {
+				t.Errorf("ReconcileDelivery() requeue = %v, want %v", result.RequeueAfter, tt.wantRequeue)
+			}
+
+			outboxDelivery := &obsv1.ObservationDelivery{}
+			err = fakeClient.Get(ctx, key, outboxDelivery)
+			if tt.wantDeleted {
+				if !apierrors.IsNotFound(err) {
+					t.Errorf("ReconcileDelivery() did not delete the delivery, error = %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if outboxDelivery.Status.State != tt.wantState {
+				t.Errorf("ReconcileDelivery() state = %v, want %v", outboxDelivery.Status.State, tt.wantState)
+			}
+			if outboxDelivery.Status.Attempts != tt.wantAttempts {
+				t.Errorf("ReconcileDelivery() attempts = %v, want %v", outboxDelivery.Status.Attempts, tt.wantAttempts)
+			}
+
+			wantDeadLettered := int64(0)
+			if tt.wantState == obsv1.DeliveryDeadLettered {
+				wantDeadLettered = 1
+				configMap := &corev1.ConfigMap{}
+				if err := fakeClient.Get(ctx, client.ObjectKey{Namespace: "test-namespace", Name: deadletter.DefaultConfigMapPrefix + "-abc"}, configMap); err != nil {
+					t.Errorf("ReconcileDelivery() did not store the dead letter - %v", err)
+				}
+			}
+			latest := &obsv1.TektonObservation{}
+			if err := fakeClient.Get(ctx, client.ObjectKeyFromObject(observation), latest); err != nil {
+				t.Fatal(err)
+			}
+			if latest.Status.DeadLetteredDeliveries != wantDeadLettered {
+				t.Errorf("ReconcileDelivery() dead-lettered deliveries = %v, want %v", latest.Status.DeadLetteredDeliveries, wantDeadLettered)
+			}
+			if wantDeadLettered > 0 && (latest.Status.LastDeadLetter == nil || latest.Status.LastDeadLetter.Sink != tt.sink) {
+				t.Errorf("ReconcileDelivery() last dead letter = %+v", latest.Status.LastDeadLetter)
+			}
+		})
+	}
+}
+
+func TestTektonObservationReconciler_processPipelineRun_outbox(t *testing.T) {
+	log := zapr.NewLogger(zaptest.NewLogger(t))
+	ctx := context.Background()
+	observation := &obsv1.TektonObservation{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: tektonobserver.ObservationCrdName},
+		Spec: obsv1.TektonObservationSpec{
+			PubSubTopics: []obsv1.PubSubTopic{{PubSubProjectID: "project", PubSubTopicID: "topic"}},
+		},
+	}
+	tektonobserver.ControllerConfiguration.Set(func() tektonobserver.ControllerConfig {
+		config := tektonobserver.DefaultControllerConfig()
+		config.FeatureGates[tektonobserver.DeliveryOutbox] = true
+		return config
+	}())
+	defer tektonobserver.ControllerConfiguration.Set(tektonobserver.DefaultControllerConfig())
+
+	pipelineRun := utils.NewPipelineRun("test-namespace", "test-name", map[string]string{
+		tektonobserver.PipelineProcessingStateAnnotation: tektonobserver.ProcessingStartState,
+	}, true)
+	pipelineRun.UID = "test-uid"
+	fakeClient := utils.NewFakeClient(pipelineRun, observation.DeepCopy())
+	r := &TektonObservationReconciler{
+		Client:       fakeClient,
+		APIReader:    fakeClient,
+		Scheme:       scheme.Scheme,
+		EventEmitter: events.NewEventEmitter(fakeClient, &log, ""),
+		PubSubPublisher: func(ctx context.Context, projectID, topicID string, data []byte, attributes map[string]string, metadata delivery.Metadata) (string, error) {
+			return "", errors.New("boom")
+		},
+	}
+
+	if err := r.processPipelineRun(ctx, log, observation, pipelineRun); err != nil {
+		t.Fatalf("processPipelineRun() error = %v", err)
+	}
+
+	pr := &tknv1.PipelineRun{}
+	if err := fakeClient.Get(ctx, client.ObjectKeyFromObject(pipelineRun), pr); err != nil {
+		t.Fatal(err)
+	}
+	if got := pr.Annotations[tektonobserver.PipelineProcessingStateAnnotation]; got != tektonobserver.ProcessingCompleteState {
+		t.Errorf("processPipelineRun() annotation = %v, want %v", got, tektonobserver.ProcessingCompleteState)
+	}
+	deliveries := &obsv1.ObservationDeliveryList{}
+	if err := fakeClient.List(ctx, deliveries); err != nil {
+		t.Fatal(err)
+	}
+	if len(deliveries.Items) != 1 || deliveries.Items[0].Spec.DeliveryID != delivery.ID("test-uid", string(obsv1.PhaseFinished), "pubsub/project/topic") {
+		t.Errorf("processPipelineRun() outbox = %v, want the finished delivery", deliveries.Items)
+	}
+}
@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"github.com/kcloutie/tekton-observer/internal/onboarding"
+	"github.com/kcloutie/tekton-observer/internal/tektonobserver"
+	tknv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"go.uber.org/zap/zapcore"
+	"golang.org/x/time/rate"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// onboardPipelineRun applies the onboarding policy of the observation to the PipelineRun. Ignored PipelineRuns are
+// marked as complete and backfilled PipelineRuns are delayed to respect the backfill rate. It returns true when the
+// PipelineRun must not be processed now.
+func (r *TektonObservationReconciler) onboardPipelineRun(ctx context.Context, log logr.Logger, observation *obsv1.TektonObservation, pipelineRun *tknv1.PipelineRun) (ctrl.Result, bool, error) {
+	policy := observation.Spec.Onboarding
+	if err := onboarding.Validate(policy); err != nil {
+		r.EventEmitter.EmitMessage(ctx, observation, zapcore.ErrorLevel, "Onboarding", err.Error())
+		policy = nil
+	}
+
+	decision := onboarding.Classify(policy, pipelineRun, observation.CreationTimestamp.Time)
+	switch decision {
+	case onboarding.DecisionIgnore:
+		log.V(2).Info("Ignoring a PipelineRun that finished before the namespace was onboarded", "PipelineRun", pipelineRun.Name)
+		return ctrl.Result{}, true, onboarding.Stamp(ctx, r.Client, pipelineRun, decision)
+	case onboarding.DecisionBackfill:
+		if pipelineRun.Annotations[tektonobserver.PipelineProcessingStateAnnotation] == tektonobserver.ProcessingCompleteState {
+			return ctrl.Result{}, false, nil
+		}
+		reservation := r.backfillLimiter(observation).Reserve()
+		if delay := reservation.Delay(); delay > 0 {
+			reservation.Cancel()
+			log.V(3).Info("Backfill rate reached, delaying the PipelineRun", "PipelineRun", pipelineRun.Name, "delay", delay)
+			return ctrl.Result{RequeueAfter: delay}, true, nil
+		}
+		return ctrl.Result{}, false, onboarding.Stamp(ctx, r.Client, pipelineRun, decision)
+	}
+	return ctrl.Result{}, false, nil
+}
+
+// backfillLimiter returns the rate limiter shared by the backfilled PipelineRuns of the observation. The limiter is
+// replaced when the backfill rate of the observation changes.
+func (r *TektonObservationReconciler) backfillLimiter(observation *obsv1.TektonObservation) *rate.Limiter {
+	perMinute := onboarding.BackfillPerMinute(observation.Spec.Onboarding)
+	limit := rate.Every(time.Minute / time.Duration(perMinute))
+
+	r.backfillMu.Lock()
+	defer r.backfillMu.Unlock()
+	if r.backfillLimiters == nil {
+		r.backfillLimiters = map[string]*rate.Limiter{}
+	}
+	limiter, found := r.backfillLimiters[observation.Namespace]
+	if !found || limiter.Limit() != limit {
+		limiter = rate.NewLimiter(limit, 1)
+		r.backfillLimiters[observation.Namespace] = limiter
+	}
+	return limiter
+}
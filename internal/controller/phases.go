@@ -0,0 +1,142 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"github.com/kcloutie/tekton-observer/internal/tektonobserver"
+	"github.com/kcloutie/tekton-observer/pkg/sinks"
+	"github.com/kcloutie/tekton-observer/pkg/tekton"
+	"github.com/kcloutie/tekton-observer/pkg/tracing"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline"
+	tknv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"go.opentelemetry.io/otel/attribute"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// phaseKey identifies a delivered phase in the delivered phases annotation, the task-completed phase is delivered
+// once per pipeline task
+func phaseKey(phase obsv1.Phase, taskName string) string {
+	if taskName == "" {
+		return string(phase)
+	}
+	return fmt.Sprintf("%s/%s", phase, taskName)
+}
+
+func getDeliveredPhases(pipelineRun *tknv1.PipelineRun) []string {
+	raw := pipelineRun.Annotations[tektonobserver.DeliveredPhasesAnnotation]
+	if raw == "" {
+		return []string{}
+	}
+	return strings.Split(raw, ",")
+}
+
+// getPendingProgressEvents returns the queued, started and task-completed events of the PipelineRun that one of the
+// sinks subscribed to and that were not delivered yet
+func (r *TektonObservationReconciler) getPendingProgressEvents(ctx context.Context, sinkList []sinks.Sink, pipelineRun *tknv1.PipelineRun) ([]sinks.Event, error) {
+	delivered := getDeliveredPhases(pipelineRun)
+	running := !pipelineRun.IsDone() && pipelineRun.DeletionTimestamp == nil
+	events := []sinks.Event{}
+	pending := func(phase obsv1.Phase, taskName string) bool {
+		return subscribed(sinkList, phase) && !slices.Contains(delivered, phaseKey(phase, taskName))
+	}
+
+	if running && !pipelineRun.HasStarted() && pending(obsv1.PhaseQueued, "") {
+		events = append(events, sinks.Event{Phase: obsv1.PhaseQueued})
+	}
+	if running && pipelineRun.HasStarted() && pending(obsv1.PhaseStarted, "") {
+		events = append(events, sinks.Event{Phase: obsv1.PhaseStarted})
+	}
+
+	if !pipelineRun.HasStarted() || !subscribed(sinkList, obsv1.PhaseTaskCompleted) || !tektonobserver.ControllerConfiguration.IsFeatureEnabled(tektonobserver.LiveProgressUpdates) {
+		return events, nil
+	}
+	taskRuns := &tknv1.TaskRunList{}
+	if err := r.List(ctx, taskRuns, client.InNamespace(pipelineRun.Namespace), client.MatchingLabels{pipeline.PipelineRunLabelKey: pipelineRun.Name}); err != nil {
+		return nil, fmt.Errorf("failed to list the TaskRuns of the PipelineRun '%s' - %w", pipelineRun.Name, err)
+	}
+	taskNames := []string{}
+	for _, taskRun := range taskRuns.Items {
+		taskName := taskRun.Labels[pipeline.PipelineTaskLabelKey]
+		if taskName != "" && taskRun.IsDone() && pending(obsv1.PhaseTaskCompleted, taskName) {
+			taskNames = append(taskNames, taskName)
+		}
+	}
+	sort.Strings(taskNames)
+	for _, taskName := range taskNames {
+		events = append(events, sinks.Event{Phase: obsv1.PhaseTaskCompleted, TaskName: taskName})
+	}
+	return events, nil
+}
+
+// deliverProgress delivers the pending queued, started and task-completed events of the PipelineRun and records
+// them, with the references returned by the sinks, on the PipelineRun
+func (r *TektonObservationReconciler) deliverProgress(ctx context.Context, log logr.Logger, observation *obsv1.TektonObservation, pipelineRun *tknv1.PipelineRun) error {
+	sinkList := r.getSinks(observation)
+	events, err := r.getPendingProgressEvents(ctx, sinkList, pipelineRun)
+	if err != nil || len(events) == 0 {
+		return err
+	}
+
+	data, err := r.getPipelineRunData(ctx, pipelineRun)
+	if err != nil {
+		return err
+	}
+	if data == nil {
+		return nil
+	}
+
+	delivered := getDeliveredPhases(pipelineRun)
+	refs := getSinkRefs(pipelineRun)
+	attempts := getDeliveryAttempts(pipelineRun)
+	var deliverErr error
+	for _, event := range events {
+		event.Data = data
+		if deliverErr = r.deliver(ctx, log, observation, sinkList, event, refs, attempts); deliverErr != nil {
+			break
+		}
+		delivered = append(delivered, phaseKey(event.Phase, event.TaskName))
+	}
+
+	// The phases delivered before a failure are recorded so they are not delivered twice
+	rawRefs, err := json.Marshal(refs)
+	if err != nil {
+		return fmt.Errorf("failed to marshal the sink references - %w", err)
+	}
+	rawAttempts, err := marshalDeliveryAttempts(attempts)
+	if err != nil {
+		return err
+	}
+	if err := r.updatePipelineRunAnnotations(ctx, pipelineRun, map[string]string{
+		tektonobserver.DeliveredPhasesAnnotation:  strings.Join(delivered, ","),
+		tektonobserver.SinkRefsAnnotation:         string(rawRefs),
+		tektonobserver.DeliveryAttemptsAnnotation: rawAttempts,
+	}); err != nil {
+		return fmt.Errorf("failed to record the delivered phases of the PipelineRun '%s' - %w", pipelineRun.Name, err)
+	}
+	return deliverErr
+}
+
+// getPipelineRunData builds the data delivered to the sinks from the full PipelineRun. Nil is returned when the
+// PipelineRun no longer exists.
+func (r *TektonObservationReconciler) getPipelineRunData(ctx context.Context, pipelineRun *tknv1.PipelineRun) (data *tekton.PipelineRunData, err error) {
+	ctx, span := tracing.Start(ctx, "extract PipelineRun data", attribute.String("tekton.pipelinerun.name", pipelineRun.Name))
+	defer func() {
+		tracing.End(span, err)
+	}()
+
+	fullPipelineRun, err := r.getFullPipelineRun(ctx, pipelineRun)
+	if client.IgnoreNotFound(err) != nil {
+		return nil, fmt.Errorf("failed to read the PipelineRun '%s' - %w", pipelineRun.Name, err)
+	}
+	if err != nil {
+		return nil, nil
+	}
+	return tekton.GetPipelineRunData(ctx, fullPipelineRun, r.EventEmitter)
+}
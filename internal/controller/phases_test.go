@@ -0,0 +1,134 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-logr/zapr"
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"github.com/kcloutie/tekton-observer/internal/tektonobserver"
+	"github.com/kcloutie/tekton-observer/pkg/delivery"
+	"github.com/kcloutie/tekton-observer/pkg/events"
+	"github.com/kcloutie/tekton-observer/test/utils"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline"
+	tknv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"go.uber.org/zap/zaptest"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func newTaskRun(pipelineRun *tknv1.PipelineRun, taskName string) *tknv1.TaskRun {
+	return &tknv1.TaskRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: pipelineRun.Namespace,
+			Name:      pipelineRun.Name + "-" + taskName,
+			Labels: map[string]string{
+				pipeline.PipelineRunLabelKey:  pipelineRun.Name,
+				pipeline.PipelineTaskLabelKey: taskName,
+			},
+		},
+		Status: tknv1.TaskRunStatus{
+			Status: duckv1.Status{
+				Conditions: []apis.Condition{{Type: apis.ConditionSucceeded, Status: "True"}},
+			},
+		},
+	}
+}
+
+func TestTektonObservationReconciler_processPipelineRun_phases(t *testing.T) {
+	ctx := context.Background()
+	log := zapr.NewLogger(zaptest.NewLogger(t))
+	config := tektonobserver.DefaultControllerConfig()
+	config.FeatureGates[tektonobserver.LiveProgressUpdates] = true
+	tektonobserver.ControllerConfiguration.Set(config)
+	defer tektonobserver.ControllerConfiguration.Set(tektonobserver.DefaultControllerConfig())
+
+	observation := &obsv1.TektonObservation{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "test-namespace",
+			Name:      tektonobserver.ObservationCrdName,
+		},
+		Spec: obsv1.TektonObservationSpec{
+			PubSubTopics: []obsv1.PubSubTopic{
+				{PubSubProjectID: "project", PubSubTopicID: "progress", Phases: obsv1.Phases},
+				{PubSubProjectID: "project", PubSubTopicID: "finished"},
+			},
+		},
+	}
+	pipelineRun := utils.NewPipelineRun("test-namespace", "test-name", map[string]string{}, false)
+	fakeClient := utils.NewFakeClient(pipelineRun, observation.DeepCopy())
+
+	published := []string{}
+	r := &TektonObservationReconciler{
+		Client:       fakeClient,
+		APIReader:    fakeClient,
+		Scheme:       scheme.Scheme,
+		EventEmitter: events.NewEventEmitter(fakeClient, &log, ""),
+		PubSubPublisher: func(ctx context.Context, projectID, topicID string, data []byte, attributes map[string]string, metadata delivery.Metadata) (string, error) {
+			published = append(published, topicID+":"+phaseKey(obsv1.Phase(attributes["phase"]), attributes["taskName"]))
+			return "id-" + attributes["phase"], nil
+		},
+	}
+	reconcile := func(update func(pr *tknv1.PipelineRun)) {
+		pr := &tknv1.PipelineRun{}
+		if err := fakeClient.Get(ctx, client.ObjectKeyFromObject(pipelineRun), pr); err != nil {
+			t.Fatal(err)
+		}
+		if update != nil {
+			update(pr)
+			if err := fakeClient.Update(ctx, pr); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := r.processPipelineRun(ctx, log, observation, pr); err != nil {
+			t.Fatalf("processPipelineRun() error = %v", err)
+		}
+	}
+
+	reconcile(nil)
+	reconcile(nil)
+	reconcile(func(pr *tknv1.PipelineRun) {
+		pr.Status.StartTime = &metav1.Time{Time: time.Now()}
+	})
+	if err := fakeClient.Create(ctx, newTaskRun(pipelineRun, "build")); err != nil {
+		t.Fatal(err)
+	}
+	reconcile(nil)
+	if err := fakeClient.Create(ctx, newTaskRun(pipelineRun, "test")); err != nil {
+		t.Fatal(err)
+	}
+	reconcile(func(pr *tknv1.PipelineRun) {
+		pr.Status.Conditions = []apis.Condition{{Type: apis.ConditionSucceeded, Status: "True"}}
+	})
+
+	want := []string{
+		"progress:queued",
+		"progress:started",
+		"progress:task-completed/build",
+		"progress:task-completed/test",
+		"progress:finished",
+		"finished:finished",
+	}
+	if len(published) != len(want) {
+		t.Fatalf("processPipelineRun() published %v, want %v", published, want)
+	}
+	for i := range want {
+		if published[i] != want[i] {
+			t.Errorf("processPipelineRun() published %v, want %v", published, want)
+			break
+		}
+	}
+
+	pr := &tknv1.PipelineRun{}
+	if err := fakeClient.Get(ctx, client.ObjectKeyFromObject(pipelineRun), pr); err != nil {
+		t.Fatal(err)
+	}
+	refs := getSinkRefs(pr)
+	if refs["pubsub/project/progress"] != "id-task-completed" {
+		t.Errorf("processPipelineRun() sink refs = %v", refs)
+	}
+}
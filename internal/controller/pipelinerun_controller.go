@@ -0,0 +1,94 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"github.com/kcloutie/tekton-observer/internal/replay"
+	"github.com/kcloutie/tekton-observer/internal/tektonobserver"
+	tknv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"go.uber.org/zap/zapcore"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// ReconcilePipelineRun processes a single PipelineRun. The PipelineRuns are reconciled independently of each other
+// so concurrent runs are processed in parallel, up to the maximum number of concurrent reconciles. A PipelineRun
+// whose sinks are throttled is requeued after the wait they asked for.
+func (r *TektonObservationReconciler) ReconcilePipelineRun(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx).WithValues("clusterName", tektonobserver.ControllerConfiguration.GetClusterName())
+
+	pipelineRun := &tknv1.PipelineRun{}
+	if err := r.Get(ctx, req.NamespacedName, pipelineRun); err != nil {
+		// Its likely the pipelineRun was deleted if it does not exist...so just return
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !r.ownsPipelineRun(pipelineRun) {
+		return ctrl.Result{}, nil
+	}
+	complete := pipelineRun.Annotations[tektonobserver.PipelineProcessingStateAnnotation] == tektonobserver.ProcessingCompleteState
+	if complete && !hasObserverFinalizer(pipelineRun) && replay.Requested(pipelineRun) == "" {
+		log.V(3).Info("PipelineRun has already been processed...skipping", "PipelineRun", pipelineRun.Name)
+		return ctrl.Result{}, nil
+	}
+
+	observation, err := r.getOrCreateObservation(ctx, pipelineRun)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if !observation.DeletionTimestamp.IsZero() {
+		log.V(2).Info("TektonObservation is being deleted...skipping", "PipelineRun", pipelineRun.Name)
+		return ctrl.Result{}, nil
+	}
+
+	if replayed, err := r.replayPipelineRun(ctx, log, observation, pipelineRun); replayed || err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if result, skip, err := r.onboardPipelineRun(ctx, log, observation, pipelineRun); skip || err != nil {
+		return result, err
+	}
+
+	err = r.processPipelineRun(ctx, log, observation, pipelineRun)
+	if after, throttled := throttledFor(err); throttled {
+		// The PipelineRun is reconciled again when the throttled sinks accept deliveries, not on the backoff of the
+		// work queue
+		return ctrl.Result{RequeueAfter: after}, nil
+	}
+	return ctrl.Result{}, err
+}
+
+// getOrCreateObservation returns the TektonObservation of the namespace of the PipelineRun, it is created when it
+// does not exist
+func (r *TektonObservationReconciler) getOrCreateObservation(ctx context.Context, pipelineRun *tknv1.PipelineRun) (*obsv1.TektonObservation, error) {
+	observationNamespacedName := types.NamespacedName{
+		Namespace: pipelineRun.Namespace,
+		Name:      tektonobserver.ObservationCrdName,
+	}
+	observation := &obsv1.TektonObservation{}
+	err := r.Get(ctx, observationNamespacedName, observation)
+	if err == nil {
+		return observation, nil
+	}
+	if !apierrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to get the TektonObservation '%s' - %w", observationNamespacedName, err)
+	}
+
+	observation = &obsv1.TektonObservation{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: observationNamespacedName.Namespace,
+			Name:      observationNamespacedName.Name,
+		},
+	}
+	if err := r.Create(ctx, observation, &client.CreateOptions{}); err != nil && !apierrors.IsAlreadyExists(err) {
+		mess := "Failed to create TektonObservation CR"
+		r.EventEmitter.EmitMessagePipelineRun(ctx, pipelineRun, zapcore.ErrorLevel, "Create TektonObservation CR", fmt.Sprintf("%v. %v", mess, err))
+		return nil, fmt.Errorf("%s '%s' - %w", mess, observationNamespacedName, err)
+	}
+	return observation, nil
+}
@@ -0,0 +1,275 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-logr/zapr"
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"github.com/kcloutie/tekton-observer/internal/tektonobserver"
+	"github.com/kcloutie/tekton-observer/pkg/delivery"
+	"github.com/kcloutie/tekton-observer/pkg/events"
+	"github.com/kcloutie/tekton-observer/pkg/throttle"
+	"github.com/kcloutie/tekton-observer/test/utils"
+	tknv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"go.uber.org/zap/zaptest"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func TestTektonObservationReconciler_ReconcilePipelineRun(t *testing.T) {
+	log := zapr.NewLogger(zaptest.NewLogger(t))
+	tests := []struct {
+		name            string
+		annotations     map[string]string
+		isDone          bool
+		wantAnnotation  string
+		wantPublished   int
+		wantObservation bool
+		wantReplayed    string
+	}{
+		{
+			name:            "Test with a new pipelineRun",
+			annotations:     map[string]string{},
+			wantAnnotation:  tektonobserver.ProcessingStartState,
+			wantObservation: true,
+		},
+		{
+			name:            "Test with a new pipelineRun already done",
+			annotations:     map[string]string{},
+			isDone:          true,
+			wantAnnotation:  tektonobserver.ProcessingCompleteState,
+			wantPublished:   1,
+			wantObservation: true,
+		},
+		{
+			name: "Test with a pipelineRun already processed",
+			annotations: map[string]string{
+				tektonobserver.PipelineProcessingStateAnnotation: tektonobserver.ProcessingCompleteState,
+			},
+			isDone:         true,
+			wantAnnotation: tektonobserver.ProcessingCompleteState,
+		},
+		{
+			name: "Test with a replay requested",
+			annotations: map[string]string{
+				tektonobserver.PipelineProcessingStateAnnotation: tektonobserver.ProcessingCompleteState,
+				tektonobserver.ReplayAnnotation:                  "replay-1",
+			},
+			isDone:          true,
+			wantAnnotation:  tektonobserver.ProcessingCompleteState,
+			wantPublished:   1,
+			wantObservation: true,
+			wantReplayed:    "replay-1",
+		},
+		{
+			name: "Test with a replay already delivered",
+			annotations: map[string]string{
+				tektonobserver.PipelineProcessingStateAnnotation: tektonobserver.ProcessingCompleteState,
+				tektonobserver.ReplayAnnotation:                  "replay-1",
+				tektonobserver.ReplayedAnnotation:                "replay-1",
+			},
+			isDone:         true,
+			wantAnnotation: tektonobserver.ProcessingCompleteState,
+			wantReplayed:   "replay-1",
+		},
+		{
+			name: "Test with a replay to an unknown sink",
+			annotations: map[string]string{
+				tektonobserver.PipelineProcessingStateAnnotation: tektonobserver.ProcessingCompleteState,
+				tektonobserver.ReplayAnnotation:                  "replay-1",
+				tektonobserver.ReplaySinkAnnotation:              "pubsub/project/unknown",
+			},
+			isDone:          true,
+			wantAnnotation:  tektonobserver.ProcessingCompleteState,
+			wantObservation: true,
+			wantReplayed:    "replay-1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			pipelineRun := utils.NewPipelineRun("test-namespace", "test-name", tt.annotations, tt.isDone)
+			fakeClient := utils.NewFakeClient(pipelineRun)
+			tektonobserver.ControllerConfiguration.Set(func() tektonobserver.ControllerConfig {
+				config := tektonobserver.DefaultControllerConfig()
+				config.DefaultSinks.PubSubTopics = []obsv1.PubSubTopic{{PubSubProjectID: "project", PubSubTopicID: "topic"}}
+				return config
+			}())
+			defer tektonobserver.ControllerConfiguration.Set(tektonobserver.DefaultControllerConfig())
+
+			published := 0
+			r := &TektonObservationReconciler{
+				Client:       fakeClient,
+				Scheme:       scheme.Scheme,
+				EventEmitter: events.NewEventEmitter(fakeClient, &log, ""),
+				PubSubPublisher: func(ctx context.Context, projectID, topicID string, data []byte, attributes map[string]string, metadata delivery.Metadata) (string, error) {
+					published++
+					return "id", nil
+				},
+			}
+
+			if _, err := r.ReconcilePipelineRun(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(pipelineRun)}); err != nil {
+				t.Fatalf("ReconcilePipelineRun() error = %v", err)
+			}
+
+			pr := &tknv1.PipelineRun{}
+			if err := fakeClient.Get(ctx, client.ObjectKeyFromObject(pipelineRun), pr); err != nil {
+				t.Fatal(err)
+			}
+			if got := pr.Annotations[tektonobserver.PipelineProcessingStateAnnotation]; got != tt.wantAnnotation {
+				t.Errorf("ReconcilePipelineRun() annotation = %v, want %v", got, tt.wantAnnotation)
+			}
+			if got := pr.Annotations[tektonobserver.ReplayedAnnotation]; got != tt.wantReplayed {
+				t.Errorf("ReconcilePipelineRun() replayed = %v, want %v", got, tt.wantReplayed)
+			}
+			if published != tt.wantPublished {
+				t.Errorf("ReconcilePipelineRun() published %d times, want %d", published, tt.wantPublished)
+			}
+			err := fakeClient.Get(ctx, client.ObjectKey{Namespace: "test-namespace", Name: tektonobserver.ObservationCrdName}, &obsv1.TektonObservation{})
+			if (err == nil) != tt.wantObservation {
+				t.Errorf("ReconcilePipelineRun() created the TektonObservation = %v, want %v", err == nil, tt.wantObservation)
+			}
+		})
+	}
+}
+
+func TestTektonObservationReconciler_ReconcilePipelineRun_throttled(t *testing.T) {
+	log := zapr.NewLogger(zaptest.NewLogger(t))
+	tests := []struct {
+		name           string
+		publishErr     error
+		wantErr        bool
+		wantRequeue    time.Duration
+		wantErrorEvent bool
+	}{
+		{
+			name:        "Test with a sink asking to retry later",
+			publishErr:  &throttle.RetryAfterError{After: 30 * time.Second, Err: errors.New("quota exceeded")},
+			wantRequeue: 30 * time.Second,
+		},
+		{
+			name:           "Test with a failing sink",
+			publishErr:     errors.New("boom"),
+			wantErr:        true,
+			wantErrorEvent: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			observation := &obsv1.TektonObservation{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: tektonobserver.ObservationCrdName},
+				Spec: obsv1.TektonObservationSpec{
+					PubSubTopics: []obsv1.PubSubTopic{{PubSubProjectID: "project", PubSubTopicID: "topic"}},
+				},
+			}
+			pipelineRun := utils.NewPipelineRun("test-namespace", "test-name", map[string]string{
+				tektonobserver.PipelineProcessingStateAnnotation: tektonobserver.ProcessingStartState,
+			}, true)
+			pipelineRun.Finalizers = []string{tektonobserver.Finalizer}
+			fakeClient := utils.NewFakeClient(pipelineRun, observation)
+			r := &TektonObservationReconciler{
+				Client:       fakeClient,
+				Scheme:       scheme.Scheme,
+				EventEmitter: events.NewEventEmitter(fakeClient, &log, ""),
+				PubSubPublisher: func(ctx context.Context, projectID, topicID string, data []byte, attributes map[string]string, metadata delivery.Metadata) (string, error) {
+					return "", tt.publishErr
+				},
+			}
+
+			result, err := r.ReconcilePipelineRun(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(pipelineRun)})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ReconcilePipelineRun() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if result.RequeueAfter != tt.wantRequeue {
+				t.Errorf("ReconcilePipelineRun() requeues after %v, want %v", result.RequeueAfter, tt.wantRequeue)
+			}
+			eventList := &corev1.EventList{}
+			if err := fakeClient.List(ctx, eventList, client.InNamespace("test-namespace")); err != nil {
+				t.Fatal(err)
+			}
+			errorEvent := false
+			for _, e := range eventList.Items {
+				if e.Reason == "ProcessPipelineRun" {
+					errorEvent = true
+				}
+			}
+			if errorEvent != tt.wantErrorEvent {
+				t.Errorf("ReconcilePipelineRun() emitted an error event = %v, want %v", errorEvent, tt.wantErrorEvent)
+			}
+		})
+	}
+}
+
+func Test_pipelineRunTransitions(t *testing.T) {
+	running := utils.NewPipelineRun("test-namespace", "test-name", nil, false)
+	running.Status.StartTime = &metav1.Time{Time: time.Now()}
+	done := utils.NewPipelineRun("test-namespace", "test-name", nil, true)
+	done.Status.StartTime = running.Status.StartTime
+	deleted := running.DeepCopy()
+	deleted.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	annotated := running.DeepCopy()
+	annotated.Annotations[tektonobserver.PipelineProcessingStateAnnotation] = tektonobserver.ProcessingStartState
+	replayRequested := done.DeepCopy()
+	replayRequested.Annotations[tektonobserver.ReplayAnnotation] = "replay-1"
+	replayed := replayRequested.DeepCopy()
+	replayed.Annotations[tektonobserver.ReplayedAnnotation] = "replay-1"
+
+	tests := []struct {
+		name   string
+		oldRun *tknv1.PipelineRun
+		newRun *tknv1.PipelineRun
+		want   bool
+	}{
+		{
+			name:   "Test with a pipelineRun starting",
+			oldRun: utils.NewPipelineRun("test-namespace", "test-name", nil, false),
+			newRun: running,
+			want:   true,
+		},
+		{
+			name:   "Test with a pipelineRun finishing",
+			oldRun: running,
+			newRun: done,
+			want:   true,
+		},
+		{
+			name:   "Test with a pipelineRun being deleted",
+			oldRun: running,
+			newRun: deleted,
+			want:   true,
+		},
+		{
+			name:   "Test with the processing state updated",
+			oldRun: running,
+			newRun: annotated,
+			want:   false,
+		},
+		{
+			name:   "Test with a replay requested",
+			oldRun: done,
+			newRun: replayRequested,
+			want:   true,
+		},
+		{
+			name:   "Test with a replay delivered",
+			oldRun: replayRequested,
+			newRun: replayed,
+			want:   false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pipelineRunTransitions().Update(event.UpdateEvent{ObjectOld: tt.oldRun, ObjectNew: tt.newRun}); got != tt.want {
+				t.Errorf("pipelineRunTransitions().Update() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
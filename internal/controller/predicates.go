@@ -0,0 +1,69 @@
+package controller
+
+import (
+	"github.com/kcloutie/tekton-observer/internal/replay"
+	"github.com/kcloutie/tekton-observer/internal/tektonobserver"
+	tknv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// pipelineRunTransitions only lets the events of the meaningful transitions of a PipelineRun through: its creation,
+// its start, its completion, its deletion and the replay requests. The updates made by the observer itself are
+// filtered out.
+func pipelineRunTransitions() predicate.Funcs {
+	return predicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool {
+			return true
+		},
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldRun, ok := e.ObjectOld.(*tknv1.PipelineRun)
+			if !ok {
+				return false
+			}
+			newRun, ok := e.ObjectNew.(*tknv1.PipelineRun)
+			if !ok {
+				return false
+			}
+			started := !oldRun.HasStarted() && newRun.HasStarted()
+			finished := !oldRun.IsDone() && newRun.IsDone()
+			deleted := oldRun.DeletionTimestamp == nil && newRun.DeletionTimestamp != nil
+			key := replay.Requested(newRun)
+			replayRequested := key != "" && key != oldRun.Annotations[tektonobserver.ReplayAnnotation]
+			return started || finished || deleted || replayRequested
+		},
+		// The observed PipelineRuns are deleted once their finalizer is released, there is nothing left to do
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			return false
+		},
+		GenericFunc: func(e event.GenericEvent) bool {
+			return true
+		},
+	}
+}
+
+// taskRunFinished only lets the events of the TaskRuns that just finished through
+func taskRunFinished() predicate.Funcs {
+	return predicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool {
+			return false
+		},
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldRun, ok := e.ObjectOld.(*tknv1.TaskRun)
+			if !ok {
+				return false
+			}
+			newRun, ok := e.ObjectNew.(*tknv1.TaskRun)
+			if !ok {
+				return false
+			}
+			return !oldRun.IsDone() && newRun.IsDone()
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			return false
+		},
+		GenericFunc: func(e event.GenericEvent) bool {
+			return false
+		},
+	}
+}
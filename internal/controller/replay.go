@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"github.com/kcloutie/tekton-observer/internal/replay"
+	"github.com/kcloutie/tekton-observer/internal/tektonobserver"
+	"github.com/kcloutie/tekton-observer/pkg/sinks"
+	tknv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"go.uber.org/zap/zapcore"
+)
+
+// replayPipelineRun delivers the finished phase of a processed PipelineRun again when a replay is requested on it.
+// The replay of a PipelineRun that was not delivered yet is dropped, the PipelineRun is delivered by the regular
+// processing. It returns true when the PipelineRun was replayed and must not be processed any further.
+func (r *TektonObservationReconciler) replayPipelineRun(ctx context.Context, log logr.Logger, observation *obsv1.TektonObservation, pipelineRun *tknv1.PipelineRun) (bool, error) {
+	key := replay.Requested(pipelineRun)
+	if key == "" {
+		return false, nil
+	}
+	log = log.WithValues("PipelineRun", pipelineRun.Name, "replayKey", key)
+
+	if pipelineRun.Annotations[tektonobserver.PipelineProcessingStateAnnotation] != tektonobserver.ProcessingCompleteState {
+		log.V(2).Info("PipelineRun has not been delivered yet...dropping the replay")
+		return false, replay.Complete(ctx, r.Client, pipelineRun, key)
+	}
+
+	sinkList := r.getSinks(observation)
+	if sinkName := pipelineRun.Annotations[tektonobserver.ReplaySinkAnnotation]; sinkName != "" {
+		sinkList = filterSinks(sinkList, sinkName)
+		if len(sinkList) == 0 {
+			mess := fmt.Sprintf("The replay of the PipelineRun '%s' was dropped, the sink '%s' does not exist", pipelineRun.Name, sinkName)
+			log.Info(mess)
+			r.EventEmitter.EmitMessage(ctx, observation, zapcore.WarnLevel, "Replay", mess)
+			return true, replay.Complete(ctx, r.Client, pipelineRun, key)
+		}
+	}
+
+	data, err := r.getPipelineRunData(ctx, pipelineRun)
+	if err != nil || data == nil {
+		return true, err
+	}
+	if data.Attributes == nil {
+		data.Attributes = map[string]string{}
+	}
+	data.Attributes[replay.ReplayAttribute] = "true"
+	data.Attributes[replay.KeyAttribute] = key
+
+	// The replayed messages are new messages, they do not update the messages sent earlier. Their delivery ID is
+	// derived from the replay key, so only the deliveries of the same replay are deduplicated.
+	attempts := getDeliveryAttempts(pipelineRun)
+	err = r.deliver(ctx, log, observation, sinkList, sinks.Event{Phase: obsv1.PhaseFinished, Data: data, ReplayKey: key}, map[string]string{}, attempts)
+	if recordErr := r.recordDeliveryAttempts(ctx, pipelineRun, attempts); recordErr != nil {
+		err = errors.Join(err, recordErr)
+	}
+	if err != nil {
+		mess := fmt.Sprintf("Failed to replay the PipelineRun '%s'", pipelineRun.Name)
+		log.Error(err, mess)
+		r.EventEmitter.EmitMessage(ctx, observation, zapcore.ErrorLevel, "Replay", fmt.Sprintf("%v. %v", mess, err))
+		return true, err
+	}
+	log.V(1).Info("PipelineRun replayed")
+	return true, replay.Complete(ctx, r.Client, pipelineRun, key)
+}
+
+// filterSinks returns the sinks with the name
+func filterSinks(sinkList []sinks.Sink, name string) []sinks.Sink {
+	result := []sinks.Sink{}
+	for _, sink := range sinkList {
+		if sink.Name() == name {
+			result = append(result, sink)
+		}
+	}
+	return result
+}
@@ -0,0 +1,142 @@
+package controller
+
+import (
+	"context"
+	"slices"
+
+	"github.com/go-logr/logr"
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"github.com/kcloutie/tekton-observer/internal/tektonobserver"
+	"github.com/kcloutie/tekton-observer/pkg/metrics"
+	"github.com/kcloutie/tekton-observer/pkg/tekton"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline"
+	tknv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/apis"
+)
+
+// otherPipeline is the pipeline the PipelineRuns of the pipelines left out of the allow-list are recorded under
+const otherPipeline = "other"
+
+// recordRunMetrics records the duration and outcome metrics of the complete PipelineRun and, when the observation
+// asks for them, of its TaskRuns. The backfilled PipelineRuns finished before the observation and are not recorded.
+func (r *TektonObservationReconciler) recordRunMetrics(ctx context.Context, log logr.Logger, observation *obsv1.TektonObservation, pipelineRun *tknv1.PipelineRun, data *tekton.PipelineRunData) {
+	policy := observation.Spec.Metrics
+	if (policy != nil && policy.Disabled) || pipelineRun.Annotations[tektonobserver.BackfillAnnotation] == "true" {
+		return
+	}
+	histograms := metrics.RunHistograms.For(data.Namespace, getRunBuckets(policy))
+	labels := getRunLabels(policy, data, data.Status)
+	metrics.PipelineRunsTotal.WithLabelValues(labels...).Inc()
+	if seconds, ok := elapsed(data.StartTime, data.CompletionTime); ok {
+		histograms.PipelineRunDuration.WithLabelValues(labels...).Observe(seconds)
+	}
+	if seconds, ok := elapsed(&pipelineRun.CreationTimestamp, data.StartTime); ok {
+		histograms.PipelineRunQueue.WithLabelValues(labels...).Observe(seconds)
+	}
+	if policy == nil || !policy.TaskRuns {
+		return
+	}
+
+	taskRuns, err := r.getTaskRuns(ctx, data)
+	if err != nil {
+		log.Error(err, "Failed to get the TaskRuns to record their metrics")
+		return
+	}
+	for i := range taskRuns {
+		taskRun := &taskRuns[i]
+		taskName := taskRun.Labels[pipeline.PipelineTaskLabelKey]
+		if taskName == "" || !taskRun.IsDone() {
+			continue
+		}
+		status := tekton.StatusFailed
+		if taskRun.Status.GetCondition(apis.ConditionSucceeded).IsTrue() {
+			status = tekton.StatusSucceeded
+		}
+		taskLabels := append(getRunLabels(policy, data, status), taskName)
+		metrics.TaskRunsTotal.WithLabelValues(taskLabels...).Inc()
+		if seconds, ok := elapsed(taskRun.Status.StartTime, taskRun.Status.CompletionTime); ok {
+			histograms.TaskRunDuration.WithLabelValues(taskLabels...).Observe(seconds)
+		}
+		if seconds, ok := elapsed(taskRun.Status.StartTime, getFirstStepStartTime(taskRun)); ok {
+			histograms.TaskRunQueue.WithLabelValues(taskLabels...).Observe(seconds)
+		}
+	}
+}
+
+// getRunLabels returns the values of metrics.RunLabelNames for the PipelineRun, or one of its TaskRuns, with the status
+func getRunLabels(policy *obsv1.RunMetricsPolicy, data *tekton.PipelineRunData, status string) []string {
+	pipelineName := data.PipelineName
+	var enabled []obsv1.MetricLabel
+	if policy != nil {
+		if len(policy.Pipelines) > 0 && !slices.Contains(policy.Pipelines, pipelineName) {
+			pipelineName = otherPipeline
+		}
+		enabled = policy.Labels
+	}
+	optional := func(label obsv1.MetricLabel, pacLabel string) string {
+		if !slices.Contains(enabled, label) {
+			return ""
+		}
+		return data.PacLabels[pacLabel]
+	}
+	return []string{
+		data.Namespace,
+		pipelineName,
+		status,
+		optional(obsv1.MetricLabelRepository, "repository"),
+		optional(obsv1.MetricLabelEventType, "event-type"),
+		optional(obsv1.MetricLabelBranch, "branch"),
+	}
+}
+
+// getRunBuckets returns the bucket layouts of the histograms of the policy in seconds
+func getRunBuckets(policy *obsv1.RunMetricsPolicy) metrics.Buckets {
+	buckets := metrics.Buckets{Duration: metrics.DefaultDurationBuckets, Queue: metrics.DefaultQueueBuckets}
+	if policy == nil {
+		return buckets
+	}
+	if len(policy.DurationBuckets) > 0 {
+		buckets.Duration = toSeconds(policy.DurationBuckets)
+	}
+	if len(policy.QueueBuckets) > 0 {
+		buckets.Queue = toSeconds(policy.QueueBuckets)
+	}
+	return buckets
+}
+
+func toSeconds(durations []metav1.Duration) []float64 {
+	seconds := []float64{}
+	for _, duration := range durations {
+		seconds = append(seconds, duration.Seconds())
+	}
+	slices.Sort(seconds)
+	return slices.Compact(seconds)
+}
+
+// getFirstStepStartTime returns when the first step of the TaskRun started, the time before it is spent waiting for
+// its pod to be scheduled and its images to be pulled
+func getFirstStepStartTime(taskRun *tknv1.TaskRun) *metav1.Time {
+	var first *metav1.Time
+	for _, step := range taskRun.Status.Steps {
+		var started *metav1.Time
+		switch {
+		case step.Running != nil:
+			started = &step.Running.StartedAt
+		case step.Terminated != nil:
+			started = &step.Terminated.StartedAt
+		}
+		if started != nil && !started.IsZero() && (first == nil || started.Before(first)) {
+			first = started
+		}
+	}
+	return first
+}
+
+// elapsed returns the seconds between the times when they are both set
+func elapsed(from, to *metav1.Time) (float64, bool) {
+	if from == nil || to == nil || from.IsZero() || to.IsZero() || to.Before(from) {
+		return 0, false
+	}
+	return to.Sub(from.Time).Seconds(), true
+}
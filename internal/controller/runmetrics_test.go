@@ -0,0 +1,120 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-logr/zapr"
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"github.com/kcloutie/tekton-observer/internal/tektonobserver"
+	"github.com/kcloutie/tekton-observer/pkg/metrics"
+	"github.com/kcloutie/tekton-observer/pkg/tekton"
+	"github.com/kcloutie/tekton-observer/test/utils"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	tknv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"go.uber.org/zap/zaptest"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestTektonObservationReconciler_recordRunMetrics(t *testing.T) {
+	log := zapr.NewLogger(zaptest.NewLogger(t))
+	tests := []struct {
+		name          string
+		policy        *obsv1.RunMetricsPolicy
+		backfill      bool
+		wantLabels    []string
+		wantTaskLabel []string
+		wantRecorded  bool
+	}{
+		{
+			name:         "Test with the default policy",
+			wantLabels:   []string{"namespace-default", "build", tekton.StatusSucceeded, "", "", ""},
+			wantRecorded: true,
+		},
+		{
+			name: "Test with optional labels and task runs",
+			policy: &obsv1.RunMetricsPolicy{
+				Labels:   []obsv1.MetricLabel{obsv1.MetricLabelRepository, obsv1.MetricLabelEventType},
+				TaskRuns: true,
+			},
+			wantLabels:    []string{"namespace-labels", "build", tekton.StatusSucceeded, "my-repo", "push", ""},
+			wantTaskLabel: []string{"namespace-labels", "build", tekton.StatusSucceeded, "my-repo", "push", "", "unit-tests"},
+			wantRecorded:  true,
+		},
+		{
+			name:         "Test with a pipeline left out of the allow-list",
+			policy:       &obsv1.RunMetricsPolicy{Pipelines: []string{"deploy"}},
+			wantLabels:   []string{"namespace-allow-list", otherPipeline, tekton.StatusSucceeded, "", "", ""},
+			wantRecorded: true,
+		},
+		{
+			name:       "Test with the metrics disabled",
+			policy:     &obsv1.RunMetricsPolicy{Disabled: true},
+			wantLabels: []string{"namespace-disabled", "build", tekton.StatusSucceeded, "", "", ""},
+		},
+		{
+			name:       "Test with a backfilled PipelineRun",
+			backfill:   true,
+			wantLabels: []string{"namespace-backfill", "build", tekton.StatusSucceeded, "", "", ""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			namespace := tt.wantLabels[0]
+			defer metrics.ForgetNamespace(namespace)
+			observation := &obsv1.TektonObservation{
+				ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: tektonobserver.ObservationCrdName},
+				Spec:       obsv1.TektonObservationSpec{Metrics: tt.policy},
+			}
+			start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			pipelineRun := utils.NewPipelineRun(namespace, "test-name", map[string]string{}, true)
+			if tt.backfill {
+				pipelineRun.Annotations[tektonobserver.BackfillAnnotation] = "true"
+			}
+			pipelineRun.CreationTimestamp = metav1.Time{Time: start.Add(-time.Minute)}
+			pipelineRun.Status.StartTime = &metav1.Time{Time: start}
+			pipelineRun.Status.CompletionTime = &metav1.Time{Time: start.Add(10 * time.Minute)}
+			taskRun := newTaskRun(pipelineRun, "unit-tests")
+			taskRun.Status.StartTime = &metav1.Time{Time: start}
+			taskRun.Status.CompletionTime = &metav1.Time{Time: start.Add(5 * time.Minute)}
+			taskRun.Status.Steps = []tknv1.StepState{{ContainerState: corev1.ContainerState{
+				Terminated: &corev1.ContainerStateTerminated{StartedAt: metav1.Time{Time: start.Add(30 * time.Second)}},
+			}}}
+			fakeClient := utils.NewFakeClient(pipelineRun, taskRun)
+			r := &TektonObservationReconciler{Client: fakeClient}
+			data := &tekton.PipelineRunData{
+				Namespace:       namespace,
+				PipelineRunName: pipelineRun.Name,
+				PipelineName:    "build",
+				PacLabels:       map[string]string{"repository": "my-repo", "event-type": "push"},
+				Status:          tekton.StatusSucceeded,
+				StartTime:       pipelineRun.Status.StartTime,
+				CompletionTime:  pipelineRun.Status.CompletionTime,
+			}
+
+			r.recordRunMetrics(ctx, log, observation, pipelineRun, data)
+
+			want := 0.0
+			if tt.wantRecorded {
+				want = 1
+			}
+			if got := testutil.ToFloat64(metrics.PipelineRunsTotal.WithLabelValues(tt.wantLabels...)); got != want {
+				t.Errorf("recordRunMetrics() pipeline runs = %v, want %v", got, want)
+			}
+			if tt.wantTaskLabel != nil {
+				if got := testutil.ToFloat64(metrics.TaskRunsTotal.WithLabelValues(tt.wantTaskLabel...)); got != 1 {
+					t.Errorf("recordRunMetrics() task runs = %v, want 1", got)
+				}
+			}
+			if tt.wantRecorded {
+				histograms := metrics.RunHistograms.For(namespace, getRunBuckets(tt.policy))
+				if got := testutil.CollectAndCount(histograms.PipelineRunDuration); got < 1 {
+					t.Errorf("recordRunMetrics() duration series = %v, want at least 1", got)
+				}
+			}
+		})
+	}
+}
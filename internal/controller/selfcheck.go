@@ -0,0 +1,101 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/go-logr/logr"
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"github.com/kcloutie/tekton-observer/internal/tektonobserver"
+	tknv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// SelfCheck verifies that the dependencies of the controller are available. The checks are logged once on startup
+// and reported through the readiness probe.
+type SelfCheck struct {
+	Reader client.Reader
+	Mapper meta.RESTMapper
+	// CheckPubSubCredentials verifies the Pub/Sub credentials resolve, gcp.CheckPubSubCredentials is used in main
+	CheckPubSubCredentials func(ctx context.Context) error
+	Log                    logr.Logger
+
+	mu                sync.Mutex
+	credentialsPassed bool
+}
+
+// PipelineRunCRD implements healthz.Checker and fails when the Tekton PipelineRun CRD is not installed
+func (s *SelfCheck) PipelineRunCRD(_ *http.Request) error {
+	gk := schema.GroupKind{Group: tknv1.SchemeGroupVersion.Group, Kind: "PipelineRun"}
+	if _, err := s.Mapper.RESTMapping(gk, tknv1.SchemeGroupVersion.Version); err != nil {
+		return fmt.Errorf("the Tekton %s/%s CRD is not installed - %w", tknv1.SchemeGroupVersion.String(), gk.Kind, err)
+	}
+	return nil
+}
+
+// PubSubCredentials implements healthz.Checker and fails when Pub/Sub is used but the credentials do not resolve.
+// Once the credentials resolved they are not checked again.
+func (s *SelfCheck) PubSubCredentials(req *http.Request) error {
+	return s.checkPubSubCredentials(req.Context())
+}
+
+func (s *SelfCheck) checkPubSubCredentials(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.credentialsPassed {
+		return nil
+	}
+
+	inUse, err := s.pubSubInUse(ctx)
+	if err != nil {
+		return err
+	}
+	if !inUse {
+		return nil
+	}
+	if err := s.CheckPubSubCredentials(ctx); err != nil {
+		return err
+	}
+	s.credentialsPassed = true
+	return nil
+}
+
+func (s *SelfCheck) pubSubInUse(ctx context.Context) (bool, error) {
+	if len(tektonobserver.ControllerConfiguration.GetDefaultPubSubTopics()) > 0 {
+		return true, nil
+	}
+	observations := &obsv1.TektonObservationList{}
+	if err := s.Reader.List(ctx, observations); err != nil {
+		return false, fmt.Errorf("failed to list the TektonObservations - %w", err)
+	}
+	for _, observation := range observations.Items {
+		if len(observation.Spec.PubSubTopics) > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Start implements manager.Runnable and logs the result of every check once the manager started
+func (s *SelfCheck) Start(ctx context.Context) error {
+	if err := s.PipelineRunCRD(nil); err != nil {
+		s.Log.Error(err, "Self check failed, the controller will not be ready until the Tekton CRDs are installed")
+	} else {
+		s.Log.Info("Self check passed", "check", "tekton-crd")
+	}
+	if err := s.checkPubSubCredentials(ctx); err != nil {
+		s.Log.Error(err, "Self check failed, the controller will not be ready until the Pub/Sub credentials resolve")
+	} else {
+		s.Log.Info("Self check passed", "check", "pubsub-credentials")
+	}
+	return nil
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable, every replica reports its own readiness
+func (s *SelfCheck) NeedLeaderElection() bool {
+	return false
+}
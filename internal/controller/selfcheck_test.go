@@ -0,0 +1,139 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"github.com/kcloutie/tekton-observer/internal/tektonobserver"
+	"github.com/kcloutie/tekton-observer/test/utils"
+	tknv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestSelfCheck_PipelineRunCRD(t *testing.T) {
+	tests := []struct {
+		name    string
+		kinds   []schema.GroupVersionKind
+		wantErr string
+	}{
+		{
+			name:  "Test with the Tekton v1 PipelineRun kind",
+			kinds: []schema.GroupVersionKind{tknv1.SchemeGroupVersion.WithKind("PipelineRun")},
+		},
+		{
+			name:    "Test with the CRD missing",
+			wantErr: "the Tekton tekton.dev/v1/PipelineRun CRD is not installed",
+		},
+		{
+			name:    "Test with another version of the CRD only",
+			kinds:   []schema.GroupVersionKind{{Group: tknv1.SchemeGroupVersion.Group, Version: "v1beta1", Kind: "PipelineRun"}},
+			wantErr: "the Tekton tekton.dev/v1/PipelineRun CRD is not installed",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapper := meta.NewDefaultRESTMapper(nil)
+			for _, gvk := range tt.kinds {
+				mapper.Add(gvk, meta.RESTScopeNamespace)
+			}
+			s := &SelfCheck{Mapper: mapper}
+
+			err := s.PipelineRunCRD(httptest.NewRequest("GET", "/readyz", nil))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("PipelineRunCRD() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("PipelineRunCRD() unexpected error = %v", err)
+			}
+		})
+	}
+}
+
+func TestSelfCheck_PubSubCredentials(t *testing.T) {
+	withTopic := &obsv1.TektonObservation{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: tektonobserver.ObservationCrdName},
+		Spec:       obsv1.TektonObservationSpec{PubSubTopics: []obsv1.PubSubTopic{{PubSubProjectID: "project", PubSubTopicID: "topic"}}},
+	}
+	withoutTopic := &obsv1.TektonObservation{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "other-namespace", Name: tektonobserver.ObservationCrdName},
+	}
+	tests := []struct {
+		name          string
+		objects       []runtime.Object
+		defaultTopics []obsv1.PubSubTopic
+		// results are returned by the credential checker in turn, the last one is repeated
+		results []error
+		// wantErrs has an entry per readiness check
+		wantErrs  []bool
+		wantCalls int
+	}{
+		{
+			name:      "Test with Pub/Sub not used",
+			objects:   []runtime.Object{withoutTopic},
+			results:   []error{nil},
+			wantErrs:  []bool{false, false},
+			wantCalls: 0,
+		},
+		{
+			name:      "Test with credentials failing then resolving",
+			objects:   []runtime.Object{withTopic, withoutTopic},
+			results:   []error{errors.New("could not find default credentials"), nil},
+			wantErrs:  []bool{true, false, false},
+			wantCalls: 2,
+		},
+		{
+			name:          "Test with default topics and resolving credentials",
+			defaultTopics: []obsv1.PubSubTopic{{PubSubProjectID: "project", PubSubTopicID: "topic"}},
+			results:       []error{nil},
+			wantErrs:      []bool{false, false},
+			wantCalls:     1,
+		},
+		{
+			name:      "Test with credentials failing",
+			objects:   []runtime.Object{withTopic},
+			results:   []error{errors.New("could not find default credentials"), errors.New("could not find default credentials")},
+			wantErrs:  []bool{true, true, true},
+			wantCalls: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tektonobserver.ControllerConfiguration.Set(func() tektonobserver.ControllerConfig {
+				config := tektonobserver.DefaultControllerConfig()
+				config.DefaultSinks.PubSubTopics = tt.defaultTopics
+				return config
+			}())
+			defer tektonobserver.ControllerConfiguration.Set(tektonobserver.DefaultControllerConfig())
+
+			calls := 0
+			s := &SelfCheck{
+				Reader: utils.NewFakeClient(tt.objects...),
+				CheckPubSubCredentials: func(ctx context.Context) error {
+					result := tt.results[min(calls, len(tt.results)-1)]
+					calls++
+					return result
+				},
+			}
+
+			for i, wantErr := range tt.wantErrs {
+				err := s.PubSubCredentials(httptest.NewRequest("GET", "/readyz", nil))
+				if (err != nil) != wantErr {
+					t.Errorf("PubSubCredentials() check %d error = %v, wantErr %v", i+1, err, wantErr)
+				}
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("PubSubCredentials() checked the credentials %d times, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
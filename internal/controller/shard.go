@@ -0,0 +1,33 @@
+package controller
+
+import (
+	"github.com/kcloutie/tekton-observer/internal/sharding"
+	tknv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+// ownsPipelineRun returns true when the PipelineRun belongs to the shard of this replica, every PipelineRun is owned
+// when sharding is disabled
+func (r *TektonObservationReconciler) ownsPipelineRun(pipelineRun client.Object) bool {
+	return r.Sharder.Owns(pipelineRun)
+}
+
+// ownsNamespace returns true when the digests and the DORA summary of the namespace are sent by this replica, every
+// namespace is owned when sharding is disabled
+func (r *TektonObservationReconciler) ownsNamespace(namespace string) bool {
+	return r.Sharder.OwnsNamespace(namespace)
+}
+
+// shardChanges returns a channel receiving an event every time the shard members change. The events are coalesced,
+// a single pending event re-enqueues every pending PipelineRun.
+func shardChanges(sharder *sharding.Sharder) <-chan event.GenericEvent {
+	changes := make(chan event.GenericEvent, 1)
+	sharder.Membership.OnChange(func(_ []string) {
+		select {
+		case changes <- event.GenericEvent{Object: &tknv1.PipelineRun{}}:
+		default:
+		}
+	})
+	return changes
+}
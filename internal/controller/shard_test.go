@@ -0,0 +1,135 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-logr/zapr"
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"github.com/kcloutie/tekton-observer/internal/sharding"
+	"github.com/kcloutie/tekton-observer/test/utils"
+	"go.uber.org/zap/zaptest"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// newSharders returns a sharder per identity, their members are the replicas of the identities
+func newSharders(t *testing.T, c client.Client, identities ...string) []*sharding.Sharder {
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	t.Cleanup(func() {
+		cancel()
+		wg.Wait()
+	})
+	for _, identity := range identities {
+		lease := &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "tekton-observer",
+				Name:      fmt.Sprintf("test-%s", identity),
+				Labels:    map[string]string{sharding.ShardGroupLabel: "test"},
+			},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       ptr.To(identity),
+				LeaseDurationSeconds: ptr.To(int32(3600)),
+				RenewTime:            &metav1.MicroTime{Time: time.Now()},
+			},
+		}
+		if err := c.Create(ctx, lease); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result := []*sharding.Sharder{}
+	for _, identity := range identities {
+		membership := &sharding.Membership{
+			Client:        c,
+			Reader:        c,
+			Namespace:     "tekton-observer",
+			Group:         "test",
+			Identity:      identity,
+			LeaseDuration: time.Hour,
+			RenewInterval: time.Hour,
+			Log:           zapr.NewLogger(zaptest.NewLogger(t)),
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = membership.Start(ctx)
+		}()
+		deadline := time.Now().Add(5 * time.Second)
+		for members, synced := membership.Members(); !synced || len(members) != len(identities); members, synced = membership.Members() {
+			if time.Now().After(deadline) {
+				t.Fatalf("the members of %s were not listed", identity)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		result = append(result, &sharding.Sharder{Mode: sharding.ModeUID, Membership: membership})
+	}
+	return result
+}
+
+func TestTektonObservationReconciler_findObservationsForShardChange(t *testing.T) {
+	fakeClient := utils.NewFakeClient()
+	namespaces := []string{}
+	for i := 0; i < 10; i++ {
+		namespace := fmt.Sprintf("namespace-%d", i)
+		namespaces = append(namespaces, namespace)
+		observation := &obsv1.TektonObservation{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: "observation"}}
+		if err := fakeClient.Create(context.Background(), observation); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sharders := newSharders(t, fakeClient, "replica-a", "replica-b")
+
+	tests := []struct {
+		name    string
+		sharder *sharding.Sharder
+		want    []string
+	}{
+		{
+			name: "Test without sharding",
+			want: namespaces,
+		},
+		{
+			name:    "Test with the first replica",
+			sharder: sharders[0],
+			want:    ownedNamespaces(sharders[0], namespaces),
+		},
+		{
+			name:    "Test with the second replica",
+			sharder: sharders[1],
+			want:    ownedNamespaces(sharders[1], namespaces),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &TektonObservationReconciler{Client: fakeClient, Sharder: tt.sharder}
+			requests := r.findObservationsForShardChange(context.Background(), nil)
+			got := []string{}
+			for _, request := range requests {
+				got = append(got, request.Namespace)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("findObservationsForShardChange() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if len(ownedNamespaces(sharders[0], namespaces))+len(ownedNamespaces(sharders[1], namespaces)) != len(namespaces) {
+		t.Errorf("the namespaces are not owned by a single replica")
+	}
+}
+
+func ownedNamespaces(sharder *sharding.Sharder, namespaces []string) []string {
+	result := []string{}
+	for _, namespace := range namespaces {
+		if sharder.OwnsNamespace(namespace) {
+			result = append(result, namespace)
+		}
+	}
+	return result
+}
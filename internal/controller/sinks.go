@@ -0,0 +1,346 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"github.com/kcloutie/tekton-observer/internal/outbox"
+	"github.com/kcloutie/tekton-observer/internal/tektonobserver"
+	"github.com/kcloutie/tekton-observer/pkg/delivery"
+	"github.com/kcloutie/tekton-observer/pkg/gcp"
+	"github.com/kcloutie/tekton-observer/pkg/kafka"
+	"github.com/kcloutie/tekton-observer/pkg/metrics"
+	"github.com/kcloutie/tekton-observer/pkg/nats"
+	"github.com/kcloutie/tekton-observer/pkg/otlp"
+	"github.com/kcloutie/tekton-observer/pkg/sinks"
+	"github.com/kcloutie/tekton-observer/pkg/tekton"
+	"github.com/kcloutie/tekton-observer/pkg/throttle"
+	tknv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"golang.org/x/time/rate"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get
+
+// PubSubPublisher publishes a message to a Pub/Sub topic and returns the ID of the published message
+type PubSubPublisher = sinks.PubSubPublisher
+
+func (r *TektonObservationReconciler) pubSubPublisher() PubSubPublisher {
+	if r.PubSubPublisher != nil {
+		return r.PubSubPublisher
+	}
+	return gcp.PublishEvent
+}
+
+// KafkaProducer writes a message to a Kafka topic
+type KafkaProducer = sinks.KafkaProducer
+
+func (r *TektonObservationReconciler) kafkaProducer() KafkaProducer {
+	if r.KafkaProducer != nil {
+		return r.KafkaProducer
+	}
+	return kafka.Produce
+}
+
+// NATSPublisher publishes a message to JetStream and returns the acknowledgement of the stream
+type NATSPublisher = sinks.NATSPublisher
+
+func (r *TektonObservationReconciler) natsPublisher() NATSPublisher {
+	if r.NATSPublisher != nil {
+		return r.NATSPublisher
+	}
+	return nats.Publish
+}
+
+// OTLPExporterFactory creates the exporter of the spans to an OTLP receiver
+type OTLPExporterFactory = sinks.OTLPExporterFactory
+
+func (r *TektonObservationReconciler) otlpExporterFactory() OTLPExporterFactory {
+	if r.OTLPExporterFactory != nil {
+		return r.OTLPExporterFactory
+	}
+	return otlp.NewSpanExporter
+}
+
+func (r *TektonObservationReconciler) runLogger() logr.Logger {
+	if r.RunLogger.GetSink() != nil {
+		return r.RunLogger
+	}
+	return ctrl.Log.WithName("pipelineruns")
+}
+
+// getPubSubTopics returns the topics defined on the observation followed by the default topics of the controller
+func getPubSubTopics(observation *obsv1.TektonObservation) []obsv1.PubSubTopic {
+	topics := append([]obsv1.PubSubTopic{}, observation.Spec.PubSubTopics...)
+	return append(topics, tektonobserver.ControllerConfiguration.GetDefaultPubSubTopics()...)
+}
+
+// getSinks returns every sink the PipelineRuns of the observation are delivered to
+func (r *TektonObservationReconciler) getSinks(observation *obsv1.TektonObservation) []sinks.Sink {
+	result := []sinks.Sink{}
+	for _, topic := range getPubSubTopics(observation) {
+		result = append(result, r.throttleSink(observation, &sinks.PubSubSink{Topic: topic, Publisher: r.pubSubPublisher()}))
+	}
+	for _, topic := range observation.Spec.KafkaTopics {
+		result = append(result, r.throttleSink(observation, &sinks.KafkaSink{
+			Topic:     topic,
+			Namespace: observation.Namespace,
+			Producer:  r.kafkaProducer(),
+			Secrets:   r.secretReader(),
+		}))
+	}
+	for _, subject := range observation.Spec.NATSSubjects {
+		result = append(result, r.throttleSink(observation, &sinks.NATSSink{
+			Subject:   subject,
+			Namespace: observation.Namespace,
+			Publisher: r.natsPublisher(),
+			Secrets:   r.secretReader(),
+		}))
+	}
+	for _, exporter := range observation.Spec.OTLPTraces {
+		result = append(result, r.throttleSink(observation, &sinks.OTLPSink{
+			Exporter:    exporter,
+			Namespace:   observation.Namespace,
+			NewExporter: r.otlpExporterFactory(),
+			TaskRuns:    r.getTaskRuns,
+			Secrets:     r.secretReader(),
+		}))
+	}
+	if observation.Spec.Log != nil {
+		result = append(result, r.throttleSink(observation, &sinks.LogSink{Config: *observation.Spec.Log, Log: r.runLogger()}))
+	}
+	return result
+}
+
+// secretReader reads the Secrets of the sinks from the API server so they are not cached
+func (r *TektonObservationReconciler) secretReader() client.Reader {
+	if r.APIReader != nil {
+		return r.APIReader
+	}
+	return r.Client
+}
+
+const (
+	defaultRateLimitMaxWait        = 10 * time.Second
+	defaultBreakerFailureThreshold = 5
+	defaultBreakerCoolOff          = time.Minute
+	// minThrottledRequeue is the shortest wait before a throttled PipelineRun is reconciled again
+	minThrottledRequeue = time.Second
+)
+
+// throttleSink wraps the sink with the rate limiter of its type and its circuit breaker, configured by the rate
+// limits of the observation
+func (r *TektonObservationReconciler) throttleSink(observation *obsv1.TektonObservation, sink sinks.Sink) sinks.Sink {
+	limit, burst, maxWait := rate.Inf, 1, defaultRateLimitMaxWait
+	threshold, coolOff := 0, defaultBreakerCoolOff
+	if rateLimit := getRateLimit(observation, sink.Type()); rateLimit != nil {
+		if rateLimit.RequestsPerMinute > 0 {
+			limit = rate.Every(time.Minute / time.Duration(rateLimit.RequestsPerMinute))
+		}
+		if rateLimit.Burst > 0 {
+			burst = int(rateLimit.Burst)
+		}
+		if rateLimit.MaxWait != nil {
+			maxWait = rateLimit.MaxWait.Duration
+		}
+		if breaker := rateLimit.CircuitBreaker; breaker != nil {
+			threshold = defaultBreakerFailureThreshold
+			if breaker.FailureThreshold > 0 {
+				threshold = int(breaker.FailureThreshold)
+			}
+			if breaker.CoolOff != nil {
+				coolOff = breaker.CoolOff.Duration
+			}
+		}
+	}
+	return &sinks.ThrottledSink{
+		Sink:    sink,
+		Limiter: r.sinkThrottles.Limiter(observation.Namespace, string(sink.Type()), limit, burst, maxWait),
+		Breaker: r.sinkThrottles.Breaker(observation.Namespace, sink.Name(), threshold, coolOff),
+	}
+}
+
+// getRateLimit returns the rate limit of the sink type, it is nil when the observation does not limit the type
+func getRateLimit(observation *obsv1.TektonObservation, sinkType obsv1.SinkType) *obsv1.SinkRateLimit {
+	for i := range observation.Spec.RateLimits {
+		if observation.Spec.RateLimits[i].SinkType == sinkType {
+			return &observation.Spec.RateLimits[i]
+		}
+	}
+	return nil
+}
+
+// throttledFor returns how long to wait before delivering again when every failure of err is a throttled delivery,
+// rejected by the rate limit or the circuit breaker of the sink or by the sink itself with a Retry-After. The longest
+// wait is returned so every throttled sink accepts the next attempt.
+func throttledFor(err error) (time.Duration, bool) {
+	for err != nil {
+		if retryAfterErr, ok := err.(*throttle.RetryAfterError); ok {
+			return max(retryAfterErr.After, minThrottledRequeue), true
+		}
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			var longest time.Duration
+			for _, e := range joined.Unwrap() {
+				after, throttled := throttledFor(e)
+				if !throttled {
+					return 0, false
+				}
+				longest = max(longest, after)
+			}
+			return longest, longest > 0
+		}
+		err = errors.Unwrap(err)
+	}
+	return 0, false
+}
+
+// subscribed returns true when one of the sinks subscribed to the phase
+func subscribed(sinkList []sinks.Sink, phase obsv1.Phase) bool {
+	for _, sink := range sinkList {
+		if sink.Subscribed(phase) {
+			return true
+		}
+	}
+	return false
+}
+
+// deadLetteredAttempts marks in the delivery attempts the deliveries that were dead-lettered
+const deadLetteredAttempts = -1
+
+// deliver sends the event to every sink subscribed to its phase. The references returned by the sinks are stored in
+// refs, the reference of the previous phase is passed to each sink. Every delivery carries a deterministic delivery
+// ID and its attempt number, the failed attempts are counted in attempts by delivery ID. When the DeliveryOutbox
+// feature gate is enabled the failed deliveries are added to the outbox of the observation instead of failing.
+// Otherwise a delivery that is not throttled and exhausted the retries of the retry policy is dead-lettered and no
+// longer attempted.
+func (r *TektonObservationReconciler) deliver(ctx context.Context, log logr.Logger, observation *obsv1.TektonObservation, sinkList []sinks.Sink, event sinks.Event, refs map[string]string, attempts map[string]int) error {
+	useOutbox := tektonobserver.ControllerConfiguration.IsFeatureEnabled(tektonobserver.DeliveryOutbox)
+	policy := tektonobserver.ControllerConfiguration.GetRetryPolicy()
+	var errs []error
+	for _, sink := range sinkList {
+		if !sink.Subscribed(event.Phase) {
+			continue
+		}
+		sinkEvent := event
+		sinkEvent.Ref = refs[sink.Name()]
+		id := delivery.ID(getPipelineRunUID(event.Data), deliveryKey(event), sink.Name())
+		if attempts[id] == deadLetteredAttempts {
+			log.V(2).Info("Delivery dead-lettered, skipping", "sink", sink.Name(), "phase", event.Phase, "taskName", event.TaskName, "deliveryId", id)
+			continue
+		}
+		sinkEvent.Delivery = delivery.Metadata{ID: id, Attempt: attempts[id] + 1}
+		ref, err := r.deliverToSink(ctx, log, observation, sink, sinkEvent)
+		if err != nil {
+			err = &sinkError{sink: sink.Name(), err: fmt.Errorf("failed to deliver the %s phase to the sink '%s' - %w", event.Phase, sink.Name(), err)}
+			if useOutbox {
+				enqueueErr := outbox.Enqueue(ctx, r.Client, observation, sink.Name(), sinkEvent, err, time.Now())
+				if enqueueErr == nil {
+					log.Info("Delivery added to the outbox", "sink", sink.Name(), "phase", event.Phase, "taskName", event.TaskName, "deliveryId", id, "error", err.Error())
+					metrics.OutboxEnqueuedTotal.WithLabelValues(sink.Name()).Inc()
+					delete(attempts, id)
+					continue
+				}
+				err = &sinkError{sink: sink.Name(), err: errors.Join(err, enqueueErr)}
+			}
+			attempts[id]++
+			if _, throttled := throttledFor(err); !throttled && outbox.Exhausted(policy, attempts[id]) {
+				if deadLetterErr := r.deadLetterExhausted(ctx, log, observation, sink.Name(), sinkEvent, err); deadLetterErr != nil {
+					errs = append(errs, &sinkError{sink: sink.Name(), err: errors.Join(err, deadLetterErr)})
+					continue
+				}
+				attempts[id] = deadLetteredAttempts
+				continue
+			}
+			errs = append(errs, err)
+			continue
+		}
+		delete(attempts, id)
+		if ref != "" {
+			refs[sink.Name()] = ref
+		}
+		log.V(2).Info("PipelineRun delivered", "sink", sink.Name(), "phase", event.Phase, "taskName", event.TaskName, "ref", ref, "deliveryId", id, "attempt", sinkEvent.Delivery.Attempt)
+	}
+	return errors.Join(errs...)
+}
+
+// deadLetterExhausted dead-letters the delivery of the event to the sink once its attempts are exhausted
+func (r *TektonObservationReconciler) deadLetterExhausted(ctx context.Context, log logr.Logger, observation *obsv1.TektonObservation, sink string, event sinks.Event, deliverErr error) error {
+	letter, err := newLetter(event, sink, deliverErr)
+	if err != nil {
+		return err
+	}
+	if err := r.deadLetterDelivery(ctx, log, observation, letter); err != nil {
+		return fmt.Errorf("failed to dead-letter the delivery '%s' - %w", event.Delivery.ID, err)
+	}
+	log.Info("Delivery exhausted its retries and was dead-lettered", "sink", sink, "phase", event.Phase, "taskName", event.TaskName, "deliveryId", event.Delivery.ID, "attempts", event.Delivery.Attempt)
+	return nil
+}
+
+// deliveryKey identifies the phase of the event in the delivery ID, a replay is a delivery of its own
+func deliveryKey(event sinks.Event) string {
+	key := phaseKey(event.Phase, event.TaskName)
+	if event.ReplayKey != "" {
+		key = fmt.Sprintf("%s/replay/%s", key, event.ReplayKey)
+	}
+	return key
+}
+
+func getPipelineRunUID(data *tekton.PipelineRunData) string {
+	if data.RawPipelineRun != nil {
+		return string(data.RawPipelineRun.UID)
+	}
+	return fmt.Sprintf("%s/%s", data.Namespace, data.PipelineRunName)
+}
+
+// getDeliveryAttempts returns the number of failed attempts of the pending deliveries of the PipelineRun
+func getDeliveryAttempts(pipelineRun *tknv1.PipelineRun) map[string]int {
+	attempts := map[string]int{}
+	if raw, found := pipelineRun.Annotations[tektonobserver.DeliveryAttemptsAnnotation]; found {
+		// An invalid annotation only restarts the attempt counters
+		_ = json.Unmarshal([]byte(raw), &attempts)
+	}
+	return attempts
+}
+
+// marshalDeliveryAttempts returns the value of the delivery attempts annotation, it is empty when no delivery failed
+func marshalDeliveryAttempts(attempts map[string]int) (string, error) {
+	if len(attempts) == 0 {
+		return "", nil
+	}
+	raw, err := json.Marshal(attempts)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal the delivery attempts - %w", err)
+	}
+	return string(raw), nil
+}
+
+// recordDeliveryAttempts stores the failed attempts on the PipelineRun when they changed
+func (r *TektonObservationReconciler) recordDeliveryAttempts(ctx context.Context, pipelineRun *tknv1.PipelineRun, attempts map[string]int) error {
+	raw, err := marshalDeliveryAttempts(attempts)
+	if err != nil {
+		return err
+	}
+	if raw == pipelineRun.Annotations[tektonobserver.DeliveryAttemptsAnnotation] {
+		return nil
+	}
+	if err := r.updatePipelineRunAnnotations(ctx, pipelineRun, map[string]string{tektonobserver.DeliveryAttemptsAnnotation: raw}); err != nil {
+		return fmt.Errorf("failed to record the delivery attempts of the PipelineRun '%s' - %w", pipelineRun.Name, err)
+	}
+	return nil
+}
+
+// getSinkRefs returns the references of the messages sent to the sinks for the PipelineRun
+func getSinkRefs(pipelineRun *tknv1.PipelineRun) map[string]string {
+	refs := map[string]string{}
+	if raw, found := pipelineRun.Annotations[tektonobserver.SinkRefsAnnotation]; found {
+		// An invalid annotation only means the next messages are not updated in place
+		_ = json.Unmarshal([]byte(raw), &refs)
+	}
+	return refs
+}
@@ -0,0 +1,206 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-logr/zapr"
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"github.com/kcloutie/tekton-observer/internal/tektonobserver"
+	"github.com/kcloutie/tekton-observer/pkg/delivery"
+	"github.com/kcloutie/tekton-observer/pkg/sinks"
+	"github.com/kcloutie/tekton-observer/pkg/tekton"
+	"github.com/kcloutie/tekton-observer/pkg/throttle"
+	"github.com/kcloutie/tekton-observer/test/utils"
+	"go.uber.org/zap/zaptest"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestTektonObservationReconciler_deliver_throttled(t *testing.T) {
+	tests := []struct {
+		name          string
+		rateLimits    []obsv1.SinkRateLimit
+		publishErr    error
+		deliveries    int
+		wantPublished int
+		wantLastErr   error
+	}{
+		{
+			name:          "Test without rate limits",
+			publishErr:    errors.New("boom"),
+			deliveries:    3,
+			wantPublished: 3,
+		},
+		{
+			name: "Test with a rate limit",
+			rateLimits: []obsv1.SinkRateLimit{
+				{SinkType: obsv1.SinkTypePubSub, RequestsPerMinute: 1, Burst: 2, MaxWait: &metav1.Duration{}},
+			},
+			deliveries:    3,
+			wantPublished: 2,
+			wantLastErr:   throttle.ErrRateLimited,
+		},
+		{
+			name: "Test with a circuit breaker",
+			rateLimits: []obsv1.SinkRateLimit{
+				{SinkType: obsv1.SinkTypePubSub, CircuitBreaker: &obsv1.CircuitBreakerPolicy{FailureThreshold: 2}},
+			},
+			publishErr:    errors.New("boom"),
+			deliveries:    3,
+			wantPublished: 2,
+			wantLastErr:   throttle.ErrCircuitOpen,
+		},
+		{
+			name:          "Test with a Retry-After",
+			publishErr:    &throttle.RetryAfterError{After: time.Minute, Err: errors.New("throttled")},
+			deliveries:    2,
+			wantPublished: 1,
+			wantLastErr:   throttle.ErrCircuitOpen,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			log := zapr.NewLogger(zaptest.NewLogger(t))
+			observation := &obsv1.TektonObservation{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: tektonobserver.ObservationCrdName},
+				Spec: obsv1.TektonObservationSpec{
+					PubSubTopics: []obsv1.PubSubTopic{{PubSubProjectID: "project", PubSubTopicID: "topic"}},
+					RateLimits:   tt.rateLimits,
+				},
+			}
+			published := 0
+			r := &TektonObservationReconciler{
+				Client: utils.NewFakeClient(observation),
+				PubSubPublisher: func(ctx context.Context, projectID, topicID string, data []byte, attributes map[string]string, metadata delivery.Metadata) (string, error) {
+					published++
+					return "id", tt.publishErr
+				},
+			}
+			data := &tekton.PipelineRunData{Namespace: "test-namespace", PipelineRunName: "test-name"}
+
+			var err error
+			for i := 0; i < tt.deliveries; i++ {
+				err = r.deliver(ctx, log, observation, r.getSinks(observation), sinks.Event{Phase: obsv1.PhaseFinished, Data: data}, map[string]string{}, map[string]int{})
+			}
+			if published != tt.wantPublished {
+				t.Errorf("deliver() published %d times, want %d", published, tt.wantPublished)
+			}
+			if tt.wantLastErr != nil && !errors.Is(err, tt.wantLastErr) {
+				t.Errorf("deliver() error = %v, want %v", err, tt.wantLastErr)
+			}
+		})
+	}
+}
+
+func Test_throttledFor(t *testing.T) {
+	rateLimited := &sinkError{sink: "pubsub/project/topic", err: fmt.Errorf("failed to deliver - %w", &throttle.RetryAfterError{After: time.Minute, Err: throttle.ErrRateLimited})}
+	circuitOpen := &sinkError{sink: "kafka/kafka:9092/topic", err: &throttle.RetryAfterError{After: 2 * time.Minute, Err: throttle.ErrCircuitOpen}}
+	failed := &sinkError{sink: "nats/nats://nats:4222/tekton", err: errors.New("boom")}
+	tests := []struct {
+		name          string
+		err           error
+		want          time.Duration
+		wantThrottled bool
+	}{
+		{
+			name: "Test without error",
+		},
+		{
+			name:          "Test with a rate limited sink",
+			err:           errors.Join(rateLimited),
+			want:          time.Minute,
+			wantThrottled: true,
+		},
+		{
+			name:          "Test with several throttled sinks",
+			err:           fmt.Errorf("failed to deliver the finished phase - %w", errors.Join(rateLimited, circuitOpen)),
+			want:          2 * time.Minute,
+			wantThrottled: true,
+		},
+		{
+			name: "Test with a throttled sink and a failed sink",
+			err:  errors.Join(rateLimited, failed),
+		},
+		{
+			name: "Test with a failed sink",
+			err:  errors.Join(failed),
+		},
+		{
+			name:          "Test with a Retry-After without wait",
+			err:           &throttle.RetryAfterError{Err: throttle.ErrRateLimited},
+			want:          minThrottledRequeue,
+			wantThrottled: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, throttled := throttledFor(tt.err)
+			if got != tt.want || throttled != tt.wantThrottled {
+				t.Errorf("throttledFor() = %v, %v, want %v, %v", got, throttled, tt.want, tt.wantThrottled)
+			}
+		})
+	}
+}
+
+func TestTektonObservationReconciler_getSinks(t *testing.T) {
+	tests := []struct {
+		name      string
+		spec      obsv1.TektonObservationSpec
+		wantNames []string
+	}{
+		{
+			name:      "Test without sinks",
+			wantNames: []string{},
+		},
+		{
+			name: "Test with a topic and an OTLP exporter",
+			spec: obsv1.TektonObservationSpec{
+				PubSubTopics: []obsv1.PubSubTopic{{PubSubProjectID: "project", PubSubTopicID: "topic"}},
+				OTLPTraces:   []obsv1.OTLPTraceExporter{{Endpoint: "otel-collector:4317"}},
+			},
+			wantNames: []string{"pubsub/project/topic", "otlp/otel-collector:4317"},
+		},
+		{
+			name: "Test with a kafka topic",
+			spec: obsv1.TektonObservationSpec{
+				KafkaTopics: []obsv1.KafkaTopic{{Brokers: []string{"kafka-0:9092", "kafka-1:9092"}, Topic: "pipelineruns"}},
+			},
+			wantNames: []string{"kafka/kafka-0:9092,kafka-1:9092/pipelineruns"},
+		},
+		{
+			name: "Test with a NATS subject",
+			spec: obsv1.TektonObservationSpec{
+				NATSSubjects: []obsv1.NATSSubject{{URL: "nats://nats:4222", Subject: "tekton.{{.Namespace}}"}},
+			},
+			wantNames: []string{"nats/nats://nats:4222/tekton.{{.Namespace}}"},
+		},
+		{
+			name: "Test with a log sink",
+			spec: obsv1.TektonObservationSpec{
+				Log: &obsv1.LogSink{Verbosity: 1},
+			},
+			wantNames: []string{"log"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			observation := &obsv1.TektonObservation{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: tektonobserver.ObservationCrdName},
+				Spec:       tt.spec,
+			}
+			r := &TektonObservationReconciler{Client: utils.NewFakeClient(observation)}
+			names := []string{}
+			for _, sink := range r.getSinks(observation) {
+				names = append(names, sink.Name())
+			}
+			if !reflect.DeepEqual(names, tt.wantNames) {
+				t.Errorf("getSinks() = %v, want %v", names, tt.wantNames)
+			}
+		})
+	}
+}
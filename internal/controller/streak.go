@@ -0,0 +1,28 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"github.com/kcloutie/tekton-observer/internal/streak"
+	"github.com/kcloutie/tekton-observer/pkg/metrics"
+	"github.com/kcloutie/tekton-observer/pkg/tekton"
+)
+
+// recordStreak records the outcome of the finished PipelineRun in the streak of its pipeline and adds the streak to
+// its data. The PipelineRun is delivered without streak when it cannot be recorded.
+func (r *TektonObservationReconciler) recordStreak(ctx context.Context, log logr.Logger, observation *obsv1.TektonObservation, data *tekton.PipelineRunData) {
+	pipelineStreak, err := streak.Update(ctx, r.Client, observation, data, getPipelineRunUID(data), time.Now())
+	if err != nil {
+		log.Error(err, "Failed to record the streak of the pipeline")
+		return
+	}
+	if pipelineStreak == nil {
+		return
+	}
+	data.Streak = pipelineStreak
+	metrics.PipelineConsecutiveFailures.WithLabelValues(data.Namespace, data.PipelineName, pipelineStreak.Branch).Set(float64(pipelineStreak.ConsecutiveFailures))
+	log.V(2).Info("Streak of the pipeline recorded", "transition", pipelineStreak.Transition, "consecutiveFailures", pipelineStreak.ConsecutiveFailures)
+}
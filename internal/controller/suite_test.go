@@ -44,7 +44,7 @@ var k8sClient client.Client
 var testEnv *envtest.Environment
 
 func TestControllers(t *testing.T) {
-	return
+	t.Skip("the envtest controller suite is disabled")
 	RegisterFailHandler(Fail)
 
 	RunSpecs(t, "Controller Suite")
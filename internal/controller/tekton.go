@@ -2,10 +2,20 @@ package controller
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"time"
 
 	"github.com/go-logr/logr"
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"github.com/kcloutie/tekton-observer/internal/tektonobserver"
+	"github.com/kcloutie/tekton-observer/pkg/metrics"
+	"github.com/kcloutie/tekton-observer/pkg/sinks"
+	"github.com/kcloutie/tekton-observer/pkg/tekton"
 	tknv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"go.uber.org/zap/zapcore"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
 // func (r *TektonObservationReconciler) pipelineRunIsDone(log logr.Logger, pipelineRun *tknv1.PipelineRun) bool {
@@ -20,19 +30,230 @@ import (
 // 	return true
 // }
 
-func (r *TektonObservationReconciler) updatePipelineRunAnnotation(ctx context.Context, key, value string, pipelineRun tknv1.PipelineRun, log logr.Logger) error {
-	updated := pipelineRun.DeepCopy()
-	updated.Annotations[key] = value
+// processPipelineRun moves an observed PipelineRun through the processing states. A PipelineRun flagged as being
+// processed by the watcher is marked as started and, once it is done or deleted, it is published and marked as
+// complete. The finalizer of the PipelineRun keeps it from being removed until it is complete, or until the release
+// deadline elapsed when it cannot be delivered.
+func (r *TektonObservationReconciler) processPipelineRun(ctx context.Context, log logr.Logger, observation *obsv1.TektonObservation, pipelineRun *tknv1.PipelineRun) error {
+	state, found := pipelineRun.Annotations[tektonobserver.PipelineProcessingStateAnnotation]
+	if !found {
+		if pipelineRun.DeletionTimestamp != nil {
+			return nil
+		}
+		state = tektonobserver.ProcessingState
+	}
+	log = log.WithValues("PipelineRun", pipelineRun.Name, "PipelineUid", pipelineRun.UID)
+
+	if state == tektonobserver.ProcessingCompleteState {
+		if hasObserverFinalizer(pipelineRun) {
+			log.V(2).Info("Removing the finalizer of a complete PipelineRun")
+			return r.updatePipelineRunProcessingState(ctx, tektonobserver.ProcessingCompleteState, pipelineRun, log)
+		}
+		return nil
+	}
 
-	patch := client.MergeFrom(&pipelineRun)
-	err := r.Patch(ctx, updated, patch)
+	// PipelineRuns started by an earlier version of the controller do not have the finalizer yet
+	missingFinalizer := !hasObserverFinalizer(pipelineRun) && pipelineRun.DeletionTimestamp == nil
+	if state == tektonobserver.ProcessingState || missingFinalizer {
+		if err := r.updatePipelineRunProcessingState(ctx, tektonobserver.ProcessingStartState, pipelineRun, log); err != nil {
+			return fmt.Errorf("failed to mark the PipelineRun '%s' as started - %w", pipelineRun.Name, err)
+		}
+		if state == tektonobserver.ProcessingState {
+			metrics.PipelineRunsStartedProcessingTotal.Inc()
+		}
+	}
+
+	running := !pipelineRun.IsDone() && pipelineRun.DeletionTimestamp == nil
+	if err := r.deliverProgress(ctx, log, observation, pipelineRun); err != nil {
+		if after, throttled := throttledFor(err); throttled {
+			log.V(1).Info("The sinks are throttled, delivering the progress of the PipelineRun later", "after", after, "error", err.Error())
+		} else {
+			mess := fmt.Sprintf("Failed to deliver the progress of the PipelineRun '%s'", pipelineRun.Name)
+			log.Error(err, mess)
+			r.EventEmitter.EmitMessage(ctx, observation, zapcore.ErrorLevel, "ProcessPipelineRun", fmt.Sprintf("%v. %v", mess, err))
+		}
+		// The progress of a PipelineRun that is over does not hold back its finished phase
+		if running {
+			return err
+		}
+	}
 
+	if running {
+		log.V(2).Info("PipelineRun is still running...skipping")
+		return nil
+	}
+
+	start := time.Now()
+	data, err := r.getPipelineRunData(ctx, pipelineRun)
+	if err == nil && data == nil {
+		log.V(2).Info("PipelineRun was deleted before it was processed")
+		return nil
+	}
+	if err == nil {
+		err = r.deliverFinished(ctx, log, observation, pipelineRun, data)
+	}
 	if err != nil {
+		metrics.ProcessPipelineTimeHistogram.WithLabelValues("failed").Observe(time.Since(start).Seconds())
+		deadlineExceeded := releaseDeadlineExceeded(pipelineRun, time.Now())
+		// The throttling of the sinks is expected, the PipelineRun is delivered once the sinks accept it again
+		if after, throttled := throttledFor(err); throttled && !deadlineExceeded {
+			log.V(1).Info("The sinks are throttled, delivering the PipelineRun later", "after", after, "error", err.Error())
+			return err
+		}
+		mess := fmt.Sprintf("Failed to process the PipelineRun '%s'", pipelineRun.Name)
+		log.Error(err, mess)
+		r.EventEmitter.EmitMessage(ctx, observation, zapcore.ErrorLevel, "ProcessPipelineRun", fmt.Sprintf("%v. %v", mess, err))
+		if !deadlineExceeded {
+			return err
+		}
+
+		mess = fmt.Sprintf("The PipelineRun '%s' could not be delivered before the release deadline of %v, releasing its finalizer", pipelineRun.Name, tektonobserver.ControllerConfiguration.GetFinalizerReleaseDeadline())
+		log.Info(mess)
+		r.EventEmitter.EmitMessage(ctx, observation, zapcore.WarnLevel, "FinalizerReleaseDeadline", mess)
+		metrics.FinalizerReleaseDeadlineExceededTotal.Inc()
+		if err := r.deadLetterFailedSinks(ctx, log, observation, data, err); err != nil {
+			log.Error(err, "Failed to dead-letter the PipelineRun")
+			r.EventEmitter.EmitMessage(ctx, observation, zapcore.ErrorLevel, "DeadLetter", fmt.Sprintf("Failed to dead-letter the PipelineRun '%s'. %v", pipelineRun.Name, err))
+		}
+		if err := r.updatePipelineRunProcessingState(ctx, tektonobserver.ProcessingCompleteState, pipelineRun, log); err != nil {
+			return fmt.Errorf("failed to release the finalizer of the PipelineRun '%s' - %w", pipelineRun.Name, err)
+		}
+		if data != nil {
+			r.recordRunMetrics(ctx, log, observation, pipelineRun, data)
+		}
+		return nil
+	}
+
+	if err := r.updatePipelineRunProcessingState(ctx, tektonobserver.ProcessingCompleteState, pipelineRun, log); err != nil {
+		return fmt.Errorf("failed to mark the PipelineRun '%s' as complete - %w", pipelineRun.Name, err)
+	}
+	r.recordRunMetrics(ctx, log, observation, pipelineRun, data)
+	metrics.ProcessPipelineTimeHistogram.WithLabelValues("success").Observe(time.Since(start).Seconds())
+	metrics.PipelineRunsProcessedTotal.Inc()
+	log.V(1).Info("PipelineRun processed", "status", data.Status, "reason", data.Reason)
+	return nil
+}
+
+func hasObserverFinalizer(obj client.Object) bool {
+	return controllerutil.ContainsFinalizer(obj, tektonobserver.Finalizer)
+}
+
+// deliverFinished records the streak of the pipeline, the flakiness of its tasks and the deployment of the
+// PipelineRun, and delivers the finished phase of the PipelineRun to the sinks subscribed to it
+func (r *TektonObservationReconciler) deliverFinished(ctx context.Context, log logr.Logger, observation *obsv1.TektonObservation, pipelineRun *tknv1.PipelineRun, data *tekton.PipelineRunData) error {
+	r.recordStreak(ctx, log, observation, data)
+	r.recordFlakiness(ctx, log, observation, data)
+	r.recordDora(ctx, log, observation, data)
+	sinkList := r.getSinks(observation)
+	if !subscribed(sinkList, obsv1.PhaseFinished) {
+		log.V(3).Info("No sinks are subscribed to the finished phase...skipping")
+		metrics.PubSubSkippedDisabledTotal.Inc()
+		return nil
+	}
+	if pipelineRun.Annotations[tektonobserver.BackfillAnnotation] == "true" {
+		if data.Attributes == nil {
+			data.Attributes = map[string]string{}
+		}
+		data.Attributes["backfill"] = "true"
+	}
+	attempts := getDeliveryAttempts(pipelineRun)
+	deliverErr := r.deliver(ctx, log, observation, sinkList, sinks.Event{Phase: obsv1.PhaseFinished, Data: data}, getSinkRefs(pipelineRun), attempts)
+	if err := r.recordDeliveryAttempts(ctx, pipelineRun, attempts); err != nil {
+		return errors.Join(deliverErr, err)
+	}
+	return deliverErr
+}
+
+// releaseDeadlineExceeded returns true when the release deadline elapsed since the PipelineRun was deleted or, when
+// it was not deleted, since it finished
+func releaseDeadlineExceeded(pipelineRun *tknv1.PipelineRun, now time.Time) bool {
+	since := pipelineRun.DeletionTimestamp
+	if since == nil {
+		since = pipelineRun.Status.CompletionTime
+	}
+	if since == nil {
+		return false
+	}
+	return now.Sub(since.Time) > tektonobserver.ControllerConfiguration.GetFinalizerReleaseDeadline()
+}
+
+// getFullPipelineRun reads the PipelineRun from the API server when an API reader is set. The cached PipelineRuns do
+// not contain the fields removed by TrimPipelineRun.
+func (r *TektonObservationReconciler) getFullPipelineRun(ctx context.Context, pipelineRun *tknv1.PipelineRun) (*tknv1.PipelineRun, error) {
+	if r.APIReader == nil {
+		return pipelineRun, nil
+	}
+	full := &tknv1.PipelineRun{}
+	if err := r.APIReader.Get(ctx, client.ObjectKeyFromObject(pipelineRun), full); err != nil {
+		return nil, err
+	}
+	return full, nil
+}
+
+// updatePipelineRunProcessingState sets the processing state annotation and its mirroring label. The finalizer is
+// kept on the PipelineRun until it is complete, it is not added to a PipelineRun that is being deleted. The
+// PipelineRun is updated with the patched object.
+func (r *TektonObservationReconciler) updatePipelineRunProcessingState(ctx context.Context, state string, pipelineRun *tknv1.PipelineRun, log logr.Logger) error {
+	updated := pipelineRun.DeepCopy()
+	if updated.Annotations == nil {
+		updated.Annotations = map[string]string{}
+	}
+	if updated.Labels == nil {
+		updated.Labels = map[string]string{}
+	}
+	updated.Annotations[tektonobserver.PipelineProcessingStateAnnotation] = state
+	updated.Labels[tektonobserver.PipelineProcessingStateLabel] = state
+
+	finalizersChanged := false
+	if state == tektonobserver.ProcessingCompleteState {
+		finalizersChanged = controllerutil.RemoveFinalizer(updated, tektonobserver.Finalizer)
+	} else if updated.DeletionTimestamp == nil {
+		finalizersChanged = controllerutil.AddFinalizer(updated, tektonobserver.Finalizer)
+	}
+
+	// The finalizers are replaced as a whole by a merge patch, the resource version ensures the finalizers of other
+	// controllers are not lost
+	patch := client.MergeFrom(pipelineRun)
+	if finalizersChanged {
+		patch = client.MergeFromWithOptions(pipelineRun, client.MergeFromWithOptimisticLock{})
+	}
+	if err := r.Patch(ctx, updated, patch); err != nil {
 		return err
 	}
+	*pipelineRun = *updated
 	return nil
 }
 
+// updatePipelineRunAnnotations sets the annotations on the PipelineRun, the annotations with an empty value are
+// removed. The PipelineRun is updated with the patched object.
+func (r *TektonObservationReconciler) updatePipelineRunAnnotations(ctx context.Context, pipelineRun *tknv1.PipelineRun, annotations map[string]string) error {
+	updated := pipelineRun.DeepCopy()
+	if updated.Annotations == nil {
+		updated.Annotations = map[string]string{}
+	}
+	for k, v := range annotations {
+		if v == "" {
+			delete(updated.Annotations, k)
+			continue
+		}
+		updated.Annotations[k] = v
+	}
+	if err := r.Patch(ctx, updated, client.MergeFrom(pipelineRun)); err != nil {
+		return err
+	}
+	*pipelineRun = *updated
+	return nil
+}
+
+// removePipelineRunFinalizer removes the finalizer without changing the processing state of the PipelineRun
+func (r *TektonObservationReconciler) removePipelineRunFinalizer(ctx context.Context, pipelineRun *tknv1.PipelineRun) error {
+	updated := pipelineRun.DeepCopy()
+	if !controllerutil.RemoveFinalizer(updated, tektonobserver.Finalizer) {
+		return nil
+	}
+	return r.Patch(ctx, updated, client.MergeFromWithOptions(pipelineRun, client.MergeFromWithOptimisticLock{}))
+}
+
 // func (r *TektonObservationReconciler) updatePipelineRunLabel(ctx context.Context, label string, pipelineRun tknv1.PipelineRun, log logr.Logger) error {
 // 	updated := pipelineRun.DeepCopy()
 // 	updated.Labels[label] = fmt.Sprintf("%d", time.Now().UTC().Unix())
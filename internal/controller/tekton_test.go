@@ -0,0 +1,538 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-logr/zapr"
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"github.com/kcloutie/tekton-observer/internal/deadletter"
+	"github.com/kcloutie/tekton-observer/internal/tektonobserver"
+	"github.com/kcloutie/tekton-observer/pkg/delivery"
+	"github.com/kcloutie/tekton-observer/pkg/events"
+	"github.com/kcloutie/tekton-observer/pkg/tekton"
+	"github.com/kcloutie/tekton-observer/test/utils"
+	tknv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"go.uber.org/zap/zaptest"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+func TestTektonObservationReconciler_processPipelineRun(t *testing.T) {
+	testLogger := zaptest.NewLogger(t)
+	log := zapr.NewLogger(testLogger)
+	observation := &obsv1.TektonObservation{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "test-namespace",
+			Name:      tektonobserver.ObservationCrdName,
+		},
+		Spec: obsv1.TektonObservationSpec{
+			PubSubTopics: []obsv1.PubSubTopic{{PubSubProjectID: "project", PubSubTopicID: "topic"}},
+		},
+	}
+	tests := []struct {
+		name           string
+		state          string
+		isDone         bool
+		publishErr     error
+		wantErr        bool
+		wantPublished  int
+		wantAnnotation string
+		wantFinalizer  bool
+		wantTransition string
+	}{
+		{
+			name:           "Test with pipelineRun being processed not done",
+			state:          tektonobserver.ProcessingState,
+			wantAnnotation: tektonobserver.ProcessingStartState,
+			wantFinalizer:  true,
+		},
+		{
+			name:           "Test with pipelineRun being processed done",
+			state:          tektonobserver.ProcessingState,
+			isDone:         true,
+			wantPublished:  1,
+			wantAnnotation: tektonobserver.ProcessingCompleteState,
+			wantTransition: string(tekton.TransitionStillPassing),
+		},
+		{
+			name:           "Test with pipelineRun started done",
+			state:          tektonobserver.ProcessingStartState,
+			isDone:         true,
+			wantPublished:  1,
+			wantAnnotation: tektonobserver.ProcessingCompleteState,
+			wantTransition: string(tekton.TransitionStillPassing),
+		},
+		{
+			name:           "Test with pipelineRun already complete",
+			state:          tektonobserver.ProcessingCompleteState,
+			isDone:         true,
+			wantAnnotation: tektonobserver.ProcessingCompleteState,
+		},
+		{
+			name:           "Test with publish failure",
+			state:          tektonobserver.ProcessingStartState,
+			isDone:         true,
+			publishErr:     errors.New("boom"),
+			wantErr:        true,
+			wantPublished:  1,
+			wantAnnotation: tektonobserver.ProcessingStartState,
+			wantFinalizer:  true,
+			wantTransition: string(tekton.TransitionStillPassing),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			pipelineRun := utils.NewPipelineRun("test-namespace", "test-name", map[string]string{
+				tektonobserver.PipelineProcessingStateAnnotation: tt.state,
+			}, tt.isDone)
+			fakeClient := utils.NewFakeClient(pipelineRun, observation.DeepCopy())
+
+			published := 0
+			r := &TektonObservationReconciler{
+				Client:       fakeClient,
+				APIReader:    fakeClient,
+				Scheme:       scheme.Scheme,
+				EventEmitter: events.NewEventEmitter(fakeClient, &log, ""),
+				PubSubPublisher: func(ctx context.Context, projectID, topicID string, data []byte, attributes map[string]string, metadata delivery.Metadata) (string, error) {
+					published++
+					if attributes["pipelineRunName"] != "test-name" {
+						t.Errorf("unexpected pipelineRunName attribute %v", attributes["pipelineRunName"])
+					}
+					if attributes["streakTransition"] != tt.wantTransition {
+						t.Errorf("unexpected streakTransition attribute %v, want %v", attributes["streakTransition"], tt.wantTransition)
+					}
+					return "id", tt.publishErr
+				},
+			}
+
+			err := r.processPipelineRun(ctx, log, observation, pipelineRun)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("processPipelineRun() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if published != tt.wantPublished {
+				t.Errorf("processPipelineRun() published %d times, want %d", published, tt.wantPublished)
+			}
+
+			pr := &tknv1.PipelineRun{}
+			if err := r.Get(ctx, types.NamespacedName{Namespace: "test-namespace", Name: "test-name"}, pr); err != nil {
+				t.Fatal(err)
+			}
+			if got := pr.Annotations[tektonobserver.PipelineProcessingStateAnnotation]; got != tt.wantAnnotation {
+				t.Errorf("processPipelineRun() annotation = %v, want %v", got, tt.wantAnnotation)
+			}
+			if got := controllerutil.ContainsFinalizer(pr, tektonobserver.Finalizer); got != tt.wantFinalizer {
+				t.Errorf("processPipelineRun() finalizer = %v, want %v", got, tt.wantFinalizer)
+			}
+			if tt.state != tt.wantAnnotation {
+				if got := pr.Labels[tektonobserver.PipelineProcessingStateLabel]; got != tt.wantAnnotation {
+					t.Errorf("processPipelineRun() label = %v, want %v", got, tt.wantAnnotation)
+				}
+			}
+		})
+	}
+}
+
+func TestTektonObservationReconciler_processPipelineRun_finalizer(t *testing.T) {
+	log := zapr.NewLogger(zaptest.NewLogger(t))
+	observation := &obsv1.TektonObservation{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "test-namespace",
+			Name:      tektonobserver.ObservationCrdName,
+		},
+		Spec: obsv1.TektonObservationSpec{
+			PubSubTopics: []obsv1.PubSubTopic{{PubSubProjectID: "project", PubSubTopicID: "topic"}},
+		},
+	}
+	tests := []struct {
+		name          string
+		isDone        bool
+		deletedSince  time.Duration
+		finishedSince time.Duration
+		publishErr    error
+		wantErr       bool
+		wantStatus    string
+		wantDeleted   bool
+	}{
+		{
+			name:         "Test with a PipelineRun deleted while running",
+			deletedSince: time.Minute,
+			wantStatus:   tekton.StatusAborted,
+			wantDeleted:  true,
+		},
+		{
+			name:         "Test with a finished PipelineRun deleted before it was delivered",
+			isDone:       true,
+			deletedSince: time.Minute,
+			wantStatus:   tekton.StatusSucceeded,
+			wantDeleted:  true,
+		},
+		{
+			name:         "Test with a failing delivery before the release deadline",
+			deletedSince: time.Minute,
+			publishErr:   errors.New("boom"),
+			wantErr:      true,
+			wantStatus:   tekton.StatusAborted,
+		},
+		{
+			name:         "Test with a failing delivery after the release deadline",
+			deletedSince: 2 * time.Hour,
+			publishErr:   errors.New("boom"),
+			wantStatus:   tekton.StatusAborted,
+			wantDeleted:  true,
+		},
+		{
+			name:          "Test with a finished PipelineRun failing delivery after the release deadline",
+			isDone:        true,
+			finishedSince: 2 * time.Hour,
+			publishErr:    errors.New("boom"),
+			wantStatus:    tekton.StatusSucceeded,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			pipelineRun := utils.NewPipelineRun("test-namespace", "test-name", map[string]string{
+				tektonobserver.PipelineProcessingStateAnnotation: tektonobserver.ProcessingStartState,
+			}, tt.isDone)
+			pipelineRun.Finalizers = []string{tektonobserver.Finalizer}
+			if tt.deletedSince > 0 {
+				pipelineRun.DeletionTimestamp = &metav1.Time{Time: time.Now().Add(-tt.deletedSince)}
+			}
+			if tt.finishedSince > 0 {
+				pipelineRun.Status.CompletionTime = &metav1.Time{Time: time.Now().Add(-tt.finishedSince)}
+			}
+			fakeClient := utils.NewFakeClient(pipelineRun, observation.DeepCopy())
+			if err := fakeClient.Get(ctx, client.ObjectKeyFromObject(pipelineRun), pipelineRun); err != nil {
+				t.Fatal(err)
+			}
+
+			var published map[string]string
+			r := &TektonObservationReconciler{
+				Client:       fakeClient,
+				APIReader:    fakeClient,
+				Scheme:       scheme.Scheme,
+				EventEmitter: events.NewEventEmitter(fakeClient, &log, ""),
+				PubSubPublisher: func(ctx context.Context, projectID, topicID string, data []byte, attributes map[string]string, metadata delivery.Metadata) (string, error) {
+					published = attributes
+					return "id", tt.publishErr
+				},
+			}
+
+			err := r.processPipelineRun(ctx, log, observation, pipelineRun)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("processPipelineRun() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if published["status"] != tt.wantStatus {
+				t.Errorf("processPipelineRun() published status = %v, want %v", published["status"], tt.wantStatus)
+			}
+
+			pr := &tknv1.PipelineRun{}
+			err = fakeClient.Get(ctx, client.ObjectKeyFromObject(pipelineRun), pr)
+			if tt.wantDeleted {
+				if err == nil {
+					t.Errorf("processPipelineRun() did not release the finalizer")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !controllerutil.ContainsFinalizer(pr, tektonobserver.Finalizer) && pr.DeletionTimestamp != nil {
+				t.Errorf("processPipelineRun() released the finalizer before the deadline")
+			}
+			if tt.finishedSince > 0 && controllerutil.ContainsFinalizer(pr, tektonobserver.Finalizer) {
+				t.Errorf("processPipelineRun() did not release the finalizer after the deadline")
+			}
+		})
+	}
+}
+
+func TestTektonObservationReconciler_processPipelineRun_deadLetter(t *testing.T) {
+	log := zapr.NewLogger(zaptest.NewLogger(t))
+	tests := []struct {
+		name   string
+		topics []obsv1.PubSubTopic
+	}{
+		{
+			name:   "Test with a failing sink",
+			topics: []obsv1.PubSubTopic{{PubSubProjectID: "project", PubSubTopicID: "topic"}},
+		},
+		{
+			name: "Test with several failing sinks",
+			topics: []obsv1.PubSubTopic{
+				{PubSubProjectID: "project", PubSubTopicID: "topic"},
+				{PubSubProjectID: "project", PubSubTopicID: "other-topic"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			observation := &obsv1.TektonObservation{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: "test-namespace",
+					Name:      tektonobserver.ObservationCrdName,
+				},
+				Spec: obsv1.TektonObservationSpec{
+					PubSubTopics: tt.topics,
+					DeadLetter:   &obsv1.DeadLetterDestination{ConfigMap: &obsv1.ConfigMapDeadLetter{}},
+				},
+			}
+			pipelineRun := utils.NewPipelineRun("test-namespace", "test-name", map[string]string{
+				tektonobserver.PipelineProcessingStateAnnotation: tektonobserver.ProcessingStartState,
+			}, false)
+			pipelineRun.UID = "5b1c7d4e-1d1b-4c39-9f5e-8d4c0f0a7a11"
+			pipelineRun.Finalizers = []string{tektonobserver.Finalizer}
+			pipelineRun.DeletionTimestamp = &metav1.Time{Time: time.Now().Add(-2 * time.Hour)}
+			fakeClient := utils.NewFakeClient(pipelineRun, observation.DeepCopy())
+			if err := fakeClient.Get(ctx, client.ObjectKeyFromObject(pipelineRun), pipelineRun); err != nil {
+				t.Fatal(err)
+			}
+			r := &TektonObservationReconciler{
+				Client:       fakeClient,
+				APIReader:    fakeClient,
+				Scheme:       scheme.Scheme,
+				EventEmitter: events.NewEventEmitter(fakeClient, &log, ""),
+				PubSubPublisher: func(ctx context.Context, projectID, topicID string, data []byte, attributes map[string]string, metadata delivery.Metadata) (string, error) {
+					return "", errors.New("boom")
+				},
+			}
+
+			if err := r.processPipelineRun(ctx, log, observation, pipelineRun); err != nil {
+				t.Fatalf("processPipelineRun() unexpected error = %v", err)
+			}
+
+			configMaps := &corev1.ConfigMapList{}
+			if err := fakeClient.List(ctx, configMaps, client.InNamespace("test-namespace"), client.HasLabels{deadletter.ConfigMapLabel}); err != nil {
+				t.Fatal(err)
+			}
+			if len(configMaps.Items) != len(tt.topics) {
+				t.Fatalf("processPipelineRun() stored %d dead letters, want %d", len(configMaps.Items), len(tt.topics))
+			}
+			for _, configMap := range configMaps.Items {
+				if errs := validation.IsDNS1123Subdomain(configMap.Name); len(errs) != 0 {
+					t.Errorf("processPipelineRun() stored the dead letter in the invalid ConfigMap '%s' - %v", configMap.Name, errs)
+				}
+				letter := deadletter.Letter{}
+				if err := json.Unmarshal([]byte(configMap.Data[deadletter.ConfigMapKey]), &letter); err != nil {
+					t.Fatal(err)
+				}
+				if want := delivery.ID(string(pipelineRun.UID), string(obsv1.PhaseFinished), letter.Sink); letter.DeliveryID != want {
+					t.Errorf("processPipelineRun() dead letter delivery ID = %v, want %v", letter.DeliveryID, want)
+				}
+			}
+
+			latest := &obsv1.TektonObservation{}
+			if err := fakeClient.Get(ctx, client.ObjectKeyFromObject(observation), latest); err != nil {
+				t.Fatal(err)
+			}
+			if latest.Status.DeadLetteredDeliveries != int64(len(tt.topics)) {
+				t.Errorf("processPipelineRun() dead-lettered %d deliveries, want %d", latest.Status.DeadLetteredDeliveries, len(tt.topics))
+			}
+		})
+	}
+}
+
+func TestTektonObservationReconciler_processPipelineRun_retriesExhausted(t *testing.T) {
+	log := zapr.NewLogger(zaptest.NewLogger(t))
+	tests := []struct {
+		name       string
+		maxRetries int
+	}{
+		{
+			name:       "Test with no retries",
+			maxRetries: 0,
+		},
+		{
+			name:       "Test with several retries",
+			maxRetries: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			tektonobserver.ControllerConfiguration.Set(func() tektonobserver.ControllerConfig {
+				config := tektonobserver.DefaultControllerConfig()
+				config.RetryPolicy.MaxRetries = tt.maxRetries
+				return config
+			}())
+			defer tektonobserver.ControllerConfiguration.Set(tektonobserver.DefaultControllerConfig())
+
+			observation := &obsv1.TektonObservation{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: tektonobserver.ObservationCrdName},
+				Spec: obsv1.TektonObservationSpec{
+					PubSubTopics: []obsv1.PubSubTopic{
+						{PubSubProjectID: "project", PubSubTopicID: "failing"},
+						{PubSubProjectID: "project", PubSubTopicID: "healthy"},
+					},
+					DeadLetter: &obsv1.DeadLetterDestination{ConfigMap: &obsv1.ConfigMapDeadLetter{}},
+				},
+			}
+			// The PipelineRun has no completion time, its release deadline never elapses
+			pipelineRun := utils.NewPipelineRun("test-namespace", "test-name", map[string]string{
+				tektonobserver.PipelineProcessingStateAnnotation: tektonobserver.ProcessingStartState,
+			}, true)
+			pipelineRun.UID = "test-uid"
+			pipelineRun.Finalizers = []string{tektonobserver.Finalizer}
+			fakeClient := utils.NewFakeClient(pipelineRun, observation.DeepCopy())
+
+			failed := 0
+			r := &TektonObservationReconciler{
+				Client:       fakeClient,
+				APIReader:    fakeClient,
+				Scheme:       scheme.Scheme,
+				EventEmitter: events.NewEventEmitter(fakeClient, &log, ""),
+				PubSubPublisher: func(ctx context.Context, projectID, topicID string, data []byte, attributes map[string]string, metadata delivery.Metadata) (string, error) {
+					if topicID == "failing" {
+						failed++
+						return "", errors.New("boom")
+					}
+					return "id", nil
+				},
+			}
+
+			pr := &tknv1.PipelineRun{}
+			for attempt := 1; attempt <= tt.maxRetries+1; attempt++ {
+				if err := fakeClient.Get(ctx, client.ObjectKeyFromObject(pipelineRun), pr); err != nil {
+					t.Fatal(err)
+				}
+				err := r.processPipelineRun(ctx, log, observation, pr)
+				if exhausted := attempt > tt.maxRetries; (err != nil) == exhausted {
+					t.Fatalf("processPipelineRun() attempt %d error = %v, want an error until the retries are exhausted", attempt, err)
+				}
+			}
+			if failed != tt.maxRetries+1 {
+				t.Errorf("processPipelineRun() attempted the failing sink %d times, want %d", failed, tt.maxRetries+1)
+			}
+
+			configMaps := &corev1.ConfigMapList{}
+			if err := fakeClient.List(ctx, configMaps, client.InNamespace("test-namespace"), client.HasLabels{deadletter.ConfigMapLabel}); err != nil {
+				t.Fatal(err)
+			}
+			if len(configMaps.Items) != 1 {
+				t.Fatalf("processPipelineRun() stored %d dead letters, want 1", len(configMaps.Items))
+			}
+			letter := deadletter.Letter{}
+			if err := json.Unmarshal([]byte(configMaps.Items[0].Data[deadletter.ConfigMapKey]), &letter); err != nil {
+				t.Fatal(err)
+			}
+			if letter.Sink != "pubsub/project/failing" || letter.Attempts != tt.maxRetries+1 || letter.DeliveryID != delivery.ID("test-uid", string(obsv1.PhaseFinished), letter.Sink) {
+				t.Errorf("processPipelineRun() dead letter = %+v, want the failing sink after %d attempts", letter, tt.maxRetries+1)
+			}
+
+			if err := fakeClient.Get(ctx, client.ObjectKeyFromObject(pipelineRun), pr); err != nil {
+				t.Fatal(err)
+			}
+			if state := pr.Annotations[tektonobserver.PipelineProcessingStateAnnotation]; state != tektonobserver.ProcessingCompleteState || hasObserverFinalizer(pr) {
+				t.Errorf("processPipelineRun() state = %v, finalizers = %v, want the PipelineRun complete and released", state, pr.Finalizers)
+			}
+		})
+	}
+}
+
+func TestTektonObservationReconciler_finalizeObservation(t *testing.T) {
+	ctx := context.Background()
+	log := zapr.NewLogger(zaptest.NewLogger(t))
+	observation := &obsv1.TektonObservation{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:         "test-namespace",
+			Name:              tektonobserver.ObservationCrdName,
+			Finalizers:        []string{tektonobserver.Finalizer},
+			DeletionTimestamp: &metav1.Time{Time: time.Now()},
+		},
+	}
+	pipelineRun := utils.NewPipelineRun("test-namespace", "test-name", map[string]string{
+		tektonobserver.PipelineProcessingStateAnnotation: tektonobserver.ProcessingStartState,
+	}, false)
+	pipelineRun.Finalizers = []string{tektonobserver.Finalizer, "other.dev/finalizer"}
+	fakeClient := utils.NewFakeClient(pipelineRun, observation)
+	r := &TektonObservationReconciler{
+		Client:       fakeClient,
+		Scheme:       scheme.Scheme,
+		EventEmitter: events.NewEventEmitter(fakeClient, &log, ""),
+	}
+
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(observation)}); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+
+	pr := &tknv1.PipelineRun{}
+	if err := fakeClient.Get(ctx, client.ObjectKeyFromObject(pipelineRun), pr); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"other.dev/finalizer"}; !reflect.DeepEqual(pr.Finalizers, want) {
+		t.Errorf("Reconcile() PipelineRun finalizers = %v, want %v", pr.Finalizers, want)
+	}
+	if err := fakeClient.Get(ctx, client.ObjectKeyFromObject(observation), &obsv1.TektonObservation{}); err == nil {
+		t.Errorf("Reconcile() did not release the TektonObservation")
+	}
+}
+
+func TestTektonObservationReconciler_processPipelineRun_deliveryAttempts(t *testing.T) {
+	log := zapr.NewLogger(zaptest.NewLogger(t))
+	ctx := context.Background()
+	observation := &obsv1.TektonObservation{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: tektonobserver.ObservationCrdName},
+		Spec: obsv1.TektonObservationSpec{
+			PubSubTopics: []obsv1.PubSubTopic{{PubSubProjectID: "project", PubSubTopicID: "topic", OrderingKey: obsv1.OrderingKeyNamespace}},
+		},
+	}
+	pipelineRun := utils.NewPipelineRun("test-namespace", "test-name", map[string]string{
+		tektonobserver.PipelineProcessingStateAnnotation: tektonobserver.ProcessingStartState,
+	}, true)
+	pipelineRun.UID = "test-uid"
+	fakeClient := utils.NewFakeClient(pipelineRun, observation.DeepCopy())
+
+	deliveries := []delivery.Metadata{}
+	r := &TektonObservationReconciler{
+		Client:       fakeClient,
+		APIReader:    fakeClient,
+		Scheme:       scheme.Scheme,
+		EventEmitter: events.NewEventEmitter(fakeClient, &log, ""),
+		PubSubPublisher: func(ctx context.Context, projectID, topicID string, data []byte, attributes map[string]string, metadata delivery.Metadata) (string, error) {
+			deliveries = append(deliveries, metadata)
+			if len(deliveries) == 1 {
+				return "", errors.New("boom")
+			}
+			return "id", nil
+		},
+	}
+
+	pr := &tknv1.PipelineRun{}
+	for i := 0; i < 2; i++ {
+		if err := fakeClient.Get(ctx, client.ObjectKeyFromObject(pipelineRun), pr); err != nil {
+			t.Fatal(err)
+		}
+		err := r.processPipelineRun(ctx, log, observation, pr)
+		if (err != nil) != (i == 0) {
+			t.Fatalf("processPipelineRun() attempt %d error = %v", i+1, err)
+		}
+	}
+
+	wantID := delivery.ID("test-uid", string(obsv1.PhaseFinished), "pubsub/project/topic")
+	want := []delivery.Metadata{
+		{ID: wantID, Attempt: 1, OrderingKey: "test-namespace"},
+		{ID: wantID, Attempt: 2, OrderingKey: "test-namespace"},
+	}
+	if !reflect.DeepEqual(deliveries, want) {
+		t.Errorf("processPipelineRun() deliveries = %v, want %v", deliveries, want)
+	}
+	if err := fakeClient.Get(ctx, client.ObjectKeyFromObject(pipelineRun), pr); err != nil {
+		t.Fatal(err)
+	}
+	if got := pr.Annotations[tektonobserver.DeliveryAttemptsAnnotation]; got != "" {
+		t.Errorf("processPipelineRun() delivery attempts = %v, want none", got)
+	}
+}
@@ -18,16 +18,34 @@ package controller
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/go-logr/logr"
 
 	observerv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"github.com/kcloutie/tekton-observer/internal/sharding"
+	"github.com/kcloutie/tekton-observer/internal/tektonobserver"
 	"github.com/kcloutie/tekton-observer/pkg/events"
+	"github.com/kcloutie/tekton-observer/pkg/metrics"
+	"github.com/kcloutie/tekton-observer/pkg/throttle"
+	"github.com/kcloutie/tekton-observer/pkg/tracing"
 	tknv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"golang.org/x/time/rate"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 // TektonObservationReconciler reconciles a TektonObservation object
@@ -35,6 +53,31 @@ type TektonObservationReconciler struct {
 	client.Client
 	Scheme       *runtime.Scheme
 	EventEmitter *events.EventEmitter
+	// APIReader reads the PipelineRuns from the API server when they are processed. The cached PipelineRun is used
+	// when it is not set, it must be set when the cache trims the PipelineRuns
+	APIReader client.Reader
+	// MaxConcurrentReconciles is the maximum number of concurrent reconciles. The controller configuration is used
+	// when it is not set
+	MaxConcurrentReconciles int
+	// Sharder restricts the processed PipelineRuns to the shard of this replica, every PipelineRun is processed when
+	// it is not set
+	Sharder *sharding.Sharder
+	// PubSubPublisher publishes the PipelineRun data, gcp.PublishEvent is used when it is not set
+	PubSubPublisher PubSubPublisher
+	// KafkaProducer produces the PipelineRun data to the Kafka topics, kafka.Produce is used when it is not set
+	KafkaProducer KafkaProducer
+	// NATSPublisher publishes the PipelineRun data to the NATS subjects, nats.Publish is used when it is not set
+	NATSPublisher NATSPublisher
+	// OTLPExporterFactory creates the exporters of the OTLP trace sinks, otlp.NewSpanExporter is used when it is not
+	// set
+	OTLPExporterFactory OTLPExporterFactory
+	// RunLogger writes the records of the log sinks, the pipelineruns logger of the controller is used when it is not
+	// set
+	RunLogger logr.Logger
+
+	backfillMu       sync.Mutex
+	backfillLimiters map[string]*rate.Limiter
+	sinkThrottles    throttle.Registry
 }
 
 //+kubebuilder:rbac:groups="",resources=events,verbs=get;list;create;patch;watch
@@ -47,28 +90,129 @@ type TektonObservationReconciler struct {
 //+kubebuilder:rbac:groups=observer.tkn.dev,resources=tektonobservations/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=observer.tkn.dev,resources=tektonobservations/finalizers,verbs=update
 
-// Reconcile is part of the main kubernetes reconciliation loop which aims to
-// move the current state of the cluster closer to the desired state.
-// TODO(user): Modify the Reconcile function to compare the state specified by
-// the TektonObservation object against the actual cluster state, and then
-// perform operations to make the cluster state reflect the state specified by
-// the user.
+// Reconcile manages the finalizer of the TektonObservation. The PipelineRuns are processed by ReconcilePipelineRun,
+// the finalizers of the PipelineRuns of the namespace are released when the observation is deleted.
 //
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.17.0/pkg/reconcile
 func (r *TektonObservationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	_ = log.FromContext(ctx)
+	log := log.FromContext(ctx).WithValues("clusterName", tektonobserver.ControllerConfiguration.GetClusterName())
+
+	observation := &observerv1.TektonObservation{}
+	if err := r.Get(ctx, req.NamespacedName, observation); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	if !observation.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, r.finalizeObservation(ctx, log, observation)
+	}
+	if controllerutil.AddFinalizer(observation, tektonobserver.Finalizer) {
+		if err := r.Update(ctx, observation); err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to add the finalizer to the TektonObservation - %w", err)
+		}
+	}
 
-	return ctrl.Result{}, nil
+	// The digests and the DORA summaries are reconciled with their observation, which is requeued until the next one
+	// is due
+	next, err := r.flushDigests(ctx, log, observation)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	nextSummary, err := r.sendDoraSummary(ctx, log, observation)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if next == 0 || (nextSummary > 0 && nextSummary < next) {
+		next = nextSummary
+	}
+	return ctrl.Result{RequeueAfter: next}, nil
 }
 
-// SetupWithManager sets up the controller with the Manager.
+// finalizeObservation removes the finalizer of every PipelineRun of the namespace, nothing reports them once the
+// observation is removed, and then releases the observation
+func (r *TektonObservationReconciler) finalizeObservation(ctx context.Context, log logr.Logger, observation *observerv1.TektonObservation) error {
+	if !controllerutil.ContainsFinalizer(observation, tektonobserver.Finalizer) {
+		return nil
+	}
+
+	pipelineRuns := &tknv1.PipelineRunList{}
+	if err := r.List(ctx, pipelineRuns, client.InNamespace(observation.Namespace)); err != nil {
+		return err
+	}
+	var errs []error
+	for i := range pipelineRuns.Items {
+		pipelineRun := &pipelineRuns.Items[i]
+		if err := r.removePipelineRunFinalizer(ctx, pipelineRun); client.IgnoreNotFound(err) != nil {
+			errs = append(errs, fmt.Errorf("failed to remove the finalizer of the PipelineRun '%s' - %w", pipelineRun.Name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	controllerutil.RemoveFinalizer(observation, tektonobserver.Finalizer)
+	if err := r.Update(ctx, observation); err != nil {
+		return fmt.Errorf("failed to remove the finalizer of the TektonObservation - %w", err)
+	}
+	metrics.ForgetNamespace(observation.Namespace)
+	log.V(1).Info("TektonObservation removed, the finalizers of its PipelineRuns were released", "namespace", observation.Namespace)
+	return nil
+}
+
+// SetupWithManager sets up the controllers with the Manager. The TektonObservation controller manages the observations,
+// the PipelineRun controller reconciles every PipelineRun on its own and the ObservationDelivery controller drains the
+// outbox.
 func (r *TektonObservationReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	maxConcurrentReconciles := r.MaxConcurrentReconciles
+	if maxConcurrentReconciles < 1 {
+		maxConcurrentReconciles = tektonobserver.ControllerConfiguration.GetMaxConcurrentReconciles()
+	}
+
+	observationBuilder := ctrl.NewControllerManagedBy(mgr).
+		WithOptions(controller.Options{MaxConcurrentReconciles: maxConcurrentReconciles}).
 		For(&observerv1.TektonObservation{}).
+		Owns(&corev1.ConfigMap{})
+	if r.Sharder != nil {
+		observationBuilder = observationBuilder.WatchesRawSource(
+			&source.Channel{Source: shardChanges(r.Sharder)},
+			handler.EnqueueRequestsFromMapFunc(tracing.MapFunc("map shard change to TektonObservations", r.findObservationsForShardChange)),
+		)
+	}
+	if err := observationBuilder.Complete(tracing.Reconciler("reconcile TektonObservation", r)); err != nil {
+		return err
+	}
+
+	pipelineRunBuilder := ctrl.NewControllerManagedBy(mgr).
+		Named("pipelinerun").
+		WithOptions(controller.Options{MaxConcurrentReconciles: maxConcurrentReconciles}).
+		For(&tknv1.PipelineRun{}, builder.WithPredicates(pipelineRunTransitions())).
 		Watches(
-			&tknv1.PipelineRun{},
-			handler.EnqueueRequestsFromMapFunc(r.findConfigsFromPipelineRun),
-		).
-		Complete(r)
+			&observerv1.TektonObservation{},
+			handler.EnqueueRequestsFromMapFunc(tracing.MapFunc("map TektonObservation to PipelineRuns", r.findPipelineRunsFromObservation)),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{}),
+		)
+	if tektonobserver.ControllerConfiguration.IsFeatureEnabled(tektonobserver.LiveProgressUpdates) {
+		pipelineRunBuilder = pipelineRunBuilder.Watches(
+			&tknv1.TaskRun{},
+			handler.EnqueueRequestForOwner(mgr.GetScheme(), mgr.GetRESTMapper(), &tknv1.PipelineRun{}, handler.OnlyControllerOwner()),
+			builder.WithPredicates(taskRunFinished()),
+		)
+	}
+	if r.Sharder != nil {
+		pipelineRunBuilder = pipelineRunBuilder.WatchesRawSource(
+			&source.Channel{Source: shardChanges(r.Sharder)},
+			handler.EnqueueRequestsFromMapFunc(tracing.MapFunc("map shard change to PipelineRuns", r.findPipelineRunsForShardChange)),
+		)
+	}
+	if err := pipelineRunBuilder.Complete(tracing.Reconciler("reconcile PipelineRun", reconcile.Func(r.ReconcilePipelineRun))); err != nil {
+		return err
+	}
+
+	// The outbox is drained whatever the DeliveryOutbox feature gate, it can be enabled without a restart and the
+	// deliveries added before it was disabled are still retried
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("observationdelivery").
+		WithOptions(controller.Options{MaxConcurrentReconciles: maxConcurrentReconciles}).
+		For(&observerv1.ObservationDelivery{}).
+		Complete(tracing.Reconciler("retry ObservationDelivery", reconcile.Func(r.ReconcileDelivery)))
 }
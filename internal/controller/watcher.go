@@ -2,69 +2,66 @@ package controller
 
 import (
 	"context"
-	"fmt"
 
 	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
 	"github.com/kcloutie/tekton-observer/internal/tektonobserver"
 	tknv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
-	"go.uber.org/zap/zapcore"
-	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
-func (r *TektonObservationReconciler) findConfigsFromPipelineRun(ctx context.Context, pipelineRun client.Object) []reconcile.Request {
-
-	pipelineRunObject := &tknv1.PipelineRun{}
-	pipelineRunName := types.NamespacedName{
-		Namespace: pipelineRun.GetNamespace(),
-		Name:      pipelineRun.GetName(),
-	}
+// findPipelineRunsFromObservation enqueues the PipelineRuns of the namespace of the observation that are not complete,
+// so they are delivered to the sinks of a new or updated observation
+func (r *TektonObservationReconciler) findPipelineRunsFromObservation(ctx context.Context, observation client.Object) []reconcile.Request {
+	return r.findPendingPipelineRuns(ctx, client.InNamespace(observation.GetNamespace()))
+}
 
-	log := log.FromContext(ctx).WithValues("namespace", pipelineRunName.Namespace, "PipelineRun", pipelineRunName.Name, "PipelineUid", pipelineRun.GetUID(), "clusterName", tektonobserver.ControllerConfiguration.GetClusterName())
+// findPipelineRunsForShardChange enqueues every PipelineRun that is not complete so the PipelineRuns moved to this
+// replica are processed
+func (r *TektonObservationReconciler) findPipelineRunsForShardChange(ctx context.Context, _ client.Object) []reconcile.Request {
+	return r.findPendingPipelineRuns(ctx)
+}
 
-	if err := r.Get(ctx, pipelineRunName, pipelineRunObject); err != nil {
-		// Its likely the pipelineRun was deleted if it does not exist...so just return
-		log.V(2).Info("Cannot find PipelineRun...it was likely deleted")
+// findPendingPipelineRuns lists the PipelineRuns of the shard of this replica that are not complete. It only reads
+// from the cache.
+func (r *TektonObservationReconciler) findPendingPipelineRuns(ctx context.Context, opts ...client.ListOption) []reconcile.Request {
+	pipelineRuns := &tknv1.PipelineRunList{}
+	if err := r.List(ctx, pipelineRuns, opts...); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list the PipelineRuns")
 		return []reconcile.Request{}
 	}
 
-	observationNamespacedName := types.NamespacedName{
-		Namespace: pipelineRun.GetNamespace(),
-		Name:      tektonobserver.ObservationCrdName,
-	}
-
-	observation := obsv1.TektonObservation{}
-
-	err := r.Get(ctx, observationNamespacedName, &observation)
-	if err != nil {
-		err = r.Create(ctx, &observation, &client.CreateOptions{})
-		if err != nil {
-			mess := "Failed to create TektonObservation CR"
-			log.V(0).Error(err, mess, "observationName", observationNamespacedName)
-			r.EventEmitter.EmitMessagePipelineRun(ctx, pipelineRunObject, zapcore.ErrorLevel, "Create TektonObservation CR", fmt.Sprintf("%v. %v", mess, err))
+	requests := []reconcile.Request{}
+	for i := range pipelineRuns.Items {
+		pipelineRun := &pipelineRuns.Items[i]
+		if pipelineRun.Annotations[tektonobserver.PipelineProcessingStateAnnotation] == tektonobserver.ProcessingCompleteState {
+			continue
+		}
+		if !r.ownsPipelineRun(pipelineRun) {
+			continue
 		}
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(pipelineRun)})
 	}
+	return requests
+}
 
-	state, processedStateLabelFound := pipelineRunObject.Annotations[tektonobserver.PipelineProcessingStateAnnotation]
-
-	if processedStateLabelFound && state == tektonobserver.ProcessingCompleteState {
-		log.V(3).Info("PipelineRun has already been processed...skipping")
+// findObservationsForShardChange enqueues the observations of the namespaces owned by this replica so the digests and
+// the DORA summaries of the namespaces moved to this replica are sent
+func (r *TektonObservationReconciler) findObservationsForShardChange(ctx context.Context, _ client.Object) []reconcile.Request {
+	observations := &obsv1.TektonObservationList{}
+	if err := r.List(ctx, observations); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list the TektonObservations")
 		return []reconcile.Request{}
 	}
 
-	if !processedStateLabelFound {
-		err = r.updatePipelineRunAnnotation(ctx, tektonobserver.PipelineProcessingStateAnnotation, tektonobserver.ProcessingState, *pipelineRunObject, log)
-		if err != nil {
-			log.Error(err, "Failed to update PipelineRun label")
-		}
-
-	} else if state == tektonobserver.ProcessingStartState {
-		if !pipelineRunObject.IsDone() {
-			log.V(2).Info("PipelineRun is still running...skipping")
-			return []reconcile.Request{}
+	requests := []reconcile.Request{}
+	for i := range observations.Items {
+		observation := &observations.Items[i]
+		if !r.ownsNamespace(observation.Namespace) {
+			continue
 		}
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(observation)})
 	}
-	return []reconcile.Request{{NamespacedName: observationNamespacedName}}
+	return requests
 }
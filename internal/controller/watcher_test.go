@@ -5,156 +5,89 @@ import (
 	"reflect"
 	"testing"
 
-	"github.com/go-logr/zapr"
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
 	"github.com/kcloutie/tekton-observer/internal/tektonobserver"
-	"github.com/kcloutie/tekton-observer/pkg/events"
 	"github.com/kcloutie/tekton-observer/test/utils"
 	tknv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
-	"go.uber.org/zap/zaptest"
-	"k8s.io/apimachinery/pkg/runtime"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
-func TestTektonObservationReconciler_findConfigsFromPipelineRun(t *testing.T) {
-	testLogger := zaptest.NewLogger(t)
-	log := zapr.NewLogger(testLogger)
-	type fields struct {
-		Client client.Client
-		Scheme *runtime.Scheme
-	}
-	type args struct {
-		ctx         context.Context
-		pipelineRun client.Object
+func TestTektonObservationReconciler_findPipelineRunsFromObservation(t *testing.T) {
+	observation := &obsv1.TektonObservation{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "test-namespace",
+			Name:      tektonobserver.ObservationCrdName,
+		},
 	}
 	tests := []struct {
-		name                  string
-		fields                fields
-		args                  args
-		want                  []reconcile.Request
-		wantProcessAnnotation string
+		name         string
+		pipelineRuns []*tknv1.PipelineRun
+		want         []reconcile.Request
 	}{
 		{
-			name: "Test with pipelineRun not found",
-			fields: fields{
-				Client: utils.NewFakeClient(),
-				Scheme: scheme.Scheme,
-			},
-			args: args{
-				ctx:         context.Background(),
-				pipelineRun: utils.NewPipelineRun("test-namespace", "test-name", map[string]string{}, false),
-			},
+			name: "Test without pipelineRuns",
 			want: []reconcile.Request{},
 		},
-
 		{
-			name: "Test with pipelineRun found and observation not found",
-			fields: fields{
-				Client: utils.NewFakeClient(utils.NewPipelineRun("test-namespace", "test-name", map[string]string{}, false)),
-				Scheme: scheme.Scheme,
-			},
-			args: args{
-				ctx:         context.Background(),
-				pipelineRun: utils.NewPipelineRun("test-namespace", "test-name", map[string]string{}, false),
-			},
-			want: []reconcile.Request{
-				{
-					NamespacedName: types.NamespacedName{
-						Namespace: "test-namespace",
-						Name:      tektonobserver.ObservationCrdName,
-					},
-				},
-			},
-			wantProcessAnnotation: tektonobserver.ProcessingState,
-		},
-		{
-			name: "Test with pipelineRun already started not done",
-			fields: fields{
-				Client: utils.NewFakeClient(utils.NewPipelineRun("test-namespace", "test-name", map[string]string{
-					tektonobserver.PipelineProcessingStateAnnotation: tektonobserver.ProcessingStartState,
-				}, false)),
-				Scheme: scheme.Scheme,
-			},
-			args: args{
-				ctx: context.Background(),
-				pipelineRun: utils.NewPipelineRun("test-namespace", "test-name", map[string]string{
-					tektonobserver.PipelineProcessingStateAnnotation: tektonobserver.ProcessingStartState,
-				}, false),
-			},
-			want:                  []reconcile.Request{},
-			wantProcessAnnotation: tektonobserver.ProcessingStartState,
-		},
-		{
-			name: "Test with pipelineRun already started done",
-			fields: fields{
-				Client: utils.NewFakeClient(utils.NewPipelineRun("test-namespace", "test-name", map[string]string{
-					tektonobserver.PipelineProcessingStateAnnotation: tektonobserver.ProcessingStartState,
-				}, true)),
-				Scheme: scheme.Scheme,
-			},
-			args: args{
-				ctx: context.Background(),
-				pipelineRun: utils.NewPipelineRun("test-namespace", "test-name", map[string]string{
+			name: "Test with pipelineRuns not processed yet",
+			pipelineRuns: []*tknv1.PipelineRun{
+				utils.NewPipelineRun("test-namespace", "new", map[string]string{}, false),
+				utils.NewPipelineRun("test-namespace", "started", map[string]string{
 					tektonobserver.PipelineProcessingStateAnnotation: tektonobserver.ProcessingStartState,
 				}, true),
 			},
 			want: []reconcile.Request{
-				{
-					NamespacedName: types.NamespacedName{
-						Namespace: "test-namespace",
-						Name:      tektonobserver.ObservationCrdName,
-					},
-				},
+				{NamespacedName: types.NamespacedName{Namespace: "test-namespace", Name: "new"}},
+				{NamespacedName: types.NamespacedName{Namespace: "test-namespace", Name: "started"}},
 			},
-			wantProcessAnnotation: tektonobserver.ProcessingStartState,
 		},
 		{
-			name: "Test with pipelineRun already processed",
-			fields: fields{
-				Client: utils.NewFakeClient(utils.NewPipelineRun("test-namespace", "test-name", map[string]string{
-					tektonobserver.PipelineProcessingStateAnnotation: tektonobserver.ProcessingCompleteState,
-				}, false)),
-				Scheme: scheme.Scheme,
-			},
-			args: args{
-				ctx: context.Background(),
-				pipelineRun: utils.NewPipelineRun("test-namespace", "test-name", map[string]string{
+			name: "Test with pipelineRuns already processed or in another namespace",
+			pipelineRuns: []*tknv1.PipelineRun{
+				utils.NewPipelineRun("test-namespace", "complete", map[string]string{
 					tektonobserver.PipelineProcessingStateAnnotation: tektonobserver.ProcessingCompleteState,
-				}, false),
+				}, true),
+				utils.NewPipelineRun("other-namespace", "new", map[string]string{}, false),
 			},
-			want:                  []reconcile.Request{},
-			wantProcessAnnotation: tektonobserver.ProcessingCompleteState,
+			want: []reconcile.Request{},
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			fakeClient := utils.NewFakeClient()
+			for _, pipelineRun := range tt.pipelineRuns {
+				if err := fakeClient.Create(ctx, pipelineRun); err != nil {
+					t.Fatal(err)
+				}
+			}
 			r := &TektonObservationReconciler{
-				Client:       tt.fields.Client,
-				Scheme:       tt.fields.Scheme,
-				EventEmitter: events.NewEventEmitter(tt.fields.Client, &log, ""),
+				Client: fakeClient,
+				Scheme: scheme.Scheme,
 			}
 
-			got := r.findConfigsFromPipelineRun(tt.args.ctx, tt.args.pipelineRun)
+			got := r.findPipelineRunsFromObservation(ctx, observation)
 
 			if !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("TektonObservationReconciler.findConfigsFromPipelineRun() = %v, want %v", got, tt.want)
+				t.Errorf("TektonObservationReconciler.findPipelineRunsFromObservation() = %v, want %v", got, tt.want)
 			}
-			if tt.wantProcessAnnotation != "" {
+			// The map function does not modify anything
+			for _, pipelineRun := range tt.pipelineRuns {
 				pr := &tknv1.PipelineRun{}
-				err := r.Get(tt.args.ctx, types.NamespacedName{
-					Namespace: tt.args.pipelineRun.GetNamespace(),
-					Name:      tt.args.pipelineRun.GetName(),
-				}, pr)
-				if err != nil {
-					t.Errorf("TektonObservationReconciler.findConfigsFromPipelineRun() = %v, want %v", got, tt.want)
+				if err := fakeClient.Get(ctx, client.ObjectKeyFromObject(pipelineRun), pr); err != nil {
+					t.Fatal(err)
 				}
-				if pr.Annotations[tektonobserver.PipelineProcessingStateAnnotation] != tt.wantProcessAnnotation {
-					t.Errorf("TektonObservationReconciler.findConfigsFromPipelineRun() = %v, want %v", got, tt.want)
+				if pr.ResourceVersion != pipelineRun.ResourceVersion {
+					t.Errorf("TektonObservationReconciler.findPipelineRunsFromObservation() updated the PipelineRun %s", pr.Name)
 				}
 			}
-
+			if err := fakeClient.Get(ctx, client.ObjectKeyFromObject(observation), &obsv1.TektonObservation{}); err == nil {
+				t.Errorf("TektonObservationReconciler.findPipelineRunsFromObservation() created the TektonObservation")
+			}
 		})
 	}
 }
@@ -0,0 +1,145 @@
+package deadletter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"github.com/kcloutie/tekton-observer/internal/tektonobserver"
+	"github.com/kcloutie/tekton-observer/pkg/delivery"
+	"github.com/kcloutie/tekton-observer/pkg/metrics"
+	"github.com/kcloutie/tekton-observer/pkg/sinks"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	DefaultConfigMapPrefix = "tekton-observer-dead-letter"
+	// ConfigMapLabel is set on the ConfigMaps holding a dead-lettered delivery
+	ConfigMapLabel = tektonobserver.GroupName + "/dead-letter"
+	// ConfigMapKey is the key of the dead-lettered delivery in its ConfigMap
+	ConfigMapKey = "letter.json"
+	// DestinationLog is the name of the log destination
+	DestinationLog = "log"
+)
+
+// Letter is a delivery that exhausted its retries
+type Letter struct {
+	Namespace      string      `json:"namespace"`
+	PipelineRun    string      `json:"pipelineRun"`
+	PipelineRunUID string      `json:"pipelineRunUid,omitempty"`
+	Sink           string      `json:"sink"`
+	Phase          obsv1.Phase `json:"phase"`
+	TaskName       string      `json:"taskName,omitempty"`
+	DeliveryID     string      `json:"deliveryId,omitempty"`
+	Attempts       int         `json:"attempts,omitempty"`
+	Reason         string      `json:"reason,omitempty"`
+	Time           metav1.Time `json:"time"`
+	// Payload is the rendered PipelineRun data that could not be delivered
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// Sender sends the dead-lettered deliveries to their destinations
+type Sender struct {
+	Client    client.Client
+	Publisher sinks.PubSubPublisher
+	Log       logr.Logger
+}
+
+// Send sends the letter to every destination set, it is only logged when there is no destination. The names of the
+// destinations the letter was sent to are returned.
+func (s *Sender) Send(ctx context.Context, destination *obsv1.DeadLetterDestination, letter Letter) ([]string, error) {
+	if destination == nil {
+		destination = &obsv1.DeadLetterDestination{Log: true}
+	}
+	sent := []string{}
+	var errs []error
+	if destination.PubSubTopic != nil {
+		name := fmt.Sprintf("pubsub/%s/%s", destination.PubSubTopic.PubSubProjectID, destination.PubSubTopic.PubSubTopicID)
+		if err := s.publish(ctx, destination.PubSubTopic, letter); err != nil {
+			errs = append(errs, fmt.Errorf("failed to send the dead letter to '%s' - %w", name, err))
+			metrics.DeadLetterFailedTotal.WithLabelValues(name).Inc()
+		} else {
+			sent = append(sent, name)
+		}
+	}
+	if destination.ConfigMap != nil {
+		configMap, err := configMapName(destination.ConfigMap, letter)
+		name := fmt.Sprintf("configmap/%s", configMap)
+		if err == nil {
+			err = s.store(ctx, configMap, letter)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to send the dead letter to '%s' - %w", name, err))
+			metrics.DeadLetterFailedTotal.WithLabelValues(name).Inc()
+		} else {
+			sent = append(sent, name)
+		}
+	}
+	if destination.Log {
+		s.Log.Info("Dead-lettered delivery", "namespace", letter.Namespace, "PipelineRun", letter.PipelineRun, "sink", letter.Sink,
+			"phase", letter.Phase, "taskName", letter.TaskName, "deliveryId", letter.DeliveryID, "attempts", letter.Attempts,
+			"reason", letter.Reason, "payload", string(letter.Payload))
+		sent = append(sent, DestinationLog)
+	}
+	return sent, errors.Join(errs...)
+}
+
+func (s *Sender) publish(ctx context.Context, topic *obsv1.PubSubTopic, letter Letter) error {
+	data, err := json.Marshal(letter)
+	if err != nil {
+		return fmt.Errorf("failed to marshal the dead letter - %w", err)
+	}
+	attributes := map[string]string{
+		"deadLetter":      "true",
+		"clusterName":     tektonobserver.ControllerConfiguration.GetClusterName(),
+		"namespace":       letter.Namespace,
+		"pipelineRunName": letter.PipelineRun,
+		"pipelineRunUid":  letter.PipelineRunUID,
+		"sink":            letter.Sink,
+		"phase":           string(letter.Phase),
+	}
+	_, err = s.Publisher(ctx, topic.PubSubProjectID, topic.PubSubTopicID, data, attributes, delivery.Metadata{ID: letter.DeliveryID})
+	return err
+}
+
+func (s *Sender) store(ctx context.Context, name string, letter Letter) error {
+	data, err := json.Marshal(letter)
+	if err != nil {
+		return fmt.Errorf("failed to marshal the dead letter - %w", err)
+	}
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: letter.Namespace,
+			Name:      name,
+			Labels:    map[string]string{ConfigMapLabel: "true"},
+		},
+		Data: map[string]string{ConfigMapKey: string(data)},
+	}
+	if err := s.Client.Create(ctx, configMap); err != nil && !apierrors.IsAlreadyExists(err) {
+		return err
+	}
+	return nil
+}
+
+// configMapName returns the name of the ConfigMap of the letter, a delivery is stored only once so the letter must
+// have a delivery ID
+func configMapName(config *obsv1.ConfigMapDeadLetter, letter Letter) (string, error) {
+	prefix := config.NamePrefix
+	if prefix == "" {
+		prefix = DefaultConfigMapPrefix
+	}
+	id := letter.DeliveryID
+	if id == "" {
+		return prefix, errors.New("the dead letter has no delivery ID")
+	}
+	if len(id) > 16 {
+		id = id[:16]
+	}
+	return fmt.Sprintf("%s-%s", prefix, id), nil
+}
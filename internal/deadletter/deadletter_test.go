@@ -0,0 +1,108 @@
+package deadletter
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/go-logr/zapr"
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"github.com/kcloutie/tekton-observer/pkg/delivery"
+	"github.com/kcloutie/tekton-observer/test/utils"
+	"go.uber.org/zap/zaptest"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestSender_Send(t *testing.T) {
+	letter := Letter{
+		Namespace:   "test-namespace",
+		PipelineRun: "test-name",
+		Sink:        "pubsub/project/topic",
+		Phase:       obsv1.PhaseFinished,
+		DeliveryID:  "0123456789abcdef0123",
+		Payload:     []byte(`{"status":"Failed"}`),
+	}
+	topic := &obsv1.PubSubTopic{PubSubProjectID: "project", PubSubTopicID: "dead-letters"}
+	tests := []struct {
+		name          string
+		destination   *obsv1.DeadLetterDestination
+		noDeliveryID  bool
+		publishErr    error
+		want          []string
+		wantErr       bool
+		wantPublished int
+		wantConfigMap string
+	}{
+		{
+			name: "Test without destination",
+			want: []string{DestinationLog},
+		},
+		{
+			name:          "Test with every destination",
+			destination:   &obsv1.DeadLetterDestination{PubSubTopic: topic, ConfigMap: &obsv1.ConfigMapDeadLetter{NamePrefix: "letters"}, Log: true},
+			want:          []string{"pubsub/project/dead-letters", "configmap/letters-0123456789abcdef", DestinationLog},
+			wantPublished: 1,
+			wantConfigMap: "letters-0123456789abcdef",
+		},
+		{
+			name:          "Test with a failing destination",
+			destination:   &obsv1.DeadLetterDestination{PubSubTopic: topic, ConfigMap: &obsv1.ConfigMapDeadLetter{}},
+			publishErr:    errors.New("boom"),
+			want:          []string{"configmap/tekton-observer-dead-letter-0123456789abcdef"},
+			wantErr:       true,
+			wantPublished: 1,
+			wantConfigMap: "tekton-observer-dead-letter-0123456789abcdef",
+		},
+		{
+			name:         "Test with a letter without delivery ID",
+			destination:  &obsv1.DeadLetterDestination{ConfigMap: &obsv1.ConfigMapDeadLetter{}, Log: true},
+			noDeliveryID: true,
+			want:         []string{DestinationLog},
+			wantErr:      true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			fakeClient := utils.NewFakeClient()
+			letter := letter
+			if tt.noDeliveryID {
+				letter.DeliveryID = ""
+			}
+			published := 0
+			s := &Sender{
+				Client: fakeClient,
+				Log:    zapr.NewLogger(zaptest.NewLogger(t)),
+				Publisher: func(ctx context.Context, projectID, topicID string, data []byte, attributes map[string]string, metadata delivery.Metadata) (string, error) {
+					published++
+					if attributes["deadLetter"] != "true" || metadata.ID != letter.DeliveryID {
+						t.Errorf("unexpected attributes %v and metadata %v", attributes, metadata)
+					}
+					return "id", tt.publishErr
+				},
+			}
+
+			got, err := s.Send(ctx, tt.destination, letter)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Send() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Send() = %v, want %v", got, tt.want)
+			}
+			if published != tt.wantPublished {
+				t.Errorf("Send() published %d times, want %d", published, tt.wantPublished)
+			}
+			if tt.wantConfigMap != "" {
+				configMap := &corev1.ConfigMap{}
+				if err := fakeClient.Get(ctx, client.ObjectKey{Namespace: "test-namespace", Name: tt.wantConfigMap}, configMap); err != nil {
+					t.Fatal(err)
+				}
+				if configMap.Data[ConfigMapKey] == "" {
+					t.Errorf("Send() stored an empty dead letter")
+				}
+			}
+		})
+	}
+}
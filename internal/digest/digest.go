@@ -0,0 +1,346 @@
+package digest
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"text/template"
+	"time"
+
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"github.com/kcloutie/tekton-observer/internal/state"
+	"github.com/kcloutie/tekton-observer/internal/tektonobserver"
+	"github.com/kcloutie/tekton-observer/pkg/tekton"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	// State is the value of the state label of the ConfigMaps buffering the digests
+	State = "digest"
+	// SinkAnnotation holds the name of the sink of a digest
+	SinkAnnotation = tektonobserver.GroupName + "/digest-sink"
+	// GroupAnnotation holds the group of the PipelineRuns of a digest
+	GroupAnnotation = tektonobserver.GroupName + "/digest-group"
+	// WindowStartAnnotation is the time the first PipelineRun of a digest was buffered
+	WindowStartAnnotation = tektonobserver.GroupName + "/digest-window-start"
+	// RunsKey is the key of the buffered PipelineRuns in the ConfigMap of a digest
+	RunsKey = "runs.json"
+
+	DefaultWindow  = 10 * time.Minute
+	DefaultMaxRuns = 50
+	// DefaultTemplate renders the number of failed and succeeded PipelineRuns followed by the failed PipelineRuns
+	DefaultTemplate = `{{.Failed}} failed, {{.Succeeded}} succeeded{{range .FailedRuns}}
+- {{.Namespace}}/{{.PipelineRun}}{{if .Reason}} ({{.Reason}}){{end}}{{if .URL}} {{.URL}}{{end}}{{end}}`
+)
+
+// Run is a PipelineRun buffered in a digest
+type Run struct {
+	UID            string       `json:"uid"`
+	Namespace      string       `json:"namespace"`
+	PipelineRun    string       `json:"pipelineRun"`
+	Pipeline       string       `json:"pipeline,omitempty"`
+	Repository     string       `json:"repository,omitempty"`
+	Status         string       `json:"status"`
+	Reason         string       `json:"reason,omitempty"`
+	URL            string       `json:"url,omitempty"`
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	// Transition is the streak transition of the PipelineRun, such as first-failure or recovered
+	Transition tekton.Transition `json:"transition,omitempty"`
+}
+
+// Summary is the digest delivered to the sink once its window elapsed or it is full
+type Summary struct {
+	Sink        string              `json:"sink"`
+	GroupBy     obsv1.DigestGroupBy `json:"groupBy"`
+	Group       string              `json:"group"`
+	WindowStart metav1.Time         `json:"windowStart"`
+	WindowEnd   metav1.Time         `json:"windowEnd"`
+	Total       int                 `json:"total"`
+	Succeeded   int                 `json:"succeeded"`
+	Failed      int                 `json:"failed"`
+	// Other counts the PipelineRuns that neither succeeded nor failed, such as the aborted ones
+	Other      int    `json:"other"`
+	FailedRuns []Run  `json:"failedRuns,omitempty"`
+	Runs       []Run  `json:"runs"`
+	Text       string `json:"text"`
+}
+
+// NewRun returns the buffered PipelineRun of the data
+func NewRun(data *tekton.PipelineRunData) Run {
+	run := Run{
+		UID:            fmt.Sprintf("%s/%s", data.Namespace, data.PipelineRunName),
+		Namespace:      data.Namespace,
+		PipelineRun:    data.PipelineRunName,
+		Pipeline:       data.PipelineName,
+		Repository:     data.PacLabels["repository"],
+		Status:         data.Status,
+		Reason:         data.Reason,
+		CompletionTime: data.CompletionTime,
+	}
+	if data.RawPipelineRun != nil {
+		run.UID = string(data.RawPipelineRun.UID)
+	}
+	if data.Streak != nil {
+		run.Transition = data.Streak.Transition
+	}
+	// A dashboard link is only a convenience, the digest is sent without it when the template fails
+	if url, err := tektonobserver.ControllerConfiguration.RenderDashboardURL(data); err == nil {
+		run.URL = url
+	}
+	return run
+}
+
+// GroupBy returns how the PipelineRuns of the policy are grouped
+func GroupBy(policy *obsv1.DigestPolicy) obsv1.DigestGroupBy {
+	if policy == nil || policy.GroupBy == "" {
+		return obsv1.DigestByPipeline
+	}
+	return policy.GroupBy
+}
+
+// Window returns how long the PipelineRuns of the policy are buffered
+func Window(policy *obsv1.DigestPolicy) time.Duration {
+	if policy == nil || policy.Window == nil || policy.Window.Duration <= 0 {
+		return DefaultWindow
+	}
+	return policy.Window.Duration
+}
+
+// MaxRuns returns the number of PipelineRuns sending the digest of the policy before its window elapsed
+func MaxRuns(policy *obsv1.DigestPolicy) int {
+	if policy == nil || policy.MaxRuns < 1 {
+		return DefaultMaxRuns
+	}
+	return int(policy.MaxRuns)
+}
+
+// Group returns the group of the PipelineRun
+func Group(policy *obsv1.DigestPolicy, run Run) string {
+	switch GroupBy(policy) {
+	case obsv1.DigestByRepository:
+		if run.Repository != "" {
+			return fmt.Sprintf("%s/%s", run.Namespace, run.Repository)
+		}
+		return run.Namespace
+	case obsv1.DigestByNamespace:
+		return run.Namespace
+	}
+	return fmt.Sprintf("%s/%s", run.Namespace, run.Pipeline)
+}
+
+// Name returns the name of the ConfigMap buffering the digest of the group for the sink
+func Name(sink, group string) string {
+	sum := sha256.Sum256([]byte(sink + "\x00" + group))
+	return fmt.Sprintf("tekton-observer-digest-%s", hex.EncodeToString(sum[:8]))
+}
+
+// Add buffers the PipelineRun in the digest of its group, the ConfigMap of the digest is created by the first
+// PipelineRun of the group and is owned by the observation. A PipelineRun already buffered is not added twice. The
+// updated ConfigMap is returned.
+func Add(ctx context.Context, c client.Client, observation *obsv1.TektonObservation, sink string, policy *obsv1.DigestPolicy, run Run, now time.Time) (*corev1.ConfigMap, error) {
+	group := Group(policy, run)
+	key := client.ObjectKey{Namespace: observation.Namespace, Name: Name(sink, group)}
+	configMap, err := state.Update(ctx, c, key, func() (*corev1.ConfigMap, error) {
+		return newConfigMap(c, observation, key, sink, group, now)
+	}, func(configMap *corev1.ConfigMap) (bool, error) {
+		runs, err := Runs(configMap)
+		if err != nil {
+			return false, err
+		}
+		for _, buffered := range runs {
+			if buffered.UID == run.UID {
+				return false, nil
+			}
+		}
+		if err := setRuns(configMap, append(runs, run)); err != nil {
+			return false, err
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to buffer the PipelineRun '%s' in the digest '%s' - %w", run.PipelineRun, key.Name, err)
+	}
+	return configMap, nil
+}
+
+func newConfigMap(c client.Client, observation *obsv1.TektonObservation, key client.ObjectKey, sink, group string, now time.Time) (*corev1.ConfigMap, error) {
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: key.Namespace,
+			Name:      key.Name,
+			Labels:    map[string]string{tektonobserver.StateConfigMapLabel: State},
+			Annotations: map[string]string{
+				SinkAnnotation:        sink,
+				GroupAnnotation:       group,
+				WindowStartAnnotation: now.UTC().Format(time.RFC3339),
+			},
+		},
+	}
+	if err := controllerutil.SetControllerReference(observation, configMap, c.Scheme()); err != nil {
+		return nil, fmt.Errorf("failed to set the owner of the digest - %w", err)
+	}
+	return configMap, nil
+}
+
+// Runs returns the PipelineRuns buffered in the ConfigMap of a digest
+func Runs(configMap *corev1.ConfigMap) ([]Run, error) {
+	runs := []Run{}
+	raw, found := configMap.Data[RunsKey]
+	if !found {
+		return runs, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &runs); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal the PipelineRuns of the digest '%s' - %w", configMap.Name, err)
+	}
+	return runs, nil
+}
+
+func setRuns(configMap *corev1.ConfigMap, runs []Run) error {
+	raw, err := json.Marshal(runs)
+	if err != nil {
+		return fmt.Errorf("failed to marshal the PipelineRuns of the digest - %w", err)
+	}
+	if configMap.Data == nil {
+		configMap.Data = map[string]string{}
+	}
+	configMap.Data[RunsKey] = string(raw)
+	return nil
+}
+
+// WindowStart returns the time the first PipelineRun of the digest was buffered, the creation time of the ConfigMap
+// is used when the annotation is invalid
+func WindowStart(configMap *corev1.ConfigMap) time.Time {
+	if start, err := time.Parse(time.RFC3339, configMap.Annotations[WindowStartAnnotation]); err == nil {
+		return start
+	}
+	return configMap.CreationTimestamp.Time
+}
+
+// Due returns true when the window of the digest elapsed or the digest is full. The time left before the window
+// elapses is returned otherwise.
+func Due(configMap *corev1.ConfigMap, policy *obsv1.DigestPolicy, now time.Time) (bool, time.Duration, error) {
+	runs, err := Runs(configMap)
+	if err != nil {
+		return false, 0, err
+	}
+	if len(runs) >= MaxRuns(policy) {
+		return true, 0, nil
+	}
+	left := WindowStart(configMap).Add(Window(policy)).Sub(now)
+	if left <= 0 {
+		return true, 0, nil
+	}
+	return false, left, nil
+}
+
+// Summarize returns the summary of the buffered PipelineRuns, its text is not rendered
+func Summarize(configMap *corev1.ConfigMap, policy *obsv1.DigestPolicy, now time.Time) (Summary, error) {
+	runs, err := Runs(configMap)
+	if err != nil {
+		return Summary{}, err
+	}
+	sort.SliceStable(runs, func(i, j int) bool {
+		return completedAt(runs[i]).Before(completedAt(runs[j]))
+	})
+	summary := Summary{
+		Sink:        configMap.Annotations[SinkAnnotation],
+		GroupBy:     GroupBy(policy),
+		Group:       configMap.Annotations[GroupAnnotation],
+		WindowStart: metav1.Time{Time: WindowStart(configMap)},
+		WindowEnd:   metav1.Time{Time: now},
+		Total:       len(runs),
+		FailedRuns:  []Run{},
+		Runs:        runs,
+	}
+	for _, run := range runs {
+		switch run.Status {
+		case tekton.StatusSucceeded:
+			summary.Succeeded++
+		case tekton.StatusFailed:
+			summary.Failed++
+			summary.FailedRuns = append(summary.FailedRuns, run)
+		default:
+			summary.Other++
+		}
+	}
+	return summary, nil
+}
+
+// Render renders the text of the summary with the template of the policy, DefaultTemplate is used when the policy
+// has no template
+func Render(policy *obsv1.DigestPolicy, summary Summary) (string, error) {
+	tmpl := DefaultTemplate
+	if policy != nil && policy.Template != "" {
+		tmpl = policy.Template
+	}
+	return render(tmpl, summary)
+}
+
+func render(tmpl string, summary Summary) (string, error) {
+	t, err := template.New("digest").Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse the digest template - %w", err)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, summary); err != nil {
+		return "", fmt.Errorf("failed to render the digest template - %w", err)
+	}
+	return buf.String(), nil
+}
+
+func completedAt(run Run) time.Time {
+	if run.CompletionTime == nil {
+		return time.Time{}
+	}
+	return run.CompletionTime.Time
+}
+
+// Remove removes the delivered PipelineRuns from the digest. The ConfigMap is deleted when no PipelineRun was
+// buffered in the meantime, the window of the digest restarts otherwise.
+func Remove(ctx context.Context, c client.Client, configMap *corev1.ConfigMap, delivered []Run, now time.Time) error {
+	deliveredUIDs := map[string]bool{}
+	for _, run := range delivered {
+		deliveredUIDs[run.UID] = true
+	}
+	current := configMap.DeepCopy()
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		runs, err := Runs(current)
+		if err != nil {
+			return err
+		}
+		left := []Run{}
+		for _, run := range runs {
+			if !deliveredUIDs[run.UID] {
+				left = append(left, run)
+			}
+		}
+		if len(left) == 0 {
+			err = c.Delete(ctx, current, client.Preconditions{ResourceVersion: &current.ResourceVersion})
+		} else {
+			if err = setRuns(current, left); err != nil {
+				return err
+			}
+			current.Annotations[WindowStartAnnotation] = now.UTC().Format(time.RFC3339)
+			err = c.Update(ctx, current)
+		}
+		if apierrors.IsConflict(err) {
+			if getErr := c.Get(ctx, client.ObjectKeyFromObject(configMap), current); getErr != nil {
+				return getErr
+			}
+		}
+		return err
+	})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to remove the delivered PipelineRuns from the digest '%s' - %w", configMap.Name, err)
+	}
+	return nil
+}
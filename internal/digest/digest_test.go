@@ -0,0 +1,249 @@
+package digest
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"github.com/kcloutie/tekton-observer/internal/tektonobserver"
+	"github.com/kcloutie/tekton-observer/pkg/tekton"
+	"github.com/kcloutie/tekton-observer/test/utils"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func newRun(name, status, repository string) Run {
+	return NewRun(&tekton.PipelineRunData{
+		Namespace:       "test-namespace",
+		PipelineRunName: name,
+		PipelineName:    "nightly",
+		PacLabels:       map[string]string{"repository": repository},
+		Status:          status,
+		Reason:          status,
+	})
+}
+
+func TestGroup(t *testing.T) {
+	tests := []struct {
+		name    string
+		groupBy obsv1.DigestGroupBy
+		run     Run
+		want    string
+	}{
+		{
+			name: "Test with the default grouping",
+			run:  newRun("run-1", tekton.StatusFailed, "repo"),
+			want: "test-namespace/nightly",
+		},
+		{
+			name:    "Test with a repository",
+			groupBy: obsv1.DigestByRepository,
+			run:     newRun("run-1", tekton.StatusFailed, "repo"),
+			want:    "test-namespace/repo",
+		},
+		{
+			name:    "Test without a repository",
+			groupBy: obsv1.DigestByRepository,
+			run:     newRun("run-1", tekton.StatusFailed, ""),
+			want:    "test-namespace",
+		},
+		{
+			name:    "Test with a namespace",
+			groupBy: obsv1.DigestByNamespace,
+			run:     newRun("run-1", tekton.StatusFailed, "repo"),
+			want:    "test-namespace",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Group(&obsv1.DigestPolicy{GroupBy: tt.groupBy}, tt.run); got != tt.want {
+				t.Errorf("Group() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdd(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	observation := &obsv1.TektonObservation{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: tektonobserver.ObservationCrdName, UID: "observation-uid"},
+	}
+	policy := &obsv1.DigestPolicy{MaxRuns: 3, Window: &metav1.Duration{Duration: 10 * time.Minute}}
+	fakeClient := utils.NewFakeClient(observation)
+
+	runs := []Run{
+		newRun("run-1", tekton.StatusFailed, ""),
+		newRun("run-2", tekton.StatusSucceeded, ""),
+		newRun("run-1", tekton.StatusFailed, ""),
+	}
+	var configMap *corev1.ConfigMap
+	for i, run := range runs {
+		var err error
+		configMap, err = Add(ctx, fakeClient, observation, "pubsub/project/topic", policy, run, now.Add(time.Duration(i)*time.Minute))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	buffered, err := Runs(configMap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buffered) != 2 {
+		t.Errorf("Add() buffered %d PipelineRuns, want 2", len(buffered))
+	}
+	if configMap.Labels[tektonobserver.StateConfigMapLabel] != State || len(configMap.OwnerReferences) != 1 {
+		t.Errorf("Add() created the ConfigMap with the labels %v and the owners %v", configMap.Labels, configMap.OwnerReferences)
+	}
+	if !WindowStart(configMap).Equal(now) {
+		t.Errorf("WindowStart() = %v, want %v", WindowStart(configMap), now)
+	}
+
+	dueTests := []struct {
+		name     string
+		at       time.Duration
+		wantDue  bool
+		wantLeft time.Duration
+	}{
+		{name: "Test within the window", at: 4 * time.Minute, wantLeft: 6 * time.Minute},
+		{name: "Test with an elapsed window", at: 10 * time.Minute, wantDue: true},
+	}
+	for _, tt := range dueTests {
+		t.Run(tt.name, func(t *testing.T) {
+			due, left, err := Due(configMap, policy, now.Add(tt.at))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if due != tt.wantDue || left != tt.wantLeft {
+				t.Errorf("Due() = %v, %v, want %v, %v", due, left, tt.wantDue, tt.wantLeft)
+			}
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{GroupAnnotation: "test-namespace/nightly"}},
+	}
+	if err := setRuns(configMap, []Run{
+		newRun("run-1", tekton.StatusFailed, ""),
+		newRun("run-2", tekton.StatusSucceeded, ""),
+		newRun("run-3", tekton.StatusSucceeded, ""),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	summary, err := Summarize(configMap, nil, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name: "Test with the default template",
+			want: "1 failed, 2 succeeded\n- test-namespace/run-1 (Failed)",
+		},
+		{
+			name:     "Test with a template",
+			template: "{{.Group}}: {{.Failed}}/{{.Total}} failed",
+			want:     "test-namespace/nightly: 1/3 failed",
+		},
+		{
+			name:     "Test with an invalid template",
+			template: "{{.Unknown}",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Render(&obsv1.DigestPolicy{Template: tt.template}, summary)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Render() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Render() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemove(t *testing.T) {
+	tests := []struct {
+		name       string
+		delivered  []string
+		wantDelete bool
+		wantLeft   []string
+	}{
+		{
+			name:       "Test with every PipelineRun delivered",
+			delivered:  []string{"run-1", "run-2"},
+			wantDelete: true,
+		},
+		{
+			name:      "Test with a PipelineRun buffered in the meantime",
+			delivered: []string{"run-1"},
+			wantLeft:  []string{"run-2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			observation := &obsv1.TektonObservation{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: tektonobserver.ObservationCrdName, UID: "observation-uid"},
+			}
+			fakeClient := utils.NewFakeClient(observation)
+			configMap, err := Add(ctx, fakeClient, observation, "sink", nil, newRun("run-1", tekton.StatusFailed, ""), now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			stale := configMap.DeepCopy()
+			if _, err := Add(ctx, fakeClient, observation, "sink", nil, newRun("run-2", tekton.StatusFailed, ""), now); err != nil {
+				t.Fatal(err)
+			}
+
+			delivered := []Run{}
+			for _, name := range tt.delivered {
+				delivered = append(delivered, newRun(name, tekton.StatusFailed, ""))
+			}
+			if err := Remove(ctx, fakeClient, stale, delivered, now.Add(time.Minute)); err != nil {
+				t.Fatal(err)
+			}
+
+			current := &corev1.ConfigMap{}
+			err = fakeClient.Get(ctx, client.ObjectKeyFromObject(configMap), current)
+			if tt.wantDelete {
+				if !apierrors.IsNotFound(err) {
+					t.Errorf("Remove() did not delete the digest - %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			left, err := Runs(current)
+			if err != nil {
+				t.Fatal(err)
+			}
+			names := []string{}
+			for _, run := range left {
+				names = append(names, run.PipelineRun)
+			}
+			if strings.Join(names, ",") != strings.Join(tt.wantLeft, ",") {
+				t.Errorf("Remove() left %v, want %v", names, tt.wantLeft)
+			}
+			if !WindowStart(current).Equal(now.Add(time.Minute)) {
+				t.Errorf("Remove() did not restart the window")
+			}
+		})
+	}
+}
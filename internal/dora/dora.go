@@ -0,0 +1,434 @@
+package dora
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"github.com/kcloutie/tekton-observer/internal/state"
+	"github.com/kcloutie/tekton-observer/internal/tektonobserver"
+	"github.com/kcloutie/tekton-observer/pkg/tekton"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	// State is the value of the state label of the ConfigMap storing the deployment histories
+	State = "dora"
+	// ConfigMapName is the name of the ConfigMap storing the deployment histories of a namespace
+	ConfigMapName = "tekton-observer-dora"
+	// EnvironmentLabel flags a PipelineRun as a deployment to the environment of its value
+	EnvironmentLabel = tektonobserver.GroupName + "/environment"
+	// CommitTimestampAnnotation is the time of the commit deployed by a PipelineRun in RFC 3339 format. When it is not
+	// set, the lead time starts when the first PipelineRun of the commit was created.
+	CommitTimestampAnnotation = tektonobserver.GroupName + "/commit-timestamp"
+	// LastSummaryAnnotation is the end of the period of the last summary delivered to the sinks
+	LastSummaryAnnotation = tektonobserver.GroupName + "/dora-last-summary"
+	// CommitsKey is the key of the creation time of the first PipelineRun of the commits in the ConfigMap
+	CommitsKey = "commits.json"
+
+	DefaultWindow          = 7 * 24 * time.Hour
+	DefaultSummaryInterval = 24 * time.Hour
+	// CommitRetention is how long the first PipelineRun of a commit is remembered
+	CommitRetention = 30 * 24 * time.Hour
+)
+
+// Deployment is a finished PipelineRun of a deployment pipeline
+type Deployment struct {
+	UID            string      `json:"uid"`
+	PipelineRun    string      `json:"pipelineRun"`
+	SHA            string      `json:"sha,omitempty"`
+	Succeeded      bool        `json:"succeeded"`
+	CompletionTime metav1.Time `json:"completionTime"`
+	// LeadTimeSeconds is the time from the commit to the completion of the deployment, it is only set for the
+	// successful deployments of a known commit
+	LeadTimeSeconds *float64 `json:"leadTimeSeconds,omitempty"`
+}
+
+// Restore is a successful deployment following failed deployments
+type Restore struct {
+	Time metav1.Time `json:"time"`
+	// Seconds is the time from the first failed deployment to the successful deployment
+	Seconds float64 `json:"seconds"`
+}
+
+// Record is the deployment history of a repository to an environment over the window
+type Record struct {
+	Repository  string       `json:"repository"`
+	Environment string       `json:"environment"`
+	Deployments []Deployment `json:"deployments"`
+	// FailingSince is the completion time of the first failed deployment since the last successful deployment
+	FailingSince *metav1.Time `json:"failingSince,omitempty"`
+	Restores     []Restore    `json:"restores,omitempty"`
+}
+
+// Metrics are the DORA metrics of a repository and environment over the window
+type Metrics struct {
+	Repository        string  `json:"repository"`
+	Environment       string  `json:"environment"`
+	Deployments       int     `json:"deployments"`
+	Failed            int     `json:"failed"`
+	DeploymentsPerDay float64 `json:"deploymentsPerDay"`
+	// LeadTimeSeconds is the median lead time of the successful deployments, it is 0 when no lead time is known
+	LeadTimeSeconds   float64 `json:"leadTimeSeconds"`
+	ChangeFailureRate float64 `json:"changeFailureRate"`
+	// TimeToRestoreSeconds is the median time to restore of the restores, it is 0 when nothing was restored
+	TimeToRestoreSeconds float64 `json:"timeToRestoreSeconds"`
+	// Failing is true when the last deployment failed
+	Failing bool `json:"failing"`
+}
+
+// Summary is delivered periodically to the sinks subscribed to the dora-summary phase
+type Summary struct {
+	Namespace    string      `json:"namespace"`
+	WindowStart  metav1.Time `json:"windowStart"`
+	WindowEnd    metav1.Time `json:"windowEnd"`
+	Environments []Metrics   `json:"environments"`
+}
+
+// Environment returns the environment the PipelineRun deploys to, it is empty when the PipelineRun is not a
+// deployment
+func Environment(policy *obsv1.DoraPolicy, data *tekton.PipelineRunData) string {
+	if data.RawPipelineRun != nil && data.RawPipelineRun.Labels[EnvironmentLabel] != "" {
+		return data.RawPipelineRun.Labels[EnvironmentLabel]
+	}
+	for _, deployment := range policy.Deployments {
+		if deployment.Pipeline == data.PipelineName {
+			return deployment.Environment
+		}
+	}
+	return ""
+}
+
+// Repository returns the Pipelines-as-Code repository of the PipelineRun, or its pipeline when it is not set
+func Repository(data *tekton.PipelineRunData) string {
+	if repository := data.PacLabels["repository"]; repository != "" {
+		return repository
+	}
+	return data.PipelineName
+}
+
+// Window returns the period the metrics are computed over
+func Window(policy *obsv1.DoraPolicy) time.Duration {
+	if policy == nil || policy.Window == nil || policy.Window.Duration <= 0 {
+		return DefaultWindow
+	}
+	return policy.Window.Duration
+}
+
+// SummaryInterval returns how often the summary is delivered
+func SummaryInterval(policy *obsv1.DoraPolicy) time.Duration {
+	if policy == nil || policy.SummaryInterval == nil || policy.SummaryInterval.Duration <= 0 {
+		return DefaultSummaryInterval
+	}
+	return policy.SummaryInterval.Duration
+}
+
+// Key returns the key of the history of the repository and environment in the ConfigMap
+func Key(repository, environment string) string {
+	sum := sha256.Sum256([]byte(repository + "\x00" + environment))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Next returns the history following the deployment, the deployments and restores that left the window are removed
+func Next(record Record, deployment Deployment, window time.Duration, now time.Time) Record {
+	if !deployment.Succeeded && record.FailingSince == nil {
+		failingSince := deployment.CompletionTime
+		record.FailingSince = &failingSince
+	}
+	if deployment.Succeeded && record.FailingSince != nil {
+		record.Restores = append(record.Restores, Restore{
+			Time:    deployment.CompletionTime,
+			Seconds: deployment.CompletionTime.Sub(record.FailingSince.Time).Seconds(),
+		})
+		record.FailingSince = nil
+	}
+	record.Deployments = append(record.Deployments, deployment)
+	sort.SliceStable(record.Deployments, func(i, j int) bool {
+		return record.Deployments[i].CompletionTime.Before(&record.Deployments[j].CompletionTime)
+	})
+
+	start := now.Add(-window)
+	record.Deployments = slices.DeleteFunc(record.Deployments, func(d Deployment) bool { return d.CompletionTime.Time.Before(start) })
+	record.Restores = slices.DeleteFunc(record.Restores, func(r Restore) bool { return r.Time.Time.Before(start) })
+	return record
+}
+
+// Compute returns the metrics of the history over the window ending at now
+func Compute(record Record, window time.Duration, now time.Time) Metrics {
+	metrics := Metrics{Repository: record.Repository, Environment: record.Environment, Failing: record.FailingSince != nil}
+	start := now.Add(-window)
+	leadTimes := []float64{}
+	for _, deployment := range record.Deployments {
+		if deployment.CompletionTime.Time.Before(start) || deployment.CompletionTime.Time.After(now) {
+			continue
+		}
+		metrics.Deployments++
+		if !deployment.Succeeded {
+			metrics.Failed++
+		} else if deployment.LeadTimeSeconds != nil {
+			leadTimes = append(leadTimes, *deployment.LeadTimeSeconds)
+		}
+	}
+	restores := []float64{}
+	for _, restore := range record.Restores {
+		if !restore.Time.Time.Before(start) && !restore.Time.Time.After(now) {
+			restores = append(restores, restore.Seconds)
+		}
+	}
+	metrics.DeploymentsPerDay = float64(metrics.Deployments) / (window.Hours() / 24)
+	if metrics.Deployments > 0 {
+		metrics.ChangeFailureRate = float64(metrics.Failed) / float64(metrics.Deployments)
+	}
+	metrics.LeadTimeSeconds = median(leadTimes)
+	metrics.TimeToRestoreSeconds = median(restores)
+	return metrics
+}
+
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	slices.Sort(values)
+	middle := len(values) / 2
+	if len(values)%2 == 0 {
+		return (values[middle-1] + values[middle]) / 2
+	}
+	return values[middle]
+}
+
+// Update remembers the commit of the finished PipelineRun and, when the PipelineRun is a deployment that succeeded or
+// failed, records it in the history of its repository and environment. The metrics of the history are returned for
+// the deployments, nil is returned for the other PipelineRuns and for the deployments already recorded.
+func Update(ctx context.Context, c client.Client, observation *obsv1.TektonObservation, data *tekton.PipelineRunData, uid string, now time.Time) (*Metrics, error) {
+	policy := observation.Spec.Dora
+	environment := Environment(policy, data)
+	deployment := environment != "" && (data.Status == tekton.StatusSucceeded || data.Status == tekton.StatusFailed)
+	sha := data.PacLabels["sha"]
+	if !deployment && sha == "" {
+		return nil, nil
+	}
+	repository := Repository(data)
+	key := Key(repository, environment)
+	window := Window(policy)
+
+	var metrics *Metrics
+	_, err := state.Update(ctx, c, client.ObjectKey{Namespace: observation.Namespace, Name: ConfigMapName}, func() (*corev1.ConfigMap, error) {
+		return newConfigMap(c, observation, now)
+	}, func(configMap *corev1.ConfigMap) (bool, error) {
+		metrics = nil
+		commits, err := getCommits(configMap)
+		if err != nil {
+			return false, err
+		}
+		changed := rememberCommit(commits, sha, data, now)
+
+		if deployment {
+			record, err := decode(configMap, key)
+			if err != nil {
+				return false, err
+			}
+			if record == nil {
+				record = &Record{Repository: repository, Environment: environment}
+			}
+			if !slices.ContainsFunc(record.Deployments, func(d Deployment) bool { return d.UID == uid }) {
+				next := Next(*record, newDeployment(data, uid, commits, now), window, now)
+				raw, err := json.Marshal(next)
+				if err != nil {
+					return false, fmt.Errorf("failed to marshal the deployment history - %w", err)
+				}
+				configMap.Data[key] = string(raw)
+				computed := Compute(next, window, now)
+				metrics = &computed
+				changed = true
+			}
+		}
+		if !changed {
+			return false, nil
+		}
+		raw, err := json.Marshal(commits)
+		if err != nil {
+			return false, fmt.Errorf("failed to marshal the commits - %w", err)
+		}
+		configMap.Data[CommitsKey] = string(raw)
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to record the deployment of the pipeline '%s' - %w", data.PipelineName, err)
+	}
+	return metrics, nil
+}
+
+// newDeployment returns the deployment of the PipelineRun. The lead time starts at the commit timestamp annotation,
+// or at the creation of the first PipelineRun of the commit.
+func newDeployment(data *tekton.PipelineRunData, uid string, commits map[string]metav1.Time, now time.Time) Deployment {
+	deployment := Deployment{
+		UID:            uid,
+		PipelineRun:    data.PipelineRunName,
+		SHA:            data.PacLabels["sha"],
+		Succeeded:      data.Status == tekton.StatusSucceeded,
+		CompletionTime: metav1.Time{Time: now},
+	}
+	if data.CompletionTime != nil {
+		deployment.CompletionTime = *data.CompletionTime
+	}
+	if !deployment.Succeeded {
+		return deployment
+	}
+	var committed *time.Time
+	if data.RawPipelineRun != nil {
+		if timestamp, err := time.Parse(time.RFC3339, data.RawPipelineRun.Annotations[CommitTimestampAnnotation]); err == nil {
+			committed = &timestamp
+		}
+	}
+	if firstSeen, found := commits[deployment.SHA]; committed == nil && found {
+		committed = &firstSeen.Time
+	}
+	if committed != nil && !deployment.CompletionTime.Time.Before(*committed) {
+		leadTime := deployment.CompletionTime.Sub(*committed).Seconds()
+		deployment.LeadTimeSeconds = &leadTime
+	}
+	return deployment
+}
+
+// rememberCommit keeps the creation time of the first PipelineRun of the commit and forgets the commits older than
+// the retention, it returns true when the commits changed
+func rememberCommit(commits map[string]metav1.Time, sha string, data *tekton.PipelineRunData, now time.Time) bool {
+	changed := false
+	for commit, firstSeen := range commits {
+		if now.Sub(firstSeen.Time) > CommitRetention {
+			delete(commits, commit)
+			changed = true
+		}
+	}
+	if sha == "" {
+		return changed
+	}
+	created := metav1.Time{Time: now}
+	if data.RawPipelineRun != nil && !data.RawPipelineRun.CreationTimestamp.IsZero() {
+		created = data.RawPipelineRun.CreationTimestamp
+	}
+	if firstSeen, found := commits[sha]; !found || created.Before(&firstSeen) {
+		commits[sha] = created
+		changed = true
+	}
+	return changed
+}
+
+func getCommits(configMap *corev1.ConfigMap) (map[string]metav1.Time, error) {
+	commits := map[string]metav1.Time{}
+	raw, found := configMap.Data[CommitsKey]
+	if !found {
+		return commits, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &commits); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal the commits - %w", err)
+	}
+	return commits, nil
+}
+
+// Records returns every deployment history stored in the ConfigMap, sorted by repository and environment
+func Records(configMap *corev1.ConfigMap) ([]Record, error) {
+	records := []Record{}
+	for key := range configMap.Data {
+		if key == CommitsKey {
+			continue
+		}
+		record, err := decode(configMap, key)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, *record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Repository != records[j].Repository {
+			return records[i].Repository < records[j].Repository
+		}
+		return records[i].Environment < records[j].Environment
+	})
+	return records, nil
+}
+
+// Due returns true when a summary is due, with the end of the period of the summary. The end is the last due time,
+// so the summaries missed while the controller was down are sent as one. The time left before the next summary is
+// due is returned when it is not due.
+func Due(configMap *corev1.ConfigMap, interval time.Duration, now time.Time) (bool, time.Time, time.Duration, error) {
+	last, err := time.Parse(time.RFC3339, configMap.Annotations[LastSummaryAnnotation])
+	if err != nil {
+		return false, time.Time{}, 0, fmt.Errorf("failed to parse the time of the last DORA summary - %w", err)
+	}
+	elapsed := now.Sub(last)
+	if elapsed < interval {
+		return false, time.Time{}, interval - elapsed, nil
+	}
+	return true, last.Add(elapsed / interval * interval), 0, nil
+}
+
+// Summarize returns the summary of the metrics of every history over the window ending at end
+func Summarize(configMap *corev1.ConfigMap, window time.Duration, end time.Time) (Summary, error) {
+	records, err := Records(configMap)
+	if err != nil {
+		return Summary{}, err
+	}
+	summary := Summary{
+		Namespace:    configMap.Namespace,
+		WindowStart:  metav1.Time{Time: end.Add(-window)},
+		WindowEnd:    metav1.Time{Time: end},
+		Environments: []Metrics{},
+	}
+	for _, record := range records {
+		summary.Environments = append(summary.Environments, Compute(record, window, end))
+	}
+	return summary, nil
+}
+
+// MarkSummarized records the end of the period of the summary delivered to the sinks
+func MarkSummarized(ctx context.Context, c client.Client, configMap *corev1.ConfigMap, end time.Time) error {
+	patch := client.MergeFrom(configMap.DeepCopy())
+	if configMap.Annotations == nil {
+		configMap.Annotations = map[string]string{}
+	}
+	configMap.Annotations[LastSummaryAnnotation] = end.UTC().Format(time.RFC3339)
+	if err := c.Patch(ctx, configMap, patch); err != nil {
+		return fmt.Errorf("failed to record the DORA summary - %w", err)
+	}
+	return nil
+}
+
+func newConfigMap(c client.Client, observation *obsv1.TektonObservation, now time.Time) (*corev1.ConfigMap, error) {
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: observation.Namespace,
+			Name:      ConfigMapName,
+			Labels:    map[string]string{tektonobserver.StateConfigMapLabel: State},
+			// The first summary is sent an interval after the first recorded PipelineRun
+			Annotations: map[string]string{LastSummaryAnnotation: now.UTC().Format(time.RFC3339)},
+		},
+		Data: map[string]string{},
+	}
+	// The histories are not reconciled with the observation, the owner only removes them with it
+	if err := controllerutil.SetOwnerReference(observation, configMap, c.Scheme()); err != nil {
+		return nil, fmt.Errorf("failed to set the owner of the deployment histories - %w", err)
+	}
+	return configMap, nil
+}
+
+func decode(configMap *corev1.ConfigMap, key string) (*Record, error) {
+	raw, found := configMap.Data[key]
+	if !found {
+		return nil, nil
+	}
+	record := &Record{}
+	if err := json.Unmarshal([]byte(raw), record); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal the deployment history '%s' - %w", key, err)
+	}
+	return record, nil
+}
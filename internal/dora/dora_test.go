@@ -0,0 +1,244 @@
+package dora
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"github.com/kcloutie/tekton-observer/internal/tektonobserver"
+	"github.com/kcloutie/tekton-observer/pkg/tekton"
+	"github.com/kcloutie/tekton-observer/test/utils"
+	tknv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestCompute(t *testing.T) {
+	now := time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC)
+	at := func(hours int) metav1.Time { return metav1.Time{Time: now.Add(time.Duration(hours) * time.Hour)} }
+	seconds := func(value float64) *float64 { return &value }
+	tests := []struct {
+		name              string
+		deployments       []Deployment
+		wantDeployments   int
+		wantFailureRate   float64
+		wantLeadTime      float64
+		wantTimeToRestore float64
+		wantFailing       bool
+	}{
+		{
+			name: "Test with successful deployments",
+			deployments: []Deployment{
+				{UID: "1", Succeeded: true, CompletionTime: at(-48), LeadTimeSeconds: seconds(100)},
+				{UID: "2", Succeeded: true, CompletionTime: at(-24), LeadTimeSeconds: seconds(300)},
+			},
+			wantDeployments: 2,
+			wantLeadTime:    200,
+		},
+		{
+			name: "Test with a restored failure",
+			deployments: []Deployment{
+				{UID: "1", Succeeded: false, CompletionTime: at(-10)},
+				{UID: "2", Succeeded: false, CompletionTime: at(-9)},
+				{UID: "3", Succeeded: true, CompletionTime: at(-8)},
+				{UID: "4", Succeeded: true, CompletionTime: at(-7)},
+			},
+			wantDeployments:   4,
+			wantFailureRate:   0.5,
+			wantTimeToRestore: 2 * 3600,
+		},
+		{
+			name: "Test with a failing environment",
+			deployments: []Deployment{
+				{UID: "1", Succeeded: true, CompletionTime: at(-2)},
+				{UID: "2", Succeeded: false, CompletionTime: at(-1)},
+			},
+			wantDeployments: 2,
+			wantFailureRate: 0.5,
+			wantFailing:     true,
+		},
+		{
+			name: "Test with a failure out of the window restored in the window",
+			deployments: []Deployment{
+				{UID: "1", Succeeded: false, CompletionTime: at(-24 * 8)},
+				{UID: "2", Succeeded: true, CompletionTime: at(-1)},
+			},
+			wantDeployments:   1,
+			wantTimeToRestore: (24*8 - 1) * 3600,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			record := Record{Repository: "repo", Environment: "production"}
+			for _, deployment := range tt.deployments {
+				record = Next(record, deployment, DefaultWindow, now)
+			}
+			got := Compute(record, DefaultWindow, now)
+			if got.Deployments != tt.wantDeployments {
+				t.Errorf("Compute() deployments = %v, want %v", got.Deployments, tt.wantDeployments)
+			}
+			if got.DeploymentsPerDay != float64(tt.wantDeployments)/7 {
+				t.Errorf("Compute() deployments per day = %v, want %v", got.DeploymentsPerDay, float64(tt.wantDeployments)/7)
+			}
+			if got.ChangeFailureRate != tt.wantFailureRate {
+				t.Errorf("Compute() change failure rate = %v, want %v", got.ChangeFailureRate, tt.wantFailureRate)
+			}
+			if got.LeadTimeSeconds != tt.wantLeadTime {
+				t.Errorf("Compute() lead time = %v, want %v", got.LeadTimeSeconds, tt.wantLeadTime)
+			}
+			if got.TimeToRestoreSeconds != tt.wantTimeToRestore {
+				t.Errorf("Compute() time to restore = %v, want %v", got.TimeToRestoreSeconds, tt.wantTimeToRestore)
+			}
+			if got.Failing != tt.wantFailing {
+				t.Errorf("Compute() failing = %v, want %v", got.Failing, tt.wantFailing)
+			}
+		})
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	observation := &obsv1.TektonObservation{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: tektonobserver.ObservationCrdName, UID: "observation-uid"},
+		Spec: obsv1.TektonObservationSpec{
+			Dora: &obsv1.DoraPolicy{Deployments: []obsv1.DeploymentPipeline{{Pipeline: "deploy", Environment: "production"}}},
+		},
+	}
+	newData := func(name, pipelineName string, labels map[string]string, annotations map[string]string, created time.Time) *tekton.PipelineRunData {
+		return &tekton.PipelineRunData{
+			RawPipelineRun: &tknv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				UID:               types.UID(name),
+				Labels:            labels,
+				Annotations:       annotations,
+				CreationTimestamp: metav1.Time{Time: created},
+			}},
+			Namespace:       "test-namespace",
+			PipelineRunName: name,
+			PipelineName:    pipelineName,
+			PacLabels:       map[string]string{"repository": "my-repo", "sha": "abc"},
+			Status:          tekton.StatusSucceeded,
+			CompletionTime:  &metav1.Time{Time: now},
+		}
+	}
+	tests := []struct {
+		name            string
+		runs            []*tekton.PipelineRunData
+		wantEnvironment string
+		wantLeadTime    float64
+		wantNil         bool
+	}{
+		{
+			name: "Test with the lead time from the first PipelineRun of the commit",
+			runs: []*tekton.PipelineRunData{
+				newData("build", "build", nil, nil, now.Add(-2*time.Hour)),
+				newData("deploy", "deploy", nil, nil, now.Add(-time.Hour)),
+			},
+			wantEnvironment: "production",
+			wantLeadTime:    2 * 3600,
+		},
+		{
+			name: "Test with the lead time from the commit timestamp",
+			runs: []*tekton.PipelineRunData{
+				newData("deploy", "deploy", nil, map[string]string{CommitTimestampAnnotation: now.Add(-3 * time.Hour).Format(time.RFC3339)}, now.Add(-time.Hour)),
+			},
+			wantEnvironment: "production",
+			wantLeadTime:    3 * 3600,
+		},
+		{
+			name: "Test with the environment label",
+			runs: []*tekton.PipelineRunData{
+				newData("release", "release", map[string]string{EnvironmentLabel: "staging"}, nil, now.Add(-time.Hour)),
+			},
+			wantEnvironment: "staging",
+			wantLeadTime:    3600,
+		},
+		{
+			name:    "Test with a PipelineRun that is not a deployment",
+			runs:    []*tekton.PipelineRunData{newData("build", "build", nil, nil, now.Add(-time.Hour))},
+			wantNil: true,
+		},
+		{
+			name: "Test with a deployment recorded again",
+			runs: []*tekton.PipelineRunData{
+				newData("deploy", "deploy", nil, nil, now.Add(-time.Hour)),
+				newData("deploy", "deploy", nil, nil, now.Add(-time.Hour)),
+			},
+			wantNil: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeClient := utils.NewFakeClient(observation)
+			var got *Metrics
+			for _, data := range tt.runs {
+				var err error
+				got, err = Update(ctx, fakeClient, observation, data, data.PipelineRunName, now)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.wantNil {
+				if got != nil {
+					t.Errorf("Update() = %+v, want nil", got)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatal("Update() = nil")
+			}
+			if got.Environment != tt.wantEnvironment || got.Repository != "my-repo" || got.Deployments != 1 {
+				t.Errorf("Update() = %+v, want a deployment of my-repo to %v", got, tt.wantEnvironment)
+			}
+			if got.LeadTimeSeconds != tt.wantLeadTime {
+				t.Errorf("Update() lead time = %v, want %v", got.LeadTimeSeconds, tt.wantLeadTime)
+			}
+		})
+	}
+}
+
+func TestDue(t *testing.T) {
+	last := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+		Annotations: map[string]string{LastSummaryAnnotation: last.Format(time.RFC3339)},
+	}}
+	tests := []struct {
+		name     string
+		now      time.Time
+		wantDue  bool
+		wantEnd  time.Time
+		wantLeft time.Duration
+	}{
+		{
+			name:     "Test with a summary not due",
+			now:      last.Add(20 * time.Hour),
+			wantLeft: 4 * time.Hour,
+		},
+		{
+			name:    "Test with a summary due",
+			now:     last.Add(25 * time.Hour),
+			wantDue: true,
+			wantEnd: last.Add(24 * time.Hour),
+		},
+		{
+			name:    "Test with missed summaries",
+			now:     last.Add(73 * time.Hour),
+			wantDue: true,
+			wantEnd: last.Add(72 * time.Hour),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			due, end, left, err := Due(configMap, DefaultSummaryInterval, tt.now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if due != tt.wantDue || !end.Equal(tt.wantEnd) || left != tt.wantLeft {
+				t.Errorf("Due() = %v, %v, %v, want %v, %v, %v", due, end, left, tt.wantDue, tt.wantEnd, tt.wantLeft)
+			}
+		})
+	}
+}
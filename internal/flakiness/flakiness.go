@@ -0,0 +1,216 @@
+package flakiness
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"github.com/kcloutie/tekton-observer/internal/state"
+	"github.com/kcloutie/tekton-observer/internal/tektonobserver"
+	"github.com/kcloutie/tekton-observer/pkg/tekton"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	// State is the value of the state label of the ConfigMap storing the flakiness history
+	State = "flakiness"
+	// ConfigMapName is the name of the ConfigMap storing the flakiness history of the tasks of a namespace
+	ConfigMapName = "tekton-observer-flakiness"
+
+	DefaultWindow           = 50
+	DefaultThresholdPercent = 10
+	DefaultMinRuns          = 10
+	// maxFailedSHAs is the number of commits a task failed on that are remembered to detect the re-runs
+	maxFailedSHAs = 20
+)
+
+// Outcome is the outcome of a run of a task in the history
+type Outcome byte
+
+const (
+	// OutcomePassed is a task that passed on its first attempt
+	OutcomePassed Outcome = 'P'
+	// OutcomeFailed is a task that failed
+	OutcomeFailed Outcome = 'F'
+	// OutcomeFlaky is a task that passed on a retry, or passed on a commit it failed on before
+	OutcomeFlaky Outcome = 'R'
+)
+
+// TaskRun is the outcome of a TaskRun of the PipelineRun
+type TaskRun struct {
+	Task string
+	// Succeeded is true when the TaskRun succeeded, false when it failed
+	Succeeded bool
+	// Retries is the number of failed attempts before the last attempt of the TaskRun
+	Retries int
+}
+
+// Record is the flakiness history of a task of a pipeline
+type Record struct {
+	Namespace string `json:"namespace"`
+	Pipeline  string `json:"pipeline"`
+	Task      string `json:"task"`
+	// Outcomes are the outcomes of the last runs of the task, the most recent last
+	Outcomes   string      `json:"outcomes"`
+	FailedSHAs []string    `json:"failedShas,omitempty"`
+	LastUID    string      `json:"lastUid"`
+	UpdateTime metav1.Time `json:"updateTime"`
+}
+
+// Flaky returns the number of flaky runs in the history
+func (r *Record) Flaky() int {
+	return strings.Count(r.Outcomes, string(OutcomeFlaky))
+}
+
+// Ratio returns the share of the runs of the history where the task was flaky
+func (r *Record) Ratio() float64 {
+	if len(r.Outcomes) == 0 {
+		return 0
+	}
+	return float64(r.Flaky()) / float64(len(r.Outcomes))
+}
+
+// Message describes the flakiness of the task
+func (r *Record) Message() string {
+	return fmt.Sprintf("this task is flaky: %d%% over last %d runs", int(math.Round(r.Ratio()*100)), len(r.Outcomes))
+}
+
+// Window returns the number of runs of a task the ratio is computed over
+func Window(policy *obsv1.FlakinessPolicy) int {
+	if policy == nil || policy.Window < 1 {
+		return DefaultWindow
+	}
+	return int(policy.Window)
+}
+
+// IsFlaky returns true when the task is reported as flaky by the policy
+func IsFlaky(policy *obsv1.FlakinessPolicy, record Record) bool {
+	threshold, minRuns := DefaultThresholdPercent, DefaultMinRuns
+	if policy != nil && policy.ThresholdPercent > 0 {
+		threshold = int(policy.ThresholdPercent)
+	}
+	if policy != nil && policy.MinRuns > 0 {
+		minRuns = int(policy.MinRuns)
+	}
+	return len(record.Outcomes) >= minRuns && record.Flaky() > 0 && record.Ratio()*100 >= float64(threshold)
+}
+
+// Key returns the key of the history of the task in the ConfigMap
+func Key(namespace, pipeline, task string) string {
+	sum := sha256.Sum256([]byte(namespace + "\x00" + pipeline + "\x00" + task))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Next returns the history of the task following the TaskRun, sha is the commit of the PipelineRun. The history keeps
+// the outcomes of the last window runs.
+func Next(record Record, taskRun TaskRun, sha, uid string, window int, now time.Time) Record {
+	outcome := OutcomePassed
+	switch {
+	case !taskRun.Succeeded:
+		outcome = OutcomeFailed
+		if sha != "" && !slices.Contains(record.FailedSHAs, sha) {
+			record.FailedSHAs = append(record.FailedSHAs, sha)
+			if len(record.FailedSHAs) > maxFailedSHAs {
+				record.FailedSHAs = record.FailedSHAs[len(record.FailedSHAs)-maxFailedSHAs:]
+			}
+		}
+	case taskRun.Retries > 0:
+		outcome = OutcomeFlaky
+	case sha != "" && slices.Contains(record.FailedSHAs, sha):
+		outcome = OutcomeFlaky
+		record.FailedSHAs = slices.DeleteFunc(slices.Clone(record.FailedSHAs), func(failed string) bool { return failed == sha })
+	}
+	record.Outcomes += string(outcome)
+	if len(record.Outcomes) > window {
+		record.Outcomes = record.Outcomes[len(record.Outcomes)-window:]
+	}
+	record.LastUID = uid
+	record.UpdateTime = metav1.Time{Time: now}
+	return record
+}
+
+// Update records the TaskRuns of the PipelineRun in the history of their tasks and returns the updated histories,
+// sorted by task. Recording the same PipelineRun again does not change the histories.
+func Update(ctx context.Context, c client.Client, observation *obsv1.TektonObservation, data *tekton.PipelineRunData, uid string, taskRuns []TaskRun, window int, now time.Time) ([]Record, error) {
+	if len(taskRuns) == 0 {
+		return []Record{}, nil
+	}
+	sha := data.PacLabels["sha"]
+	var records []Record
+	_, err := state.Update(ctx, c, client.ObjectKey{Namespace: observation.Namespace, Name: ConfigMapName}, func() (*corev1.ConfigMap, error) {
+		return newConfigMap(c, observation)
+	}, func(configMap *corev1.ConfigMap) (bool, error) {
+		records = []Record{}
+		changed := false
+		for _, taskRun := range taskRuns {
+			key := Key(data.Namespace, data.PipelineName, taskRun.Task)
+			record := Record{Namespace: data.Namespace, Pipeline: data.PipelineName, Task: taskRun.Task}
+			if raw, found := configMap.Data[key]; found {
+				// A corrupted history restarts from scratch
+				_ = json.Unmarshal([]byte(raw), &record)
+			}
+			if record.LastUID != uid {
+				record = Next(record, taskRun, sha, uid, window, now)
+				raw, err := json.Marshal(record)
+				if err != nil {
+					return false, fmt.Errorf("failed to marshal the flakiness history - %w", err)
+				}
+				configMap.Data[key] = string(raw)
+				changed = true
+			}
+			records = append(records, record)
+		}
+		return changed, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to record the flakiness of the tasks of the pipeline '%s' - %w", data.PipelineName, err)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Task < records[j].Task })
+	return records, nil
+}
+
+// Records returns every history stored in the ConfigMap, sorted by pipeline and task
+func Records(configMap *corev1.ConfigMap) ([]Record, error) {
+	records := []Record{}
+	for key, raw := range configMap.Data {
+		record := Record{}
+		if err := json.Unmarshal([]byte(raw), &record); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal the flakiness history '%s' - %w", key, err)
+		}
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Pipeline != records[j].Pipeline {
+			return records[i].Pipeline < records[j].Pipeline
+		}
+		return records[i].Task < records[j].Task
+	})
+	return records, nil
+}
+
+func newConfigMap(c client.Client, observation *obsv1.TektonObservation) (*corev1.ConfigMap, error) {
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: observation.Namespace,
+			Name:      ConfigMapName,
+			Labels:    map[string]string{tektonobserver.StateConfigMapLabel: State},
+		},
+		Data: map[string]string{},
+	}
+	// The history is not reconciled with the observation, the owner only removes it with it
+	if err := controllerutil.SetOwnerReference(observation, configMap, c.Scheme()); err != nil {
+		return nil, fmt.Errorf("failed to set the owner of the flakiness history - %w", err)
+	}
+	return configMap, nil
+}
@@ -0,0 +1,172 @@
+package flakiness
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"github.com/kcloutie/tekton-observer/internal/tektonobserver"
+	"github.com/kcloutie/tekton-observer/pkg/tekton"
+	"github.com/kcloutie/tekton-observer/test/utils"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNext(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name           string
+		record         Record
+		taskRun        TaskRun
+		sha            string
+		window         int
+		wantOutcomes   string
+		wantFailedSHAs []string
+	}{
+		{
+			name:         "Test with a task passing on its first attempt",
+			taskRun:      TaskRun{Task: "unit-tests", Succeeded: true},
+			sha:          "abc",
+			window:       5,
+			wantOutcomes: "P",
+		},
+		{
+			name:         "Test with a task passing on a retry",
+			taskRun:      TaskRun{Task: "unit-tests", Succeeded: true, Retries: 1},
+			sha:          "abc",
+			window:       5,
+			wantOutcomes: "R",
+		},
+		{
+			name:           "Test with a failing task",
+			record:         Record{Outcomes: "P"},
+			taskRun:        TaskRun{Task: "unit-tests"},
+			sha:            "abc",
+			window:         5,
+			wantOutcomes:   "PF",
+			wantFailedSHAs: []string{"abc"},
+		},
+		{
+			name:         "Test with a task passing on a commit it failed on",
+			record:       Record{Outcomes: "PF", FailedSHAs: []string{"abc"}},
+			taskRun:      TaskRun{Task: "unit-tests", Succeeded: true},
+			sha:          "abc",
+			window:       5,
+			wantOutcomes: "PFR",
+		},
+		{
+			name:           "Test with a task passing on another commit",
+			record:         Record{Outcomes: "PF", FailedSHAs: []string{"abc"}},
+			taskRun:        TaskRun{Task: "unit-tests", Succeeded: true},
+			sha:            "def",
+			window:         5,
+			wantOutcomes:   "PFP",
+			wantFailedSHAs: []string{"abc"},
+		},
+		{
+			name:         "Test with a full window",
+			record:       Record{Outcomes: "RPP"},
+			taskRun:      TaskRun{Task: "unit-tests", Succeeded: true},
+			window:       3,
+			wantOutcomes: "PPP",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Next(tt.record, tt.taskRun, tt.sha, "uid", tt.window, now)
+			if got.Outcomes != tt.wantOutcomes {
+				t.Errorf("Next() outcomes = %v, want %v", got.Outcomes, tt.wantOutcomes)
+			}
+			if len(got.FailedSHAs) != len(tt.wantFailedSHAs) {
+				t.Fatalf("Next() failed SHAs = %v, want %v", got.FailedSHAs, tt.wantFailedSHAs)
+			}
+			for i := range got.FailedSHAs {
+				if got.FailedSHAs[i] != tt.wantFailedSHAs[i] {
+					t.Errorf("Next() failed SHAs = %v, want %v", got.FailedSHAs, tt.wantFailedSHAs)
+				}
+			}
+			if got.LastUID != "uid" {
+				t.Errorf("Next() last UID = %v, want uid", got.LastUID)
+			}
+		})
+	}
+}
+
+func TestIsFlaky(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   *obsv1.FlakinessPolicy
+		outcomes string
+		want     bool
+	}{
+		{
+			name:     "Test with the default policy",
+			policy:   &obsv1.FlakinessPolicy{},
+			outcomes: "PPPPPPPPPR",
+			want:     true,
+		},
+		{
+			name:     "Test with too few runs",
+			policy:   &obsv1.FlakinessPolicy{},
+			outcomes: "PPPR",
+		},
+		{
+			name:     "Test with a ratio under the threshold",
+			policy:   &obsv1.FlakinessPolicy{ThresholdPercent: 30, MinRuns: 4},
+			outcomes: "PPPR",
+		},
+		{
+			name:     "Test with a ratio over the threshold",
+			policy:   &obsv1.FlakinessPolicy{ThresholdPercent: 25, MinRuns: 4},
+			outcomes: "PPPR",
+			want:     true,
+		},
+		{
+			name:     "Test with no flaky run",
+			policy:   &obsv1.FlakinessPolicy{MinRuns: 1},
+			outcomes: "PFPF",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsFlaky(tt.policy, Record{Outcomes: tt.outcomes}); got != tt.want {
+				t.Errorf("IsFlaky() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	observation := &obsv1.TektonObservation{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: tektonobserver.ObservationCrdName, UID: "observation-uid"},
+	}
+	fakeClient := utils.NewFakeClient(observation)
+	data := &tekton.PipelineRunData{
+		Namespace:    "test-namespace",
+		PipelineName: "build",
+		PacLabels:    map[string]string{"sha": "abc"},
+	}
+	taskRuns := []TaskRun{{Task: "unit-tests", Succeeded: true, Retries: 2}, {Task: "lint", Succeeded: true}}
+
+	for _, uid := range []string{"1", "1", "2"} {
+		if _, err := Update(ctx, fakeClient, observation, data, uid, taskRuns, DefaultWindow, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := Update(ctx, fakeClient, observation, data, "2", taskRuns, DefaultWindow, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Task != "lint" || got[1].Task != "unit-tests" {
+		t.Fatalf("Update() = %+v, want the histories of lint and unit-tests", got)
+	}
+	if got[0].Outcomes != "PP" || got[1].Outcomes != "RR" {
+		t.Errorf("Update() outcomes = %v and %v, want PP and RR", got[0].Outcomes, got[1].Outcomes)
+	}
+	if got[1].Message() != "this task is flaky: 100% over last 2 runs" {
+		t.Errorf("Message() = %v", got[1].Message())
+	}
+}
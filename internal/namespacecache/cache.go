@@ -0,0 +1,406 @@
+// Package namespacecache provides a controller-runtime cache that only watches the namespaces matching a label
+// selector. Namespaces are added and removed while the controller runs, as they are labelled and unlabelled, so the
+// controller only needs namespaced permissions on the objects it watches.
+package namespacecache
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var log = logf.Log.WithName("namespacecache")
+
+// NewCacheFunc returns a cache.NewCacheFunc creating a cache that watches the namespaces matching the selector
+func NewCacheFunc(selector labels.Selector) cache.NewCacheFunc {
+	return func(config *rest.Config, opts cache.Options) (cache.Cache, error) {
+		clusterOpts := opts
+		clusterOpts.DefaultNamespaces = nil
+		clusterOpts.ByObject = map[client.Object]cache.ByObject{}
+		for obj, byObject := range opts.ByObject {
+			clusterOpts.ByObject[obj] = byObject
+		}
+		clusterOpts.ByObject[&corev1.Namespace{}] = cache.ByObject{Label: selector}
+
+		clusterCache, err := cache.New(config, clusterOpts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the cluster scoped cache - %w", err)
+		}
+
+		newNamespaceCache := func(namespace string) (cache.Cache, error) {
+			namespaceOpts := opts
+			namespaceOpts.DefaultNamespaces = map[string]cache.Config{namespace: {}}
+			return cache.New(config, namespaceOpts)
+		}
+		return New(clusterCache, newNamespaceCache, selector, opts), nil
+	}
+}
+
+type namespaceEntry struct {
+	cache  cache.Cache
+	cancel context.CancelFunc
+}
+
+type indexField struct {
+	obj          client.Object
+	field        string
+	extractValue client.IndexerFunc
+}
+
+// Cache dispatches the requests for namespaced objects to one cache per selected namespace. Requests for cluster
+// scoped objects are served by the cluster cache.
+type Cache struct {
+	clusterCache      cache.Cache
+	newNamespaceCache func(namespace string) (cache.Cache, error)
+	selector          labels.Selector
+	opts              cache.Options
+
+	// synced is closed once the namespaces selected on startup are watched
+	synced chan struct{}
+
+	mu         sync.RWMutex
+	ctx        context.Context
+	namespaces map[string]*namespaceEntry
+	informers  map[schema.GroupVersionKind]*dynamicInformer
+	indexes    []indexField
+}
+
+var _ cache.Cache = &Cache{}
+
+func New(clusterCache cache.Cache, newNamespaceCache func(namespace string) (cache.Cache, error), selector labels.Selector, opts cache.Options) *Cache {
+	return &Cache{
+		clusterCache:      clusterCache,
+		newNamespaceCache: newNamespaceCache,
+		selector:          selector,
+		opts:              opts,
+		synced:            make(chan struct{}),
+		namespaces:        map[string]*namespaceEntry{},
+		informers:         map[schema.GroupVersionKind]*dynamicInformer{},
+	}
+}
+
+// Namespaces returns the namespaces currently watched
+func (c *Cache) Namespaces() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	namespaces := make([]string, 0, len(c.namespaces))
+	for ns := range c.namespaces {
+		namespaces = append(namespaces, ns)
+	}
+	return namespaces
+}
+
+// Start starts the cluster cache and keeps the set of namespace caches in line with the selected namespaces
+func (c *Cache) Start(ctx context.Context) error {
+	c.mu.Lock()
+	c.ctx = ctx
+	c.mu.Unlock()
+
+	go func() {
+		if err := c.clusterCache.Start(ctx); err != nil {
+			log.Error(err, "cluster scoped cache failed to start")
+		}
+	}()
+
+	informer, err := c.clusterCache.GetInformer(ctx, &corev1.Namespace{})
+	if err != nil {
+		return fmt.Errorf("failed to get the namespace informer - %w", err)
+	}
+	registration, err := informer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { c.onNamespace(obj, false) },
+		UpdateFunc: func(_, obj interface{}) { c.onNamespace(obj, false) },
+		DeleteFunc: func(obj interface{}) { c.onNamespace(obj, true) },
+	})
+	if err != nil {
+		return fmt.Errorf("failed to watch the namespaces - %w", err)
+	}
+
+	// The registration is synced once the handler received every namespace of the initial list
+	if toolscache.WaitForCacheSync(ctx.Done(), registration.HasSynced) {
+		close(c.synced)
+	}
+	<-ctx.Done()
+	return nil
+}
+
+func (c *Cache) onNamespace(obj interface{}, deleted bool) {
+	if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	namespace, ok := obj.(*corev1.Namespace)
+	if !ok {
+		return
+	}
+	if deleted || !c.selector.Matches(labels.Set(namespace.Labels)) {
+		c.removeNamespace(namespace.Name)
+		return
+	}
+	if err := c.addNamespace(namespace.Name); err != nil {
+		log.Error(err, "failed to start watching the namespace", "namespace", namespace.Name)
+	}
+}
+
+// addNamespace creates and starts the cache of a namespace and attaches every registered handler to its informers
+func (c *Cache) addNamespace(namespace string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.namespaces[namespace]; ok {
+		return nil
+	}
+
+	namespaceCache, err := c.newNamespaceCache(namespace)
+	if err != nil {
+		return err
+	}
+	for _, index := range c.indexes {
+		if err := namespaceCache.IndexField(context.Background(), index.obj, index.field, index.extractValue); err != nil {
+			return err
+		}
+	}
+
+	parent := c.ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithCancel(parent)
+	for _, informer := range c.informers {
+		nsInformer, err := namespaceCache.GetInformer(ctx, informer.obj, cache.BlockUntilSynced(false))
+		if err != nil {
+			cancel()
+			return err
+		}
+		if err := informer.addNamespace(namespace, nsInformer); err != nil {
+			cancel()
+			return err
+		}
+	}
+
+	c.namespaces[namespace] = &namespaceEntry{cache: namespaceCache, cancel: cancel}
+	go func() {
+		if err := namespaceCache.Start(ctx); err != nil {
+			log.Error(err, "namespace cache failed to start", "namespace", namespace)
+		}
+	}()
+	log.Info("Started watching namespace", "namespace", namespace)
+	return nil
+}
+
+// removeNamespace stops the cache of a namespace that is no longer selected
+func (c *Cache) removeNamespace(namespace string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.namespaces[namespace]
+	if !ok {
+		return
+	}
+	for _, informer := range c.informers {
+		informer.removeNamespace(namespace)
+	}
+	entry.cancel()
+	delete(c.namespaces, namespace)
+	log.Info("Stopped watching namespace", "namespace", namespace)
+}
+
+// isNamespaced returns true when the object, or the items of the list, are namespace scoped
+func (c *Cache) isNamespaced(obj runtime.Object) (bool, error) {
+	gvk, err := apiutil.GVKForObject(obj, c.opts.Scheme)
+	if err != nil {
+		return false, err
+	}
+	if apimeta.IsListType(obj) {
+		gvk.Kind = strings.TrimSuffix(gvk.Kind, "List")
+	}
+	return apiutil.IsGVKNamespaced(gvk, c.opts.Mapper)
+}
+
+func (c *Cache) GetInformer(ctx context.Context, obj client.Object, opts ...cache.InformerGetOption) (cache.Informer, error) {
+	isNamespaced, err := c.isNamespaced(obj)
+	if err != nil {
+		return nil, err
+	}
+	if !isNamespaced {
+		return c.clusterCache.GetInformer(ctx, obj, opts...)
+	}
+
+	gvk, err := apiutil.GVKForObject(obj, c.opts.Scheme)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if informer, ok := c.informers[gvk]; ok {
+		return informer, nil
+	}
+
+	// The informers of the namespaces must not block while the lock is held, the controllers wait for the
+	// synchronisation through the registrations of their handlers
+	opts = append(opts, cache.BlockUntilSynced(false))
+	informer := newDynamicInformer(obj)
+	for ns, entry := range c.namespaces {
+		nsInformer, err := entry.cache.GetInformer(ctx, obj, opts...)
+		if err != nil {
+			return nil, err
+		}
+		if err := informer.addNamespace(ns, nsInformer); err != nil {
+			return nil, err
+		}
+	}
+	c.informers[gvk] = informer
+	return informer, nil
+}
+
+func (c *Cache) GetInformerForKind(ctx context.Context, gvk schema.GroupVersionKind, opts ...cache.InformerGetOption) (cache.Informer, error) {
+	obj, err := c.opts.Scheme.New(gvk)
+	if err != nil {
+		return nil, err
+	}
+	clientObj, ok := obj.(client.Object)
+	if !ok {
+		return nil, fmt.Errorf("%v is not a client.Object", gvk)
+	}
+	return c.GetInformer(ctx, clientObj, opts...)
+}
+
+func (c *Cache) RemoveInformer(ctx context.Context, obj client.Object) error {
+	isNamespaced, err := c.isNamespaced(obj)
+	if err != nil {
+		return err
+	}
+	if !isNamespaced {
+		return c.clusterCache.RemoveInformer(ctx, obj)
+	}
+	gvk, err := apiutil.GVKForObject(obj, c.opts.Scheme)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.informers, gvk)
+	for _, entry := range c.namespaces {
+		if err := entry.cache.RemoveInformer(ctx, obj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WaitForCacheSync waits for the namespaces selected on startup to be watched and for their caches to sync
+func (c *Cache) WaitForCacheSync(ctx context.Context) bool {
+	if !c.clusterCache.WaitForCacheSync(ctx) {
+		return false
+	}
+	select {
+	case <-c.synced:
+	case <-ctx.Done():
+		return false
+	}
+	c.mu.RLock()
+	caches := make([]cache.Cache, 0, len(c.namespaces))
+	for _, entry := range c.namespaces {
+		caches = append(caches, entry.cache)
+	}
+	c.mu.RUnlock()
+
+	synced := true
+	for _, namespaceCache := range caches {
+		if !namespaceCache.WaitForCacheSync(ctx) {
+			synced = false
+		}
+	}
+	return synced
+}
+
+func (c *Cache) IndexField(ctx context.Context, obj client.Object, field string, extractValue client.IndexerFunc) error {
+	isNamespaced, err := c.isNamespaced(obj)
+	if err != nil {
+		return err
+	}
+	if !isNamespaced {
+		return c.clusterCache.IndexField(ctx, obj, field, extractValue)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.indexes = append(c.indexes, indexField{obj: obj, field: field, extractValue: extractValue})
+	for _, entry := range c.namespaces {
+		if err := entry.cache.IndexField(ctx, obj, field, extractValue); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Cache) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	isNamespaced, err := c.isNamespaced(obj)
+	if err != nil {
+		return err
+	}
+	if !isNamespaced {
+		return c.clusterCache.Get(ctx, key, obj, opts...)
+	}
+
+	c.mu.RLock()
+	entry, ok := c.namespaces[key.Namespace]
+	c.mu.RUnlock()
+	if !ok {
+		gvk, _ := apiutil.GVKForObject(obj, c.opts.Scheme)
+		return apierrors.NewNotFound(schema.GroupResource{Group: gvk.Group, Resource: gvk.Kind}, key.Name)
+	}
+	return entry.cache.Get(ctx, key, obj, opts...)
+}
+
+// List returns the objects of the requested namespace, or of every watched namespace when no namespace is requested
+func (c *Cache) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	isNamespaced, err := c.isNamespaced(list)
+	if err != nil {
+		return err
+	}
+	if !isNamespaced {
+		return c.clusterCache.List(ctx, list, opts...)
+	}
+
+	listOpts := client.ListOptions{}
+	listOpts.ApplyOptions(opts)
+
+	c.mu.RLock()
+	entries := map[string]*namespaceEntry{}
+	for ns, entry := range c.namespaces {
+		if listOpts.Namespace == corev1.NamespaceAll || listOpts.Namespace == ns {
+			entries[ns] = entry
+		}
+	}
+	c.mu.RUnlock()
+
+	allItems := []runtime.Object{}
+	for _, entry := range entries {
+		listObj := list.DeepCopyObject().(client.ObjectList)
+		if err := apimeta.SetList(listObj, nil); err != nil {
+			return err
+		}
+		if err := entry.cache.List(ctx, listObj, &listOpts); err != nil {
+			return err
+		}
+		items, err := apimeta.ExtractList(listObj)
+		if err != nil {
+			return err
+		}
+		allItems = append(allItems, items...)
+	}
+	return apimeta.SetList(list, allItems)
+}
@@ -0,0 +1,255 @@
+package namespacecache
+
+import (
+	"context"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	tknv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache/informertest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllertest"
+)
+
+func newTestCache(t *testing.T, selector string) (*Cache, map[string]*informertest.FakeInformers) {
+	scheme := runtime.NewScheme()
+	if err := tknv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{tknv1.SchemeGroupVersion, corev1.SchemeGroupVersion})
+	mapper.Add(tknv1.SchemeGroupVersion.WithKind("PipelineRun"), meta.RESTScopeNamespace)
+	mapper.Add(corev1.SchemeGroupVersion.WithKind("Namespace"), meta.RESTScopeRoot)
+
+	sel, err := labels.Parse(selector)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	caches := map[string]*informertest.FakeInformers{}
+	newNamespaceCache := func(namespace string) (cache.Cache, error) {
+		caches[namespace] = &informertest.FakeInformers{Scheme: scheme}
+		return caches[namespace], nil
+	}
+	return New(&informertest.FakeInformers{Scheme: scheme}, newNamespaceCache, sel, cache.Options{Scheme: scheme, Mapper: mapper}), caches
+}
+
+func newNamespace(name string, nsLabels map[string]string) *corev1.Namespace {
+	return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: nsLabels}}
+}
+
+func TestCache_onNamespace(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []struct {
+			ns      *corev1.Namespace
+			deleted bool
+		}
+		want []string
+	}{
+		{
+			name: "Test with matching and non matching namespaces",
+			events: []struct {
+				ns      *corev1.Namespace
+				deleted bool
+			}{
+				{ns: newNamespace("team-a", map[string]string{"observer": "enabled"})},
+				{ns: newNamespace("team-b", map[string]string{})},
+				{ns: newNamespace("team-c", map[string]string{"observer": "enabled"})},
+			},
+			want: []string{"team-a", "team-c"},
+		},
+		{
+			name: "Test with namespace unlabelled",
+			events: []struct {
+				ns      *corev1.Namespace
+				deleted bool
+			}{
+				{ns: newNamespace("team-a", map[string]string{"observer": "enabled"})},
+				{ns: newNamespace("team-a", map[string]string{})},
+			},
+			want: []string{},
+		},
+		{
+			name: "Test with namespace deleted",
+			events: []struct {
+				ns      *corev1.Namespace
+				deleted bool
+			}{
+				{ns: newNamespace("team-a", map[string]string{"observer": "enabled"})},
+				{ns: newNamespace("team-a", map[string]string{"observer": "enabled"}), deleted: true},
+			},
+			want: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := newTestCache(t, "observer=enabled")
+			for _, event := range tt.events {
+				c.onNamespace(event.ns, event.deleted)
+			}
+			got := c.Namespaces()
+			sort.Strings(got)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Namespaces() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("Namespaces() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestCache_GetInformer(t *testing.T) {
+	ctx := context.Background()
+	c, caches := newTestCache(t, "observer=enabled")
+
+	// A namespace selected before the informer is requested
+	c.onNamespace(newNamespace("team-a", map[string]string{"observer": "enabled"}), false)
+
+	informer, err := c.GetInformer(ctx, &tknv1.PipelineRun{})
+	if err != nil {
+		t.Fatalf("GetInformer() unexpected error = %v", err)
+	}
+	added := map[string]int{}
+	_, err = informer.AddEventHandler(toolscache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			added[obj.(*tknv1.PipelineRun).Namespace]++
+		},
+	})
+	if err != nil {
+		t.Fatalf("AddEventHandler() unexpected error = %v", err)
+	}
+
+	// A namespace selected after the handler was registered
+	c.onNamespace(newNamespace("team-b", map[string]string{"observer": "enabled"}), false)
+
+	for _, ns := range []string{"team-a", "team-b"} {
+		fake, err := caches[ns].FakeInformerFor(ctx, &tknv1.PipelineRun{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		fake.Add(&tknv1.PipelineRun{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: "pr"}})
+	}
+	if added["team-a"] != 1 || added["team-b"] != 1 {
+		t.Errorf("handler calls = %v, want one call per namespace", added)
+	}
+
+	// Once unselected, the namespace informer is detached from the handlers
+	c.onNamespace(newNamespace("team-b", map[string]string{}), false)
+	if _, ok := informer.(*dynamicInformer).informers["team-b"]; ok {
+		t.Errorf("the informer of team-b is still attached")
+	}
+}
+
+// namespaceInformer replays the initial namespaces to every handler, as the namespace informer does once it listed
+// them, and reports the registration synced once initialSynced is set
+type namespaceInformer struct {
+	controllertest.FakeInformer
+	initial       []*corev1.Namespace
+	initialSynced atomic.Bool
+}
+
+func (i *namespaceInformer) AddEventHandler(handler toolscache.ResourceEventHandler) (toolscache.ResourceEventHandlerRegistration, error) {
+	if _, err := i.FakeInformer.AddEventHandler(handler); err != nil {
+		return nil, err
+	}
+	go func() {
+		for _, ns := range i.initial {
+			handler.OnAdd(ns, true)
+		}
+	}()
+	return i, nil
+}
+
+func (i *namespaceInformer) HasSynced() bool {
+	return i.initialSynced.Load()
+}
+
+// namespaceClusterCache serves the namespace informer from the cluster cache
+type namespaceClusterCache struct {
+	*informertest.FakeInformers
+	informer *namespaceInformer
+}
+
+func (c *namespaceClusterCache) GetInformer(ctx context.Context, obj client.Object, opts ...cache.InformerGetOption) (cache.Informer, error) {
+	if _, ok := obj.(*corev1.Namespace); ok {
+		return c.informer, nil
+	}
+	return c.FakeInformers.GetInformer(ctx, obj, opts...)
+}
+
+func TestCache_WaitForCacheSync(t *testing.T) {
+	c, _ := newTestCache(t, "observer=enabled")
+	informer := &namespaceInformer{initial: []*corev1.Namespace{
+		newNamespace("team-a", map[string]string{"observer": "enabled"}),
+		newNamespace("team-b", map[string]string{}),
+	}}
+	c.clusterCache = &namespaceClusterCache{FakeInformers: c.clusterCache.(*informertest.FakeInformers), informer: informer}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		if err := c.Start(ctx); err != nil {
+			t.Errorf("Start() unexpected error = %v", err)
+		}
+	}()
+
+	timeoutCtx, timeoutCancel := context.WithTimeout(ctx, 300*time.Millisecond)
+	defer timeoutCancel()
+	if c.WaitForCacheSync(timeoutCtx) {
+		t.Fatalf("WaitForCacheSync() = true before the initial namespaces were handled")
+	}
+
+	informer.initialSynced.Store(true)
+	if !c.WaitForCacheSync(ctx) {
+		t.Fatalf("WaitForCacheSync() = false, want true")
+	}
+	if got := c.Namespaces(); len(got) != 1 || got[0] != "team-a" {
+		t.Errorf("Namespaces() = %v once synced, want [team-a]", got)
+	}
+}
+
+func TestCache_List(t *testing.T) {
+	ctx := context.Background()
+	c, _ := newTestCache(t, "observer=enabled")
+	c.onNamespace(newNamespace("team-a", map[string]string{"observer": "enabled"}), false)
+
+	tests := []struct {
+		name string
+		list *tknv1.PipelineRunList
+	}{
+		{
+			name: "Test with an empty list",
+			list: &tknv1.PipelineRunList{},
+		},
+		{
+			name: "Test with a list reused from an earlier call",
+			list: &tknv1.PipelineRunList{Items: []tknv1.PipelineRun{{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "earlier"}}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := c.List(ctx, tt.list); err != nil {
+				t.Fatalf("List() unexpected error = %v", err)
+			}
+			if len(tt.list.Items) != 0 {
+				t.Errorf("List() items = %v, want only the items of the namespace caches", tt.list.Items)
+			}
+		})
+	}
+}
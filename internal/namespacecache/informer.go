@@ -0,0 +1,183 @@
+package namespacecache
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type handlerEntry struct {
+	handler      toolscache.ResourceEventHandler
+	resyncPeriod *time.Duration
+	// registrations holds the registration of the handler on the informer of every namespace
+	registrations map[string]toolscache.ResourceEventHandlerRegistration
+}
+
+// registration is returned to the callers of AddEventHandler, it identifies the handler across every namespace
+type registration struct {
+	informer *dynamicInformer
+	entry    *handlerEntry
+}
+
+type syncer interface {
+	HasSynced() bool
+}
+
+// HasSynced returns true once the handler received the initial state of every namespace
+func (r *registration) HasSynced() bool {
+	r.informer.mu.RLock()
+	defer r.informer.mu.RUnlock()
+	for _, reg := range r.entry.registrations {
+		if s, ok := reg.(syncer); ok && !s.HasSynced() {
+			return false
+		}
+	}
+	return true
+}
+
+// dynamicInformer fans the handlers out to the informers of the namespaces. The handlers registered on it are
+// attached to the informer of every namespace added later on.
+type dynamicInformer struct {
+	obj client.Object
+
+	mu        sync.RWMutex
+	informers map[string]cache.Informer
+	handlers  []*handlerEntry
+	indexers  []toolscache.Indexers
+}
+
+var _ cache.Informer = &dynamicInformer{}
+
+func newDynamicInformer(obj client.Object) *dynamicInformer {
+	return &dynamicInformer{
+		obj:       obj,
+		informers: map[string]cache.Informer{},
+	}
+}
+
+func (i *dynamicInformer) addNamespace(namespace string, informer cache.Informer) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for _, indexers := range i.indexers {
+		if err := informer.AddIndexers(indexers); err != nil {
+			return err
+		}
+	}
+	for _, entry := range i.handlers {
+		reg, err := addHandler(informer, entry)
+		if err != nil {
+			return err
+		}
+		entry.registrations[namespace] = reg
+	}
+	i.informers[namespace] = informer
+	return nil
+}
+
+func (i *dynamicInformer) removeNamespace(namespace string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	informer, ok := i.informers[namespace]
+	if !ok {
+		return
+	}
+	for _, entry := range i.handlers {
+		if reg, ok := entry.registrations[namespace]; ok {
+			if err := informer.RemoveEventHandler(reg); err != nil {
+				log.Error(err, "failed to remove the event handler", "namespace", namespace)
+			}
+			delete(entry.registrations, namespace)
+		}
+	}
+	delete(i.informers, namespace)
+}
+
+func addHandler(informer cache.Informer, entry *handlerEntry) (toolscache.ResourceEventHandlerRegistration, error) {
+	if entry.resyncPeriod != nil {
+		return informer.AddEventHandlerWithResyncPeriod(entry.handler, *entry.resyncPeriod)
+	}
+	return informer.AddEventHandler(entry.handler)
+}
+
+func (i *dynamicInformer) addEventHandler(handler toolscache.ResourceEventHandler, resyncPeriod *time.Duration) (toolscache.ResourceEventHandlerRegistration, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	entry := &handlerEntry{
+		handler:       handler,
+		resyncPeriod:  resyncPeriod,
+		registrations: map[string]toolscache.ResourceEventHandlerRegistration{},
+	}
+	for ns, informer := range i.informers {
+		reg, err := addHandler(informer, entry)
+		if err != nil {
+			return nil, err
+		}
+		entry.registrations[ns] = reg
+	}
+	i.handlers = append(i.handlers, entry)
+	return &registration{informer: i, entry: entry}, nil
+}
+
+func (i *dynamicInformer) AddEventHandler(handler toolscache.ResourceEventHandler) (toolscache.ResourceEventHandlerRegistration, error) {
+	return i.addEventHandler(handler, nil)
+}
+
+func (i *dynamicInformer) AddEventHandlerWithResyncPeriod(handler toolscache.ResourceEventHandler, resyncPeriod time.Duration) (toolscache.ResourceEventHandlerRegistration, error) {
+	return i.addEventHandler(handler, &resyncPeriod)
+}
+
+func (i *dynamicInformer) RemoveEventHandler(handle toolscache.ResourceEventHandlerRegistration) error {
+	reg, ok := handle.(*registration)
+	if !ok || reg.informer != i {
+		return fmt.Errorf("registration was not returned by this informer")
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for ns, nsReg := range reg.entry.registrations {
+		if informer, ok := i.informers[ns]; ok {
+			if err := informer.RemoveEventHandler(nsReg); err != nil {
+				return err
+			}
+		}
+	}
+	for idx, entry := range i.handlers {
+		if entry == reg.entry {
+			i.handlers = append(i.handlers[:idx], i.handlers[idx+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (i *dynamicInformer) AddIndexers(indexers toolscache.Indexers) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for _, informer := range i.informers {
+		if err := informer.AddIndexers(indexers); err != nil {
+			return err
+		}
+	}
+	i.indexers = append(i.indexers, indexers)
+	return nil
+}
+
+func (i *dynamicInformer) HasSynced() bool {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	for _, informer := range i.informers {
+		if !informer.HasSynced() {
+			return false
+		}
+	}
+	return true
+}
+
+// IsStopped always returns false, the informer keeps running while namespaces come and go
+func (i *dynamicInformer) IsStopped() bool {
+	return false
+}
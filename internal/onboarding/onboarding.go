@@ -0,0 +1,110 @@
+package onboarding
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"github.com/kcloutie/tekton-observer/internal/tektonobserver"
+	tknv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DefaultBackfillPerMinute is the number of PipelineRuns backfilled per minute when the policy does not set it
+const DefaultBackfillPerMinute = 10
+
+// Decision is what happens to a PipelineRun when a namespace is onboarded
+type Decision string
+
+const (
+	// DecisionDeliver reports the PipelineRun like any other
+	DecisionDeliver Decision = "deliver"
+	// DecisionIgnore marks the PipelineRun as complete without reporting it
+	DecisionIgnore Decision = "ignore"
+	// DecisionBackfill reports the PipelineRun at a limited rate with the backfill=true attribute
+	DecisionBackfill Decision = "backfill"
+)
+
+// Validate returns an error when the policy is incomplete
+func Validate(policy *obsv1.OnboardingPolicy) error {
+	if policy == nil {
+		return nil
+	}
+	switch policy.Mode {
+	case obsv1.OnboardingIgnoreExisting, obsv1.OnboardingBackfill:
+	case obsv1.OnboardingSinceTimestamp:
+		if policy.SinceTimestamp == nil {
+			return fmt.Errorf("sinceTimestamp is required by the '%s' onboarding mode", policy.Mode)
+		}
+	default:
+		return fmt.Errorf("unknown onboarding mode '%s'", policy.Mode)
+	}
+	return nil
+}
+
+// Classify decides what happens to the PipelineRun. The PipelineRuns that finished before onboardedAt and that were
+// never observed are the existing PipelineRuns the policy applies to, every other PipelineRun is delivered.
+func Classify(policy *obsv1.OnboardingPolicy, pipelineRun *tknv1.PipelineRun, onboardedAt time.Time) Decision {
+	if pipelineRun.Annotations[tektonobserver.BackfillAnnotation] == "true" {
+		return DecisionBackfill
+	}
+	if _, observed := pipelineRun.Annotations[tektonobserver.PipelineProcessingStateAnnotation]; observed || policy == nil {
+		return DecisionDeliver
+	}
+	completionTime := pipelineRun.Status.CompletionTime
+	if !pipelineRun.IsDone() || completionTime == nil || !completionTime.Time.Before(onboardedAt) {
+		return DecisionDeliver
+	}
+
+	switch policy.Mode {
+	case obsv1.OnboardingIgnoreExisting:
+		return DecisionIgnore
+	case obsv1.OnboardingBackfill:
+		return DecisionBackfill
+	case obsv1.OnboardingSinceTimestamp:
+		if policy.SinceTimestamp != nil && completionTime.Time.Before(policy.SinceTimestamp.Time) {
+			return DecisionIgnore
+		}
+		return DecisionBackfill
+	}
+	return DecisionDeliver
+}
+
+// Stamp records the decision on the PipelineRun. An ignored PipelineRun is marked as complete, a backfilled
+// PipelineRun gets the backfill annotation. The PipelineRun is updated with the patched object.
+func Stamp(ctx context.Context, c client.Client, pipelineRun *tknv1.PipelineRun, decision Decision) error {
+	updated := pipelineRun.DeepCopy()
+	if updated.Annotations == nil {
+		updated.Annotations = map[string]string{}
+	}
+	switch decision {
+	case DecisionIgnore:
+		if updated.Labels == nil {
+			updated.Labels = map[string]string{}
+		}
+		updated.Annotations[tektonobserver.PipelineProcessingStateAnnotation] = tektonobserver.ProcessingCompleteState
+		updated.Labels[tektonobserver.PipelineProcessingStateLabel] = tektonobserver.ProcessingCompleteState
+	case DecisionBackfill:
+		if updated.Annotations[tektonobserver.BackfillAnnotation] == "true" {
+			return nil
+		}
+		updated.Annotations[tektonobserver.BackfillAnnotation] = "true"
+	default:
+		return nil
+	}
+
+	if err := c.Patch(ctx, updated, client.MergeFrom(pipelineRun)); err != nil {
+		return fmt.Errorf("failed to stamp the PipelineRun '%s' - %w", pipelineRun.Name, err)
+	}
+	*pipelineRun = *updated
+	return nil
+}
+
+// BackfillPerMinute returns the backfill rate of the policy
+func BackfillPerMinute(policy *obsv1.OnboardingPolicy) int {
+	if policy == nil || policy.BackfillPerMinute < 1 {
+		return DefaultBackfillPerMinute
+	}
+	return policy.BackfillPerMinute
+}
@@ -0,0 +1,142 @@
+package onboarding
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"github.com/kcloutie/tekton-observer/internal/tektonobserver"
+	"github.com/kcloutie/tekton-observer/test/utils"
+	tknv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestClassify(t *testing.T) {
+	onboardedAt := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	finished := func(completion time.Time, annotations map[string]string) *tknv1.PipelineRun {
+		pr := utils.NewPipelineRun("ns", "name", annotations, true)
+		pr.Status.CompletionTime = &metav1.Time{Time: completion}
+		return pr
+	}
+	before := onboardedAt.Add(-48 * time.Hour)
+	since := &metav1.Time{Time: onboardedAt.Add(-24 * time.Hour)}
+	tests := []struct {
+		name        string
+		policy      *obsv1.OnboardingPolicy
+		pipelineRun *tknv1.PipelineRun
+		want        Decision
+	}{
+		{
+			name:        "Test without policy",
+			pipelineRun: finished(before, nil),
+			want:        DecisionDeliver,
+		},
+		{
+			name:        "Test with ignoreExisting and an existing PipelineRun",
+			policy:      &obsv1.OnboardingPolicy{Mode: obsv1.OnboardingIgnoreExisting},
+			pipelineRun: finished(before, nil),
+			want:        DecisionIgnore,
+		},
+		{
+			name:        "Test with ignoreExisting and a PipelineRun finished after onboarding",
+			policy:      &obsv1.OnboardingPolicy{Mode: obsv1.OnboardingIgnoreExisting},
+			pipelineRun: finished(onboardedAt.Add(time.Minute), nil),
+			want:        DecisionDeliver,
+		},
+		{
+			name:        "Test with ignoreExisting and a running PipelineRun",
+			policy:      &obsv1.OnboardingPolicy{Mode: obsv1.OnboardingIgnoreExisting},
+			pipelineRun: utils.NewPipelineRun("ns", "name", nil, false),
+			want:        DecisionDeliver,
+		},
+		{
+			name:   "Test with ignoreExisting and a PipelineRun already observed",
+			policy: &obsv1.OnboardingPolicy{Mode: obsv1.OnboardingIgnoreExisting},
+			pipelineRun: finished(before, map[string]string{
+				tektonobserver.PipelineProcessingStateAnnotation: tektonobserver.ProcessingStartState,
+			}),
+			want: DecisionDeliver,
+		},
+		{
+			name:        "Test with backfill",
+			policy:      &obsv1.OnboardingPolicy{Mode: obsv1.OnboardingBackfill},
+			pipelineRun: finished(before, nil),
+			want:        DecisionBackfill,
+		},
+		{
+			name:        "Test with sinceTimestamp and a PipelineRun finished before",
+			policy:      &obsv1.OnboardingPolicy{Mode: obsv1.OnboardingSinceTimestamp, SinceTimestamp: since},
+			pipelineRun: finished(before, nil),
+			want:        DecisionIgnore,
+		},
+		{
+			name:        "Test with sinceTimestamp and a PipelineRun finished after",
+			policy:      &obsv1.OnboardingPolicy{Mode: obsv1.OnboardingSinceTimestamp, SinceTimestamp: since},
+			pipelineRun: finished(onboardedAt.Add(-time.Hour), nil),
+			want:        DecisionBackfill,
+		},
+		{
+			name: "Test with a PipelineRun stamped for backfill",
+			pipelineRun: finished(before, map[string]string{
+				tektonobserver.BackfillAnnotation:                "true",
+				tektonobserver.PipelineProcessingStateAnnotation: tektonobserver.ProcessingStartState,
+			}),
+			want: DecisionBackfill,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Classify(tt.policy, tt.pipelineRun, onboardedAt); got != tt.want {
+				t.Errorf("Classify() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStamp(t *testing.T) {
+	tests := []struct {
+		name           string
+		decision       Decision
+		wantState      string
+		wantBackfilled bool
+	}{
+		{
+			name:     "Test with deliver",
+			decision: DecisionDeliver,
+		},
+		{
+			name:      "Test with ignore",
+			decision:  DecisionIgnore,
+			wantState: tektonobserver.ProcessingCompleteState,
+		},
+		{
+			name:           "Test with backfill",
+			decision:       DecisionBackfill,
+			wantBackfilled: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			pipelineRun := utils.NewPipelineRun("ns", "name", nil, true)
+			fakeClient := utils.NewFakeClient(pipelineRun)
+
+			if err := Stamp(ctx, fakeClient, pipelineRun.DeepCopy(), tt.decision); err != nil {
+				t.Fatalf("Stamp() error = %v", err)
+			}
+
+			pr := &tknv1.PipelineRun{}
+			if err := fakeClient.Get(ctx, client.ObjectKeyFromObject(pipelineRun), pr); err != nil {
+				t.Fatal(err)
+			}
+			if got := pr.Annotations[tektonobserver.PipelineProcessingStateAnnotation]; got != tt.wantState {
+				t.Errorf("Stamp() state = %v, want %v", got, tt.wantState)
+			}
+			if got := pr.Annotations[tektonobserver.BackfillAnnotation] == "true"; got != tt.wantBackfilled {
+				t.Errorf("Stamp() backfilled = %v, want %v", got, tt.wantBackfilled)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"github.com/kcloutie/tekton-observer/internal/tektonobserver"
+	"github.com/kcloutie/tekton-observer/pkg/delivery"
+	"github.com/kcloutie/tekton-observer/pkg/sinks"
+	"github.com/kcloutie/tekton-observer/pkg/tekton"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	// PipelineRunLabel holds the name of the PipelineRun of a delivery
+	PipelineRunLabel = tektonobserver.GroupName + "/pipelinerun"
+)
+
+// Name returns the name of the ObservationDelivery of a delivery, a delivery is added to the outbox only once
+func Name(deliveryID string) string {
+	return fmt.Sprintf("delivery-%s", deliveryID)
+}
+
+// Enqueue adds a failed delivery of the event to the outbox of the observation. The delivery is owned by the
+// observation so it is removed with it. A delivery already in the outbox is left untouched.
+func Enqueue(ctx context.Context, c client.Client, observation *obsv1.TektonObservation, sink string, event sinks.Event, deliveryErr error, now time.Time) error {
+	payload, err := json.Marshal(event.Data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal the PipelineRun data - %w", err)
+	}
+
+	pipelineRun := obsv1.PipelineRunReference{Name: event.Data.PipelineRunName}
+	if event.Data.RawPipelineRun != nil {
+		pipelineRun.UID = event.Data.RawPipelineRun.UID
+	}
+	outboxDelivery := &obsv1.ObservationDelivery{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: observation.Namespace,
+			Name:      Name(event.Delivery.ID),
+			Labels: map[string]string{
+				PipelineRunLabel: truncateLabel(pipelineRun.Name),
+			},
+		},
+		Spec: obsv1.ObservationDeliverySpec{
+			PipelineRun: pipelineRun,
+			Sink:        sink,
+			Phase:       event.Phase,
+			TaskName:    event.TaskName,
+			ReplayKey:   event.ReplayKey,
+			DeliveryID:  event.Delivery.ID,
+			Payload:     payload,
+		},
+	}
+	if err := controllerutil.SetOwnerReference(observation, outboxDelivery, c.Scheme()); err != nil {
+		return fmt.Errorf("failed to set the owner of the delivery - %w", err)
+	}
+	if err := c.Create(ctx, outboxDelivery); err != nil {
+		if apierrors.IsAlreadyExists(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to add the delivery '%s' to the outbox - %w", outboxDelivery.Name, err)
+	}
+
+	outboxDelivery.Status = obsv1.ObservationDeliveryStatus{
+		State:           obsv1.DeliveryPending,
+		Attempts:        event.Delivery.Attempt,
+		LastError:       deliveryErr.Error(),
+		LastAttemptTime: &metav1.Time{Time: now},
+		NextAttemptTime: &metav1.Time{Time: now.Add(Backoff(tektonobserver.ControllerConfiguration.GetRetryPolicy(), event.Delivery.Attempt))},
+	}
+	if err := c.Status().Update(ctx, outboxDelivery); err != nil {
+		return fmt.Errorf("failed to set the status of the delivery '%s' - %w", outboxDelivery.Name, err)
+	}
+	return nil
+}
+
+// Event rebuilds the event of the delivery for its next attempt
+func Event(outboxDelivery *obsv1.ObservationDelivery) (sinks.Event, error) {
+	data := &tekton.PipelineRunData{}
+	if err := json.Unmarshal(outboxDelivery.Spec.Payload, data); err != nil {
+		return sinks.Event{}, fmt.Errorf("failed to unmarshal the payload of the delivery '%s' - %w", outboxDelivery.Name, err)
+	}
+	return sinks.Event{
+		Phase:     outboxDelivery.Spec.Phase,
+		Data:      data,
+		TaskName:  outboxDelivery.Spec.TaskName,
+		ReplayKey: outboxDelivery.Spec.ReplayKey,
+		Delivery: delivery.Metadata{
+			ID:      outboxDelivery.Spec.DeliveryID,
+			Attempt: outboxDelivery.Status.Attempts + 1,
+		},
+	}, nil
+}
+
+// Backoff returns the time to wait after the failed attempts before the next one, it doubles with every attempt
+// from the initial backoff up to the maximum backoff of the retry policy
+func Backoff(policy tektonobserver.RetryPolicy, attempts int) time.Duration {
+	backoff := policy.InitialBackoff.Duration
+	for i := 1; i < attempts && backoff < policy.MaxBackoff.Duration; i++ {
+		backoff *= 2
+	}
+	if backoff > policy.MaxBackoff.Duration {
+		return policy.MaxBackoff.Duration
+	}
+	return backoff
+}
+
+// Exhausted returns true when the failed attempts used up the retries of the retry policy
+func Exhausted(policy tektonobserver.RetryPolicy, attempts int) bool {
+	return attempts > policy.MaxRetries
+}
+
+// truncateLabel truncates the value to the maximum length of a label value, which must end with an alphanumeric
+// character
+func truncateLabel(value string) string {
+	if len(value) > 63 {
+		value = strings.TrimRight(value[:63], "-_.")
+	}
+	return value
+}
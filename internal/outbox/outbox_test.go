@@ -0,0 +1,97 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"github.com/kcloutie/tekton-observer/internal/tektonobserver"
+	"github.com/kcloutie/tekton-observer/pkg/delivery"
+	"github.com/kcloutie/tekton-observer/pkg/sinks"
+	"github.com/kcloutie/tekton-observer/pkg/tekton"
+	"github.com/kcloutie/tekton-observer/test/utils"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestBackoff(t *testing.T) {
+	policy := tektonobserver.RetryPolicy{
+		MaxRetries:     5,
+		InitialBackoff: metav1.Duration{Duration: 5 * time.Second},
+		MaxBackoff:     metav1.Duration{Duration: time.Minute},
+	}
+	tests := []struct {
+		name     string
+		attempts int
+		want     time.Duration
+	}{
+		{
+			name:     "Test with the first attempt",
+			attempts: 1,
+			want:     5 * time.Second,
+		},
+		{
+			name:     "Test with the third attempt",
+			attempts: 3,
+			want:     20 * time.Second,
+		},
+		{
+			name:     "Test with the maximum backoff",
+			attempts: 10,
+			want:     time.Minute,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Backoff(policy, tt.attempts); got != tt.want {
+				t.Errorf("Backoff() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnqueue(t *testing.T) {
+	ctx := context.Background()
+	observation := &obsv1.TektonObservation{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: tektonobserver.ObservationCrdName, UID: "observation-uid"},
+	}
+	pipelineRun := utils.NewPipelineRun("test-namespace", "test-name", nil, true)
+	pipelineRun.UID = "test-uid"
+	event := sinks.Event{
+		Phase:    obsv1.PhaseTaskCompleted,
+		TaskName: "build",
+		Data:     &tekton.PipelineRunData{RawPipelineRun: pipelineRun, Namespace: "test-namespace", PipelineRunName: "test-name", Status: tekton.StatusSucceeded},
+		Delivery: delivery.Metadata{ID: "abc", Attempt: 1},
+	}
+	fakeClient := utils.NewFakeClient(observation)
+
+	for i := 0; i < 2; i++ {
+		if err := Enqueue(ctx, fakeClient, observation, "pubsub/project/topic", event, errors.New("boom"), time.Now()); err != nil {
+			t.Fatalf("Enqueue() error = %v", err)
+		}
+	}
+
+	outboxDelivery := &obsv1.ObservationDelivery{}
+	if err := fakeClient.Get(ctx, client.ObjectKey{Namespace: "test-namespace", Name: Name("abc")}, outboxDelivery); err != nil {
+		t.Fatal(err)
+	}
+	if outboxDelivery.Status.State != obsv1.DeliveryPending || outboxDelivery.Status.Attempts != 1 || outboxDelivery.Status.LastError != "boom" {
+		t.Errorf("Enqueue() status = %+v", outboxDelivery.Status)
+	}
+	if len(outboxDelivery.OwnerReferences) != 1 || outboxDelivery.OwnerReferences[0].UID != observation.UID {
+		t.Errorf("Enqueue() owner references = %v", outboxDelivery.OwnerReferences)
+	}
+
+	got, err := Event(outboxDelivery)
+	if err != nil {
+		t.Fatalf("Event() error = %v", err)
+	}
+	if got.Phase != event.Phase || got.TaskName != event.TaskName || got.Data.Status != tekton.StatusSucceeded || got.Data.RawPipelineRun.UID != "test-uid" {
+		t.Errorf("Event() = %+v", got)
+	}
+	if got.Delivery != (delivery.Metadata{ID: "abc", Attempt: 2}) {
+		t.Errorf("Event() delivery = %+v, want the second attempt", got.Delivery)
+	}
+}
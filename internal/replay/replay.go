@@ -0,0 +1,123 @@
+package replay
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/kcloutie/tekton-observer/internal/tektonobserver"
+	tknv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// ReplayAttribute flags the messages of a replay
+	ReplayAttribute = "replay"
+	// KeyAttribute carries the idempotency key of the replay, consumers use it to dedupe the replayed messages
+	KeyAttribute = "replayKey"
+)
+
+// Selection selects the PipelineRuns to replay
+type Selection struct {
+	// Namespace of the PipelineRuns, every namespace is selected when it is empty
+	Namespace string
+	Selector  labels.Selector
+	// Names of the PipelineRuns, every PipelineRun is selected when it is empty
+	Names []string
+	// Since and Until select the PipelineRuns by completion time, a zero time leaves the window open
+	Since time.Time
+	Until time.Time
+}
+
+// Matches returns true when the PipelineRun finished and is selected
+func (s *Selection) Matches(pipelineRun *tknv1.PipelineRun) bool {
+	if !pipelineRun.IsDone() {
+		return false
+	}
+	if s.Namespace != "" && pipelineRun.Namespace != s.Namespace {
+		return false
+	}
+	if s.Selector != nil && !s.Selector.Matches(labels.Set(pipelineRun.Labels)) {
+		return false
+	}
+	if len(s.Names) > 0 && !contains(s.Names, pipelineRun.Name) {
+		return false
+	}
+	if !s.Since.IsZero() || !s.Until.IsZero() {
+		completion := pipelineRun.Status.CompletionTime
+		if completion == nil {
+			return false
+		}
+		if !s.Since.IsZero() && completion.Time.Before(s.Since) {
+			return false
+		}
+		if !s.Until.IsZero() && completion.Time.After(s.Until) {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Requested returns the idempotency key of the replay requested on the PipelineRun. An empty key is returned when no
+// replay is requested or when the replay with the same key was already delivered.
+func Requested(pipelineRun *tknv1.PipelineRun) string {
+	key := pipelineRun.Annotations[tektonobserver.ReplayAnnotation]
+	if key == "" || key == pipelineRun.Annotations[tektonobserver.ReplayedAnnotation] {
+		return ""
+	}
+	return key
+}
+
+// Request asks the controller to deliver the PipelineRun again, to the named sink only when sink is set. The
+// processing state label is removed so the PipelineRun is cached again when the processed PipelineRuns are excluded
+// from the cache of the controller. The PipelineRun is updated with the patched object.
+func Request(ctx context.Context, c client.Client, pipelineRun *tknv1.PipelineRun, key, sink string) error {
+	updated := pipelineRun.DeepCopy()
+	if updated.Annotations == nil {
+		updated.Annotations = map[string]string{}
+	}
+	updated.Annotations[tektonobserver.ReplayAnnotation] = key
+	if sink != "" {
+		updated.Annotations[tektonobserver.ReplaySinkAnnotation] = sink
+	} else {
+		delete(updated.Annotations, tektonobserver.ReplaySinkAnnotation)
+	}
+	delete(updated.Labels, tektonobserver.PipelineProcessingStateLabel)
+
+	if err := c.Patch(ctx, updated, client.MergeFrom(pipelineRun)); err != nil {
+		return fmt.Errorf("failed to request the replay of the PipelineRun '%s' - %w", pipelineRun.Name, err)
+	}
+	*pipelineRun = *updated
+	return nil
+}
+
+// Complete records the key of the replay on the PipelineRun and removes the replay request. The processing state
+// label is restored from the annotation. The PipelineRun is updated with the patched object.
+func Complete(ctx context.Context, c client.Client, pipelineRun *tknv1.PipelineRun, key string) error {
+	updated := pipelineRun.DeepCopy()
+	delete(updated.Annotations, tektonobserver.ReplayAnnotation)
+	delete(updated.Annotations, tektonobserver.ReplaySinkAnnotation)
+	updated.Annotations[tektonobserver.ReplayedAnnotation] = key
+	if state, found := updated.Annotations[tektonobserver.PipelineProcessingStateAnnotation]; found {
+		if updated.Labels == nil {
+			updated.Labels = map[string]string{}
+		}
+		updated.Labels[tektonobserver.PipelineProcessingStateLabel] = state
+	}
+
+	if err := c.Patch(ctx, updated, client.MergeFrom(pipelineRun)); err != nil {
+		return fmt.Errorf("failed to complete the replay of the PipelineRun '%s' - %w", pipelineRun.Name, err)
+	}
+	*pipelineRun = *updated
+	return nil
+}
@@ -0,0 +1,114 @@
+package replay
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kcloutie/tekton-observer/internal/tektonobserver"
+	"github.com/kcloutie/tekton-observer/test/utils"
+	tknv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestSelection_Matches(t *testing.T) {
+	now := time.Now()
+	pipelineRun := utils.NewPipelineRun("team-a", "build-1", nil, true)
+	pipelineRun.Status.CompletionTime = &metav1.Time{Time: now.Add(-time.Hour)}
+
+	tests := []struct {
+		name        string
+		selection   Selection
+		pipelineRun *tknv1.PipelineRun
+		want        bool
+	}{
+		{
+			name:        "Test with an empty selection",
+			pipelineRun: pipelineRun,
+			want:        true,
+		},
+		{
+			name:        "Test with a running pipelineRun",
+			pipelineRun: utils.NewPipelineRun("team-a", "build-2", nil, false),
+			want:        false,
+		},
+		{
+			name:        "Test with another namespace",
+			selection:   Selection{Namespace: "team-b"},
+			pipelineRun: pipelineRun,
+			want:        false,
+		},
+		{
+			name:        "Test with a matching selector",
+			selection:   Selection{Selector: labels.SelectorFromSet(labels.Set{"tekton.dev/pipelineRun": "test-pipeline-run"})},
+			pipelineRun: pipelineRun,
+			want:        true,
+		},
+		{
+			name:        "Test with another name",
+			selection:   Selection{Names: []string{"build-2"}},
+			pipelineRun: pipelineRun,
+			want:        false,
+		},
+		{
+			name:        "Test with a matching time window",
+			selection:   Selection{Since: now.Add(-2 * time.Hour), Until: now},
+			pipelineRun: pipelineRun,
+			want:        true,
+		},
+		{
+			name:        "Test with a completion before the time window",
+			selection:   Selection{Since: now.Add(-30 * time.Minute)},
+			pipelineRun: pipelineRun,
+			want:        false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.selection.Matches(tt.pipelineRun); got != tt.want {
+				t.Errorf("Selection.Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequestAndComplete(t *testing.T) {
+	ctx := context.Background()
+	pipelineRun := utils.NewPipelineRun("team-a", "build-1", map[string]string{
+		tektonobserver.PipelineProcessingStateAnnotation: tektonobserver.ProcessingCompleteState,
+	}, true)
+	pipelineRun.Labels[tektonobserver.PipelineProcessingStateLabel] = tektonobserver.ProcessingCompleteState
+	fakeClient := utils.NewFakeClient(pipelineRun)
+
+	if err := Request(ctx, fakeClient, pipelineRun, "replay-1", "pubsub/project/topic"); err != nil {
+		t.Fatalf("Request() error = %v", err)
+	}
+	pr := &tknv1.PipelineRun{}
+	if err := fakeClient.Get(ctx, client.ObjectKeyFromObject(pipelineRun), pr); err != nil {
+		t.Fatal(err)
+	}
+	if got := Requested(pr); got != "replay-1" {
+		t.Errorf("Requested() = %v, want replay-1", got)
+	}
+	if _, found := pr.Labels[tektonobserver.PipelineProcessingStateLabel]; found {
+		t.Errorf("Request() kept the processing state label")
+	}
+
+	if err := Complete(ctx, fakeClient, pr, "replay-1"); err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+	if err := fakeClient.Get(ctx, client.ObjectKeyFromObject(pipelineRun), pr); err != nil {
+		t.Fatal(err)
+	}
+	if got := Requested(pr); got != "" {
+		t.Errorf("Requested() = %v, want no replay", got)
+	}
+	if _, found := pr.Annotations[tektonobserver.ReplaySinkAnnotation]; found {
+		t.Errorf("Complete() kept the replay sink annotation")
+	}
+	if got := pr.Labels[tektonobserver.PipelineProcessingStateLabel]; got != tektonobserver.ProcessingCompleteState {
+		t.Errorf("Complete() label = %v, want %v", got, tektonobserver.ProcessingCompleteState)
+	}
+}
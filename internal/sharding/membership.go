@@ -0,0 +1,188 @@
+package sharding
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/kcloutie/tekton-observer/internal/tektonobserver"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// ShardGroupLabel is set on the Leases of the members of a shard group
+	ShardGroupLabel = tektonobserver.GroupName + "/shard-group"
+
+	DefaultLeaseDuration = 30 * time.Second
+	DefaultRenewInterval = 10 * time.Second
+)
+
+// Membership keeps a Lease of this replica renewed and tracks the replicas holding a Lease in the same group. A
+// replica that stops renewing its Lease is dropped from the members once the Lease expired.
+type Membership struct {
+	// Client writes the Lease of this replica
+	Client client.Client
+	// Reader lists the Leases of the group, it should read from the API server so the Leases are not cached
+	Reader    client.Reader
+	Namespace string
+	Group     string
+	Identity  string
+	// LeaseDuration is the time after which a Lease that was not renewed is considered dead
+	LeaseDuration time.Duration
+	RenewInterval time.Duration
+	Log           logr.Logger
+
+	mu        sync.RWMutex
+	members   []string
+	synced    bool
+	listeners []func(members []string)
+}
+
+// Members returns the sorted identities of the live members and whether they have been listed yet
+func (m *Membership) Members() ([]string, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.members, m.synced
+}
+
+// OnChange registers a function called with the new members every time they change
+func (m *Membership) OnChange(listener func(members []string)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.listeners = append(m.listeners, listener)
+}
+
+func (m *Membership) leaseName() string {
+	return fmt.Sprintf("%s-%s", m.Group, m.Identity)
+}
+
+func (m *Membership) leaseDuration() time.Duration {
+	if m.LeaseDuration > 0 {
+		return m.LeaseDuration
+	}
+	return DefaultLeaseDuration
+}
+
+// Start implements manager.Runnable, it renews the Lease and refreshes the members until the context is cancelled.
+// The Lease is deleted on shutdown so the shards of this replica are reassigned right away.
+func (m *Membership) Start(ctx context.Context) error {
+	interval := m.RenewInterval
+	if interval <= 0 {
+		interval = DefaultRenewInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.renew(ctx, time.Now()); err != nil {
+			m.Log.Error(err, "Failed to renew the shard Lease")
+		}
+		if err := m.refresh(ctx, time.Now()); err != nil {
+			m.Log.Error(err, "Failed to list the shard members")
+		}
+
+		select {
+		case <-ctx.Done():
+			m.release()
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable, every replica is a member
+func (m *Membership) NeedLeaderElection() bool {
+	return false
+}
+
+func (m *Membership) renew(ctx context.Context, now time.Time) error {
+	lease := &coordinationv1.Lease{}
+	err := m.Reader.Get(ctx, client.ObjectKey{Namespace: m.Namespace, Name: m.leaseName()}, lease)
+	if apierrors.IsNotFound(err) {
+		lease = &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: m.Namespace,
+				Name:      m.leaseName(),
+				Labels:    map[string]string{ShardGroupLabel: m.Group},
+			},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       ptr.To(m.Identity),
+				LeaseDurationSeconds: ptr.To(int32(m.leaseDuration().Seconds())),
+				AcquireTime:          &metav1.MicroTime{Time: now},
+				RenewTime:            &metav1.MicroTime{Time: now},
+			},
+		}
+		if err := m.Client.Create(ctx, lease); err != nil {
+			return fmt.Errorf("failed to create the Lease '%s' - %w", m.leaseName(), err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get the Lease '%s' - %w", m.leaseName(), err)
+	}
+
+	lease.Spec.HolderIdentity = ptr.To(m.Identity)
+	lease.Spec.LeaseDurationSeconds = ptr.To(int32(m.leaseDuration().Seconds()))
+	lease.Spec.RenewTime = &metav1.MicroTime{Time: now}
+	if err := m.Client.Update(ctx, lease); err != nil {
+		return fmt.Errorf("failed to renew the Lease '%s' - %w", m.leaseName(), err)
+	}
+	return nil
+}
+
+func (m *Membership) refresh(ctx context.Context, now time.Time) error {
+	leases := &coordinationv1.LeaseList{}
+	if err := m.Reader.List(ctx, leases, client.InNamespace(m.Namespace), client.MatchingLabels{ShardGroupLabel: m.Group}); err != nil {
+		return err
+	}
+
+	members := []string{}
+	for _, lease := range leases.Items {
+		if isAlive(lease, now) {
+			members = append(members, *lease.Spec.HolderIdentity)
+		}
+	}
+	slices.Sort(members)
+	members = slices.Compact(members)
+
+	m.mu.Lock()
+	changed := !m.synced || !slices.Equal(m.members, members)
+	m.members = members
+	m.synced = true
+	listeners := make([]func([]string), len(m.listeners))
+	copy(listeners, m.listeners)
+	m.mu.Unlock()
+
+	if changed {
+		m.Log.Info("Shard members changed", "members", members)
+		for _, listener := range listeners {
+			listener(members)
+		}
+	}
+	return nil
+}
+
+func (m *Membership) release() {
+	// The manager context is already cancelled
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	lease := &coordinationv1.Lease{ObjectMeta: metav1.ObjectMeta{Namespace: m.Namespace, Name: m.leaseName()}}
+	if err := m.Client.Delete(ctx, lease); client.IgnoreNotFound(err) != nil {
+		m.Log.Error(err, "Failed to release the shard Lease")
+	}
+}
+
+func isAlive(lease coordinationv1.Lease, now time.Time) bool {
+	if lease.Spec.HolderIdentity == nil || lease.Spec.RenewTime == nil || lease.Spec.LeaseDurationSeconds == nil {
+		return false
+	}
+	expiry := lease.Spec.RenewTime.Add(time.Duration(*lease.Spec.LeaseDurationSeconds) * time.Second)
+	return now.Before(expiry)
+}
@@ -0,0 +1,98 @@
+package sharding
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/go-logr/zapr"
+	"github.com/kcloutie/tekton-observer/test/utils"
+	"go.uber.org/zap/zaptest"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func newLease(group, identity string, renewTime time.Time) *coordinationv1.Lease {
+	return &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "tekton-observer-system",
+			Name:      group + "-" + identity,
+			Labels:    map[string]string{ShardGroupLabel: group},
+		},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity:       ptr.To(identity),
+			LeaseDurationSeconds: ptr.To(int32(30)),
+			RenewTime:            &metav1.MicroTime{Time: renewTime},
+		},
+	}
+}
+
+func TestMembership(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+	fakeClient := utils.NewFakeClient(
+		newLease("tekton-observer", "replica-b", now.Add(-10*time.Second)),
+		newLease("tekton-observer", "replica-dead", now.Add(-time.Minute)),
+		newLease("other-group", "replica-c", now),
+	)
+	m := &Membership{
+		Client:    fakeClient,
+		Reader:    fakeClient,
+		Namespace: "tekton-observer-system",
+		Group:     "tekton-observer",
+		Identity:  "replica-a",
+		Log:       zapr.NewLogger(zaptest.NewLogger(t)),
+	}
+	changes := 0
+	m.OnChange(func(_ []string) { changes++ })
+
+	if err := m.renew(ctx, now); err != nil {
+		t.Fatalf("renew() error = %v", err)
+	}
+	if err := m.refresh(ctx, now); err != nil {
+		t.Fatalf("refresh() error = %v", err)
+	}
+	members, synced := m.Members()
+	if want := []string{"replica-a", "replica-b"}; !synced || !slices.Equal(members, want) {
+		t.Errorf("Members() = %v, %v, want %v, true", members, synced, want)
+	}
+
+	// Renewing updates the existing Lease and the unchanged members do not notify the listeners
+	later := now.Add(15 * time.Second)
+	if err := m.renew(ctx, later); err != nil {
+		t.Fatalf("renew() error = %v", err)
+	}
+	lease := &coordinationv1.Lease{}
+	if err := fakeClient.Get(ctx, client.ObjectKey{Namespace: m.Namespace, Name: "tekton-observer-replica-a"}, lease); err != nil {
+		t.Fatal(err)
+	}
+	if !lease.Spec.RenewTime.Time.Equal(later) {
+		t.Errorf("renew() RenewTime = %v, want %v", lease.Spec.RenewTime.Time, later)
+	}
+	if err := m.refresh(ctx, now); err != nil {
+		t.Fatalf("refresh() error = %v", err)
+	}
+	if changes != 1 {
+		t.Errorf("OnChange listener called %d times, want 1", changes)
+	}
+
+	// replica-b stops renewing its Lease
+	if err := m.refresh(ctx, now.Add(25*time.Second)); err != nil {
+		t.Fatalf("refresh() error = %v", err)
+	}
+	members, _ = m.Members()
+	if want := []string{"replica-a"}; !slices.Equal(members, want) {
+		t.Errorf("Members() = %v, want %v", members, want)
+	}
+	if changes != 2 {
+		t.Errorf("OnChange listener called %d times, want 2", changes)
+	}
+
+	m.release()
+	if err := fakeClient.Get(ctx, client.ObjectKey{Namespace: m.Namespace, Name: "tekton-observer-replica-a"}, lease); err == nil {
+		t.Errorf("release() did not delete the Lease")
+	}
+}
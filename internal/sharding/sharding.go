@@ -0,0 +1,86 @@
+package sharding
+
+import (
+	"fmt"
+	"hash/fnv"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Mode defines how the PipelineRuns are split between the replicas
+type Mode string
+
+const (
+	// ModeNamespace assigns every PipelineRun of a namespace to the same replica
+	ModeNamespace Mode = "namespace"
+	// ModeUID assigns the PipelineRuns to the replicas by UID
+	ModeUID Mode = "uid"
+)
+
+// ParseMode parses the sharding mode, an empty mode disables sharding
+func ParseMode(mode string) (Mode, error) {
+	switch Mode(mode) {
+	case "", ModeNamespace, ModeUID:
+		return Mode(mode), nil
+	}
+	return "", fmt.Errorf("unknown sharding mode '%s', the supported modes are '%s' and '%s'", mode, ModeNamespace, ModeUID)
+}
+
+// Owner returns the member owning the key using rendezvous hashing. Removing a member only moves the keys it owned,
+// every other key keeps its owner.
+func Owner(key string, members []string) string {
+	owner := ""
+	var highest uint64
+	for _, member := range members {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(member))
+		_, _ = h.Write([]byte{0})
+		_, _ = h.Write([]byte(key))
+		score := h.Sum64()
+		if owner == "" || score > highest || (score == highest && member < owner) {
+			owner = member
+			highest = score
+		}
+	}
+	return owner
+}
+
+// Sharder decides which PipelineRuns are processed by this replica
+type Sharder struct {
+	Mode       Mode
+	Membership *Membership
+}
+
+// Key returns the sharding key of the object
+func (s *Sharder) Key(obj client.Object) string {
+	if s.Mode == ModeUID {
+		return string(obj.GetUID())
+	}
+	return obj.GetNamespace()
+}
+
+// Owns returns true when the object belongs to the shard of this replica. Nothing is owned until the members have
+// been listed once, so the replicas starting together do not process the same PipelineRuns.
+func (s *Sharder) Owns(obj client.Object) bool {
+	if s == nil {
+		return true
+	}
+	return s.owns(s.Key(obj))
+}
+
+// OwnsNamespace returns true when the work done once for the whole namespace, such as flushing its digests, belongs
+// to this replica. A namespace is owned by a single replica whatever the mode.
+func (s *Sharder) OwnsNamespace(namespace string) bool {
+	if s == nil {
+		return true
+	}
+	return s.owns(namespace)
+}
+
+func (s *Sharder) owns(key string) bool {
+	members, synced := s.Membership.Members()
+	if !synced {
+		return false
+	}
+	return Owner(key, members) == s.Membership.Identity
+}
@@ -0,0 +1,138 @@
+package sharding
+
+import (
+	"fmt"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestOwner(t *testing.T) {
+	members := []string{"replica-a", "replica-b", "replica-c"}
+	owners := map[string]string{}
+	counts := map[string]int{}
+	for i := 0; i < 300; i++ {
+		key := fmt.Sprintf("namespace-%d", i)
+		owners[key] = Owner(key, members)
+		counts[owners[key]]++
+	}
+	for _, member := range members {
+		if counts[member] == 0 {
+			t.Errorf("Owner() did not assign any key to %s", member)
+		}
+	}
+
+	// Removing a member only moves the keys it owned
+	remaining := []string{"replica-a", "replica-c"}
+	for key, owner := range owners {
+		got := Owner(key, remaining)
+		if owner != "replica-b" && got != owner {
+			t.Errorf("Owner(%s) moved from %s to %s", key, owner, got)
+		}
+		if got == "replica-b" {
+			t.Errorf("Owner(%s) = replica-b which is not a member", key)
+		}
+	}
+
+	if got := Owner("key", nil); got != "" {
+		t.Errorf("Owner() without members = %v, want empty", got)
+	}
+}
+
+func TestSharder_Owns(t *testing.T) {
+	obj := func(namespace, uid string) client.Object {
+		return &metav1.PartialObjectMetadata{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, UID: types.UID(uid)}}
+	}
+	members := []string{"replica-a", "replica-b"}
+	tests := []struct {
+		name     string
+		sharder  *Sharder
+		obj      client.Object
+		want     bool
+		identity string
+	}{
+		{
+			name: "Test without sharding",
+			obj:  obj("ns", "uid"),
+			want: true,
+		},
+		{
+			name:    "Test before the members are listed",
+			sharder: &Sharder{Mode: ModeNamespace, Membership: &Membership{Identity: Owner("ns", members)}},
+			obj:     obj("ns", "uid"),
+			want:    false,
+		},
+		{
+			name:     "Test with a namespace owned by the replica",
+			sharder:  &Sharder{Mode: ModeNamespace},
+			identity: Owner("ns", members),
+			obj:      obj("ns", "uid"),
+			want:     true,
+		},
+		{
+			name:     "Test with a uid owned by another replica",
+			sharder:  &Sharder{Mode: ModeUID},
+			identity: otherMember(Owner("uid", members), members),
+			obj:      obj("ns", "uid"),
+			want:     false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.sharder != nil && tt.sharder.Membership == nil {
+				tt.sharder.Membership = &Membership{Identity: tt.identity, members: members, synced: true}
+			}
+			if got := tt.sharder.Owns(tt.obj); got != tt.want {
+				t.Errorf("Owns() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func otherMember(member string, members []string) string {
+	for _, m := range members {
+		if m != member {
+			return m
+		}
+	}
+	return ""
+}
+
+func TestSharder_OwnsNamespace(t *testing.T) {
+	members := []string{"replica-a", "replica-b"}
+	tests := []struct {
+		name     string
+		sharder  *Sharder
+		identity string
+		want     bool
+	}{
+		{
+			name: "Test without sharding",
+			want: true,
+		},
+		{
+			name:     "Test with a namespace owned by the replica in uid mode",
+			sharder:  &Sharder{Mode: ModeUID},
+			identity: Owner("ns", members),
+			want:     true,
+		},
+		{
+			name:     "Test with a namespace owned by another replica",
+			sharder:  &Sharder{Mode: ModeNamespace},
+			identity: otherMember(Owner("ns", members), members),
+			want:     false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.sharder != nil {
+				tt.sharder.Membership = &Membership{Identity: tt.identity, members: members, synced: true}
+			}
+			if got := tt.sharder.OwnsNamespace("ns"); got != tt.want {
+				t.Errorf("OwnsNamespace() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
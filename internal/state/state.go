@@ -0,0 +1,52 @@
+// Package state stores the state of the controller in the ConfigMaps of the observed namespaces
+package state
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Update reads the state ConfigMap, or builds it with create when it does not exist, and passes it to mutate. The
+// ConfigMap is only created or updated when mutate reports a change. Conflicts retry from the read, including when
+// another PipelineRun creates the ConfigMap first. The ConfigMap as last read or written is returned.
+func Update(ctx context.Context, c client.Client, key client.ObjectKey, create func() (*corev1.ConfigMap, error), mutate func(configMap *corev1.ConfigMap) (bool, error)) (*corev1.ConfigMap, error) {
+	var configMap *corev1.ConfigMap
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		configMap = &corev1.ConfigMap{}
+		err := c.Get(ctx, key, configMap)
+		notFound := apierrors.IsNotFound(err)
+		if notFound {
+			configMap, err = create()
+		}
+		if err != nil {
+			return err
+		}
+		if configMap.Data == nil {
+			configMap.Data = map[string]string{}
+		}
+
+		changed, err := mutate(configMap)
+		if err != nil || !changed {
+			return err
+		}
+		if notFound {
+			if err := c.Create(ctx, configMap); err != nil {
+				if apierrors.IsAlreadyExists(err) {
+					// Another PipelineRun created it first, the conflict retries the update
+					return apierrors.NewConflict(corev1.Resource("configmaps"), key.Name, err)
+				}
+				return err
+			}
+			return nil
+		}
+		return c.Update(ctx, configMap)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return configMap, nil
+}
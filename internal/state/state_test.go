@@ -0,0 +1,116 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/kcloutie/tekton-observer/test/utils"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestUpdate(t *testing.T) {
+	key := client.ObjectKey{Namespace: "test-namespace", Name: "tekton-observer-state"}
+	newConfigMap := func(data map[string]string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name}, Data: data}
+	}
+	tests := []struct {
+		name       string
+		existing   *corev1.ConfigMap
+		concurrent *corev1.ConfigMap
+		unchanged  bool
+		mutateErr  error
+		wantData   map[string]string
+		wantErr    string
+	}{
+		{
+			name:     "Test with a missing ConfigMap",
+			wantData: map[string]string{"key": "value"},
+		},
+		{
+			name:     "Test with an existing ConfigMap",
+			existing: newConfigMap(map[string]string{"other": "value"}),
+			wantData: map[string]string{"key": "value", "other": "value"},
+		},
+		{
+			name:     "Test with an existing ConfigMap without data",
+			existing: newConfigMap(nil),
+			wantData: map[string]string{"key": "value"},
+		},
+		{
+			name:       "Test with a ConfigMap created by another PipelineRun first",
+			concurrent: newConfigMap(map[string]string{"other": "value"}),
+			wantData:   map[string]string{"key": "value", "other": "value"},
+		},
+		{
+			name:      "Test with no change",
+			unchanged: true,
+		},
+		{
+			name:      "Test with a mutate error",
+			mutateErr: errors.New("corrupted state"),
+			wantErr:   "corrupted state",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			fakeClient := utils.NewFakeClient()
+			if tt.existing != nil {
+				if err := fakeClient.Create(ctx, tt.existing); err != nil {
+					t.Fatal(err)
+				}
+			}
+			create := func() (*corev1.ConfigMap, error) {
+				if tt.concurrent != nil {
+					if err := fakeClient.Create(ctx, tt.concurrent.DeepCopy()); err != nil {
+						return nil, err
+					}
+				}
+				return newConfigMap(nil), nil
+			}
+			mutate := func(configMap *corev1.ConfigMap) (bool, error) {
+				if tt.mutateErr != nil || tt.unchanged {
+					return false, tt.mutateErr
+				}
+				configMap.Data["key"] = "value"
+				return true, nil
+			}
+
+			got, err := Update(ctx, fakeClient, key, create, mutate)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Update() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Update() unexpected error = %v", err)
+			}
+
+			stored := &corev1.ConfigMap{}
+			err = fakeClient.Get(ctx, key, stored)
+			if tt.unchanged {
+				if !apierrors.IsNotFound(err) {
+					t.Errorf("Update() stored the ConfigMap without a change, get error = %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(stored.Data) != len(tt.wantData) || len(got.Data) != len(tt.wantData) {
+				t.Fatalf("Update() data = %v, stored %v, want %v", got.Data, stored.Data, tt.wantData)
+			}
+			for k, v := range tt.wantData {
+				if stored.Data[k] != v || got.Data[k] != v {
+					t.Errorf("Update() data = %v, stored %v, want %v", got.Data, stored.Data, tt.wantData)
+				}
+			}
+		})
+	}
+}
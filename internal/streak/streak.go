@@ -0,0 +1,197 @@
+package streak
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"github.com/kcloutie/tekton-observer/internal/state"
+	"github.com/kcloutie/tekton-observer/internal/tektonobserver"
+	"github.com/kcloutie/tekton-observer/pkg/tekton"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	// State is the value of the state label of the ConfigMap storing the streaks
+	State = "streaks"
+	// ConfigMapName is the name of the ConfigMap storing the streaks of the pipelines of a namespace
+	ConfigMapName = "tekton-observer-streaks"
+	// Retention is how long the streak of a pipeline that does not run anymore is kept
+	Retention = 90 * 24 * time.Hour
+)
+
+// Record is the stored outcome history of a pipeline on a branch
+type Record struct {
+	Pipeline            string            `json:"pipeline"`
+	Branch              string            `json:"branch,omitempty"`
+	ConsecutiveFailures int               `json:"consecutiveFailures"`
+	FailingSince        *metav1.Time      `json:"failingSince,omitempty"`
+	LastStatus          string            `json:"lastStatus"`
+	PreviousStatus      string            `json:"previousStatus,omitempty"`
+	LastTransition      tekton.Transition `json:"lastTransition"`
+	LastPipelineRun     string            `json:"lastPipelineRun"`
+	LastUID             string            `json:"lastUid"`
+	LastCompletionTime  *metav1.Time      `json:"lastCompletionTime,omitempty"`
+	UpdateTime          metav1.Time       `json:"updateTime"`
+}
+
+// Streak returns the streak of the last PipelineRun of the record
+func (r *Record) Streak() *tekton.Streak {
+	return &tekton.Streak{
+		Branch:              r.Branch,
+		Transition:          r.LastTransition,
+		ConsecutiveFailures: r.ConsecutiveFailures,
+		FailingSince:        r.FailingSince,
+		PreviousStatus:      r.PreviousStatus,
+	}
+}
+
+// Branch returns the branch of the PipelineRun, it is read from the Pipelines-as-Code labels
+func Branch(data *tekton.PipelineRunData) string {
+	return data.PacLabels["branch"]
+}
+
+// Key returns the key of the streak of the pipeline of the PipelineRun in the ConfigMap
+func Key(data *tekton.PipelineRunData) string {
+	sum := sha256.Sum256([]byte(data.Namespace + "\x00" + data.PipelineName + "\x00" + Branch(data)))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Next returns the record following the outcome of the PipelineRun, previous is nil for the first PipelineRun of
+// the pipeline
+func Next(previous *Record, data *tekton.PipelineRunData, uid string, now time.Time) Record {
+	next := Record{
+		Pipeline:           data.PipelineName,
+		Branch:             Branch(data),
+		LastStatus:         data.Status,
+		LastPipelineRun:    data.PipelineRunName,
+		LastUID:            uid,
+		LastCompletionTime: data.CompletionTime,
+		UpdateTime:         metav1.Time{Time: now},
+	}
+	failed := data.Status == tekton.StatusFailed
+	previouslyFailing := previous != nil && previous.ConsecutiveFailures > 0
+	if previous != nil {
+		next.PreviousStatus = previous.LastStatus
+	}
+	switch {
+	case failed && previouslyFailing:
+		next.LastTransition = tekton.TransitionStillFailing
+		next.ConsecutiveFailures = previous.ConsecutiveFailures + 1
+		next.FailingSince = previous.FailingSince
+	case failed:
+		next.LastTransition = tekton.TransitionFirstFailure
+		next.ConsecutiveFailures = 1
+		next.FailingSince = data.CompletionTime
+		if next.FailingSince == nil {
+			next.FailingSince = &metav1.Time{Time: now}
+		}
+	case previouslyFailing:
+		next.LastTransition = tekton.TransitionRecovered
+	default:
+		next.LastTransition = tekton.TransitionStillPassing
+	}
+	return next
+}
+
+// Update records the outcome of the PipelineRun in the streak of its pipeline and branch, and returns the resulting
+// streak. Recording the same PipelineRun again returns the same streak. The PipelineRuns that neither succeeded nor
+// failed, and the PipelineRuns that completed before the last recorded PipelineRun, return a nil streak.
+func Update(ctx context.Context, c client.Client, observation *obsv1.TektonObservation, data *tekton.PipelineRunData, uid string, now time.Time) (*tekton.Streak, error) {
+	if data.Status != tekton.StatusSucceeded && data.Status != tekton.StatusFailed {
+		return nil, nil
+	}
+	key := Key(data)
+	var streak *tekton.Streak
+	_, err := state.Update(ctx, c, client.ObjectKey{Namespace: observation.Namespace, Name: ConfigMapName}, func() (*corev1.ConfigMap, error) {
+		return newConfigMap(c, observation)
+	}, func(configMap *corev1.ConfigMap) (bool, error) {
+		streak = nil
+		previous, err := decode(configMap, key)
+		if err != nil {
+			return false, err
+		}
+		if previous != nil && previous.LastUID == uid {
+			streak = previous.Streak()
+			return false, nil
+		}
+		if previous != nil && previous.LastCompletionTime != nil && data.CompletionTime != nil && data.CompletionTime.Before(previous.LastCompletionTime) {
+			return false, nil
+		}
+
+		next := Next(previous, data, uid, now)
+		raw, err := json.Marshal(next)
+		if err != nil {
+			return false, fmt.Errorf("failed to marshal the streak - %w", err)
+		}
+		prune(configMap, now)
+		configMap.Data[key] = string(raw)
+		streak = next.Streak()
+		return true, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to record the streak of the pipeline '%s' - %w", data.PipelineName, err)
+	}
+	return streak, nil
+}
+
+// Records returns every streak stored in the ConfigMap
+func Records(configMap *corev1.ConfigMap) ([]Record, error) {
+	records := []Record{}
+	for key := range configMap.Data {
+		record, err := decode(configMap, key)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, *record)
+	}
+	return records, nil
+}
+
+func newConfigMap(c client.Client, observation *obsv1.TektonObservation) (*corev1.ConfigMap, error) {
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: observation.Namespace,
+			Name:      ConfigMapName,
+			Labels:    map[string]string{tektonobserver.StateConfigMapLabel: State},
+		},
+		Data: map[string]string{},
+	}
+	// The streaks are not reconciled with the observation, the owner only removes them with it
+	if err := controllerutil.SetOwnerReference(observation, configMap, c.Scheme()); err != nil {
+		return nil, fmt.Errorf("failed to set the owner of the streaks - %w", err)
+	}
+	return configMap, nil
+}
+
+func decode(configMap *corev1.ConfigMap, key string) (*Record, error) {
+	raw, found := configMap.Data[key]
+	if !found {
+		return nil, nil
+	}
+	record := &Record{}
+	if err := json.Unmarshal([]byte(raw), record); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal the streak '%s' - %w", key, err)
+	}
+	return record, nil
+}
+
+// prune removes the streaks of the pipelines that did not run during the retention period
+func prune(configMap *corev1.ConfigMap, now time.Time) {
+	if configMap.Data == nil {
+		configMap.Data = map[string]string{}
+	}
+	for key := range configMap.Data {
+		record, err := decode(configMap, key)
+		if err != nil || now.Sub(record.UpdateTime.Time) > Retention {
+			delete(configMap.Data, key)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package streak
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"github.com/kcloutie/tekton-observer/internal/tektonobserver"
+	"github.com/kcloutie/tekton-observer/pkg/tekton"
+	"github.com/kcloutie/tekton-observer/test/utils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type outcome struct {
+	uid       string
+	status    string
+	branch    string
+	completed int
+}
+
+func TestUpdate(t *testing.T) {
+	tests := []struct {
+		name         string
+		outcomes     []outcome
+		want         tekton.Transition
+		wantFailures int
+		wantNil      bool
+	}{
+		{
+			name:     "Test with a first success",
+			outcomes: []outcome{{uid: "1", status: tekton.StatusSucceeded, completed: 1}},
+			want:     tekton.TransitionStillPassing,
+		},
+		{
+			name: "Test with a first failure",
+			outcomes: []outcome{
+				{uid: "1", status: tekton.StatusSucceeded, completed: 1},
+				{uid: "2", status: tekton.StatusFailed, completed: 2},
+			},
+			want:         tekton.TransitionFirstFailure,
+			wantFailures: 1,
+		},
+		{
+			name: "Test with failures in a row",
+			outcomes: []outcome{
+				{uid: "1", status: tekton.StatusFailed, completed: 1},
+				{uid: "2", status: tekton.StatusFailed, completed: 2},
+				{uid: "3", status: tekton.StatusFailed, completed: 3},
+			},
+			want:         tekton.TransitionStillFailing,
+			wantFailures: 3,
+		},
+		{
+			name: "Test with a recovery",
+			outcomes: []outcome{
+				{uid: "1", status: tekton.StatusFailed, completed: 1},
+				{uid: "2", status: tekton.StatusFailed, completed: 2},
+				{uid: "3", status: tekton.StatusSucceeded, completed: 3},
+			},
+			want: tekton.TransitionRecovered,
+		},
+		{
+			name: "Test with the same PipelineRun recorded again",
+			outcomes: []outcome{
+				{uid: "1", status: tekton.StatusSucceeded, completed: 1},
+				{uid: "2", status: tekton.StatusFailed, completed: 2},
+				{uid: "2", status: tekton.StatusFailed, completed: 2},
+			},
+			want:         tekton.TransitionFirstFailure,
+			wantFailures: 1,
+		},
+		{
+			name: "Test with another branch",
+			outcomes: []outcome{
+				{uid: "1", status: tekton.StatusFailed, branch: "main", completed: 1},
+				{uid: "2", status: tekton.StatusFailed, branch: "feature", completed: 2},
+			},
+			want:         tekton.TransitionFirstFailure,
+			wantFailures: 1,
+		},
+		{
+			name: "Test with a PipelineRun completed before the last one",
+			outcomes: []outcome{
+				{uid: "1", status: tekton.StatusFailed, completed: 2},
+				{uid: "2", status: tekton.StatusSucceeded, completed: 1},
+			},
+			wantNil: true,
+		},
+		{
+			name:     "Test with an aborted PipelineRun",
+			outcomes: []outcome{{uid: "1", status: tekton.StatusAborted, completed: 1}},
+			wantNil:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			observation := &obsv1.TektonObservation{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: tektonobserver.ObservationCrdName, UID: "observation-uid"},
+			}
+			fakeClient := utils.NewFakeClient(observation)
+
+			var got *tekton.Streak
+			for _, o := range tt.outcomes {
+				data := &tekton.PipelineRunData{
+					Namespace:       "test-namespace",
+					PipelineRunName: "run-" + o.uid,
+					PipelineName:    "build",
+					PacLabels:       map[string]string{"branch": o.branch},
+					Status:          o.status,
+					CompletionTime:  &metav1.Time{Time: now.Add(time.Duration(o.completed) * time.Minute)},
+				}
+				var err error
+				got, err = Update(ctx, fakeClient, observation, data, o.uid, now)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if tt.wantNil {
+				if got != nil {
+					t.Errorf("Update() = %+v, want nil", got)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatal("Update() = nil")
+			}
+			if got.Transition != tt.want || got.ConsecutiveFailures != tt.wantFailures {
+				t.Errorf("Update() = %v with %d failures, want %v with %d failures", got.Transition, got.ConsecutiveFailures, tt.want, tt.wantFailures)
+			}
+			if (got.ConsecutiveFailures > 0) != (got.FailingSince != nil) {
+				t.Errorf("Update() failing since %v with %d failures", got.FailingSince, got.ConsecutiveFailures)
+			}
+		})
+	}
+}
+
+func TestUpdate_prune(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	observation := &obsv1.TektonObservation{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: tektonobserver.ObservationCrdName, UID: "observation-uid"},
+	}
+	fakeClient := utils.NewFakeClient(observation)
+	for i, pipeline := range []string{"old", "new"} {
+		data := &tekton.PipelineRunData{Namespace: "test-namespace", PipelineRunName: pipeline, PipelineName: pipeline, Status: tekton.StatusFailed}
+		if _, err := Update(ctx, fakeClient, observation, data, pipeline, now.Add(time.Duration(i)*(Retention+time.Hour))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	configMap := &corev1.ConfigMap{}
+	if err := fakeClient.Get(ctx, client.ObjectKey{Namespace: "test-namespace", Name: ConfigMapName}, configMap); err != nil {
+		t.Fatal(err)
+	}
+	records, err := Records(configMap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Pipeline != "new" {
+		t.Errorf("Records() = %+v, want only the new pipeline", records)
+	}
+}
@@ -0,0 +1,381 @@
+package tektonobserver
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"text/template"
+	"time"
+
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"github.com/kcloutie/tekton-observer/pkg/metrics"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	ConfigAPIVersion = GroupName + "/" + V1Version
+	ConfigKind       = "ControllerConfiguration"
+
+	DefaultMaxConcurrentReconciles  = 1
+	DefaultMaxRetries               = 5
+	DefaultInitialBackoff           = 5 * time.Second
+	DefaultMaxBackoff               = 5 * time.Minute
+	DefaultFinalizerReleaseDeadline = time.Hour
+)
+
+// knownFeatureGates lists every feature gate the controller understands along with its default value.
+// Gates that are not listed here are rejected when the configuration is validated.
+var knownFeatureGates = map[string]bool{
+	// LiveProgressUpdates delivers the task-completed phase every time a TaskRun of an observed PipelineRun finishes.
+	// The TaskRuns are only watched when the gate is enabled on startup.
+	LiveProgressUpdates: false,
+	// DeliveryOutbox adds the failed deliveries to the ObservationDelivery outbox, where they are retried with a
+	// backoff, instead of retrying the whole PipelineRun until the release deadline.
+	DeliveryOutbox: false,
+}
+
+const (
+	LiveProgressUpdates = "LiveProgressUpdates"
+	DeliveryOutbox      = "DeliveryOutbox"
+)
+
+// ControllerConfig is the configuration of the controller, loaded from the file referenced by the --config flag
+type ControllerConfig struct {
+	APIVersion string `json:"apiVersion,omitempty" yaml:"apiVersion,omitempty"`
+	Kind       string `json:"kind,omitempty" yaml:"kind,omitempty"`
+
+	// ClusterName is the name of the cluster the controller is running in. It is added to every event that is published
+	ClusterName string `json:"clusterName,omitempty" yaml:"clusterName,omitempty"`
+	// DefaultSinks are the sinks used for every PipelineRun in addition to the ones defined on the TektonObservation
+	DefaultSinks DefaultSinks `json:"defaultSinks,omitempty" yaml:"defaultSinks,omitempty"`
+	// Concurrency controls how much work the controller does in parallel
+	Concurrency ConcurrencyConfig `json:"concurrency,omitempty" yaml:"concurrency,omitempty"`
+	// RetryPolicy controls how failed deliveries are retried
+	RetryPolicy RetryPolicy `json:"retryPolicy,omitempty" yaml:"retryPolicy,omitempty"`
+	// Finalizer controls how long the finalizer of a PipelineRun is kept when its delivery fails
+	Finalizer FinalizerConfig `json:"finalizer,omitempty" yaml:"finalizer,omitempty"`
+	// DashboardURLTemplate is a go template used to render a link to the PipelineRun in the Tekton dashboard
+	DashboardURLTemplate string `json:"dashboardURLTemplate,omitempty" yaml:"dashboardURLTemplate,omitempty"`
+	// LogArchive controls whether the logs of the PipelineRuns are archived
+	LogArchive LogArchiveConfig `json:"logArchive,omitempty" yaml:"logArchive,omitempty"`
+	// FeatureGates enables or disables optional features of the controller
+	FeatureGates map[string]bool `json:"featureGates,omitempty" yaml:"featureGates,omitempty"`
+	// Metrics controls the metrics exposed by the controller
+	Metrics MetricsConfig `json:"metrics,omitempty" yaml:"metrics,omitempty"`
+}
+
+type DefaultSinks struct {
+	// PubSubTopics is a list of PubSub topics every PipelineRun is published to
+	PubSubTopics []obsv1.PubSubTopic `json:"pubSubTopics,omitempty" yaml:"pubSubTopics,omitempty"`
+}
+
+type ConcurrencyConfig struct {
+	// MaxConcurrentReconciles is the maximum number of reconciles that can run at the same time. It is only read on
+	// startup.
+	MaxConcurrentReconciles int `json:"maxConcurrentReconciles,omitempty" yaml:"maxConcurrentReconciles,omitempty"`
+}
+
+type RetryPolicy struct {
+	// MaxRetries is the number of times a failed delivery is retried before giving up
+	MaxRetries int `json:"maxRetries,omitempty" yaml:"maxRetries,omitempty"`
+	// InitialBackoff is the time to wait before the first retry
+	InitialBackoff metav1.Duration `json:"initialBackoff,omitempty" yaml:"initialBackoff,omitempty"`
+	// MaxBackoff is the maximum time to wait between two retries
+	MaxBackoff metav1.Duration `json:"maxBackoff,omitempty" yaml:"maxBackoff,omitempty"`
+}
+
+type FinalizerConfig struct {
+	// ReleaseDeadline is the time after the PipelineRun finished, or was deleted, after which the finalizer is
+	// released even though the PipelineRun could not be delivered to every sink
+	ReleaseDeadline metav1.Duration `json:"releaseDeadline,omitempty" yaml:"releaseDeadline,omitempty"`
+}
+
+type LogArchiveConfig struct {
+	// Enabled turns on the archiving of the PipelineRun logs
+	Enabled bool `json:"enabled,omitempty" yaml:"enabled,omitempty"`
+	// Bucket is the GCS bucket the logs are saved to
+	Bucket string `json:"bucket,omitempty" yaml:"bucket,omitempty"`
+	// Prefix is prepended to the name of every log object
+	Prefix string `json:"prefix,omitempty" yaml:"prefix,omitempty"`
+}
+
+type MetricsConfig struct {
+	// Buckets are the upper bounds of the buckets of the request histograms by histogram name, in seconds. The
+	// histograms that are not listed use metrics.DefaultRequestBuckets. They are only read on startup.
+	Buckets map[string][]float64 `json:"buckets,omitempty" yaml:"buckets,omitempty"`
+}
+
+// DefaultControllerConfig returns the configuration used when no configuration file is provided
+func DefaultControllerConfig() ControllerConfig {
+	return ControllerConfig{
+		APIVersion: ConfigAPIVersion,
+		Kind:       ConfigKind,
+		Concurrency: ConcurrencyConfig{
+			MaxConcurrentReconciles: DefaultMaxConcurrentReconciles,
+		},
+		RetryPolicy: RetryPolicy{
+			MaxRetries:     DefaultMaxRetries,
+			InitialBackoff: metav1.Duration{Duration: DefaultInitialBackoff},
+			MaxBackoff:     metav1.Duration{Duration: DefaultMaxBackoff},
+		},
+		Finalizer: FinalizerConfig{
+			ReleaseDeadline: metav1.Duration{Duration: DefaultFinalizerReleaseDeadline},
+		},
+		FeatureGates: map[string]bool{},
+	}
+}
+
+// LoadControllerConfig reads, defaults and validates the configuration file at path
+func LoadControllerConfig(path string) (ControllerConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ControllerConfig{}, fmt.Errorf("failed to read the controller configuration file '%s' - %w", path, err)
+	}
+	return ParseControllerConfig(data)
+}
+
+// ParseControllerConfig parses, defaults and validates a configuration document
+func ParseControllerConfig(data []byte) (ControllerConfig, error) {
+	config := DefaultControllerConfig()
+	if err := yaml.UnmarshalStrict(data, &config); err != nil {
+		return ControllerConfig{}, fmt.Errorf("failed to parse the controller configuration - %w", err)
+	}
+	config.setDefaults()
+	if err := config.Validate(); err != nil {
+		return ControllerConfig{}, err
+	}
+	return config, nil
+}
+
+func (c *ControllerConfig) setDefaults() {
+	if c.APIVersion == "" {
+		c.APIVersion = ConfigAPIVersion
+	}
+	if c.Kind == "" {
+		c.Kind = ConfigKind
+	}
+	if c.Concurrency.MaxConcurrentReconciles == 0 {
+		c.Concurrency.MaxConcurrentReconciles = DefaultMaxConcurrentReconciles
+	}
+	if c.RetryPolicy.InitialBackoff.Duration == 0 {
+		c.RetryPolicy.InitialBackoff.Duration = DefaultInitialBackoff
+	}
+	if c.RetryPolicy.MaxBackoff.Duration == 0 {
+		c.RetryPolicy.MaxBackoff.Duration = DefaultMaxBackoff
+	}
+	if c.Finalizer.ReleaseDeadline.Duration == 0 {
+		c.Finalizer.ReleaseDeadline.Duration = DefaultFinalizerReleaseDeadline
+	}
+	if c.FeatureGates == nil {
+		c.FeatureGates = map[string]bool{}
+	}
+}
+
+// Validate returns an error describing every problem found in the configuration
+func (c *ControllerConfig) Validate() error {
+	var errs []error
+	if c.APIVersion != ConfigAPIVersion {
+		errs = append(errs, fmt.Errorf("apiVersion must be '%s' but was '%s'", ConfigAPIVersion, c.APIVersion))
+	}
+	if c.Kind != ConfigKind {
+		errs = append(errs, fmt.Errorf("kind must be '%s' but was '%s'", ConfigKind, c.Kind))
+	}
+	for i, topic := range c.DefaultSinks.PubSubTopics {
+		if topic.PubSubProjectID == "" {
+			errs = append(errs, fmt.Errorf("defaultSinks.pubSubTopics[%d].pubSubProjectID is required", i))
+		}
+		if topic.PubSubTopicID == "" {
+			errs = append(errs, fmt.Errorf("defaultSinks.pubSubTopics[%d].pubSubTopicID is required", i))
+		}
+		for _, phase := range topic.Phases {
+			if !slices.Contains(obsv1.Phases, phase) {
+				errs = append(errs, fmt.Errorf("defaultSinks.pubSubTopics[%d].phases contains the unknown phase '%s', the supported phases are %v", i, phase, obsv1.Phases))
+			}
+		}
+		if topic.OrderingKey != "" && !slices.Contains(obsv1.OrderingKeys, topic.OrderingKey) {
+			errs = append(errs, fmt.Errorf("defaultSinks.pubSubTopics[%d].orderingKey '%s' is unknown, the supported ordering keys are %v", i, topic.OrderingKey, obsv1.OrderingKeys))
+		}
+	}
+	if c.Concurrency.MaxConcurrentReconciles < 1 {
+		errs = append(errs, fmt.Errorf("concurrency.maxConcurrentReconciles must be greater than 0"))
+	}
+	if c.RetryPolicy.MaxRetries < 0 {
+		errs = append(errs, fmt.Errorf("retryPolicy.maxRetries cannot be negative"))
+	}
+	if c.RetryPolicy.InitialBackoff.Duration < 0 || c.RetryPolicy.MaxBackoff.Duration < 0 {
+		errs = append(errs, fmt.Errorf("retryPolicy backoffs cannot be negative"))
+	}
+	if c.RetryPolicy.InitialBackoff.Duration > c.RetryPolicy.MaxBackoff.Duration {
+		errs = append(errs, fmt.Errorf("retryPolicy.initialBackoff (%v) cannot be greater than retryPolicy.maxBackoff (%v)", c.RetryPolicy.InitialBackoff.Duration, c.RetryPolicy.MaxBackoff.Duration))
+	}
+	if c.Finalizer.ReleaseDeadline.Duration < 0 {
+		errs = append(errs, fmt.Errorf("finalizer.releaseDeadline cannot be negative"))
+	}
+	if c.DashboardURLTemplate != "" {
+		if _, err := template.New("dashboard").Parse(c.DashboardURLTemplate); err != nil {
+			errs = append(errs, fmt.Errorf("dashboardURLTemplate is not a valid template - %w", err))
+		}
+	}
+	if c.LogArchive.Enabled && c.LogArchive.Bucket == "" {
+		errs = append(errs, fmt.Errorf("logArchive.bucket is required when logArchive is enabled"))
+	}
+	for name := range c.FeatureGates {
+		if _, ok := knownFeatureGates[name]; !ok {
+			errs = append(errs, fmt.Errorf("unknown feature gate '%s'", name))
+		}
+	}
+	for name, buckets := range c.Metrics.Buckets {
+		if !slices.Contains(metrics.HistogramNames(), name) {
+			errs = append(errs, fmt.Errorf("metrics.buckets contains the unknown histogram '%s', the supported histograms are %v", name, metrics.HistogramNames()))
+			continue
+		}
+		for i, bucket := range buckets {
+			if bucket <= 0 || (i > 0 && bucket <= buckets[i-1]) {
+				errs = append(errs, fmt.Errorf("metrics.buckets.%s must be positive and sorted in increasing order", name))
+				break
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid controller configuration - %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+// ConfigStore holds the active controller configuration and allows it to be swapped at runtime
+type ConfigStore struct {
+	mu        sync.RWMutex
+	config    ControllerConfig
+	listeners []func(ControllerConfig)
+	overrides []func(*ControllerConfig)
+}
+
+// ControllerConfiguration is the configuration currently used by the controller
+var ControllerConfiguration = NewConfigStore(DefaultControllerConfig())
+
+func NewConfigStore(config ControllerConfig) *ConfigStore {
+	return &ConfigStore{config: config}
+}
+
+// Get returns a copy of the active configuration
+func (s *ConfigStore) Get() ControllerConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	config := s.config
+	config.DefaultSinks.PubSubTopics = append([]obsv1.PubSubTopic(nil), s.config.DefaultSinks.PubSubTopics...)
+	config.FeatureGates = make(map[string]bool, len(s.config.FeatureGates))
+	for k, v := range s.config.FeatureGates {
+		config.FeatureGates[k] = v
+	}
+	if s.config.Metrics.Buckets != nil {
+		config.Metrics.Buckets = make(map[string][]float64, len(s.config.Metrics.Buckets))
+		for name, buckets := range s.config.Metrics.Buckets {
+			config.Metrics.Buckets[name] = slices.Clone(buckets)
+		}
+	}
+	return config
+}
+
+// Set replaces the active configuration and notifies the registered listeners. The registered overrides are applied
+// to the new configuration first.
+func (s *ConfigStore) Set(config ControllerConfig) {
+	s.mu.Lock()
+	for _, override := range s.overrides {
+		override(&config)
+	}
+	s.config = config
+	listeners := make([]func(ControllerConfig), len(s.listeners))
+	copy(listeners, s.listeners)
+	s.mu.Unlock()
+
+	for _, listener := range listeners {
+		listener(config)
+	}
+}
+
+// AddOverride registers a function that modifies the configuration every time it is set, which is used to give command
+// line flags precedence over the configuration file. The override is applied to the active configuration immediately.
+func (s *ConfigStore) AddOverride(override func(*ControllerConfig)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.overrides = append(s.overrides, override)
+	override(&s.config)
+}
+
+// OnChange registers a function that is called every time the configuration is replaced
+func (s *ConfigStore) OnChange(listener func(ControllerConfig)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, listener)
+}
+
+func (s *ConfigStore) GetClusterName() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.config.ClusterName
+}
+
+func (s *ConfigStore) GetDefaultPubSubTopics() []obsv1.PubSubTopic {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]obsv1.PubSubTopic(nil), s.config.DefaultSinks.PubSubTopics...)
+}
+
+func (s *ConfigStore) GetMaxConcurrentReconciles() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.config.Concurrency.MaxConcurrentReconciles
+}
+
+func (s *ConfigStore) GetRetryPolicy() RetryPolicy {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.config.RetryPolicy
+}
+
+func (s *ConfigStore) GetFinalizerReleaseDeadline() time.Duration {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.config.Finalizer.ReleaseDeadline.Duration
+}
+
+func (s *ConfigStore) GetLogArchive() LogArchiveConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.config.LogArchive
+}
+
+// IsFeatureEnabled returns the value of the feature gate, falling back to its default when it is not configured
+func (s *ConfigStore) IsFeatureEnabled(name string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if enabled, ok := s.config.FeatureGates[name]; ok {
+		return enabled
+	}
+	return knownFeatureGates[name]
+}
+
+// RenderDashboardURL renders the dashboard URL template with the given data. An empty string is returned when no
+// template is configured
+func (s *ConfigStore) RenderDashboardURL(data interface{}) (string, error) {
+	s.mu.RLock()
+	tmpl := s.config.DashboardURLTemplate
+	s.mu.RUnlock()
+	if tmpl == "" {
+		return "", nil
+	}
+
+	t, err := template.New("dashboard").Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse the dashboard URL template - %w", err)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render the dashboard URL template - %w", err)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,251 @@
+package tektonobserver
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-logr/zapr"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestParseControllerConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		wantErr     string
+		wantCluster string
+		wantMax     int
+		wantBackoff time.Duration
+	}{
+		{
+			name:        "Test with empty document uses defaults",
+			data:        "",
+			wantMax:     DefaultMaxConcurrentReconciles,
+			wantBackoff: DefaultInitialBackoff,
+		},
+		{
+			name: "Test with full document",
+			data: `
+apiVersion: observer.tkn.dev/v1
+kind: ControllerConfiguration
+clusterName: my-cluster
+defaultSinks:
+  pubSubTopics:
+  - pubSubProjectID: my-project
+    pubSubTopicID: my-topic
+concurrency:
+  maxConcurrentReconciles: 4
+retryPolicy:
+  maxRetries: 3
+  initialBackoff: 10s
+  maxBackoff: 1m
+dashboardURLTemplate: "https://dashboard/{{ .Namespace }}"
+logArchive:
+  enabled: true
+  bucket: my-bucket
+`,
+			wantCluster: "my-cluster",
+			wantMax:     4,
+			wantBackoff: 10 * time.Second,
+		},
+		{
+			name:    "Test with unknown field",
+			data:    "clusterNam: typo",
+			wantErr: "failed to parse the controller configuration",
+		},
+		{
+			name:    "Test with wrong kind",
+			data:    "kind: Something",
+			wantErr: "kind must be 'ControllerConfiguration'",
+		},
+		{
+			name: "Test with incomplete pubsub topic",
+			data: `
+defaultSinks:
+  pubSubTopics:
+  - pubSubProjectID: my-project
+`,
+			wantErr: "defaultSinks.pubSubTopics[0].pubSubTopicID is required",
+		},
+		{
+			name: "Test with unknown phase",
+			data: `
+defaultSinks:
+  pubSubTopics:
+  - pubSubProjectID: my-project
+    pubSubTopicID: my-topic
+    phases: [started, done]
+`,
+			wantErr: "defaultSinks.pubSubTopics[0].phases contains the unknown phase 'done'",
+		},
+		{
+			name: "Test with unknown ordering key",
+			data: `
+defaultSinks:
+  pubSubTopics:
+  - pubSubProjectID: my-project
+    pubSubTopicID: my-topic
+    orderingKey: cluster
+`,
+			wantErr: "defaultSinks.pubSubTopics[0].orderingKey 'cluster' is unknown",
+		},
+		{
+			name: "Test with initial backoff greater than max backoff",
+			data: `
+retryPolicy:
+  initialBackoff: 10m
+  maxBackoff: 1m
+`,
+			wantErr: "cannot be greater than retryPolicy.maxBackoff",
+		},
+		{
+			name:    "Test with invalid dashboard template",
+			data:    `dashboardURLTemplate: "{{ .Namespace"`,
+			wantErr: "dashboardURLTemplate is not a valid template",
+		},
+		{
+			name: "Test with log archive enabled without bucket",
+			data: `
+logArchive:
+  enabled: true
+`,
+			wantErr: "logArchive.bucket is required",
+		},
+		{
+			name: "Test with unknown feature gate",
+			data: `
+featureGates:
+  doesNotExist: true
+`,
+			wantErr: "unknown feature gate 'doesNotExist'",
+		},
+		{
+			name: "Test with metrics buckets",
+			data: `
+metrics:
+  buckets:
+    tknobs_google_request_duration_seconds: [0.1, 1, 10]
+`,
+			wantMax:     DefaultMaxConcurrentReconciles,
+			wantBackoff: DefaultInitialBackoff,
+		},
+		{
+			name: "Test with buckets of an unknown histogram",
+			data: `
+metrics:
+  buckets:
+    tknobs_unknown_seconds: [1, 2]
+`,
+			wantErr: "metrics.buckets contains the unknown histogram 'tknobs_unknown_seconds'",
+		},
+		{
+			name: "Test with unsorted buckets",
+			data: `
+metrics:
+  buckets:
+    tknobs_google_request_duration_seconds: [1, 0.5]
+`,
+			wantErr: "metrics.buckets.tknobs_google_request_duration_seconds must be positive and sorted",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseControllerConfig([]byte(tt.data))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("ParseControllerConfig() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseControllerConfig() unexpected error = %v", err)
+			}
+			if got.ClusterName != tt.wantCluster {
+				t.Errorf("ParseControllerConfig() clusterName = %v, want %v", got.ClusterName, tt.wantCluster)
+			}
+			if got.Concurrency.MaxConcurrentReconciles != tt.wantMax {
+				t.Errorf("ParseControllerConfig() maxConcurrentReconciles = %v, want %v", got.Concurrency.MaxConcurrentReconciles, tt.wantMax)
+			}
+			if got.RetryPolicy.InitialBackoff.Duration != tt.wantBackoff {
+				t.Errorf("ParseControllerConfig() initialBackoff = %v, want %v", got.RetryPolicy.InitialBackoff.Duration, tt.wantBackoff)
+			}
+		})
+	}
+}
+
+func TestConfigStore_RenderDashboardURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		data     interface{}
+		want     string
+	}{
+		{
+			name:     "Test with no template",
+			template: "",
+			data:     map[string]string{"Namespace": "ns"},
+			want:     "",
+		},
+		{
+			name:     "Test with template",
+			template: "https://dashboard/#/namespaces/{{ .Namespace }}/pipelineruns/{{ .PipelineRunName }}",
+			data:     map[string]string{"Namespace": "ns", "PipelineRunName": "pr-1"},
+			want:     "https://dashboard/#/namespaces/ns/pipelineruns/pr-1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultControllerConfig()
+			config.DashboardURLTemplate = tt.template
+			got, err := NewConfigStore(config).RenderDashboardURL(tt.data)
+			if err != nil {
+				t.Fatalf("RenderDashboardURL() unexpected error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("RenderDashboardURL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigWatcher_Reload(t *testing.T) {
+	log := zapr.NewLogger(zaptest.NewLogger(t))
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	store := NewConfigStore(DefaultControllerConfig())
+
+	changes := 0
+	store.OnChange(func(ControllerConfig) { changes++ })
+
+	watcher := NewConfigWatcher(path, store, log)
+	if err := os.WriteFile(path, []byte("clusterName: first"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := watcher.Reload(); err != nil {
+		t.Fatalf("Reload() unexpected error = %v", err)
+	}
+	if store.GetClusterName() != "first" {
+		t.Errorf("GetClusterName() = %v, want first", store.GetClusterName())
+	}
+
+	// Reloading the same content does not notify the listeners
+	if err := watcher.Reload(); err != nil {
+		t.Fatalf("Reload() unexpected error = %v", err)
+	}
+	if changes != 1 {
+		t.Errorf("listener called %d times, want 1", changes)
+	}
+
+	// An invalid configuration keeps the previous one
+	if err := os.WriteFile(path, []byte("concurrency:\n  maxConcurrentReconciles: -1"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := watcher.Reload(); err == nil {
+		t.Errorf("Reload() expected an error for an invalid configuration")
+	}
+	if store.GetClusterName() != "first" {
+		t.Errorf("GetClusterName() = %v, want first", store.GetClusterName())
+	}
+}
@@ -0,0 +1,100 @@
+package tektonobserver
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-logr/logr"
+)
+
+// kubeletDataDir is the symlink the kubelet atomically swaps when the content of a mounted ConfigMap changes
+const kubeletDataDir = "..data"
+
+// ConfigWatcher reloads the controller configuration every time the configuration file changes. Mounted ConfigMaps
+// are updated by the kubelet by swapping a symlink, so the parent directory is watched instead of the file itself.
+// The settings read when the controllers are set up (concurrency.maxConcurrentReconciles, the LiveProgressUpdates
+// TaskRun watch and metrics.buckets) only take effect after a restart.
+type ConfigWatcher struct {
+	path    string
+	store   *ConfigStore
+	log     logr.Logger
+	current []byte
+}
+
+func NewConfigWatcher(path string, store *ConfigStore, log logr.Logger) *ConfigWatcher {
+	return &ConfigWatcher{
+		path:  path,
+		store: store,
+		log:   log.WithValues("configFile", path),
+	}
+}
+
+// Reload loads the configuration file and replaces the active configuration when its content changed. The active
+// configuration is kept when the new content is invalid.
+func (w *ConfigWatcher) Reload() error {
+	data, err := os.ReadFile(w.path)
+	if err != nil {
+		return fmt.Errorf("failed to read the controller configuration file '%s' - %w", w.path, err)
+	}
+	if w.current != nil && bytes.Equal(data, w.current) {
+		return nil
+	}
+	config, err := ParseControllerConfig(data)
+	if err != nil {
+		return err
+	}
+	w.current = data
+	w.store.Set(config)
+	return nil
+}
+
+// Start implements manager.Runnable
+func (w *ConfigWatcher) Start(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create the controller configuration watcher - %w", err)
+	}
+	defer watcher.Close()
+
+	if err := watcher.Add(filepath.Dir(w.path)); err != nil {
+		return fmt.Errorf("failed to watch the controller configuration directory - %w", err)
+	}
+
+	fileName := filepath.Base(w.path)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			name := filepath.Base(event.Name)
+			if name != fileName && name != kubeletDataDir {
+				continue
+			}
+			if event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 {
+				continue
+			}
+			if err := w.Reload(); err != nil {
+				w.log.Error(err, "Failed to reload the controller configuration, keeping the previous configuration")
+				continue
+			}
+			w.log.V(1).Info("Controller configuration reloaded")
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			w.log.Error(err, "Error watching the controller configuration file")
+		}
+	}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable. Every replica needs the latest configuration.
+func (w *ConfigWatcher) NeedLeaderElection() bool {
+	return false
+}
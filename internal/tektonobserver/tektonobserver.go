@@ -4,9 +4,32 @@ const (
 	ObservationCrdName                = "tekton-observer"
 	GroupName                         = "observer.tkn.dev"
 	PipelineProcessingStateAnnotation = GroupName + "/processing-state"
-	ProcessingState                   = "processing"
-	ProcessingCompleteState           = "complete"
-	ProcessingStartState              = "started"
+	// PipelineProcessingStateLabel mirrors the processing state annotation so the cache can filter on it
+	PipelineProcessingStateLabel = GroupName + "/processing-state"
+	ProcessingState              = "processing"
+	ProcessingCompleteState      = "complete"
+	ProcessingStartState         = "started"
+	// DeliveredPhasesAnnotation lists the phases of the PipelineRun already delivered to the sinks
+	DeliveredPhasesAnnotation = GroupName + "/delivered-phases"
+	// SinkRefsAnnotation holds the references of the messages sent to the sinks, so they can be updated in place
+	SinkRefsAnnotation = GroupName + "/sink-refs"
+	// DeliveryAttemptsAnnotation counts the failed attempts of the pending deliveries by delivery ID, the dead-lettered
+	// deliveries are counted as -1
+	DeliveryAttemptsAnnotation = GroupName + "/delivery-attempts"
+	// BackfillAnnotation flags the PipelineRuns reported by a backfill
+	BackfillAnnotation = GroupName + "/backfill"
+	// ReplayAnnotation requests a PipelineRun to be delivered again, its value is the idempotency key of the replay
+	ReplayAnnotation = GroupName + "/replay"
+	// ReplaySinkAnnotation restricts a replay to the sink it names
+	ReplaySinkAnnotation = GroupName + "/replay-sink"
+	// ReplayedAnnotation holds the idempotency key of the last replay of the PipelineRun
+	ReplayedAnnotation = GroupName + "/replayed"
+	// Finalizer is set on the observed PipelineRuns until they are delivered, and on the TektonObservations so the
+	// finalizers of their PipelineRuns are removed with them
+	Finalizer = GroupName + "/finalizer"
+	// StateConfigMapLabel is set on the ConfigMaps holding the state of the controller, its value is the kind of
+	// state. They are the only ConfigMaps cached by the controller.
+	StateConfigMapLabel = GroupName + "/state"
 	// PipelineProcessedStartAnnotation    = GroupName + "/processed-start"
 	// PipelineProcessedCompleteAnnotation = GroupName + "/processed-complete"
 	AttributesAnnotation      = GroupName + "/attributes"
@@ -0,0 +1,48 @@
+package delivery
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+)
+
+const (
+	// IDAttribute carries the delivery ID, it is the same for every attempt to deliver a phase of a PipelineRun to a
+	// sink so consumers can deduplicate the messages
+	IDAttribute = "deliveryId"
+	// AttemptAttribute carries the attempt number of the delivery, starting at 1
+	AttemptAttribute = "deliveryAttempt"
+)
+
+// Metadata describes a single delivery of a phase of a PipelineRun to a sink
+type Metadata struct {
+	// ID is the deterministic ID of the delivery, see ID
+	ID string
+	// Attempt is the attempt number of the delivery, starting at 1
+	Attempt int
+	// OrderingKey groups the messages that must be delivered in order, it is empty when ordering is not required
+	OrderingKey string
+}
+
+// ID returns the deterministic ID of the delivery of a phase of a PipelineRun to a sink. The phase key includes the
+// task name for the task-completed phase.
+func ID(pipelineRunUID, phaseKey, sink string) string {
+	h := sha256.New()
+	for _, part := range []string{pipelineRunUID, phaseKey, sink} {
+		_, _ = h.Write([]byte(part))
+		_, _ = h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// Attributes returns the attributes carrying the delivery ID and the attempt number
+func (m Metadata) Attributes() map[string]string {
+	attributes := map[string]string{}
+	if m.ID != "" {
+		attributes[IDAttribute] = m.ID
+	}
+	if m.Attempt > 0 {
+		attributes[AttemptAttribute] = strconv.Itoa(m.Attempt)
+	}
+	return attributes
+}
@@ -0,0 +1,69 @@
+package delivery
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestID(t *testing.T) {
+	tests := []struct {
+		name  string
+		other []string
+		want  bool
+	}{
+		{
+			name:  "Test with the same delivery",
+			other: []string{"uid", "finished", "pubsub/project/topic"},
+			want:  true,
+		},
+		{
+			name:  "Test with another phase",
+			other: []string{"uid", "started", "pubsub/project/topic"},
+			want:  false,
+		},
+		{
+			name:  "Test with another sink",
+			other: []string{"uid", "finished", "pubsub/project/other"},
+			want:  false,
+		},
+		{
+			name:  "Test with ambiguous parts",
+			other: []string{"uidfinished", "", "pubsub/project/topic"},
+			want:  false,
+		},
+	}
+	id := ID("uid", "finished", "pubsub/project/topic")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ID(tt.other[0], tt.other[1], tt.other[2]) == id; got != tt.want {
+				t.Errorf("ID() equal = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMetadata_Attributes(t *testing.T) {
+	tests := []struct {
+		name     string
+		metadata Metadata
+		want     map[string]string
+	}{
+		{
+			name:     "Test with an ID and an attempt",
+			metadata: Metadata{ID: "abc", Attempt: 2, OrderingKey: "key"},
+			want:     map[string]string{IDAttribute: "abc", AttemptAttribute: "2"},
+		},
+		{
+			name:     "Test without metadata",
+			metadata: Metadata{},
+			want:     map[string]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.metadata.Attributes(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Metadata.Attributes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -46,7 +46,7 @@ func (e *EventEmitter) EmitMessage(ctx context.Context, tektonObserver *observer
 			e.logger.Info(fmt.Sprintf("Cannot create event: %s", err.Error()), "event", fmt.Sprintf("%+v", event))
 		}
 		duration := time.Since(start)
-		metrics.EmitEventRequestTimeHistogram.WithLabelValues(fmt.Sprintf("%v", metrics.GetStatusCode(err))).Observe(duration.Seconds())
+		metrics.EmitEventRequestTimeHistogram.WithLabelValues(metrics.GetStatusCode(err)).Observe(duration.Seconds())
 	}
 }
 
@@ -59,7 +59,7 @@ func (e *EventEmitter) EmitMessagePipelineRun(ctx context.Context, pipelineRun *
 			e.logger.Info(fmt.Sprintf("Cannot create event: %s", err.Error()), "event", fmt.Sprintf("%+v", event))
 		}
 		duration := time.Since(start)
-		metrics.EmitEventRequestTimeHistogram.WithLabelValues(fmt.Sprintf("%v", metrics.GetStatusCode(err))).Observe(duration.Seconds())
+		metrics.EmitEventRequestTimeHistogram.WithLabelValues(metrics.GetStatusCode(err)).Observe(duration.Seconds())
 	}
 }
 
@@ -0,0 +1,21 @@
+package gcp
+
+import (
+	"context"
+	"fmt"
+
+	"cloud.google.com/go/pubsub"
+	"golang.org/x/oauth2/google"
+)
+
+// CheckPubSubCredentials ensures the application default credentials can be found and produce a token for Pub/Sub
+func CheckPubSubCredentials(ctx context.Context) error {
+	creds, err := google.FindDefaultCredentials(ctx, pubsub.ScopePubSub)
+	if err != nil {
+		return fmt.Errorf("failed to find the google application default credentials - %w", err)
+	}
+	if _, err := creds.TokenSource.Token(); err != nil {
+		return fmt.Errorf("failed to get a token from the google application default credentials - %w", err)
+	}
+	return nil
+}
@@ -5,19 +5,34 @@ import (
 	"fmt"
 
 	"cloud.google.com/go/pubsub"
+	"github.com/kcloutie/tekton-observer/pkg/delivery"
+	"github.com/kcloutie/tekton-observer/pkg/tracing"
+	"google.golang.org/api/option"
 )
 
-func PublishEvent(ctx context.Context, projectID, topicID string, data []byte, attributes map[string]string) (string, error) {
-	client, err := pubsub.NewClient(ctx, projectID)
+// PublishEvent publishes a message to the Pub/Sub topic and returns the ID of the published message. The delivery
+// ID and attempt are added to the attributes, and the ordering key is set on the message when there is one.
+func PublishEvent(ctx context.Context, projectID, topicID string, data []byte, attributes map[string]string, metadata delivery.Metadata) (string, error) {
+	client, err := pubsub.NewClient(ctx, projectID, option.WithGRPCDialOption(tracing.GRPCDialOption()))
 	if err != nil {
 		return "", fmt.Errorf("failed to create the pub/sub client - %w", err)
 	}
 	defer client.Close()
 
+	messageAttributes := map[string]string{}
+	for k, v := range attributes {
+		messageAttributes[k] = v
+	}
+	for k, v := range metadata.Attributes() {
+		messageAttributes[k] = v
+	}
+
 	t := client.Topic(topicID)
+	t.EnableMessageOrdering = metadata.OrderingKey != ""
 	result := t.Publish(ctx, &pubsub.Message{
-		Data:       data,
-		Attributes: attributes,
+		Data:        data,
+		Attributes:  messageAttributes,
+		OrderingKey: metadata.OrderingKey,
 	})
 	id, err := result.Get(ctx)
 	if err != nil {
@@ -0,0 +1,207 @@
+package kafka
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"github.com/kcloutie/tekton-observer/pkg/metrics"
+	kafkago "github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+)
+
+// Config is the connection of the producer to the brokers of a topic
+type Config struct {
+	// ID identifies the sink producing with the config, its writer is replaced when the rest of the config changes
+	ID          string
+	Brokers     []string
+	Topic       string
+	Compression obsv1.KafkaCompression
+	// SASL authenticates the producer, it is not authenticated when it is nil
+	SASL sasl.Mechanism
+	// TLS encrypts the connections, they are not encrypted when it is nil
+	TLS *tls.Config
+	// CredentialsVersion changes when the SASL or TLS credentials change
+	CredentialsVersion string
+}
+
+// fingerprint identifies the connection of the config, a writer is reused while its fingerprint is unchanged
+func (c Config) fingerprint() string {
+	mechanism := ""
+	if c.SASL != nil {
+		mechanism = c.SASL.Name()
+	}
+	insecure := c.TLS != nil && c.TLS.InsecureSkipVerify
+	return fmt.Sprintf("%s|%s|%s|%s|%t|%t|%s", strings.Join(c.Brokers, ","), c.Topic, c.Compression, mechanism, c.TLS != nil, insecure, c.CredentialsVersion)
+}
+
+// defaultProducer is the producer of Produce
+var defaultProducer = &Producer{}
+
+// Produce writes the message to the topic with the writers shared by the controller, see Producer.Produce
+func Produce(ctx context.Context, config Config, message kafkago.Message) error {
+	return defaultProducer.Produce(ctx, config, message)
+}
+
+// Producer keeps a writer and its connections per sink. The writer of a sink is closed and replaced when its config
+// changes, so the connection pools and their metadata refreshes are not left behind.
+type Producer struct {
+	mu      sync.Mutex
+	writers map[string]*writer
+}
+
+// writer is the writer of a sink and the fingerprint of the config it was created with
+type writer struct {
+	fingerprint string
+	writer      *kafkago.Writer
+}
+
+// Produce writes the message to the topic and waits until every in-sync replica acknowledged it. The messages are
+// partitioned by key like the Java producers do, and written once, the failed deliveries are retried by the
+// controller.
+func (p *Producer) Produce(ctx context.Context, config Config, message kafkago.Message) error {
+	w, err := p.writer(config)
+	if err != nil {
+		return err
+	}
+	err = w.WriteMessages(ctx, message)
+	var writeErrs kafkago.WriteErrors
+	if errors.As(err, &writeErrs) && len(writeErrs) == 1 {
+		err = writeErrs[0]
+	}
+	return err
+}
+
+// writer returns the writer of the sink of the config, it is created when the sink has none or its config changed
+func (p *Producer) writer(config Config) (*kafkago.Writer, error) {
+	fingerprint := config.fingerprint()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if current, found := p.writers[config.ID]; found {
+		if current.fingerprint == fingerprint {
+			return current.writer, nil
+		}
+		closeWriter(current.writer)
+		delete(p.writers, config.ID)
+	}
+
+	compression, err := codec(config.Compression)
+	if err != nil {
+		return nil, err
+	}
+	w := &kafkago.Writer{
+		Addr:         kafkago.TCP(config.Brokers...),
+		Topic:        config.Topic,
+		Balancer:     &kafkago.Murmur2Balancer{},
+		RequiredAcks: kafkago.RequireAll,
+		Compression:  compression,
+		MaxAttempts:  1,
+		BatchSize:    1,
+		Transport: &kafkago.Transport{
+			SASL: config.SASL,
+			TLS:  config.TLS,
+		},
+	}
+	if p.writers == nil {
+		p.writers = map[string]*writer{}
+	}
+	p.writers[config.ID] = &writer{fingerprint: fingerprint, writer: w}
+	return w, nil
+}
+
+// Close closes the writers and their connections
+func (p *Producer) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for id, current := range p.writers {
+		closeWriter(current.writer)
+		delete(p.writers, id)
+	}
+}
+
+// closeWriter closes the writer and its transport, Writer.Close only closes the transports it created
+func closeWriter(w *kafkago.Writer) {
+	_ = w.Close()
+	if transport, ok := w.Transport.(*kafkago.Transport); ok {
+		transport.CloseIdleConnections()
+	}
+}
+
+// codec returns the compression codec of the writer
+func codec(compression obsv1.KafkaCompression) (kafkago.Compression, error) {
+	switch compression {
+	case "", obsv1.KafkaCompressionNone:
+		return 0, nil
+	case obsv1.KafkaCompressionGzip:
+		return kafkago.Gzip, nil
+	case obsv1.KafkaCompressionSnappy:
+		return kafkago.Snappy, nil
+	case obsv1.KafkaCompressionLz4:
+		return kafkago.Lz4, nil
+	case obsv1.KafkaCompressionZstd:
+		return kafkago.Zstd, nil
+	}
+	return 0, fmt.Errorf("unsupported kafka compression '%s'", compression)
+}
+
+// SASLMechanism returns the SASL mechanism authenticating the producer with the username and password
+func SASLMechanism(mechanism obsv1.KafkaSASLMechanism, username, password string) (sasl.Mechanism, error) {
+	switch mechanism {
+	case obsv1.KafkaSASLPlain:
+		return plain.Mechanism{Username: username, Password: password}, nil
+	case obsv1.KafkaSASLScramSHA256:
+		m, err := scram.Mechanism(scram.SHA256, username, password)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the SCRAM-SHA-256 mechanism - %w", err)
+		}
+		return m, nil
+	case obsv1.KafkaSASLScramSHA512:
+		m, err := scram.Mechanism(scram.SHA512, username, password)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the SCRAM-SHA-512 mechanism - %w", err)
+		}
+		return m, nil
+	}
+	return nil, fmt.Errorf("unsupported SASL mechanism '%s'", mechanism)
+}
+
+// TLSConfig returns the TLS configuration of the connections, the system CAs are trusted when caCert is empty and
+// the producer does not present a client certificate when cert or key is empty
+func TLSConfig(caCert, cert, key []byte, insecureSkipVerify bool) (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: insecureSkipVerify,
+	}
+	if len(caCert) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCert) {
+			return nil, errors.New("failed to parse the CA certificate of the brokers")
+		}
+		config.RootCAs = pool
+	}
+	if len(cert) > 0 && len(key) > 0 {
+		certificate, err := tls.X509KeyPair(cert, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the client certificate - %w", err)
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+	return config, nil
+}
+
+// StatusCode classifies the outcome of a produce request for the status_code label of the request histogram, the
+// errors of the brokers are labelled with their name, such as NotLeaderForPartition
+func StatusCode(err error) string {
+	var kafkaErr kafkago.Error
+	if errors.As(err, &kafkaErr) {
+		return strings.ReplaceAll(kafkaErr.Title(), " ", "")
+	}
+	return metrics.GetStatusCode(err)
+}
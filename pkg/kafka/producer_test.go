@@ -0,0 +1,203 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+func TestSASLMechanism(t *testing.T) {
+	tests := []struct {
+		name      string
+		mechanism obsv1.KafkaSASLMechanism
+		want      string
+		wantErr   bool
+	}{
+		{
+			name:      "Test with the plain mechanism",
+			mechanism: obsv1.KafkaSASLPlain,
+			want:      "PLAIN",
+		},
+		{
+			name:      "Test with the SCRAM-SHA-256 mechanism",
+			mechanism: obsv1.KafkaSASLScramSHA256,
+			want:      "SCRAM-SHA-256",
+		},
+		{
+			name:      "Test with the SCRAM-SHA-512 mechanism",
+			mechanism: obsv1.KafkaSASLScramSHA512,
+			want:      "SCRAM-SHA-512",
+		},
+		{
+			name:      "Test with an unsupported mechanism",
+			mechanism: "gssapi",
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SASLMechanism(tt.mechanism, "observer", "secret")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SASLMechanism() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got.Name() != tt.want {
+				t.Errorf("SASLMechanism() = %v, want %v", got.Name(), tt.want)
+			}
+		})
+	}
+}
+
+func TestTLSConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		caCert  []byte
+		wantErr bool
+	}{
+		{
+			name: "Test with the system CAs",
+		},
+		{
+			name:    "Test with an invalid CA certificate",
+			caCert:  []byte("not a certificate"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := TLSConfig(tt.caCert, nil, nil, false)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("TLSConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (got.RootCAs != nil || len(got.Certificates) != 0) {
+				t.Errorf("TLSConfig() = %+v, want the system CAs without client certificate", got)
+			}
+		})
+	}
+}
+
+func TestStatusCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{
+			name: "Test without error",
+			want: "200",
+		},
+		{
+			name: "Test with a broker error",
+			err:  fmt.Errorf("failed to produce - %w", kafkago.NotLeaderForPartition),
+			want: "NotLeaderForPartition",
+		},
+		{
+			name: "Test with a deadline",
+			err:  context.DeadlineExceeded,
+			want: "DeadlineExceeded",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := StatusCode(tt.err); got != tt.want {
+				t.Errorf("StatusCode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProduce(t *testing.T) {
+	tests := []struct {
+		name        string
+		compression obsv1.KafkaCompression
+		wantErr     string
+	}{
+		{
+			name:        "Test with an unsupported compression",
+			compression: "brotli",
+			wantErr:     "unsupported kafka compression 'brotli'",
+		},
+		{
+			name:        "Test with unreachable brokers",
+			compression: obsv1.KafkaCompressionGzip,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			producer := &Producer{}
+			defer producer.Close()
+			config := Config{ID: "test-namespace/kafka", Brokers: []string{"127.0.0.1:1"}, Topic: "pipelineruns", Compression: tt.compression}
+			err := producer.Produce(ctx, config, kafkago.Message{Value: []byte("{}")})
+			if err == nil {
+				t.Fatal("Produce() error = nil, want an error")
+			}
+			if tt.wantErr != "" && err.Error() != tt.wantErr {
+				t.Errorf("Produce() error = %v, want %v", err, tt.wantErr)
+			}
+			var writeErrs kafkago.WriteErrors
+			if errors.As(err, &writeErrs) {
+				t.Errorf("Produce() error = %v, want the error of the message", err)
+			}
+		})
+	}
+}
+
+func TestProducer_writer(t *testing.T) {
+	config := Config{ID: "test-namespace/kafka", Brokers: []string{"127.0.0.1:1"}, Topic: "pipelineruns", CredentialsVersion: "1"}
+	tests := []struct {
+		name        string
+		next        Config
+		wantReused  bool
+		wantClosed  bool
+		wantWriters int
+	}{
+		{
+			name:        "Test with the same config",
+			next:        config,
+			wantReused:  true,
+			wantWriters: 1,
+		},
+		{
+			name:        "Test with new credentials",
+			next:        Config{ID: config.ID, Brokers: config.Brokers, Topic: config.Topic, CredentialsVersion: "2"},
+			wantClosed:  true,
+			wantWriters: 1,
+		},
+		{
+			name:        "Test with another sink",
+			next:        Config{ID: "other-namespace/kafka", Brokers: config.Brokers, Topic: config.Topic, CredentialsVersion: "1"},
+			wantWriters: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			producer := &Producer{}
+			defer producer.Close()
+			first, err := producer.writer(config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			next, err := producer.writer(tt.next)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (first == next) != tt.wantReused {
+				t.Errorf("writer() reused the writer = %v, want %v", first == next, tt.wantReused)
+			}
+			if len(producer.writers) != tt.wantWriters {
+				t.Errorf("writer() kept %d writers, want %d", len(producer.writers), tt.wantWriters)
+			}
+			err = first.WriteMessages(context.Background(), kafkago.Message{Value: []byte("{}")})
+			if closed := errors.Is(err, io.ErrClosedPipe); closed != tt.wantClosed {
+				t.Errorf("writer() closed the previous writer = %v, want %v", closed, tt.wantClosed)
+			}
+		})
+	}
+}
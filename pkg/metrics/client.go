@@ -0,0 +1,114 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// InstrumentedClient measures the writes of a client in KubernetesRequestTimeHistogram. Its reads are served by the
+// informer cache and are not requests to the API server.
+type InstrumentedClient struct {
+	client.Client
+}
+
+var _ client.Client = &InstrumentedClient{}
+
+// InstrumentClient measures the writes of the client
+func InstrumentClient(c client.Client) client.Client {
+	return &InstrumentedClient{Client: c}
+}
+
+// InstrumentReader measures the reads of a reader reading from the API server
+func InstrumentReader(reader client.Reader, scheme *runtime.Scheme) client.Reader {
+	return &instrumentedReader{Reader: reader, scheme: scheme}
+}
+
+func (c *InstrumentedClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	return observeKubernetesRequest(route(c.Scheme(), obj, ""), http.MethodPost, func() error { return c.Client.Create(ctx, obj, opts...) })
+}
+
+func (c *InstrumentedClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	return observeKubernetesRequest(route(c.Scheme(), obj, ""), http.MethodDelete, func() error { return c.Client.Delete(ctx, obj, opts...) })
+}
+
+func (c *InstrumentedClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	return observeKubernetesRequest(route(c.Scheme(), obj, ""), http.MethodPut, func() error { return c.Client.Update(ctx, obj, opts...) })
+}
+
+func (c *InstrumentedClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	return observeKubernetesRequest(route(c.Scheme(), obj, ""), http.MethodPatch, func() error { return c.Client.Patch(ctx, obj, patch, opts...) })
+}
+
+func (c *InstrumentedClient) DeleteAllOf(ctx context.Context, obj client.Object, opts ...client.DeleteAllOfOption) error {
+	return observeKubernetesRequest(route(c.Scheme(), obj, ""), http.MethodDelete, func() error { return c.Client.DeleteAllOf(ctx, obj, opts...) })
+}
+
+func (c *InstrumentedClient) Status() client.SubResourceWriter {
+	return c.SubResource("status")
+}
+
+func (c *InstrumentedClient) SubResource(subResource string) client.SubResourceClient {
+	return &instrumentedSubResourceClient{SubResourceClient: c.Client.SubResource(subResource), scheme: c.Scheme(), subResource: subResource}
+}
+
+type instrumentedSubResourceClient struct {
+	client.SubResourceClient
+	scheme      *runtime.Scheme
+	subResource string
+}
+
+func (c *instrumentedSubResourceClient) Get(ctx context.Context, obj client.Object, subResource client.Object, opts ...client.SubResourceGetOption) error {
+	return observeKubernetesRequest(route(c.scheme, obj, c.subResource), http.MethodGet, func() error { return c.SubResourceClient.Get(ctx, obj, subResource, opts...) })
+}
+
+func (c *instrumentedSubResourceClient) Create(ctx context.Context, obj client.Object, subResource client.Object, opts ...client.SubResourceCreateOption) error {
+	return observeKubernetesRequest(route(c.scheme, obj, c.subResource), http.MethodPost, func() error { return c.SubResourceClient.Create(ctx, obj, subResource, opts...) })
+}
+
+func (c *instrumentedSubResourceClient) Update(ctx context.Context, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+	return observeKubernetesRequest(route(c.scheme, obj, c.subResource), http.MethodPut, func() error { return c.SubResourceClient.Update(ctx, obj, opts...) })
+}
+
+func (c *instrumentedSubResourceClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+	return observeKubernetesRequest(route(c.scheme, obj, c.subResource), http.MethodPatch, func() error { return c.SubResourceClient.Patch(ctx, obj, patch, opts...) })
+}
+
+type instrumentedReader struct {
+	client.Reader
+	scheme *runtime.Scheme
+}
+
+func (r *instrumentedReader) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+	return observeKubernetesRequest(route(r.scheme, obj, ""), http.MethodGet, func() error { return r.Reader.Get(ctx, key, obj, opts...) })
+}
+
+func (r *instrumentedReader) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) error {
+	return observeKubernetesRequest(route(r.scheme, list, ""), http.MethodGet, func() error { return r.Reader.List(ctx, list, opts...) })
+}
+
+func observeKubernetesRequest(route, method string, request func() error) error {
+	start := time.Now()
+	err := request()
+	KubernetesRequestTimeHistogram.WithLabelValues(route, method, GetStatusCode(err)).Observe(time.Since(start).Seconds())
+	return err
+}
+
+// route returns the resource of the request, such as tekton.dev/v1/PipelineRun or v1/ConfigMap/status. The lists are
+// routed to the resource of their items.
+func route(scheme *runtime.Scheme, obj runtime.Object, subResource string) string {
+	gvk, err := apiutil.GVKForObject(obj, scheme)
+	if err != nil {
+		return "unknown"
+	}
+	resource := gvk.GroupVersion().String() + "/" + strings.TrimSuffix(gvk.Kind, "List")
+	if subResource != "" {
+		resource += "/" + subResource
+	}
+	return resource
+}
@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestInstrumentClient(t *testing.T) {
+	configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "default"}}
+	tests := []struct {
+		name    string
+		request func(ctx context.Context, c client.Client, r client.Reader) error
+		route   string
+		method  string
+		status  string
+	}{
+		{
+			name: "Test with a create",
+			request: func(ctx context.Context, c client.Client, r client.Reader) error {
+				return c.Create(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "default"}})
+			},
+			route:  "v1/ConfigMap",
+			method: "POST",
+			status: "200",
+		},
+		{
+			name: "Test with a create of an existing object",
+			request: func(ctx context.Context, c client.Client, r client.Reader) error {
+				return c.Create(ctx, configMap.DeepCopy())
+			},
+			route:  "v1/ConfigMap",
+			method: "POST",
+			status: "409",
+		},
+		{
+			name: "Test with a status update",
+			request: func(ctx context.Context, c client.Client, r client.Reader) error {
+				return c.Status().Update(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "missing", Namespace: "default"}})
+			},
+			route:  "v1/ConfigMap/status",
+			method: "PUT",
+			status: "404",
+		},
+		{
+			name: "Test with a list from the reader",
+			request: func(ctx context.Context, c client.Client, r client.Reader) error {
+				return r.List(ctx, &corev1.ConfigMapList{})
+			},
+			route:  "v1/ConfigMap",
+			method: "GET",
+			status: "200",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ConfigureBuckets(nil)
+			fakeClient := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(configMap.DeepCopy()).Build()
+			c := InstrumentClient(fakeClient)
+			r := InstrumentReader(fakeClient, scheme.Scheme)
+
+			_ = tt.request(context.Background(), c, r)
+			registry := prometheus.NewRegistry()
+			registry.MustRegister(KubernetesRequestTimeHistogram)
+			families, err := registry.Gather()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(families) != 1 || len(families[0].Metric) != 1 {
+				t.Fatalf("Gather() = %v, want a single series", families)
+			}
+			got := map[string]string{}
+			for _, label := range families[0].Metric[0].Label {
+				got[label.GetName()] = label.GetValue()
+			}
+			want := map[string]string{"route": tt.route, "method": tt.method, "status_code": tt.status}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("recorded the labels %v, want %v", got, want)
+			}
+		})
+	}
+}
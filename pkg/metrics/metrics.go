@@ -1,61 +1,238 @@
 package metrics
 
 import (
+	"context"
+	"errors"
+	"strconv"
+
 	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/metrics"
 )
 
+// DefaultRequestBuckets are the buckets of the histograms whose buckets are not configured, in seconds
+var DefaultRequestBuckets = []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60}
+
 var (
-	requestTimesBuckets = []float64{.5, 1, 2.5, 5, 10}
-
-	SendEmailRequestTimeHistogram = prometheus.NewHistogramVec(prometheus.HistogramOpts{
-		Name:    "tknobs_send_email_request_duration_seconds",
-		Help:    "Histogram of send email request time in seconds",
-		Buckets: requestTimesBuckets,
-	}, []string{"success"})
-
-	EmitEventRequestTimeHistogram = prometheus.NewHistogramVec(prometheus.HistogramOpts{
-		Name:    "tknobs_emit_event_request_duration_seconds",
-		Help:    "Histogram of emit event request time in seconds",
-		Buckets: requestTimesBuckets,
-	}, []string{"success"})
-
-	GithubRequestTimeHistogram = prometheus.NewHistogramVec(prometheus.HistogramOpts{
-		Name:    "tknobs_github_request_duration_seconds",
-		Help:    "Histogram of github request time in seconds",
-		Buckets: requestTimesBuckets,
-	}, []string{"route", "method", "status_code"})
-
-	GoogleRequestTimeHistogram = prometheus.NewHistogramVec(prometheus.HistogramOpts{
-		Name:    "tknobs_google_request_duration_seconds",
-		Help:    "Histogram of google API request time in seconds",
-		Buckets: requestTimesBuckets,
-	}, []string{"route", "method", "success"})
-
-	KubernetesRequestTimeHistogram = prometheus.NewHistogramVec(prometheus.HistogramOpts{
-		Name:    "tknobs_kubernetes_request_duration_seconds",
-		Help:    "Histogram of kubernetes API request time in seconds",
-		Buckets: requestTimesBuckets,
-	}, []string{"route", "method", "status_code"})
-
-	WebexRequestTimeHistogram = prometheus.NewHistogramVec(prometheus.HistogramOpts{
-		Name:    "tknobs_webex_request_duration_seconds",
-		Help:    "Histogram of webex API request time in seconds",
-		Buckets: requestTimesBuckets,
-	}, []string{"route", "method", "status_code"})
-
-	ProcessPipelineTimeHistogram = prometheus.NewHistogramVec(prometheus.HistogramOpts{
-		Name:    "tknobs_process_pipeline_duration_seconds",
-		Help:    "Histogram of the time it takes to process a pipeline in seconds",
-		Buckets: requestTimesBuckets,
-	}, []string{"executeStatus"})
+	SendEmailRequestTimeHistogram  *prometheus.HistogramVec
+	EmitEventRequestTimeHistogram  *prometheus.HistogramVec
+	GithubRequestTimeHistogram     *prometheus.HistogramVec
+	GoogleRequestTimeHistogram     *prometheus.HistogramVec
+	KubernetesRequestTimeHistogram *prometheus.HistogramVec
+	WebexRequestTimeHistogram      *prometheus.HistogramVec
+	OTLPRequestTimeHistogram       *prometheus.HistogramVec
+	KafkaRequestTimeHistogram      *prometheus.HistogramVec
+	NATSRequestTimeHistogram       *prometheus.HistogramVec
+	ProcessPipelineTimeHistogram   *prometheus.HistogramVec
+)
+
+// histogram is a histogram whose buckets are set by the controller configuration
+type histogram struct {
+	vec    **prometheus.HistogramVec
+	opts   prometheus.HistogramOpts
+	labels []string
+}
+
+var histograms = []histogram{
+	{&SendEmailRequestTimeHistogram, prometheus.HistogramOpts{
+		Name: "tknobs_send_email_request_duration_seconds",
+		Help: "Histogram of send email request time in seconds",
+	}, []string{"status_code"}},
+	{&EmitEventRequestTimeHistogram, prometheus.HistogramOpts{
+		Name: "tknobs_emit_event_request_duration_seconds",
+		Help: "Histogram of emit event request time in seconds",
+	}, []string{"status_code"}},
+	{&GithubRequestTimeHistogram, prometheus.HistogramOpts{
+		Name: "tknobs_github_request_duration_seconds",
+		Help: "Histogram of github request time in seconds",
+	}, []string{"route", "method", "status_code"}},
+	{&GoogleRequestTimeHistogram, prometheus.HistogramOpts{
+		Name: "tknobs_google_request_duration_seconds",
+		Help: "Histogram of google API request time in seconds",
+	}, []string{"route", "method", "status_code"}},
+	{&KubernetesRequestTimeHistogram, prometheus.HistogramOpts{
+		Name: "tknobs_kubernetes_request_duration_seconds",
+		Help: "Histogram of kubernetes API request time in seconds",
+	}, []string{"route", "method", "status_code"}},
+	{&WebexRequestTimeHistogram, prometheus.HistogramOpts{
+		Name: "tknobs_webex_request_duration_seconds",
+		Help: "Histogram of webex API request time in seconds",
+	}, []string{"route", "method", "status_code"}},
+	{&OTLPRequestTimeHistogram, prometheus.HistogramOpts{
+		Name: "tknobs_otlp_request_duration_seconds",
+		Help: "Histogram of OTLP export request time in seconds",
+	}, []string{"route", "method", "status_code"}},
+	{&KafkaRequestTimeHistogram, prometheus.HistogramOpts{
+		Name: "tknobs_kafka_request_duration_seconds",
+		Help: "Histogram of kafka produce request time in seconds",
+	}, []string{"route", "method", "status_code"}},
+	{&NATSRequestTimeHistogram, prometheus.HistogramOpts{
+		Name: "tknobs_nats_request_duration_seconds",
+		Help: "Histogram of NATS JetStream publish request time in seconds",
+	}, []string{"route", "method", "status_code"}},
+	{&ProcessPipelineTimeHistogram, prometheus.HistogramOpts{
+		Name: "tknobs_process_pipeline_duration_seconds",
+		Help: "Histogram of the time it takes to process a pipeline in seconds",
+	}, []string{"executeStatus"}},
+}
+
+func init() {
+	ConfigureBuckets(nil)
+}
+
+// HistogramNames returns the names of the histograms whose buckets can be configured
+func HistogramNames() []string {
+	names := []string{}
+	for _, h := range histograms {
+		names = append(names, h.opts.Name)
+	}
+	return names
+}
 
+// ConfigureBuckets creates the histograms with the buckets configured by histogram name, the other histograms use
+// DefaultRequestBuckets. The buckets of a histogram cannot change once it is registered, so it is called before
+// InitMetrics.
+func ConfigureBuckets(buckets map[string][]float64) {
+	for _, h := range histograms {
+		opts := h.opts
+		opts.Buckets = DefaultRequestBuckets
+		if configured := buckets[opts.Name]; len(configured) > 0 {
+			opts.Buckets = configured
+		}
+		*h.vec = prometheus.NewHistogramVec(opts, h.labels)
+	}
+}
+
+var (
 	PipelineRunsProcessedTotal = prometheus.NewCounter(
 		prometheus.CounterOpts{
 			Name: "tknobs_processed_pipeline_runs_total",
 			Help: "Number of pipeline runs processed",
 		},
 	)
+	OutboxEnqueuedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "tknobs_outbox_enqueued_total",
+			Help: "Number of failed deliveries added to the outbox",
+		}, []string{"sink"},
+	)
+	OutboxAttemptsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "tknobs_outbox_attempts_total",
+			Help: "Number of attempts of the deliveries of the outbox",
+		}, []string{"sink", "success"},
+	)
+	DeliveriesDeadLetteredTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "tknobs_deliveries_dead_lettered_total",
+			Help: "Number of deliveries that exhausted their retries and were dead-lettered",
+		}, []string{"sink"},
+	)
+	DeadLetterFailedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "tknobs_dead_letter_failed_total",
+			Help: "Number of times a dead-lettered delivery could not be sent to a dead-letter destination",
+		}, []string{"destination"},
+	)
+	OutboxDeliveries = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "tknobs_outbox_deliveries",
+			Help: "Number of deliveries in the outbox by state",
+		}, []string{"state"},
+	)
+	SinkRateLimitTokens = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "tknobs_sink_rate_limit_tokens",
+			Help: "Number of tokens left in the rate limiter of the sinks of a type",
+		}, []string{"namespace", "sink_type"},
+	)
+	SinkThrottledTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "tknobs_sink_throttled_total",
+			Help: "Number of deliveries failed by the rate limiter of the sinks of a type",
+		}, []string{"namespace", "sink_type"},
+	)
+	SinkCircuitBreakerState = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "tknobs_sink_circuit_breaker_state",
+			Help: "State of the circuit breaker of a sink, 0 is closed, 1 is open and 2 is half-open",
+		}, []string{"namespace", "sink"},
+	)
+	SinkCircuitBreakerFailures = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "tknobs_sink_circuit_breaker_consecutive_failures",
+			Help: "Number of consecutive failed deliveries to a sink",
+		}, []string{"namespace", "sink"},
+	)
+	DigestBufferedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "tknobs_digest_buffered_total",
+			Help: "Number of finished pipeline runs buffered in a digest",
+		}, []string{"sink"},
+	)
+	DigestsSentTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "tknobs_digests_sent_total",
+			Help: "Number of digests delivered to a sink",
+		}, []string{"sink", "success"},
+	)
+	PipelineConsecutiveFailures = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "tknobs_pipeline_consecutive_failures",
+			Help: "Number of failed pipeline runs in a row of a pipeline on a branch",
+		}, []string{"namespace", "pipeline", "branch"},
+	)
+	TaskFlakinessRatio = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "tknobs_task_flakiness_ratio",
+			Help: "Share of the last runs of a task that failed and then passed on retry or re-run",
+		}, []string{"namespace", "pipeline", "task"},
+	)
+	DoraDeploymentsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "tknobs_dora_deployments_total",
+			Help: "Number of finished deployments of a repository to an environment",
+		}, []string{"namespace", "repository", "environment", "status"},
+	)
+	DoraDeploymentFrequency = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "tknobs_dora_deployment_frequency_per_day",
+			Help: "Average number of deployments per day of a repository to an environment over the DORA window",
+		}, []string{"namespace", "repository", "environment"},
+	)
+	DoraLeadTime = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "tknobs_dora_lead_time_seconds",
+			Help: "Median time from the commit to its successful deployment over the DORA window",
+		}, []string{"namespace", "repository", "environment"},
+	)
+	DoraChangeFailureRate = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "tknobs_dora_change_failure_rate",
+			Help: "Share of the deployments that failed over the DORA window",
+		}, []string{"namespace", "repository", "environment"},
+	)
+	DoraTimeToRestore = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "tknobs_dora_time_to_restore_seconds",
+			Help: "Median time from a failed deployment to the next successful deployment over the DORA window",
+		}, []string{"namespace", "repository", "environment"},
+	)
+	DoraSummariesSentTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "tknobs_dora_summaries_sent_total",
+			Help: "Number of DORA summaries delivered to a sink",
+		}, []string{"sink", "success"},
+	)
+	FinalizerReleaseDeadlineExceededTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "tknobs_finalizer_release_deadline_exceeded_total",
+			Help: "Number of pipeline runs whose finalizer was released before they could be delivered",
+		},
+	)
 	PipelineRunsStartedProcessingTotal = prometheus.NewCounter(
 		prometheus.CounterOpts{
 			Name: "tknobs_started_processing_pipeline_runs_total",
@@ -259,6 +436,69 @@ var (
 		},
 	)
 
+	OTLPTracesExportedTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "tknobs_otlp_traces_exported_total",
+			Help: "Number of pipeline runs exported as traces",
+		},
+	)
+
+	OTLPExportFailedTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "tknobs_otlp_export_failed_total",
+			Help: "Number of pipeline runs that failed to be exported as traces",
+		},
+	)
+
+	KafkaMessagesSentTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "tknobs_kafka_messages_sent_total",
+			Help: "Number of kafka messages produced",
+		},
+	)
+
+	KafkaMessagesFailedTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "tknobs_kafka_messages_failed_total",
+			Help: "Number of kafka messages that failed to be produced",
+		},
+	)
+
+	KafkaMessageBytesTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "tknobs_kafka_message_bytes_total",
+			Help: "Number of bytes of the payloads of the kafka messages produced",
+		},
+	)
+
+	NATSMessagesPublishedTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "tknobs_nats_messages_published_total",
+			Help: "Number of NATS messages acknowledged by a JetStream stream",
+		},
+	)
+
+	NATSMessagesDuplicateTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "tknobs_nats_messages_duplicate_total",
+			Help: "Number of NATS messages dropped by a JetStream stream as duplicates",
+		},
+	)
+
+	NATSMessagesFailedTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "tknobs_nats_messages_failed_total",
+			Help: "Number of NATS messages that failed to be published",
+		},
+	)
+
+	LogRecordsWrittenTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "tknobs_log_records_written_total",
+			Help: "Number of records written by the log sinks",
+		},
+	)
+
 	PubSubGlobalSentTotal = prometheus.NewCounter(
 		prometheus.CounterOpts{
 			Name: "tknobs_pubsub_global_sent_total",
@@ -286,6 +526,29 @@ func InitMetrics() {
 	metrics.Registry.MustRegister(
 		PipelineRunsProcessedTotal,
 		PipelineRunsStartedProcessingTotal,
+		FinalizerReleaseDeadlineExceededTotal,
+		OutboxEnqueuedTotal,
+		OutboxAttemptsTotal,
+		DeliveriesDeadLetteredTotal,
+		DeadLetterFailedTotal,
+		OutboxDeliveries,
+		SinkRateLimitTokens,
+		SinkThrottledTotal,
+		SinkCircuitBreakerState,
+		SinkCircuitBreakerFailures,
+		DigestBufferedTotal,
+		DigestsSentTotal,
+		PipelineConsecutiveFailures,
+		TaskFlakinessRatio,
+		PipelineRunsTotal,
+		TaskRunsTotal,
+		RunHistograms,
+		DoraDeploymentsTotal,
+		DoraDeploymentFrequency,
+		DoraLeadTime,
+		DoraChangeFailureRate,
+		DoraTimeToRestore,
+		DoraSummariesSentTotal,
 		LogsSavedToGcsTotal,
 		LogsSavedToGcsSkippedDisabledTotal,
 		LogsSavedToGcsFailedTotal,
@@ -316,6 +579,15 @@ func InitMetrics() {
 		PubSubFailedTotal,
 		PubSubGlobalSentTotal,
 		PubSubGlobalFailedTotal,
+		OTLPTracesExportedTotal,
+		OTLPExportFailedTotal,
+		KafkaMessagesSentTotal,
+		KafkaMessagesFailedTotal,
+		KafkaMessageBytesTotal,
+		NATSMessagesPublishedTotal,
+		NATSMessagesDuplicateTotal,
+		NATSMessagesFailedTotal,
+		LogRecordsWrittenTotal,
 		SendEmailFailedTotal,
 		SendEmailRequestTimeHistogram,
 		EmitEventRequestTimeHistogram,
@@ -323,15 +595,45 @@ func InitMetrics() {
 		GoogleRequestTimeHistogram,
 		KubernetesRequestTimeHistogram,
 		WebexRequestTimeHistogram,
+		OTLPRequestTimeHistogram,
+		KafkaRequestTimeHistogram,
+		NATSRequestTimeHistogram,
 		ProcessPipelineTimeHistogram,
 	)
 
 }
 
+// GetStatusCode classifies the outcome of a request for the status_code label of the request histograms. It is 200
+// for the successful requests, the HTTP status code of the Kubernetes API errors and of the errors exposing a status
+// code, the reason of the Kubernetes API errors without code, the gRPC code name of the gRPC errors and unknown for
+// the other errors.
 func GetStatusCode(err error) string {
-
 	if err == nil {
 		return "200"
 	}
-	return "400"
+	var apiStatus apierrors.APIStatus
+	if errors.As(err, &apiStatus) {
+		status := apiStatus.Status()
+		if status.Code != 0 {
+			return strconv.Itoa(int(status.Code))
+		}
+		if status.Reason != metav1.StatusReasonUnknown {
+			return string(status.Reason)
+		}
+	}
+	var statusCoder interface{ StatusCode() int }
+	if errors.As(err, &statusCoder) {
+		return strconv.Itoa(statusCoder.StatusCode())
+	}
+	var grpcStatus interface{ GRPCStatus() *grpcstatus.Status }
+	if errors.As(err, &grpcStatus) {
+		return grpcStatus.GRPCStatus().Code().String()
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return codes.DeadlineExceeded.String()
+	}
+	if errors.Is(err, context.Canceled) {
+		return codes.Canceled.String()
+	}
+	return "unknown"
 }
@@ -0,0 +1,130 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+type statusCodeError struct {
+	code int
+}
+
+func (e statusCodeError) Error() string {
+	return fmt.Sprintf("request failed with status %d", e.code)
+}
+
+func (e statusCodeError) StatusCode() int {
+	return e.code
+}
+
+func TestGetStatusCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{
+			name: "Test with no error",
+			want: "200",
+		},
+		{
+			name: "Test with a kubernetes not found error",
+			err:  apierrors.NewNotFound(schema.GroupResource{Resource: "configmaps"}, "test"),
+			want: "404",
+		},
+		{
+			name: "Test with a wrapped kubernetes conflict error",
+			err:  fmt.Errorf("failed to update - %w", apierrors.NewConflict(schema.GroupResource{Resource: "configmaps"}, "test", errors.New("conflict"))),
+			want: "409",
+		},
+		{
+			name: "Test with a kubernetes error without code",
+			err:  &apierrors.StatusError{ErrStatus: metav1.Status{Reason: metav1.StatusReasonExpired}},
+			want: "Expired",
+		},
+		{
+			name: "Test with an error exposing a status code",
+			err:  fmt.Errorf("failed to send - %w", statusCodeError{code: 503}),
+			want: "503",
+		},
+		{
+			name: "Test with a gRPC error",
+			err:  fmt.Errorf("failed to publish - %w", grpcstatus.Error(codes.Unavailable, "unavailable")),
+			want: "Unavailable",
+		},
+		{
+			name: "Test with a deadline exceeded",
+			err:  fmt.Errorf("failed to publish - %w", context.DeadlineExceeded),
+			want: "DeadlineExceeded",
+		},
+		{
+			name: "Test with a canceled context",
+			err:  context.Canceled,
+			want: "Canceled",
+		},
+		{
+			name: "Test with another error",
+			err:  errors.New("boom"),
+			want: "unknown",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GetStatusCode(tt.err); got != tt.want {
+				t.Errorf("GetStatusCode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigureBuckets(t *testing.T) {
+	tests := []struct {
+		name        string
+		buckets     map[string][]float64
+		wantBuckets int
+	}{
+		{
+			name:        "Test with the default buckets",
+			wantBuckets: len(DefaultRequestBuckets),
+		},
+		{
+			name:        "Test with configured buckets",
+			buckets:     map[string][]float64{"tknobs_google_request_duration_seconds": {0.5, 5}},
+			wantBuckets: 2,
+		},
+		{
+			name:        "Test with the buckets of another histogram",
+			buckets:     map[string][]float64{"tknobs_webex_request_duration_seconds": {0.5, 5}},
+			wantBuckets: len(DefaultRequestBuckets),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ConfigureBuckets(tt.buckets)
+			defer ConfigureBuckets(nil)
+
+			GoogleRequestTimeHistogram.WithLabelValues("projects/test/topics/test", "Publish", "200").Observe(1)
+			registry := prometheus.NewRegistry()
+			registry.MustRegister(GoogleRequestTimeHistogram)
+			families, err := registry.Gather()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(families) != 1 || len(families[0].Metric) != 1 {
+				t.Fatalf("Gather() = %v, want a single series", families)
+			}
+			if got := len(families[0].Metric[0].Histogram.Bucket); got != tt.wantBuckets {
+				t.Errorf("Gather() buckets = %d, want %d", got, tt.wantBuckets)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package metrics
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// DefaultDurationBuckets are the buckets of the duration histograms of the PipelineRuns and TaskRuns, in seconds
+	DefaultDurationBuckets = []float64{10, 30, 60, 120, 300, 600, 1200, 1800, 3600, 7200}
+	// DefaultQueueBuckets are the buckets of the queue time histograms of the PipelineRuns and TaskRuns, in seconds
+	DefaultQueueBuckets = []float64{1, 5, 10, 30, 60, 120, 300, 600}
+
+	// RunLabelNames are the labels of the metrics of the PipelineRuns, the optional labels that are not enabled are
+	// empty
+	RunLabelNames = []string{"namespace", "pipeline", "status", "repository", "event_type", "branch"}
+	// TaskRunLabelNames are the labels of the metrics of the TaskRuns
+	TaskRunLabelNames = append(slices.Clone(RunLabelNames), "task")
+
+	PipelineRunsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "tknobs_pipelinerun_total",
+			Help: "Number of finished pipeline runs by outcome",
+		}, RunLabelNames,
+	)
+	TaskRunsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "tknobs_taskrun_total",
+			Help: "Number of finished task runs by outcome",
+		}, TaskRunLabelNames,
+	)
+
+	// RunHistograms are the duration and queue time histograms of the PipelineRuns and TaskRuns
+	RunHistograms = NewRunHistogramCollector()
+)
+
+// Buckets are the bucket layouts of the histograms of a namespace, in seconds
+type Buckets struct {
+	Duration []float64
+	Queue    []float64
+}
+
+// RunHistogramSet are the histograms of the namespaces sharing a bucket layout
+type RunHistogramSet struct {
+	PipelineRunDuration *prometheus.HistogramVec
+	PipelineRunQueue    *prometheus.HistogramVec
+	TaskRunDuration     *prometheus.HistogramVec
+	TaskRunQueue        *prometheus.HistogramVec
+}
+
+func newRunHistogramSet(buckets Buckets) *RunHistogramSet {
+	return &RunHistogramSet{
+		PipelineRunDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "tknobs_pipelinerun_duration_seconds",
+			Help:    "Histogram of the time from the start to the completion of the pipeline runs in seconds",
+			Buckets: buckets.Duration,
+		}, RunLabelNames),
+		PipelineRunQueue: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "tknobs_pipelinerun_queue_seconds",
+			Help:    "Histogram of the time from the creation to the start of the pipeline runs in seconds",
+			Buckets: buckets.Queue,
+		}, RunLabelNames),
+		TaskRunDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "tknobs_taskrun_duration_seconds",
+			Help:    "Histogram of the time from the start to the completion of the task runs in seconds",
+			Buckets: buckets.Duration,
+		}, TaskRunLabelNames),
+		TaskRunQueue: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "tknobs_taskrun_queue_seconds",
+			Help:    "Histogram of the time from the start of the task runs to the start of their first step in seconds",
+			Buckets: buckets.Queue,
+		}, TaskRunLabelNames),
+	}
+}
+
+func (s *RunHistogramSet) vecs() []*prometheus.HistogramVec {
+	return []*prometheus.HistogramVec{s.PipelineRunDuration, s.PipelineRunQueue, s.TaskRunDuration, s.TaskRunQueue}
+}
+
+// RunHistogramCollector collects the run histograms of every namespace. The buckets of a histogram are fixed when it
+// is created, so the namespaces sharing a bucket layout share a set of histograms.
+type RunHistogramCollector struct {
+	mu         sync.Mutex
+	sets       map[string]*RunHistogramSet
+	namespaces map[string]string
+}
+
+func NewRunHistogramCollector() *RunHistogramCollector {
+	return &RunHistogramCollector{
+		sets:       map[string]*RunHistogramSet{},
+		namespaces: map[string]string{},
+	}
+}
+
+// For returns the histograms of the namespace with the bucket layout. The series of the namespace recorded with
+// another layout are removed.
+func (c *RunHistogramCollector) For(namespace string, buckets Buckets) *RunHistogramSet {
+	key := fmt.Sprint(buckets.Duration, buckets.Queue)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if previous, found := c.namespaces[namespace]; found && previous != key {
+		c.deleteNamespace(c.sets[previous], namespace)
+	}
+	set, found := c.sets[key]
+	if !found {
+		set = newRunHistogramSet(buckets)
+		c.sets[key] = set
+	}
+	c.namespaces[namespace] = key
+	return set
+}
+
+// Forget removes the series of the namespace
+func (c *RunHistogramCollector) Forget(namespace string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if key, found := c.namespaces[namespace]; found {
+		c.deleteNamespace(c.sets[key], namespace)
+		delete(c.namespaces, namespace)
+	}
+}
+
+func (c *RunHistogramCollector) deleteNamespace(set *RunHistogramSet, namespace string) {
+	for _, vec := range set.vecs() {
+		vec.DeletePartialMatch(prometheus.Labels{"namespace": namespace})
+	}
+}
+
+// Describe sends no descriptor, the collector is unchecked since the sets of histograms share their names
+func (c *RunHistogramCollector) Describe(chan<- *prometheus.Desc) {}
+
+func (c *RunHistogramCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, set := range c.sets {
+		for _, vec := range set.vecs() {
+			vec.Collect(ch)
+		}
+	}
+}
+
+// ForgetNamespace removes the series of the metrics of the PipelineRuns, TaskRuns and deployments of the namespace
+func ForgetNamespace(namespace string) {
+	labels := prometheus.Labels{"namespace": namespace}
+	PipelineRunsTotal.DeletePartialMatch(labels)
+	TaskRunsTotal.DeletePartialMatch(labels)
+	RunHistograms.Forget(namespace)
+	DoraDeploymentsTotal.DeletePartialMatch(labels)
+	DoraDeploymentFrequency.DeletePartialMatch(labels)
+	DoraLeadTime.DeletePartialMatch(labels)
+	DoraChangeFailureRate.DeletePartialMatch(labels)
+	DoraTimeToRestore.DeletePartialMatch(labels)
+}
@@ -0,0 +1,62 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestRunHistogramCollector_For(t *testing.T) {
+	tests := []struct {
+		name        string
+		buckets     []Buckets
+		wantSets    int
+		wantBuckets int
+	}{
+		{
+			name:        "Test with the default buckets",
+			buckets:     []Buckets{{Duration: DefaultDurationBuckets, Queue: DefaultQueueBuckets}},
+			wantSets:    1,
+			wantBuckets: len(DefaultDurationBuckets),
+		},
+		{
+			name: "Test with changed buckets",
+			buckets: []Buckets{
+				{Duration: DefaultDurationBuckets, Queue: DefaultQueueBuckets},
+				{Duration: []float64{60, 600}, Queue: DefaultQueueBuckets},
+			},
+			wantSets:    2,
+			wantBuckets: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collector := NewRunHistogramCollector()
+			for _, buckets := range tt.buckets {
+				set := collector.For("test-namespace", buckets)
+				set.PipelineRunDuration.WithLabelValues("test-namespace", "build", "Succeeded", "", "", "").Observe(42)
+			}
+			if len(collector.sets) != tt.wantSets {
+				t.Errorf("For() created %d sets, want %d", len(collector.sets), tt.wantSets)
+			}
+
+			registry := prometheus.NewRegistry()
+			registry.MustRegister(collector)
+			families, err := registry.Gather()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(families) != 1 || len(families[0].Metric) != 1 {
+				t.Fatalf("Gather() = %v, want a single series", families)
+			}
+			if got := len(families[0].Metric[0].Histogram.Bucket); got != tt.wantBuckets {
+				t.Errorf("Gather() buckets = %d, want %d", got, tt.wantBuckets)
+			}
+
+			collector.Forget("test-namespace")
+			if families, _ := registry.Gather(); len(families) != 0 {
+				t.Errorf("Gather() after Forget() = %v, want no series", families)
+			}
+		})
+	}
+}
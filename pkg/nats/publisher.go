@@ -0,0 +1,101 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/kcloutie/tekton-observer/pkg/metrics"
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/nats-io/nkeys"
+)
+
+// DefaultTimeout is how long a publish waits for the acknowledgement of the stream when the context has no deadline
+const DefaultTimeout = 10 * time.Second
+
+// Config is the connection of the publisher to the NATS servers
+type Config struct {
+	URL string
+	// Stream is the stream expected to capture the subject, any stream is accepted when it is empty
+	Stream string
+	// Options authenticate the connection
+	Options []natsgo.Option
+}
+
+// Publish publishes the message to JetStream and waits for the acknowledgement of the stream. The stream drops the
+// messages whose ID it already stored within its duplicate window, the acknowledgement of a dropped message is
+// flagged as duplicate. The message is published once, the failed deliveries are retried by the controller.
+func Publish(ctx context.Context, config Config, message *natsgo.Msg, id string) (*natsgo.PubAck, error) {
+	if _, found := ctx.Deadline(); !found {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultTimeout)
+		defer cancel()
+	}
+	conn, err := natsgo.Connect(config.URL, append([]natsgo.Option{natsgo.Name("tekton-observer")}, config.Options...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the NATS servers - %w", err)
+	}
+	defer conn.Close()
+	js, err := conn.JetStream()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the JetStream context - %w", err)
+	}
+
+	opts := []natsgo.PubOpt{natsgo.Context(ctx), natsgo.RetryAttempts(0)}
+	if id != "" {
+		opts = append(opts, natsgo.MsgId(id))
+	}
+	if config.Stream != "" {
+		opts = append(opts, natsgo.ExpectStream(config.Stream))
+	}
+	return js.PublishMsg(message, opts...)
+}
+
+// CredentialsOptions returns the options authenticating the connection with the keys of the credentials Secret, a
+// user credentials file in creds, a token in token or a username and a password in username and password
+func CredentialsOptions(data map[string][]byte) ([]natsgo.Option, error) {
+	if creds := data["creds"]; len(creds) > 0 {
+		jwt, err := nkeys.ParseDecoratedJWT(creds)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the JWT of the user credentials - %w", err)
+		}
+		keyPair, err := nkeys.ParseDecoratedUserNKey(creds)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the seed of the user credentials - %w", err)
+		}
+		seed, err := keyPair.Seed()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the seed of the user credentials - %w", err)
+		}
+		return []natsgo.Option{natsgo.UserJWTAndSeed(jwt, string(seed))}, nil
+	}
+	if token := data["token"]; len(token) > 0 {
+		return []natsgo.Option{natsgo.Token(string(token))}, nil
+	}
+	if username := data["username"]; len(username) > 0 {
+		return []natsgo.Option{natsgo.UserInfo(string(username), string(data["password"]))}, nil
+	}
+	return nil, errors.New("the credentials secret has none of the creds, token and username keys")
+}
+
+// StatusCode classifies the outcome of a publish for the status_code label of the request histogram, the errors of
+// the JetStream API are labelled with their code, the rejected credentials with 401 and the subjects without stream
+// with NoResponders
+func StatusCode(err error) string {
+	var apiErr *natsgo.APIError
+	if errors.As(err, &apiErr) && apiErr.Code != 0 {
+		return strconv.Itoa(apiErr.Code)
+	}
+	if errors.Is(err, natsgo.ErrNoStreamResponse) || errors.Is(err, natsgo.ErrNoResponders) {
+		return "NoResponders"
+	}
+	if errors.Is(err, natsgo.ErrAuthorization) || errors.Is(err, natsgo.ErrAuthExpired) {
+		return "401"
+	}
+	if errors.Is(err, natsgo.ErrTimeout) {
+		return "DeadlineExceeded"
+	}
+	return metrics.GetStatusCode(err)
+}
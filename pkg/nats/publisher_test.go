@@ -0,0 +1,197 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	natsgo "github.com/nats-io/nats.go"
+)
+
+// startServer starts an embedded NATS server with JetStream and a TEKTON stream capturing the tekton.> subjects
+func startServer(t *testing.T, token string) string {
+	s, err := server.NewServer(&server.Options{
+		Host:          "127.0.0.1",
+		Port:          -1,
+		JetStream:     true,
+		StoreDir:      t.TempDir(),
+		Authorization: token,
+		NoLog:         true,
+		NoSigs:        true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	t.Cleanup(s.Shutdown)
+	if !s.ReadyForConnections(5 * time.Second) {
+		t.Fatal("the NATS server is not ready")
+	}
+
+	conn, err := natsgo.Connect(s.ClientURL(), natsgo.Token(token))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	js, err := conn.JetStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := js.AddStream(&natsgo.StreamConfig{Name: "TEKTON", Subjects: []string{"tekton.>"}}); err != nil {
+		t.Fatal(err)
+	}
+	return s.ClientURL()
+}
+
+func TestPublish(t *testing.T) {
+	tests := []struct {
+		name           string
+		subject        string
+		stream         string
+		credentials    map[string][]byte
+		wantStatusCode string
+	}{
+		{
+			name:        "Test with a subject captured by a stream",
+			subject:     "tekton.test-namespace.build.Succeeded",
+			credentials: map[string][]byte{"token": []byte("s3cr3t")},
+		},
+		{
+			name:        "Test with the expected stream",
+			subject:     "tekton.test-namespace.build.Succeeded",
+			stream:      "TEKTON",
+			credentials: map[string][]byte{"token": []byte("s3cr3t")},
+		},
+		{
+			name:           "Test with another expected stream",
+			subject:        "tekton.test-namespace.build.Succeeded",
+			stream:         "OTHER",
+			credentials:    map[string][]byte{"token": []byte("s3cr3t")},
+			wantStatusCode: "400",
+		},
+		{
+			name:           "Test with a subject without stream",
+			subject:        "other.test-namespace",
+			credentials:    map[string][]byte{"token": []byte("s3cr3t")},
+			wantStatusCode: "NoResponders",
+		},
+		{
+			name:           "Test with invalid credentials",
+			subject:        "tekton.test-namespace.build.Succeeded",
+			credentials:    map[string][]byte{"token": []byte("wrong")},
+			wantStatusCode: "401",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			url := startServer(t, "s3cr3t")
+			options, err := CredentialsOptions(tt.credentials)
+			if err != nil {
+				t.Fatal(err)
+			}
+			config := Config{URL: url, Stream: tt.stream, Options: options}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			ack, err := Publish(ctx, config, &natsgo.Msg{Subject: tt.subject, Data: []byte("{}")}, "delivery-id")
+			if tt.wantStatusCode != "" {
+				if got := StatusCode(err); err == nil || got != tt.wantStatusCode {
+					t.Errorf("Publish() error = %v with the status code %s, want the status code %s", err, got, tt.wantStatusCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Publish() unexpected error = %v", err)
+			}
+			if ack.Stream != "TEKTON" || ack.Sequence != 1 || ack.Duplicate {
+				t.Errorf("Publish() ack = %+v, want the first message of the TEKTON stream", ack)
+			}
+
+			again, err := Publish(ctx, config, &natsgo.Msg{Subject: tt.subject, Data: []byte("{}")}, "delivery-id")
+			if err != nil {
+				t.Fatalf("Publish() unexpected error = %v", err)
+			}
+			if !again.Duplicate || again.Sequence != 1 {
+				t.Errorf("Publish() ack = %+v, want the message published again to be a duplicate", again)
+			}
+		})
+	}
+}
+
+func TestCredentialsOptions(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        map[string][]byte
+		wantOptions int
+		wantErr     bool
+	}{
+		{
+			name:        "Test with a token",
+			data:        map[string][]byte{"token": []byte("s3cr3t")},
+			wantOptions: 1,
+		},
+		{
+			name:        "Test with a username and a password",
+			data:        map[string][]byte{"username": []byte("observer"), "password": []byte("s3cr3t")},
+			wantOptions: 1,
+		},
+		{
+			name:    "Test with invalid user credentials",
+			data:    map[string][]byte{"creds": []byte("not a credentials file")},
+			wantErr: true,
+		},
+		{
+			name:    "Test without credentials",
+			data:    map[string][]byte{"other": []byte("value")},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CredentialsOptions(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CredentialsOptions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != tt.wantOptions {
+				t.Errorf("CredentialsOptions() returned %d options, want %d", len(got), tt.wantOptions)
+			}
+		})
+	}
+}
+
+func TestStatusCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{
+			name: "Test without error",
+			want: "200",
+		},
+		{
+			name: "Test with a JetStream API error",
+			err:  &natsgo.APIError{Code: 503, ErrorCode: natsgo.JSErrCodeJetStreamNotEnabled},
+			want: "503",
+		},
+		{
+			name: "Test with no stream",
+			err:  natsgo.ErrNoStreamResponse,
+			want: "NoResponders",
+		},
+		{
+			name: "Test with a timeout",
+			err:  errors.Join(errors.New("publish failed"), natsgo.ErrTimeout),
+			want: "DeadlineExceeded",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := StatusCode(tt.err); got != tt.want {
+				t.Errorf("StatusCode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package otlp
+
+import (
+	"context"
+	"fmt"
+
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// NewSpanExporter creates the exporter of the spans to the OTLP receiver of the configuration. The exporter does not
+// retry, the failed exports are retried by the controller like the other deliveries.
+func NewSpanExporter(ctx context.Context, config obsv1.OTLPTraceExporter, headers map[string]string) (sdktrace.SpanExporter, error) {
+	switch config.Protocol {
+	case obsv1.OTLPProtocolHTTP:
+		opts := []otlptracehttp.Option{
+			otlptracehttp.WithEndpoint(config.Endpoint),
+			otlptracehttp.WithHeaders(headers),
+			otlptracehttp.WithRetry(otlptracehttp.RetryConfig{Enabled: false}),
+		}
+		if config.URLPath != "" {
+			opts = append(opts, otlptracehttp.WithURLPath(config.URLPath))
+		}
+		if config.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exporter, err := otlptracehttp.New(ctx, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the OTLP http exporter of the endpoint '%s' - %w", config.Endpoint, err)
+		}
+		return exporter, nil
+	case obsv1.OTLPProtocolGRPC, "":
+		opts := []otlptracegrpc.Option{
+			otlptracegrpc.WithEndpoint(config.Endpoint),
+			otlptracegrpc.WithHeaders(headers),
+			otlptracegrpc.WithRetry(otlptracegrpc.RetryConfig{Enabled: false}),
+		}
+		if config.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		exporter, err := otlptracegrpc.New(ctx, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the OTLP grpc exporter of the endpoint '%s' - %w", config.Endpoint, err)
+		}
+		return exporter, nil
+	}
+	return nil, fmt.Errorf("unknown OTLP protocol '%s'", config.Protocol)
+}
@@ -0,0 +1,272 @@
+package otlp
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/kcloutie/tekton-observer/pkg/tekton"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline"
+	tknv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+	"knative.dev/pkg/apis"
+)
+
+const (
+	// InstrumentationName is the name of the instrumentation scope of the spans of the PipelineRuns
+	InstrumentationName = "github.com/kcloutie/tekton-observer"
+	// DefaultServiceName is the service.name of the spans when the exporter does not set one
+	DefaultServiceName = "tekton-pipelines"
+)
+
+// TraceID returns the trace ID of a PipelineRun, it is derived from its UID so every export of the PipelineRun has
+// the same trace ID
+func TraceID(pipelineRunUID string) trace.TraceID {
+	var id trace.TraceID
+	sum := sha256.Sum256([]byte(pipelineRunUID))
+	copy(id[:], sum[:])
+	return id
+}
+
+// SpanID returns the ID of a span of a PipelineRun, it is derived from the PipelineRun UID and the parts identifying
+// the span, such as the name of the TaskRun and of the step
+func SpanID(pipelineRunUID string, parts ...string) trace.SpanID {
+	h := sha256.New()
+	for _, part := range append([]string{pipelineRunUID}, parts...) {
+		_, _ = h.Write([]byte(part))
+		_, _ = h.Write([]byte{0})
+	}
+	var id trace.SpanID
+	copy(id[:], h.Sum(nil))
+	return id
+}
+
+// Resource returns the resource of the spans of the PipelineRuns of a namespace
+func Resource(serviceName, clusterName, namespace string) *resource.Resource {
+	if serviceName == "" {
+		serviceName = DefaultServiceName
+	}
+	attributes := []attribute.KeyValue{semconv.ServiceName(serviceName), semconv.K8SNamespaceName(namespace)}
+	if clusterName != "" {
+		attributes = append(attributes, semconv.K8SClusterName(clusterName))
+	}
+	return resource.NewWithAttributes(semconv.SchemaURL, attributes...)
+}
+
+// Spans returns the spans of the PipelineRun, its TaskRuns and their steps. The spans are timed from the status of the
+// PipelineRun and of the TaskRuns, and their IDs are derived from the PipelineRun UID.
+func Spans(ctx context.Context, data *tekton.PipelineRunData, taskRuns []tknv1.TaskRun, res *resource.Resource) []sdktrace.ReadOnlySpan {
+	uid := pipelineRunUID(data)
+	recorder := &spanRecorder{}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.AlwaysSample()),
+		sdktrace.WithIDGenerator(idGenerator{traceID: TraceID(uid)}),
+		sdktrace.WithSpanProcessor(recorder),
+	)
+	tracer := provider.Tracer(InstrumentationName)
+
+	start, end := pipelineRunTimes(data)
+	ctx, span := tracer.Start(withSpanID(ctx, SpanID(uid)), data.PipelineName,
+		trace.WithNewRoot(),
+		trace.WithTimestamp(start),
+		trace.WithAttributes(pipelineRunAttributes(data)...),
+	)
+	for i := range taskRuns {
+		taskRunSpan(ctx, tracer, uid, &taskRuns[i], end)
+	}
+	setStatus(span, data.Status, data.Reason)
+	span.End(trace.WithTimestamp(end))
+
+	_ = provider.Shutdown(ctx)
+	return recorder.spans
+}
+
+func taskRunSpan(ctx context.Context, tracer trace.Tracer, uid string, taskRun *tknv1.TaskRun, pipelineRunEnd time.Time) {
+	name := taskRun.Labels[pipeline.PipelineTaskLabelKey]
+	if name == "" {
+		name = taskRun.Name
+	}
+	start := taskRun.CreationTimestamp.Time
+	if taskRun.Status.StartTime != nil {
+		start = taskRun.Status.StartTime.Time
+	}
+	end := pipelineRunEnd
+	if taskRun.Status.CompletionTime != nil {
+		end = taskRun.Status.CompletionTime.Time
+	}
+	status, reason := getTaskRunOutcome(taskRun)
+	attributes := []attribute.KeyValue{
+		attribute.String("tekton.taskrun.name", taskRun.Name),
+		attribute.String("tekton.taskrun.uid", string(taskRun.UID)),
+		attribute.String("tekton.pipeline_task", name),
+		attribute.String("tekton.status", status),
+		attribute.Int("tekton.retries", len(taskRun.Status.RetriesStatus)),
+	}
+	if reason != "" {
+		attributes = append(attributes, attribute.String("tekton.reason", reason))
+	}
+	if task := taskRun.Labels[pipeline.TaskLabelKey]; task != "" {
+		attributes = append(attributes, attribute.String("tekton.task.name", task))
+	}
+	ctx, span := tracer.Start(withSpanID(ctx, SpanID(uid, taskRun.Name)), name,
+		trace.WithTimestamp(start),
+		trace.WithAttributes(attributes...),
+	)
+	for _, step := range taskRun.Status.Steps {
+		stepSpan(ctx, tracer, uid, taskRun.Name, step, end)
+	}
+	setStatus(span, status, reason)
+	span.End(trace.WithTimestamp(end))
+}
+
+func stepSpan(ctx context.Context, tracer trace.Tracer, uid, taskRunName string, step tknv1.StepState, taskRunEnd time.Time) {
+	attributes := []attribute.KeyValue{
+		attribute.String("tekton.step.name", step.Name),
+		attribute.String("tekton.step.container", step.Container),
+	}
+	var start, end time.Time
+	status, reason := tekton.StatusRunning, ""
+	switch {
+	case step.Terminated != nil:
+		start, end = step.Terminated.StartedAt.Time, step.Terminated.FinishedAt.Time
+		status, reason = tekton.StatusSucceeded, step.Terminated.Reason
+		if step.Terminated.ExitCode != 0 {
+			status = tekton.StatusFailed
+		}
+		attributes = append(attributes, attribute.Int("tekton.step.exit_code", int(step.Terminated.ExitCode)))
+	case step.Running != nil:
+		start, end = step.Running.StartedAt.Time, taskRunEnd
+	default:
+		// The step never started, it has no span
+		return
+	}
+	if reason != "" {
+		attributes = append(attributes, attribute.String("tekton.reason", reason))
+	}
+	_, span := tracer.Start(withSpanID(ctx, SpanID(uid, taskRunName, step.Name)), step.Name,
+		trace.WithTimestamp(start),
+		trace.WithAttributes(attributes...),
+	)
+	setStatus(span, status, reason)
+	span.End(trace.WithTimestamp(end))
+}
+
+// pipelineRunAttributes returns the attributes of the root span, the params, the Pipelines-as-Code labels and the
+// outcome of the PipelineRun
+func pipelineRunAttributes(data *tekton.PipelineRunData) []attribute.KeyValue {
+	attributes := []attribute.KeyValue{
+		attribute.String("tekton.pipelinerun.name", data.PipelineRunName),
+		attribute.String("tekton.pipelinerun.uid", pipelineRunUID(data)),
+		attribute.String("tekton.pipeline.name", data.PipelineName),
+		attribute.String("tekton.status", data.Status),
+	}
+	if data.Reason != "" {
+		attributes = append(attributes, attribute.String("tekton.reason", data.Reason))
+	}
+	for name, value := range data.VariableValues {
+		attributes = append(attributes, attribute.String("tekton.param."+name, value))
+	}
+	for key, value := range data.PacLabels {
+		attributes = append(attributes, attribute.String(tekton.PacLabelPrefix+"/"+key, value))
+	}
+	return attributes
+}
+
+// pipelineRunTimes returns the start and the end of the root span. A PipelineRun deleted before it started starts
+// when it was created, and one without completion time ends now.
+func pipelineRunTimes(data *tekton.PipelineRunData) (time.Time, time.Time) {
+	start, end := time.Now(), time.Now()
+	if data.RawPipelineRun != nil {
+		start = data.RawPipelineRun.CreationTimestamp.Time
+	}
+	if data.StartTime != nil {
+		start = data.StartTime.Time
+	}
+	if data.CompletionTime != nil {
+		end = data.CompletionTime.Time
+	}
+	if end.Before(start) {
+		end = start
+	}
+	return start, end
+}
+
+func getTaskRunOutcome(taskRun *tknv1.TaskRun) (string, string) {
+	condition := taskRun.Status.GetCondition(apis.ConditionSucceeded)
+	if condition == nil {
+		return tekton.StatusRunning, ""
+	}
+	if !taskRun.IsDone() {
+		return tekton.StatusRunning, condition.Reason
+	}
+	if condition.IsTrue() {
+		return tekton.StatusSucceeded, condition.Reason
+	}
+	return tekton.StatusFailed, condition.Reason
+}
+
+func setStatus(span trace.Span, status, reason string) {
+	switch status {
+	case tekton.StatusSucceeded:
+		span.SetStatus(codes.Ok, "")
+	case tekton.StatusFailed, tekton.StatusAborted:
+		span.SetStatus(codes.Error, reason)
+	}
+}
+
+func pipelineRunUID(data *tekton.PipelineRunData) string {
+	if data.RawPipelineRun != nil && data.RawPipelineRun.UID != "" {
+		return string(data.RawPipelineRun.UID)
+	}
+	return fmt.Sprintf("%s/%s", data.Namespace, data.PipelineRunName)
+}
+
+type spanIDKey struct{}
+
+func withSpanID(ctx context.Context, id trace.SpanID) context.Context {
+	return context.WithValue(ctx, spanIDKey{}, id)
+}
+
+// idGenerator hands out the trace ID of the PipelineRun and the span ID set on the context the span is started with
+type idGenerator struct {
+	traceID trace.TraceID
+}
+
+func (g idGenerator) NewIDs(ctx context.Context) (trace.TraceID, trace.SpanID) {
+	return g.traceID, g.NewSpanID(ctx, g.traceID)
+}
+
+func (g idGenerator) NewSpanID(ctx context.Context, _ trace.TraceID) trace.SpanID {
+	id, _ := ctx.Value(spanIDKey{}).(trace.SpanID)
+	return id
+}
+
+// spanRecorder keeps the ended spans so they are exported together
+type spanRecorder struct {
+	mu    sync.Mutex
+	spans []sdktrace.ReadOnlySpan
+}
+
+func (r *spanRecorder) OnStart(context.Context, sdktrace.ReadWriteSpan) {}
+
+func (r *spanRecorder) OnEnd(span sdktrace.ReadOnlySpan) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, span)
+}
+
+func (r *spanRecorder) Shutdown(context.Context) error {
+	return nil
+}
+
+func (r *spanRecorder) ForceFlush(context.Context) error {
+	return nil
+}
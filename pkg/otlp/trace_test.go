@@ -0,0 +1,180 @@
+package otlp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kcloutie/tekton-observer/pkg/tekton"
+	"github.com/kcloutie/tekton-observer/test/utils"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline"
+	tknv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/apis"
+	duckv1 "knative.dev/pkg/apis/duck/v1"
+)
+
+var testStart = time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+func newTaskRun(name, taskName string, succeeded bool, steps ...tknv1.StepState) tknv1.TaskRun {
+	status := corev1.ConditionTrue
+	if !succeeded {
+		status = corev1.ConditionFalse
+	}
+	return tknv1.TaskRun{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{pipeline.PipelineTaskLabelKey: taskName},
+		},
+		Status: tknv1.TaskRunStatus{
+			Status: duckv1.Status{
+				Conditions: []apis.Condition{{Type: apis.ConditionSucceeded, Status: status, Reason: "Done"}},
+			},
+			TaskRunStatusFields: tknv1.TaskRunStatusFields{
+				StartTime:      &metav1.Time{Time: testStart.Add(time.Minute)},
+				CompletionTime: &metav1.Time{Time: testStart.Add(5 * time.Minute)},
+				Steps:          steps,
+			},
+		},
+	}
+}
+
+func newStep(name string, exitCode int32) tknv1.StepState {
+	return tknv1.StepState{
+		Name: name,
+		ContainerState: corev1.ContainerState{
+			Terminated: &corev1.ContainerStateTerminated{
+				ExitCode:   exitCode,
+				StartedAt:  metav1.Time{Time: testStart.Add(2 * time.Minute)},
+				FinishedAt: metav1.Time{Time: testStart.Add(4 * time.Minute)},
+			},
+		},
+	}
+}
+
+func TestSpans(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     string
+		taskRuns   []tknv1.TaskRun
+		wantSpans  int
+		wantErrors []string
+	}{
+		{
+			name:      "Test with a succeeded PipelineRun",
+			status:    tekton.StatusSucceeded,
+			taskRuns:  []tknv1.TaskRun{newTaskRun("build-run-clone", "clone", true, newStep("git", 0))},
+			wantSpans: 3,
+		},
+		{
+			name:   "Test with a failed step",
+			status: tekton.StatusFailed,
+			taskRuns: []tknv1.TaskRun{
+				newTaskRun("build-run-clone", "clone", true, newStep("git", 0)),
+				newTaskRun("build-run-test", "test", false, newStep("setup", 0), newStep("unit", 1)),
+			},
+			wantSpans:  6,
+			wantErrors: []string{"build", "test", "unit"},
+		},
+		{
+			name:      "Test with a step that never started",
+			status:    tekton.StatusSucceeded,
+			taskRuns:  []tknv1.TaskRun{newTaskRun("build-run-clone", "clone", true, tknv1.StepState{Name: "git"})},
+			wantSpans: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pipelineRun := utils.NewPipelineRun("test-namespace", "build-run", nil, true)
+			pipelineRun.UID = "5b1c7d4e-1d1b-4c39-9f5e-8d4c0f0a7a11"
+			data := &tekton.PipelineRunData{
+				RawPipelineRun:  pipelineRun,
+				Namespace:       pipelineRun.Namespace,
+				PipelineRunName: pipelineRun.Name,
+				PipelineName:    "build",
+				StartTime:       &metav1.Time{Time: testStart},
+				CompletionTime:  &metav1.Time{Time: testStart.Add(10 * time.Minute)},
+				Status:          tt.status,
+				VariableValues:  map[string]string{"revision": "main"},
+			}
+
+			spans := Spans(context.Background(), data, tt.taskRuns, Resource("", "test-cluster", data.Namespace))
+			if len(spans) != tt.wantSpans {
+				t.Fatalf("Spans() returned %d spans, want %d", len(spans), tt.wantSpans)
+			}
+			byName := map[string]sdktrace.ReadOnlySpan{}
+			for _, span := range spans {
+				byName[span.Name()] = span
+				if span.SpanContext().TraceID() != TraceID(string(pipelineRun.UID)) {
+					t.Errorf("span %s has the trace ID %s, want the trace ID of the PipelineRun", span.Name(), span.SpanContext().TraceID())
+				}
+			}
+
+			root := byName["build"]
+			if root == nil || root.Parent().IsValid() {
+				t.Fatalf("Spans() root span = %v, want a root span named after the pipeline", root)
+			}
+			if root.SpanContext().SpanID() != SpanID(string(pipelineRun.UID)) {
+				t.Errorf("root span ID = %s, want %s", root.SpanContext().SpanID(), SpanID(string(pipelineRun.UID)))
+			}
+			if !root.StartTime().Equal(testStart) || !root.EndTime().Equal(testStart.Add(10*time.Minute)) {
+				t.Errorf("root span is timed from %v to %v, want the start and completion of the PipelineRun", root.StartTime(), root.EndTime())
+			}
+			for _, taskRun := range tt.taskRuns {
+				span := byName[taskRun.Labels[pipeline.PipelineTaskLabelKey]]
+				if span == nil || span.Parent().SpanID() != root.SpanContext().SpanID() {
+					t.Errorf("span of the TaskRun %s = %v, want a child of the root span", taskRun.Name, span)
+					continue
+				}
+				for _, step := range taskRun.Status.Steps {
+					stepSpan := byName[step.Name]
+					if stepSpan != nil && stepSpan.Parent().SpanID() != span.SpanContext().SpanID() {
+						t.Errorf("span of the step %s is not a child of the span of its TaskRun", step.Name)
+					}
+				}
+			}
+			for _, name := range tt.wantErrors {
+				if span := byName[name]; span == nil || span.Status().Code != codes.Error {
+					t.Errorf("span %s = %v, want an error status", name, span)
+				}
+			}
+
+			again := Spans(context.Background(), data, tt.taskRuns, Resource("", "test-cluster", data.Namespace))
+			for i := range again {
+				if !again[i].SpanContext().Equal(spans[i].SpanContext()) {
+					t.Errorf("Spans() is not deterministic, span %s has the context %v then %v", spans[i].Name(), spans[i].SpanContext(), again[i].SpanContext())
+				}
+			}
+		})
+	}
+}
+
+func TestSpans_IgnoresParentSpan(t *testing.T) {
+	tests := []struct {
+		name   string
+		parent trace.SpanContext
+	}{
+		{
+			name: "Test with a span on the context",
+			parent: trace.NewSpanContext(trace.SpanContextConfig{
+				TraceID:    trace.TraceID{1},
+				SpanID:     trace.SpanID{1},
+				TraceFlags: trace.FlagsSampled,
+			}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := &tekton.PipelineRunData{Namespace: "test-namespace", PipelineRunName: "build-run", PipelineName: "build"}
+			ctx := trace.ContextWithSpanContext(context.Background(), tt.parent)
+			spans := Spans(ctx, data, nil, Resource("", "", data.Namespace))
+			if len(spans) != 1 || spans[0].Parent().IsValid() || spans[0].SpanContext().TraceID() == tt.parent.TraceID() {
+				t.Errorf("Spans() = %v, want a root span in the trace of the PipelineRun", spans)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package sinks
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"github.com/kcloutie/tekton-observer/pkg/delivery"
+	"github.com/kcloutie/tekton-observer/pkg/kafka"
+	"github.com/kcloutie/tekton-observer/pkg/metrics"
+	kafkago "github.com/segmentio/kafka-go"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// KafkaProducer writes a message to a Kafka topic
+type KafkaProducer func(ctx context.Context, config kafka.Config, message kafkago.Message) error
+
+// KafkaSink produces the PipelineRun data to a Kafka topic. The messages have the payload of the Pub/Sub messages,
+// their attributes and the delivery ID and attempt are set as headers so the consumers can deduplicate them.
+type KafkaSink struct {
+	Topic obsv1.KafkaTopic
+	// Namespace is the namespace of the observation, the SASL and TLS Secrets are read from it
+	Namespace string
+	Producer  KafkaProducer
+	// Secrets reads the SASL and TLS Secrets
+	Secrets client.Reader
+}
+
+var _ Sink = &KafkaSink{}
+
+func (s *KafkaSink) Name() string {
+	return fmt.Sprintf("kafka/%s/%s", strings.Join(s.Topic.Brokers, ","), s.Topic.Topic)
+}
+
+func (s *KafkaSink) Type() obsv1.SinkType {
+	return obsv1.SinkTypeKafka
+}
+
+func (s *KafkaSink) Subscribed(phase obsv1.Phase) bool {
+	return Subscribes(s.Topic.Phases, phase)
+}
+
+func (s *KafkaSink) Digest() *obsv1.DigestPolicy {
+	return s.Topic.Digest
+}
+
+func (s *KafkaSink) Deliver(ctx context.Context, event Event) (string, error) {
+	payload, attributes, err := message(event)
+	if err != nil {
+		return "", err
+	}
+	config, err := s.config(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	msg := kafkago.Message{
+		Key:     s.key(event),
+		Value:   payload,
+		Headers: kafkaHeaders(attributes, event),
+	}
+	start := time.Now()
+	err = s.Producer(ctx, config, msg)
+	metrics.KafkaRequestTimeHistogram.WithLabelValues(s.Topic.Topic, "produce", kafka.StatusCode(err)).Observe(time.Since(start).Seconds())
+	if err != nil {
+		metrics.KafkaMessagesFailedTotal.Inc()
+		return "", fmt.Errorf("failed to produce to the kafka topic '%s' - %w", s.Topic.Topic, err)
+	}
+	metrics.KafkaMessagesSentTotal.Inc()
+	metrics.KafkaMessageBytesTotal.Add(float64(len(payload)))
+	return "", nil
+}
+
+// key returns the key of the message produced for the event, the digests are keyed by group and the DORA summaries
+// by namespace
+func (s *KafkaSink) key(event Event) []byte {
+	if s.Topic.Key == obsv1.KafkaKeyNone {
+		return nil
+	}
+	switch {
+	case event.Digest != nil:
+		return []byte(event.Digest.Group)
+	case event.Dora != nil:
+		return []byte(event.Dora.Namespace)
+	case event.Data == nil:
+		return nil
+	}
+	data := event.Data
+	if s.Topic.Key == obsv1.KafkaKeyRepository {
+		if repository := data.PacLabels["repository"]; repository != "" {
+			return []byte(fmt.Sprintf("%s/%s", data.Namespace, repository))
+		}
+		return []byte(data.Namespace)
+	}
+	if data.RawPipelineRun != nil && data.RawPipelineRun.UID != "" {
+		return []byte(data.RawPipelineRun.UID)
+	}
+	return []byte(fmt.Sprintf("%s/%s", data.Namespace, data.PipelineRunName))
+}
+
+// kafkaHeaders returns the headers of the message, the attributes of the Pub/Sub messages followed by the delivery ID and
+// attempt
+func kafkaHeaders(attributes map[string]string, event Event) []kafkago.Header {
+	keys := []string{}
+	for k := range attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	result := []kafkago.Header{}
+	for _, k := range keys {
+		result = append(result, kafkago.Header{Key: k, Value: []byte(attributes[k])})
+	}
+	metadata := event.Delivery.Attributes()
+	for _, k := range []string{delivery.IDAttribute, delivery.AttemptAttribute} {
+		if v, found := metadata[k]; found {
+			result = append(result, kafkago.Header{Key: k, Value: []byte(v)})
+		}
+	}
+	return result
+}
+
+// config returns the connection of the producer, the SASL credentials and the TLS certificates are read from their
+// Secrets and their versions identify the credentials of the connection
+func (s *KafkaSink) config(ctx context.Context) (kafka.Config, error) {
+	config := kafka.Config{
+		ID:          fmt.Sprintf("%s/%s", s.Namespace, s.Name()),
+		Brokers:     s.Topic.Brokers,
+		Topic:       s.Topic.Topic,
+		Compression: s.Topic.Compression,
+	}
+	if s.Topic.SASL != nil {
+		secret, err := s.secret(ctx, s.Topic.SASL.Secret)
+		if err != nil {
+			return config, err
+		}
+		mechanism, err := kafka.SASLMechanism(s.Topic.SASL.Mechanism, string(secret.Data["username"]), string(secret.Data["password"]))
+		if err != nil {
+			return config, err
+		}
+		config.SASL = mechanism
+		config.CredentialsVersion = secret.ResourceVersion
+	}
+	if s.Topic.TLS != nil {
+		var caCert, cert, key []byte
+		if s.Topic.TLS.Secret != "" {
+			secret, err := s.secret(ctx, s.Topic.TLS.Secret)
+			if err != nil {
+				return config, err
+			}
+			caCert, cert, key = secret.Data["ca.crt"], secret.Data[corev1.TLSCertKey], secret.Data[corev1.TLSPrivateKeyKey]
+			config.CredentialsVersion = fmt.Sprintf("%s/%s", config.CredentialsVersion, secret.ResourceVersion)
+		}
+		tlsConfig, err := kafka.TLSConfig(caCert, cert, key, s.Topic.TLS.InsecureSkipVerify)
+		if err != nil {
+			return config, fmt.Errorf("failed to configure the TLS connections to the kafka topic '%s' - %w", s.Topic.Topic, err)
+		}
+		config.TLS = tlsConfig
+	}
+	return config, nil
+}
+
+func (s *KafkaSink) secret(ctx context.Context, name string) (*corev1.Secret, error) {
+	secret := &corev1.Secret{}
+	if err := s.Secrets.Get(ctx, client.ObjectKey{Namespace: s.Namespace, Name: name}, secret); err != nil {
+		return nil, fmt.Errorf("failed to get the secret '%s' of the kafka topic '%s' - %w", name, s.Topic.Topic, err)
+	}
+	return secret, nil
+}
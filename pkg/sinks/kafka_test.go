@@ -0,0 +1,157 @@
+package sinks
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"github.com/kcloutie/tekton-observer/pkg/delivery"
+	"github.com/kcloutie/tekton-observer/pkg/kafka"
+	"github.com/kcloutie/tekton-observer/pkg/tekton"
+	"github.com/kcloutie/tekton-observer/test/utils"
+	kafkago "github.com/segmentio/kafka-go"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// mockProducer records the messages produced instead of writing them to brokers
+type mockProducer struct {
+	err      error
+	configs  []kafka.Config
+	messages []kafkago.Message
+}
+
+func (p *mockProducer) produce(ctx context.Context, config kafka.Config, message kafkago.Message) error {
+	if p.err != nil {
+		return p.err
+	}
+	p.configs = append(p.configs, config)
+	p.messages = append(p.messages, message)
+	return nil
+}
+
+func TestKafkaSink_Deliver(t *testing.T) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "kafka-credentials"},
+		Data:       map[string][]byte{"username": []byte("observer"), "password": []byte("secret")},
+	}
+	tests := []struct {
+		name        string
+		topic       obsv1.KafkaTopic
+		producerErr error
+		wantErr     string
+		wantKey     string
+		wantSASL    string
+		wantTLS     bool
+	}{
+		{
+			name:    "Test with the default key",
+			topic:   obsv1.KafkaTopic{Brokers: []string{"kafka:9092"}, Topic: "pipelineruns"},
+			wantKey: "5b1c7d4e-1d1b-4c39-9f5e-8d4c0f0a7a11",
+		},
+		{
+			name:    "Test with the repository key",
+			topic:   obsv1.KafkaTopic{Brokers: []string{"kafka:9092"}, Topic: "pipelineruns", Key: obsv1.KafkaKeyRepository},
+			wantKey: "test-namespace/my-repo",
+		},
+		{
+			name:  "Test without key",
+			topic: obsv1.KafkaTopic{Brokers: []string{"kafka:9092"}, Topic: "pipelineruns", Key: obsv1.KafkaKeyNone},
+		},
+		{
+			name: "Test with SASL and TLS",
+			topic: obsv1.KafkaTopic{
+				Brokers:     []string{"kafka:9093"},
+				Topic:       "pipelineruns",
+				Compression: obsv1.KafkaCompressionZstd,
+				SASL:        &obsv1.KafkaSASL{Mechanism: obsv1.KafkaSASLScramSHA512, Secret: "kafka-credentials"},
+				TLS:         &obsv1.KafkaTLS{},
+			},
+			wantKey:  "5b1c7d4e-1d1b-4c39-9f5e-8d4c0f0a7a11",
+			wantSASL: "SCRAM-SHA-512",
+			wantTLS:  true,
+		},
+		{
+			name: "Test with a missing SASL secret",
+			topic: obsv1.KafkaTopic{
+				Brokers: []string{"kafka:9092"},
+				Topic:   "pipelineruns",
+				SASL:    &obsv1.KafkaSASL{Mechanism: obsv1.KafkaSASLPlain, Secret: "missing"},
+			},
+			wantErr: "failed to get the secret 'missing' of the kafka topic 'pipelineruns'",
+		},
+		{
+			name:        "Test with a producer error",
+			topic:       obsv1.KafkaTopic{Brokers: []string{"kafka:9092"}, Topic: "pipelineruns"},
+			producerErr: kafkago.LeaderNotAvailable,
+			wantErr:     "failed to produce to the kafka topic 'pipelineruns'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			producer := &mockProducer{err: tt.producerErr}
+			sink := &KafkaSink{
+				Topic:     tt.topic,
+				Namespace: "test-namespace",
+				Producer:  producer.produce,
+				Secrets:   utils.NewFakeClient(secret),
+			}
+			pipelineRun := utils.NewPipelineRun("test-namespace", "build-run", nil, true)
+			pipelineRun.UID = "5b1c7d4e-1d1b-4c39-9f5e-8d4c0f0a7a11"
+			event := Event{
+				Phase: obsv1.PhaseFinished,
+				Data: &tekton.PipelineRunData{
+					RawPipelineRun:  pipelineRun,
+					Namespace:       pipelineRun.Namespace,
+					PipelineRunName: pipelineRun.Name,
+					PipelineName:    "build",
+					Status:          tekton.StatusSucceeded,
+					PacLabels:       map[string]string{"repository": "my-repo"},
+				},
+				Delivery: delivery.Metadata{ID: "delivery-id", Attempt: 2},
+			}
+
+			_, err := sink.Deliver(context.Background(), event)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Deliver() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if tt.producerErr != nil && !errors.Is(err, tt.producerErr) {
+					t.Errorf("Deliver() error = %v, want it to wrap %v", err, tt.producerErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Deliver() unexpected error = %v", err)
+			}
+			if len(producer.messages) != 1 {
+				t.Fatalf("Deliver() produced %d messages, want 1", len(producer.messages))
+			}
+			message, config := producer.messages[0], producer.configs[0]
+			if string(message.Key) != tt.wantKey || (tt.wantKey == "" && message.Key != nil) {
+				t.Errorf("Deliver() key = %q, want %q", message.Key, tt.wantKey)
+			}
+			headers := map[string]string{}
+			for _, header := range message.Headers {
+				headers[header.Key] = string(header.Value)
+			}
+			if headers[delivery.IDAttribute] != "delivery-id" || headers[delivery.AttemptAttribute] != "2" || headers["pipelineRunName"] != "build-run" || headers["phase"] != "finished" || headers[SchemaVersionAttribute] != SchemaVersion {
+				t.Errorf("Deliver() headers = %v, want the attributes and the delivery metadata", headers)
+			}
+			if !strings.Contains(string(message.Value), `"pipelineRunName":"build-run"`) {
+				t.Errorf("Deliver() value = %s, want the PipelineRun data", message.Value)
+			}
+			if config.ID != "test-namespace/"+sink.Name() || config.Topic != tt.topic.Topic || config.Compression != tt.topic.Compression {
+				t.Errorf("Deliver() config = %+v, want the ID, the topic and the compression of the sink", config)
+			}
+			if (config.SASL == nil && tt.wantSASL != "") || (config.SASL != nil && config.SASL.Name() != tt.wantSASL) {
+				t.Errorf("Deliver() SASL = %v, want %v", config.SASL, tt.wantSASL)
+			}
+			if (config.TLS != nil) != tt.wantTLS {
+				t.Errorf("Deliver() TLS = %v, want %v", config.TLS, tt.wantTLS)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package sinks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"github.com/kcloutie/tekton-observer/internal/tektonobserver"
+	"github.com/kcloutie/tekton-observer/pkg/metrics"
+)
+
+// Redacted replaces the value of the redacted fields of the log records
+const Redacted = "[REDACTED]"
+
+// LogSink writes a JSON record per event through a dedicated logger. The record holds the Pub/Sub payload of the
+// event, restricted to the selected fields and with the redacted fields replaced.
+type LogSink struct {
+	Config obsv1.LogSink
+	Log    logr.Logger
+}
+
+var _ Sink = &LogSink{}
+
+func (s *LogSink) Name() string {
+	return "log"
+}
+
+func (s *LogSink) Type() obsv1.SinkType {
+	return obsv1.SinkTypeLog
+}
+
+func (s *LogSink) Subscribed(phase obsv1.Phase) bool {
+	return Subscribes(s.Config.Phases, phase)
+}
+
+func (s *LogSink) Digest() *obsv1.DigestPolicy {
+	return nil
+}
+
+// Deliver writes the record of the event, it is not written when the log level of the logger is below the verbosity
+// of the sink
+func (s *LogSink) Deliver(ctx context.Context, event Event) (string, error) {
+	log := s.Log.V(int(s.Config.Verbosity))
+	if !log.Enabled() {
+		return "", nil
+	}
+	message, payload := "", any(nil)
+	switch {
+	case event.Dora != nil:
+		message, payload = "DORA summary", event.Dora
+	case event.Data != nil:
+		message, payload = fmt.Sprintf("PipelineRun %s", event.Phase), event.Data
+	default:
+		return "", nil
+	}
+	record, err := s.record(payload)
+	if err != nil {
+		return "", err
+	}
+
+	keysAndValues := []any{
+		"clusterName", tektonobserver.ControllerConfiguration.GetClusterName(),
+		"phase", event.Phase,
+		"deliveryId", event.Delivery.ID,
+	}
+	if event.TaskName != "" {
+		keysAndValues = append(keysAndValues, "taskName", event.TaskName)
+	}
+	log.Info(message, append(keysAndValues, "payload", record)...)
+	metrics.LogRecordsWrittenTotal.Inc()
+	return "", nil
+}
+
+// record returns the payload as a JSON object with the selected fields and the redacted fields replaced
+func (s *LogSink) record(payload any) (map[string]any, error) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal the payload of the log record - %w", err)
+	}
+	record := map[string]any{}
+	if err := json.Unmarshal(raw, &record); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal the payload of the log record - %w", err)
+	}
+	if len(s.Config.Fields) > 0 {
+		selected := map[string]any{}
+		for _, field := range s.Config.Fields {
+			if value, found := record[field]; found {
+				selected[field] = value
+			}
+		}
+		record = selected
+	}
+	for _, path := range s.Config.Redact {
+		redact(record, strings.Split(path, "."))
+	}
+	return record, nil
+}
+
+// redact replaces the values at the path, the arrays are traversed and * matches every key of an object
+func redact(value any, path []string) {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, child := range v {
+			if path[0] != "*" && path[0] != key {
+				continue
+			}
+			if len(path) == 1 {
+				v[key] = Redacted
+				continue
+			}
+			redact(child, path[1:])
+		}
+	case []any:
+		for _, child := range v {
+			redact(child, path)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package sinks
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/go-logr/logr/funcr"
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"github.com/kcloutie/tekton-observer/internal/dora"
+	"github.com/kcloutie/tekton-observer/pkg/delivery"
+	"github.com/kcloutie/tekton-observer/pkg/tekton"
+)
+
+func TestLogSink_Deliver(t *testing.T) {
+	data := &tekton.PipelineRunData{
+		Namespace:       "test-namespace",
+		PipelineRunName: "build-run",
+		PipelineName:    "build",
+		Status:          tekton.StatusSucceeded,
+		VariableValues:  map[string]string{"revision": "main", "token": "s3cr3t"},
+		FlakyTasks:      []tekton.FlakyTask{{Task: "test", Message: "flaky"}},
+	}
+	tests := []struct {
+		name         string
+		config       obsv1.LogSink
+		logVerbosity int
+		event        Event
+		wantMessage  string
+		wantPayload  map[string]any
+	}{
+		{
+			name:        "Test with every field",
+			event:       Event{Phase: obsv1.PhaseFinished, Data: data},
+			wantMessage: "PipelineRun finished",
+			wantPayload: map[string]any{
+				"namespace":       "test-namespace",
+				"pipelineRunName": "build-run",
+				"pipelineName":    "build",
+				"status":          tekton.StatusSucceeded,
+				"variables":       map[string]any{"revision": "main", "token": "s3cr3t"},
+				"flakyTasks":      []any{map[string]any{"task": "test", "ratio": float64(0), "flaky": float64(0), "runs": float64(0), "message": "flaky"}},
+			},
+		},
+		{
+			name:        "Test with selected fields",
+			config:      obsv1.LogSink{Fields: []string{"pipelineRunName", "status", "missing"}},
+			event:       Event{Phase: obsv1.PhaseFinished, Data: data},
+			wantMessage: "PipelineRun finished",
+			wantPayload: map[string]any{"pipelineRunName": "build-run", "status": tekton.StatusSucceeded},
+		},
+		{
+			name:        "Test with redacted fields",
+			config:      obsv1.LogSink{Fields: []string{"variables", "flakyTasks"}, Redact: []string{"variables.token", "flakyTasks.*"}},
+			event:       Event{Phase: obsv1.PhaseFinished, Data: data},
+			wantMessage: "PipelineRun finished",
+			wantPayload: map[string]any{
+				"variables":  map[string]any{"revision": "main", "token": Redacted},
+				"flakyTasks": []any{map[string]any{"task": Redacted, "ratio": Redacted, "flaky": Redacted, "runs": Redacted, "message": Redacted}},
+			},
+		},
+		{
+			name:         "Test with a verbosity above the log level",
+			config:       obsv1.LogSink{Verbosity: 2},
+			logVerbosity: 1,
+			event:        Event{Phase: obsv1.PhaseFinished, Data: data},
+		},
+		{
+			name:         "Test with a verbosity within the log level",
+			config:       obsv1.LogSink{Verbosity: 1, Fields: []string{"status"}},
+			logVerbosity: 1,
+			event:        Event{Phase: obsv1.PhaseStarted, Data: data},
+			wantMessage:  "PipelineRun started",
+			wantPayload:  map[string]any{"status": tekton.StatusSucceeded},
+		},
+		{
+			name:        "Test with a DORA summary",
+			event:       Event{Phase: obsv1.PhaseDoraSummary, Dora: &dora.Summary{Namespace: "test-namespace"}},
+			wantMessage: "DORA summary",
+			wantPayload: map[string]any{"namespace": "test-namespace"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := []string{}
+			sink := &LogSink{
+				Config: tt.config,
+				Log: funcr.NewJSON(func(obj string) {
+					lines = append(lines, obj)
+				}, funcr.Options{Verbosity: tt.logVerbosity}),
+			}
+
+			tt.event.Delivery = delivery.Metadata{ID: "delivery-id", Attempt: 1}
+			if _, err := sink.Deliver(context.Background(), tt.event); err != nil {
+				t.Fatalf("Deliver() unexpected error = %v", err)
+			}
+			if tt.wantMessage == "" {
+				if len(lines) != 0 {
+					t.Errorf("Deliver() wrote %v, want no record", lines)
+				}
+				return
+			}
+			if len(lines) != 1 {
+				t.Fatalf("Deliver() wrote %d records, want 1", len(lines))
+			}
+			record := map[string]any{}
+			if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+				t.Fatalf("Deliver() wrote an invalid JSON line %s - %v", lines[0], err)
+			}
+			if record["msg"] != tt.wantMessage || record["phase"] != string(tt.event.Phase) || record["deliveryId"] != "delivery-id" {
+				t.Errorf("Deliver() wrote %v, want the message %s with the phase and the delivery ID", record, tt.wantMessage)
+			}
+			payload, _ := record["payload"].(map[string]any)
+			if tt.event.Dora != nil {
+				if payload["namespace"] != tt.wantPayload["namespace"] {
+					t.Errorf("Deliver() payload = %v, want %v", payload, tt.wantPayload)
+				}
+				return
+			}
+			if !reflect.DeepEqual(payload, tt.wantPayload) {
+				t.Errorf("Deliver() payload = %v, want %v", payload, tt.wantPayload)
+			}
+		})
+	}
+}
+
+func TestLogSink_Subscribed(t *testing.T) {
+	tests := []struct {
+		name   string
+		phases []obsv1.Phase
+		phase  obsv1.Phase
+		want   bool
+	}{
+		{
+			name:  "Test with the default phases",
+			phase: obsv1.PhaseFinished,
+			want:  true,
+		},
+		{
+			name:  "Test with a phase that is not subscribed by default",
+			phase: obsv1.PhaseStarted,
+			want:  false,
+		},
+		{
+			name:   "Test with subscribed phases",
+			phases: []obsv1.Phase{obsv1.PhaseStarted},
+			phase:  obsv1.PhaseStarted,
+			want:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := &LogSink{Config: obsv1.LogSink{Phases: tt.phases}}
+			if got := sink.Subscribed(tt.phase); got != tt.want {
+				t.Errorf("Subscribed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"github.com/kcloutie/tekton-observer/pkg/metrics"
+	"github.com/kcloutie/tekton-observer/pkg/nats"
+	natsgo "github.com/nats-io/nats.go"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// NATSPublisher publishes a message to JetStream with the message ID the stream deduplicates on, and returns the
+// acknowledgement of the stream
+type NATSPublisher func(ctx context.Context, config nats.Config, message *natsgo.Msg, id string) (*natsgo.PubAck, error)
+
+// NATSSink publishes the PipelineRun data to a NATS subject captured by a JetStream stream. The messages have the
+// payload of the Pub/Sub messages and their attributes as headers. Their Nats-Msg-Id is the delivery ID, derived from
+// the PipelineRun UID and the phase, so the stream drops the messages published again.
+type NATSSink struct {
+	Subject obsv1.NATSSubject
+	// Namespace is the namespace of the observation, the credentials Secret is read from it
+	Namespace string
+	Publisher NATSPublisher
+	// Secrets reads the credentials Secret
+	Secrets client.Reader
+}
+
+var _ Sink = &NATSSink{}
+
+func (s *NATSSink) Name() string {
+	return fmt.Sprintf("nats/%s/%s", s.Subject.URL, s.Subject.Subject)
+}
+
+func (s *NATSSink) Type() obsv1.SinkType {
+	return obsv1.SinkTypeNATS
+}
+
+func (s *NATSSink) Subscribed(phase obsv1.Phase) bool {
+	return Subscribes(s.Subject.Phases, phase)
+}
+
+func (s *NATSSink) Digest() *obsv1.DigestPolicy {
+	return nil
+}
+
+// Deliver publishes the event and returns the stream and the sequence of the message
+func (s *NATSSink) Deliver(ctx context.Context, event Event) (string, error) {
+	subject, err := s.subject(event)
+	if err != nil {
+		return "", err
+	}
+	payload, attributes, err := message(event)
+	if err != nil {
+		return "", err
+	}
+	config, err := s.config(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	msg := natsgo.NewMsg(subject)
+	msg.Data = payload
+	for k, v := range attributes {
+		msg.Header.Set(k, v)
+	}
+	for k, v := range event.Delivery.Attributes() {
+		msg.Header.Set(k, v)
+	}
+	start := time.Now()
+	ack, err := s.Publisher(ctx, config, msg, event.Delivery.ID)
+	metrics.NATSRequestTimeHistogram.WithLabelValues("jetstream/publish", "PUB", nats.StatusCode(err)).Observe(time.Since(start).Seconds())
+	if err != nil {
+		metrics.NATSMessagesFailedTotal.Inc()
+		return "", fmt.Errorf("failed to publish to the NATS subject '%s' - %w", subject, err)
+	}
+	metrics.NATSMessagesPublishedTotal.Inc()
+	if ack.Duplicate {
+		metrics.NATSMessagesDuplicateTotal.Inc()
+	}
+	return fmt.Sprintf("%s/%d", ack.Stream, ack.Sequence), nil
+}
+
+// subjectData is the data the subject template is executed with
+type subjectData struct {
+	Namespace       string
+	PipelineRunName string
+	PipelineName    string
+	Status          string
+	Reason          string
+	Phase           string
+	TaskName        string
+	PacLabels       map[string]string
+}
+
+// subject renders the subject of the message published for the event
+func (s *NATSSink) subject(event Event) (string, error) {
+	data := subjectData{Phase: string(event.Phase), TaskName: event.TaskName}
+	switch {
+	case event.Data != nil:
+		data.Namespace = event.Data.Namespace
+		data.PipelineRunName = event.Data.PipelineRunName
+		data.PipelineName = event.Data.PipelineName
+		data.Status = event.Data.Status
+		data.Reason = event.Data.Reason
+		data.PacLabels = event.Data.PacLabels
+	case event.Dora != nil:
+		data.Namespace = event.Dora.Namespace
+	}
+	tmpl, err := template.New("subject").Option("missingkey=zero").Parse(s.Subject.Subject)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse the NATS subject template '%s' - %w", s.Subject.Subject, err)
+	}
+	var subject bytes.Buffer
+	if err := tmpl.Execute(&subject, data); err != nil {
+		return "", fmt.Errorf("failed to render the NATS subject template '%s' - %w", s.Subject.Subject, err)
+	}
+	if !validSubject(subject.String()) {
+		return "", fmt.Errorf("the NATS subject template '%s' rendered the invalid subject '%s'", s.Subject.Subject, subject.String())
+	}
+	return subject.String(), nil
+}
+
+// validSubject returns true when the subject can be published to, its tokens are not empty and it has no whitespace
+// or wildcard
+func validSubject(subject string) bool {
+	if subject == "" || strings.ContainsAny(subject, " \t\r\n*>") {
+		return false
+	}
+	for _, token := range strings.Split(subject, ".") {
+		if token == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// config returns the connection of the publisher, authenticated with the credentials Secret when it is set
+func (s *NATSSink) config(ctx context.Context) (nats.Config, error) {
+	config := nats.Config{URL: s.Subject.URL, Stream: s.Subject.Stream}
+	if s.Subject.CredentialsSecret == "" {
+		return config, nil
+	}
+	secret := &corev1.Secret{}
+	if err := s.Secrets.Get(ctx, client.ObjectKey{Namespace: s.Namespace, Name: s.Subject.CredentialsSecret}, secret); err != nil {
+		return config, fmt.Errorf("failed to get the credentials secret '%s' of the NATS subject '%s' - %w", s.Subject.CredentialsSecret, s.Subject.Subject, err)
+	}
+	options, err := nats.CredentialsOptions(secret.Data)
+	if err != nil {
+		return config, fmt.Errorf("invalid credentials secret '%s' of the NATS subject '%s' - %w", s.Subject.CredentialsSecret, s.Subject.Subject, err)
+	}
+	config.Options = options
+	return config, nil
+}
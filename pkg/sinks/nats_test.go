@@ -0,0 +1,179 @@
+package sinks
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"github.com/kcloutie/tekton-observer/pkg/delivery"
+	"github.com/kcloutie/tekton-observer/pkg/nats"
+	"github.com/kcloutie/tekton-observer/pkg/tekton"
+	"github.com/kcloutie/tekton-observer/test/utils"
+	natsgo "github.com/nats-io/nats.go"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// mockPublisher records the messages published instead of sending them to JetStream
+type mockPublisher struct {
+	err       error
+	duplicate bool
+	configs   []nats.Config
+	messages  []*natsgo.Msg
+	ids       []string
+}
+
+func (p *mockPublisher) publish(ctx context.Context, config nats.Config, message *natsgo.Msg, id string) (*natsgo.PubAck, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	p.configs = append(p.configs, config)
+	p.messages = append(p.messages, message)
+	p.ids = append(p.ids, id)
+	return &natsgo.PubAck{Stream: "TEKTON", Sequence: 1, Duplicate: p.duplicate}, nil
+}
+
+func TestNATSSink_Deliver(t *testing.T) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "nats-credentials"},
+		Data:       map[string][]byte{"token": []byte("s3cr3t")},
+	}
+	tests := []struct {
+		name         string
+		subject      obsv1.NATSSubject
+		publisherErr error
+		duplicate    bool
+		wantErr      string
+		wantSubject  string
+		wantOptions  int
+	}{
+		{
+			name:        "Test with a subject template",
+			subject:     obsv1.NATSSubject{URL: "nats://nats:4222", Subject: "tekton.{{.Namespace}}.{{.PipelineName}}.{{.Status}}"},
+			wantSubject: "tekton.test-namespace.build.Succeeded",
+		},
+		{
+			name:        "Test with the PaC labels and a duplicate",
+			subject:     obsv1.NATSSubject{URL: "nats://nats:4222", Subject: "tekton.{{.PacLabels.repository}}.{{.Phase}}", Stream: "TEKTON"},
+			duplicate:   true,
+			wantSubject: "tekton.my-repo.finished",
+		},
+		{
+			name:    "Test with a template rendering an empty token",
+			subject: obsv1.NATSSubject{URL: "nats://nats:4222", Subject: "tekton.{{.PacLabels.missing}}.{{.Status}}"},
+			wantErr: "rendered the invalid subject 'tekton..Succeeded'",
+		},
+		{
+			name:    "Test with an invalid template",
+			subject: obsv1.NATSSubject{URL: "nats://nats:4222", Subject: "tekton.{{.Namespace"},
+			wantErr: "failed to parse the NATS subject template",
+		},
+		{
+			name:        "Test with a credentials secret",
+			subject:     obsv1.NATSSubject{URL: "nats://nats:4222", Subject: "tekton.runs", CredentialsSecret: "nats-credentials"},
+			wantSubject: "tekton.runs",
+			wantOptions: 1,
+		},
+		{
+			name:    "Test with a missing credentials secret",
+			subject: obsv1.NATSSubject{URL: "nats://nats:4222", Subject: "tekton.runs", CredentialsSecret: "missing"},
+			wantErr: "failed to get the credentials secret 'missing' of the NATS subject 'tekton.runs'",
+		},
+		{
+			name:         "Test with a publisher error",
+			subject:      obsv1.NATSSubject{URL: "nats://nats:4222", Subject: "tekton.runs"},
+			publisherErr: natsgo.ErrNoStreamResponse,
+			wantErr:      "failed to publish to the NATS subject 'tekton.runs'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			publisher := &mockPublisher{err: tt.publisherErr, duplicate: tt.duplicate}
+			sink := &NATSSink{
+				Subject:   tt.subject,
+				Namespace: "test-namespace",
+				Publisher: publisher.publish,
+				Secrets:   utils.NewFakeClient(secret),
+			}
+			pipelineRun := utils.NewPipelineRun("test-namespace", "build-run", nil, true)
+			event := Event{
+				Phase: obsv1.PhaseFinished,
+				Data: &tekton.PipelineRunData{
+					RawPipelineRun:  pipelineRun,
+					Namespace:       pipelineRun.Namespace,
+					PipelineRunName: pipelineRun.Name,
+					PipelineName:    "build",
+					Status:          tekton.StatusSucceeded,
+					PacLabels:       map[string]string{"repository": "my-repo"},
+				},
+				Delivery: delivery.Metadata{ID: "delivery-id", Attempt: 2},
+			}
+
+			ref, err := sink.Deliver(context.Background(), event)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Deliver() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if tt.publisherErr != nil && !errors.Is(err, tt.publisherErr) {
+					t.Errorf("Deliver() error = %v, want it to wrap %v", err, tt.publisherErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Deliver() unexpected error = %v", err)
+			}
+			if ref != "TEKTON/1" {
+				t.Errorf("Deliver() ref = %v, want TEKTON/1", ref)
+			}
+			if len(publisher.messages) != 1 {
+				t.Fatalf("Deliver() published %d messages, want 1", len(publisher.messages))
+			}
+			message, config := publisher.messages[0], publisher.configs[0]
+			if message.Subject != tt.wantSubject {
+				t.Errorf("Deliver() subject = %v, want %v", message.Subject, tt.wantSubject)
+			}
+			if publisher.ids[0] != "delivery-id" {
+				t.Errorf("Deliver() message ID = %v, want the delivery ID", publisher.ids[0])
+			}
+			if message.Header.Get(delivery.IDAttribute) != "delivery-id" || message.Header.Get(delivery.AttemptAttribute) != "2" || message.Header.Get("pipelineRunName") != "build-run" || message.Header.Get("phase") != "finished" || message.Header.Get(SchemaVersionAttribute) != SchemaVersion {
+				t.Errorf("Deliver() headers = %v, want the attributes and the delivery metadata", message.Header)
+			}
+			if !strings.Contains(string(message.Data), `"pipelineRunName":"build-run"`) {
+				t.Errorf("Deliver() data = %s, want the PipelineRun data", message.Data)
+			}
+			if config.URL != tt.subject.URL || config.Stream != tt.subject.Stream || len(config.Options) != tt.wantOptions {
+				t.Errorf("Deliver() config = %+v, want the URL, the stream and %d options", config, tt.wantOptions)
+			}
+		})
+	}
+}
+
+func TestNATSSink_Subscribed(t *testing.T) {
+	tests := []struct {
+		name   string
+		phases []obsv1.Phase
+		phase  obsv1.Phase
+		want   bool
+	}{
+		{
+			name:  "Test with the default phases",
+			phase: obsv1.PhaseFinished,
+			want:  true,
+		},
+		{
+			name:   "Test with a phase not subscribed",
+			phases: []obsv1.Phase{obsv1.PhaseStarted},
+			phase:  obsv1.PhaseFinished,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := &NATSSink{Subject: obsv1.NATSSubject{Phases: tt.phases}}
+			if got := sink.Subscribed(tt.phase); got != tt.want {
+				t.Errorf("Subscribed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package sinks
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"github.com/kcloutie/tekton-observer/internal/tektonobserver"
+	"github.com/kcloutie/tekton-observer/pkg/metrics"
+	"github.com/kcloutie/tekton-observer/pkg/otlp"
+	"github.com/kcloutie/tekton-observer/pkg/tekton"
+	"github.com/kcloutie/tekton-observer/pkg/throttle"
+	tknv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// OTLPExporterFactory creates the exporter of the spans to an OTLP receiver
+type OTLPExporterFactory func(ctx context.Context, config obsv1.OTLPTraceExporter, headers map[string]string) (sdktrace.SpanExporter, error)
+
+// TaskRunLister returns the TaskRuns of a PipelineRun
+type TaskRunLister func(ctx context.Context, data *tekton.PipelineRunData) ([]tknv1.TaskRun, error)
+
+// OTLPSink exports the finished PipelineRuns as traces to an OTLP receiver. It only subscribes to the finished phase,
+// the trace of a PipelineRun is complete once it finished.
+type OTLPSink struct {
+	Exporter obsv1.OTLPTraceExporter
+	// Namespace is the namespace of the observation, the headers Secret is read from it
+	Namespace   string
+	NewExporter OTLPExporterFactory
+	TaskRuns    TaskRunLister
+	// Secrets reads the headers Secret
+	Secrets client.Reader
+}
+
+var _ Sink = &OTLPSink{}
+
+func (s *OTLPSink) Name() string {
+	return fmt.Sprintf("otlp/%s", s.Exporter.Endpoint)
+}
+
+func (s *OTLPSink) Type() obsv1.SinkType {
+	return obsv1.SinkTypeOTLP
+}
+
+func (s *OTLPSink) Subscribed(phase obsv1.Phase) bool {
+	return phase == obsv1.PhaseFinished
+}
+
+func (s *OTLPSink) Digest() *obsv1.DigestPolicy {
+	return nil
+}
+
+// Deliver exports the spans of the PipelineRun and returns its trace ID
+func (s *OTLPSink) Deliver(ctx context.Context, event Event) (string, error) {
+	data := event.Data
+	if data == nil {
+		return "", nil
+	}
+	headers, err := s.headers(ctx)
+	if err != nil {
+		return "", err
+	}
+	taskRuns, err := s.TaskRuns(ctx, data)
+	if err != nil {
+		return "", err
+	}
+	res := otlp.Resource(s.Exporter.ServiceName, tektonobserver.ControllerConfiguration.GetClusterName(), data.Namespace)
+	spans := otlp.Spans(ctx, data, taskRuns, res)
+
+	exporter, err := s.NewExporter(ctx, s.Exporter, headers)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = exporter.Shutdown(ctx)
+	}()
+
+	start := time.Now()
+	err = exporter.ExportSpans(ctx, spans)
+	metrics.OTLPRequestTimeHistogram.WithLabelValues("traces/export", string(s.protocol()), metrics.GetStatusCode(err)).Observe(time.Since(start).Seconds())
+	if err != nil {
+		metrics.OTLPExportFailedTotal.Inc()
+		return "", throttle.FromGRPC(fmt.Errorf("failed to export the trace of the PipelineRun '%s' to the OTLP endpoint '%s' - %w", data.PipelineRunName, s.Exporter.Endpoint, err))
+	}
+	metrics.OTLPTracesExportedTotal.Inc()
+	// The root span ends last
+	return spans[len(spans)-1].SpanContext().TraceID().String(), nil
+}
+
+func (s *OTLPSink) protocol() obsv1.OTLPProtocol {
+	if s.Exporter.Protocol == "" {
+		return obsv1.OTLPProtocolGRPC
+	}
+	return s.Exporter.Protocol
+}
+
+// headers returns the headers of the exports, the keys of the headers Secret take precedence over the headers of the
+// exporter
+func (s *OTLPSink) headers(ctx context.Context) (map[string]string, error) {
+	headers := map[string]string{}
+	for k, v := range s.Exporter.Headers {
+		headers[k] = v
+	}
+	if s.Exporter.HeadersSecret == "" {
+		return headers, nil
+	}
+	secret := &corev1.Secret{}
+	if err := s.Secrets.Get(ctx, client.ObjectKey{Namespace: s.Namespace, Name: s.Exporter.HeadersSecret}, secret); err != nil {
+		return nil, fmt.Errorf("failed to get the headers secret '%s' of the OTLP endpoint '%s' - %w", s.Exporter.HeadersSecret, s.Exporter.Endpoint, err)
+	}
+	for k, v := range secret.Data {
+		headers[k] = string(v)
+	}
+	return headers, nil
+}
@@ -0,0 +1,241 @@
+package sinks
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"github.com/kcloutie/tekton-observer/pkg/otlp"
+	"github.com/kcloutie/tekton-observer/pkg/tekton"
+	"github.com/kcloutie/tekton-observer/test/utils"
+	"github.com/tektoncd/pipeline/pkg/apis/pipeline"
+	tknv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// testCollector is an in-memory OTLP receiver recording the exported spans and the headers of the exports
+type testCollector struct {
+	collectortrace.UnimplementedTraceServiceServer
+	mu       sync.Mutex
+	err      error
+	requests []*collectortrace.ExportTraceServiceRequest
+	headers  []map[string]string
+}
+
+func (c *testCollector) Export(ctx context.Context, request *collectortrace.ExportTraceServiceRequest) (*collectortrace.ExportTraceServiceResponse, error) {
+	headers := map[string]string{}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for k, v := range md {
+		headers[k] = strings.Join(v, ",")
+	}
+	return c.record(request, headers)
+}
+
+func (c *testCollector) record(request *collectortrace.ExportTraceServiceRequest, headers map[string]string) (*collectortrace.ExportTraceServiceResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return nil, c.err
+	}
+	c.requests = append(c.requests, request)
+	c.headers = append(c.headers, headers)
+	return &collectortrace.ExportTraceServiceResponse{}, nil
+}
+
+// ServeHTTP receives the exports of the http protocol
+func (c *testCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	request := &collectortrace.ExportTraceServiceRequest{}
+	if err := proto.Unmarshal(body, request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	headers := map[string]string{}
+	for k := range r.Header {
+		headers[strings.ToLower(k)] = r.Header.Get(k)
+	}
+	response, err := c.record(request, headers)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	raw, _ := proto.Marshal(response)
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	_, _ = w.Write(raw)
+}
+
+func (c *testCollector) spans() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	count := 0
+	for _, request := range c.requests {
+		for _, resourceSpans := range request.ResourceSpans {
+			for _, scopeSpans := range resourceSpans.ScopeSpans {
+				count += len(scopeSpans.Spans)
+			}
+		}
+	}
+	return count
+}
+
+// startCollector starts the collector for the protocol and returns its endpoint
+func startCollector(t *testing.T, collector *testCollector, protocol obsv1.OTLPProtocol) string {
+	if protocol == obsv1.OTLPProtocolHTTP {
+		server := httptest.NewServer(collector)
+		t.Cleanup(server.Close)
+		return strings.TrimPrefix(server.URL, "http://")
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	collectortrace.RegisterTraceServiceServer(server, collector)
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(server.Stop)
+	return listener.Addr().String()
+}
+
+func TestOTLPSink_Deliver(t *testing.T) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "otlp-headers"},
+		Data:       map[string][]byte{"x-api-key": []byte("secret-key")},
+	}
+	tests := []struct {
+		name          string
+		protocol      obsv1.OTLPProtocol
+		headersSecret string
+		collectorErr  error
+		wantErr       string
+		wantSpans     int
+		wantHeaders   map[string]string
+	}{
+		{
+			name:          "Test with the grpc protocol",
+			protocol:      obsv1.OTLPProtocolGRPC,
+			headersSecret: "otlp-headers",
+			wantSpans:     2,
+			wantHeaders:   map[string]string{"x-tenant": "team-a", "x-api-key": "secret-key"},
+		},
+		{
+			name:        "Test with the http protocol",
+			protocol:    obsv1.OTLPProtocolHTTP,
+			wantSpans:   2,
+			wantHeaders: map[string]string{"x-tenant": "team-a"},
+		},
+		{
+			name:         "Test with an unavailable receiver",
+			protocol:     obsv1.OTLPProtocolGRPC,
+			collectorErr: status.Error(codes.Unavailable, "unavailable"),
+			wantErr:      "failed to export the trace of the PipelineRun 'build-run'",
+		},
+		{
+			name:          "Test with a missing headers secret",
+			protocol:      obsv1.OTLPProtocolGRPC,
+			headersSecret: "missing",
+			wantErr:       "failed to get the headers secret 'missing'",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			collector := &testCollector{err: tt.collectorErr}
+			endpoint := startCollector(t, collector, tt.protocol)
+
+			pipelineRun := utils.NewPipelineRun("test-namespace", "build-run", nil, true)
+			pipelineRun.UID = "5b1c7d4e-1d1b-4c39-9f5e-8d4c0f0a7a11"
+			data := &tekton.PipelineRunData{
+				RawPipelineRun:  pipelineRun,
+				Namespace:       pipelineRun.Namespace,
+				PipelineRunName: pipelineRun.Name,
+				PipelineName:    "build",
+				Status:          tekton.StatusSucceeded,
+			}
+			sink := &OTLPSink{
+				Exporter: obsv1.OTLPTraceExporter{
+					Endpoint:      endpoint,
+					Protocol:      tt.protocol,
+					Insecure:      true,
+					Headers:       map[string]string{"x-tenant": "team-a"},
+					HeadersSecret: tt.headersSecret,
+				},
+				Namespace:   "test-namespace",
+				NewExporter: otlp.NewSpanExporter,
+				TaskRuns: func(ctx context.Context, data *tekton.PipelineRunData) ([]tknv1.TaskRun, error) {
+					return []tknv1.TaskRun{{ObjectMeta: metav1.ObjectMeta{
+						Name:   "build-run-clone",
+						Labels: map[string]string{pipeline.PipelineTaskLabelKey: "clone"},
+					}}}, nil
+				},
+				Secrets: utils.NewFakeClient(secret),
+			}
+
+			ref, err := sink.Deliver(context.Background(), Event{Phase: obsv1.PhaseFinished, Data: data})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Deliver() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Deliver() unexpected error = %v", err)
+			}
+			if want := otlp.TraceID(string(pipelineRun.UID)).String(); ref != want {
+				t.Errorf("Deliver() ref = %v, want the trace ID %v", ref, want)
+			}
+			if got := collector.spans(); got != tt.wantSpans {
+				t.Errorf("collector received %d spans, want %d", got, tt.wantSpans)
+			}
+			for k, v := range tt.wantHeaders {
+				if len(collector.headers) == 0 || collector.headers[0][k] != v {
+					t.Errorf("collector received the headers %v, want %s=%s", collector.headers, k, v)
+				}
+			}
+		})
+	}
+}
+
+func TestOTLPSink_Subscribed(t *testing.T) {
+	tests := []struct {
+		name  string
+		phase obsv1.Phase
+		want  bool
+	}{
+		{
+			name:  "Test with the finished phase",
+			phase: obsv1.PhaseFinished,
+			want:  true,
+		},
+		{
+			name:  "Test with the started phase",
+			phase: obsv1.PhaseStarted,
+			want:  false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := &OTLPSink{}
+			if got := sink.Subscribed(tt.phase); got != tt.want {
+				t.Errorf("Subscribed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
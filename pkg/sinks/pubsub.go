@@ -0,0 +1,179 @@
+package sinks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"github.com/kcloutie/tekton-observer/internal/digest"
+	"github.com/kcloutie/tekton-observer/internal/dora"
+	"github.com/kcloutie/tekton-observer/internal/tektonobserver"
+	"github.com/kcloutie/tekton-observer/pkg/delivery"
+	"github.com/kcloutie/tekton-observer/pkg/metrics"
+	"github.com/kcloutie/tekton-observer/pkg/throttle"
+)
+
+const (
+	// SchemaVersionAttribute is the attribute, or the header, carrying the version of the payload of the messages
+	SchemaVersionAttribute = "schemaVersion"
+	// SchemaVersion is the version of the payload of the Pub/Sub, Kafka and NATS messages, it changes when a field is
+	// removed or changes meaning
+	SchemaVersion = "v1"
+)
+
+// PubSubPublisher publishes a message to a Pub/Sub topic and returns the ID of the published message
+type PubSubPublisher func(ctx context.Context, projectID, topicID string, data []byte, attributes map[string]string, metadata delivery.Metadata) (string, error)
+
+// PubSubSink publishes the PipelineRun data to a Pub/Sub topic. Pub/Sub messages cannot be updated, every phase is
+// published as a new message.
+type PubSubSink struct {
+	Topic     obsv1.PubSubTopic
+	Publisher PubSubPublisher
+}
+
+var _ Sink = &PubSubSink{}
+
+func (s *PubSubSink) Name() string {
+	return fmt.Sprintf("pubsub/%s/%s", s.Topic.PubSubProjectID, s.Topic.PubSubTopicID)
+}
+
+func (s *PubSubSink) Type() obsv1.SinkType {
+	return obsv1.SinkTypePubSub
+}
+
+func (s *PubSubSink) Subscribed(phase obsv1.Phase) bool {
+	return Subscribes(s.Topic.Phases, phase)
+}
+
+func (s *PubSubSink) Digest() *obsv1.DigestPolicy {
+	return s.Topic.Digest
+}
+
+func (s *PubSubSink) Deliver(ctx context.Context, event Event) (string, error) {
+	payload, attributes, err := message(event)
+	if err != nil {
+		return "", err
+	}
+
+	start := time.Now()
+	metadata := event.Delivery
+	metadata.OrderingKey = s.orderingKey(event)
+	id, err := s.Publisher(ctx, s.Topic.PubSubProjectID, s.Topic.PubSubTopicID, payload, attributes, metadata)
+	metrics.GoogleRequestTimeHistogram.WithLabelValues("pubsub/publish", "POST", metrics.GetStatusCode(err)).Observe(time.Since(start).Seconds())
+	if err != nil {
+		metrics.PubSubFailedTotal.Inc()
+		return "", throttle.FromGRPC(fmt.Errorf("failed to publish to the topic '%s' in the project '%s' - %w", s.Topic.PubSubTopicID, s.Topic.PubSubProjectID, err))
+	}
+	metrics.PubSubSentTotal.Inc()
+	return id, nil
+}
+
+// message returns the payload and the attributes of the message sent for the event, they are the same for the
+// Pub/Sub, the Kafka and the NATS messages and the attributes carry the version of the payload
+func message(event Event) ([]byte, map[string]string, error) {
+	var payload []byte
+	var attributes map[string]string
+	var err error
+	switch {
+	case event.Digest != nil:
+		if payload, err = json.Marshal(event.Digest); err != nil {
+			return nil, nil, fmt.Errorf("failed to marshal the digest - %w", err)
+		}
+		attributes = GetDigestAttributes(event.Digest)
+	case event.Dora != nil:
+		if payload, err = json.Marshal(event.Dora); err != nil {
+			return nil, nil, fmt.Errorf("failed to marshal the DORA summary - %w", err)
+		}
+		attributes = GetDoraAttributes(event.Dora)
+	default:
+		if payload, err = json.Marshal(event.Data); err != nil {
+			return nil, nil, fmt.Errorf("failed to marshal the PipelineRun data - %w", err)
+		}
+		attributes = GetPubSubAttributes(event)
+	}
+	attributes[SchemaVersionAttribute] = SchemaVersion
+	return payload, attributes, nil
+}
+
+// orderingKey returns the ordering key of the message published for the event, the digests and the DORA summaries
+// are published without ordering key
+func (s *PubSubSink) orderingKey(event Event) string {
+	data := event.Data
+	if data == nil {
+		return ""
+	}
+	switch s.Topic.OrderingKey {
+	case obsv1.OrderingKeyPipelineRun:
+		if data.RawPipelineRun != nil {
+			return string(data.RawPipelineRun.UID)
+		}
+		return fmt.Sprintf("%s/%s", data.Namespace, data.PipelineRunName)
+	case obsv1.OrderingKeyPipeline:
+		return fmt.Sprintf("%s/%s", data.Namespace, data.PipelineName)
+	case obsv1.OrderingKeyNamespace:
+		return data.Namespace
+	}
+	return ""
+}
+
+// GetPubSubAttributes returns the attributes of the message published for the event
+func GetPubSubAttributes(event Event) map[string]string {
+	data := event.Data
+	attributes := map[string]string{}
+	for k, v := range data.Attributes {
+		attributes[k] = v
+	}
+	attributes["clusterName"] = tektonobserver.ControllerConfiguration.GetClusterName()
+	attributes["namespace"] = data.Namespace
+	attributes["pipelineRunName"] = data.PipelineRunName
+	attributes["pipelineName"] = data.PipelineName
+	attributes["status"] = data.Status
+	if data.Reason != "" {
+		attributes["reason"] = data.Reason
+	}
+	if data.RawPipelineRun != nil {
+		attributes["pipelineRunUid"] = string(data.RawPipelineRun.UID)
+	}
+	if data.Streak != nil {
+		attributes["streakTransition"] = string(data.Streak.Transition)
+		attributes["consecutiveFailures"] = fmt.Sprintf("%d", data.Streak.ConsecutiveFailures)
+	}
+	if len(data.FlakyTasks) > 0 {
+		flakyTasks := []string{}
+		for _, task := range data.FlakyTasks {
+			flakyTasks = append(flakyTasks, task.Task)
+		}
+		attributes["flakyTasks"] = strings.Join(flakyTasks, ",")
+	}
+	attributes["phase"] = string(event.Phase)
+	if event.TaskName != "" {
+		attributes["taskName"] = event.TaskName
+	}
+	return attributes
+}
+
+// GetDigestAttributes returns the attributes of the message published for a digest
+func GetDigestAttributes(summary *digest.Summary) map[string]string {
+	return map[string]string{
+		"clusterName": tektonobserver.ControllerConfiguration.GetClusterName(),
+		"phase":       "digest",
+		"digestGroup": summary.Group,
+		"groupBy":     string(summary.GroupBy),
+		"total":       fmt.Sprintf("%d", summary.Total),
+		"succeeded":   fmt.Sprintf("%d", summary.Succeeded),
+		"failed":      fmt.Sprintf("%d", summary.Failed),
+	}
+}
+
+// GetDoraAttributes returns the attributes of the message published for a DORA summary
+func GetDoraAttributes(summary *dora.Summary) map[string]string {
+	return map[string]string{
+		"clusterName":  tektonobserver.ControllerConfiguration.GetClusterName(),
+		"phase":        string(obsv1.PhaseDoraSummary),
+		"namespace":    summary.Namespace,
+		"environments": fmt.Sprintf("%d", len(summary.Environments)),
+	}
+}
@@ -0,0 +1,48 @@
+package sinks
+
+import (
+	"testing"
+
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"github.com/kcloutie/tekton-observer/internal/digest"
+	"github.com/kcloutie/tekton-observer/internal/dora"
+	"github.com/kcloutie/tekton-observer/pkg/tekton"
+)
+
+func Test_message(t *testing.T) {
+	tests := []struct {
+		name      string
+		event     Event
+		wantPhase string
+	}{
+		{
+			name:      "Test with a PipelineRun",
+			event:     Event{Phase: obsv1.PhaseFinished, Data: &tekton.PipelineRunData{Namespace: "test-namespace", PipelineRunName: "build-run"}},
+			wantPhase: "finished",
+		},
+		{
+			name:      "Test with a digest",
+			event:     Event{Phase: obsv1.PhaseFinished, Digest: &digest.Summary{Group: "nightly"}},
+			wantPhase: "digest",
+		},
+		{
+			name:      "Test with a DORA summary",
+			event:     Event{Phase: obsv1.PhaseDoraSummary, Dora: &dora.Summary{Namespace: "test-namespace"}},
+			wantPhase: string(obsv1.PhaseDoraSummary),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload, attributes, err := message(tt.event)
+			if err != nil {
+				t.Fatalf("message() unexpected error = %v", err)
+			}
+			if len(payload) == 0 {
+				t.Errorf("message() returned an empty payload")
+			}
+			if attributes[SchemaVersionAttribute] != SchemaVersion || attributes["phase"] != tt.wantPhase {
+				t.Errorf("message() attributes = %v, want the schema version and the phase %s", attributes, tt.wantPhase)
+			}
+		})
+	}
+}
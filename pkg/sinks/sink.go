@@ -0,0 +1,62 @@
+package sinks
+
+import (
+	"context"
+
+	obsv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
+	"github.com/kcloutie/tekton-observer/internal/digest"
+	"github.com/kcloutie/tekton-observer/internal/dora"
+	"github.com/kcloutie/tekton-observer/pkg/delivery"
+	"github.com/kcloutie/tekton-observer/pkg/tekton"
+)
+
+// DefaultPhases are the phases a sink subscribes to when it does not list any
+var DefaultPhases = []obsv1.Phase{obsv1.PhaseFinished}
+
+// Subscribes returns true when the phase is part of the subscribed phases, DefaultPhases are used when none are listed
+func Subscribes(subscribed []obsv1.Phase, phase obsv1.Phase) bool {
+	if len(subscribed) == 0 {
+		subscribed = DefaultPhases
+	}
+	for _, p := range subscribed {
+		if p == phase {
+			return true
+		}
+	}
+	return false
+}
+
+// Event is delivered to the sinks for every phase of a PipelineRun
+type Event struct {
+	Phase obsv1.Phase
+	Data  *tekton.PipelineRunData
+	// TaskName is the name of the pipeline task that finished, it is only set for PhaseTaskCompleted
+	TaskName string
+	// Ref is the reference returned by the sink when it delivered the previous phase of the PipelineRun. Sinks
+	// supporting it update the message they sent in place instead of sending a new one.
+	Ref string
+	// ReplayKey is the idempotency key of the replay the event is delivered for, it is empty for the regular deliveries
+	ReplayKey string
+	// Delivery identifies the delivery of the event to the sink, the sinks pass the delivery ID and the attempt on
+	// to the consumers so they can deduplicate the messages
+	Delivery delivery.Metadata
+	// Digest is the summary of the PipelineRuns buffered by a sink in digest mode, Data is not set when it is set
+	Digest *digest.Summary
+	// Dora is the summary of the DORA metrics of the namespace delivered for PhaseDoraSummary, Data is not set when
+	// it is set
+	Dora *dora.Summary
+}
+
+// Sink delivers the events of the PipelineRuns to an external system
+type Sink interface {
+	// Name identifies the sink, it is unique amongst the sinks of a TektonObservation
+	Name() string
+	// Type is the type of the sink, the sinks of a type share their rate limit
+	Type() obsv1.SinkType
+	// Subscribed returns true when the sink wants the events of the phase
+	Subscribed(phase obsv1.Phase) bool
+	// Digest returns the digest policy of the sink, the finished PipelineRuns are delivered one by one when it is nil
+	Digest() *obsv1.DigestPolicy
+	// Deliver sends the event and returns a reference to what was sent, which is passed back with the next phases
+	Deliver(ctx context.Context, event Event) (string, error)
+}
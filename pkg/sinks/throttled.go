@@ -0,0 +1,34 @@
+package sinks
+
+import (
+	"context"
+	"time"
+
+	"github.com/kcloutie/tekton-observer/pkg/throttle"
+)
+
+// ThrottledSink rate limits the deliveries to a sink and stops calling it while its circuit breaker is open. The
+// Retry-After of a failed delivery pauses both the sink and the other sinks of its type.
+type ThrottledSink struct {
+	Sink
+	Limiter *throttle.Limiter
+	Breaker *throttle.Breaker
+}
+
+var _ Sink = &ThrottledSink{}
+
+func (s *ThrottledSink) Deliver(ctx context.Context, event Event) (string, error) {
+	if err := s.Breaker.Allow(time.Now()); err != nil {
+		return "", err
+	}
+	if err := s.Limiter.Wait(ctx); err != nil {
+		return "", err
+	}
+	ref, err := s.Sink.Deliver(ctx, event)
+	now := time.Now()
+	if after, found := throttle.RetryAfter(err); found {
+		s.Limiter.PauseUntil(now.Add(after))
+	}
+	s.Breaker.Record(now, err)
+	return ref, err
+}
@@ -11,10 +11,18 @@ import (
 	tknv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
 	"go.uber.org/zap/zapcore"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/pkg/apis"
 )
 
 const (
 	PacLabelPrefix = "pipelinesascode.tekton.dev"
+
+	StatusSucceeded = "Succeeded"
+	StatusFailed    = "Failed"
+	StatusRunning   = "Running"
+	// StatusAborted is the status of the PipelineRuns deleted before they finished
+	StatusAborted = "Aborted"
+	ReasonDeleted = "Deleted"
 )
 
 type PipelineRunData struct {
@@ -28,21 +36,72 @@ type PipelineRunData struct {
 	CompletionTime  *metav1.Time       `json:"completionTime,omitempty" yaml:"completionTime,omitempty"`
 	TotalTime       *string            `json:"totalTime,omitempty" yaml:"totalTime,omitempty"`
 	Attributes      map[string]string  `json:"attributes,omitempty" yaml:"attributes,omitempty"`
+	Status          string             `json:"status,omitempty" yaml:"status,omitempty"`
+	Reason          string             `json:"reason,omitempty" yaml:"reason,omitempty"`
+	// Streak is the outcome history of the pipeline on the branch of the PipelineRun, it is only set for the
+	// PipelineRuns that succeeded or failed
+	Streak *Streak `json:"streak,omitempty" yaml:"streak,omitempty"`
+	// FlakyTasks are the tasks of the pipeline whose flakiness ratio reached the threshold of the observation
+	FlakyTasks []FlakyTask `json:"flakyTasks,omitempty" yaml:"flakyTasks,omitempty"`
 	// PipelineStatus     string             `json:"pipelineStatus,omitempty" yaml:"pipelineStatus,omitempty"`
 }
 
+// Transition describes how the outcome of a PipelineRun changed the outcome history of its pipeline
+type Transition string
+
+const (
+	// TransitionFirstFailure is the first failure after a success, or the first run of the pipeline failed
+	TransitionFirstFailure Transition = "first-failure"
+	// TransitionStillFailing is a failure following a failure
+	TransitionStillFailing Transition = "still-failing"
+	// TransitionRecovered is a success following a failure
+	TransitionRecovered Transition = "recovered"
+	// TransitionStillPassing is a success following a success, or the first run of the pipeline succeeded
+	TransitionStillPassing Transition = "still-passing"
+)
+
+// Streak is the outcome history of a pipeline on a branch
+type Streak struct {
+	Branch     string     `json:"branch,omitempty" yaml:"branch,omitempty"`
+	Transition Transition `json:"transition" yaml:"transition"`
+	// ConsecutiveFailures is the number of failed PipelineRuns in a row, including this PipelineRun
+	ConsecutiveFailures int `json:"consecutiveFailures" yaml:"consecutiveFailures"`
+	// FailingSince is the completion time of the first failed PipelineRun of the streak
+	FailingSince *metav1.Time `json:"failingSince,omitempty" yaml:"failingSince,omitempty"`
+	// PreviousStatus is the status of the previous PipelineRun, it is empty for the first PipelineRun
+	PreviousStatus string `json:"previousStatus,omitempty" yaml:"previousStatus,omitempty"`
+}
+
+// FlakyTask is a task of the pipeline that failed and then passed on retry or when the same commit ran again
+type FlakyTask struct {
+	Task string `json:"task" yaml:"task"`
+	// Ratio is the share of the runs of the window where the task was flaky
+	Ratio float64 `json:"ratio" yaml:"ratio"`
+	Flaky int     `json:"flaky" yaml:"flaky"`
+	Runs  int     `json:"runs" yaml:"runs"`
+	// Message describes the flakiness, such as "this task is flaky: 18% over last 50 runs"
+	Message string `json:"message" yaml:"message"`
+}
+
 func GetPipelineRunData(ctx context.Context, pipelineRun *tknv1.PipelineRun, eventEmitter *events.EventEmitter) (*PipelineRunData, error) {
 	variables := GetPipelineVariables(ctx, pipelineRun)
 	pacLabels := GetLabelsWithPrefix(pipelineRun, PacLabelPrefix)
+	totalTime := GetTotalTime(pipelineRun.Status.StartTime, pipelineRun.Status.CompletionTime)
+	status, reason := GetPipelineRunOutcome(pipelineRun)
 
 	return &PipelineRunData{
 		RawPipelineRun:  pipelineRun,
 		VariableValues:  variables,
 		PacLabels:       pacLabels,
+		Namespace:       pipelineRun.Namespace,
 		PipelineRunName: pipelineRun.Name,
 		PipelineName:    GetPipelineName(pipelineRun, pacLabels),
 		StartTime:       pipelineRun.Status.StartTime,
 		CompletionTime:  pipelineRun.Status.CompletionTime,
+		TotalTime:       &totalTime,
+		Attributes:      GetAttributes(ctx, pipelineRun, eventEmitter),
+		Status:          status,
+		Reason:          reason,
 	}, nil
 
 }
@@ -77,6 +136,25 @@ func GetLabelsWithPrefix(pipelineRun *tknv1.PipelineRun, prefix string) map[stri
 	return labels
 }
 
+// GetPipelineRunOutcome returns the status and the reason of the PipelineRun. A PipelineRun deleted before it
+// finished is reported as Aborted with the reason Deleted.
+func GetPipelineRunOutcome(pipelineRun *tknv1.PipelineRun) (string, string) {
+	condition := pipelineRun.Status.GetCondition(apis.ConditionSucceeded)
+	if !pipelineRun.IsDone() {
+		if pipelineRun.DeletionTimestamp != nil {
+			return StatusAborted, ReasonDeleted
+		}
+		if condition != nil {
+			return StatusRunning, condition.Reason
+		}
+		return StatusRunning, ""
+	}
+	if condition.IsTrue() {
+		return StatusSucceeded, condition.Reason
+	}
+	return StatusFailed, condition.Reason
+}
+
 func GetTotalTime(startTime *metav1.Time, completionTime *metav1.Time) string {
 	totalTime := "Unknown"
 	if startTime != nil && completionTime != nil && !startTime.IsZero() && !completionTime.IsZero() {
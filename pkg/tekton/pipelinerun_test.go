@@ -317,3 +317,62 @@ func TestGetAttributes(t *testing.T) {
 		})
 	}
 }
+
+func TestGetPipelineRunOutcome(t *testing.T) {
+	now := metav1.Now()
+	tests := []struct {
+		name        string
+		pipelineRun *tknv1.PipelineRun
+		wantStatus  string
+		wantReason  string
+	}{
+		{
+			name:        "Test with a running PipelineRun",
+			pipelineRun: utils.NewPipelineRun("ns", "name", nil, false),
+			wantStatus:  StatusRunning,
+		},
+		{
+			name:        "Test with a succeeded PipelineRun",
+			pipelineRun: utils.NewPipelineRun("ns", "name", nil, true),
+			wantStatus:  StatusSucceeded,
+		},
+		{
+			name: "Test with a cancelled PipelineRun",
+			pipelineRun: func() *tknv1.PipelineRun {
+				pr := utils.NewPipelineRun("ns", "name", nil, true)
+				pr.Status.Conditions[0].Status = "False"
+				pr.Status.Conditions[0].Reason = "Cancelled"
+				return pr
+			}(),
+			wantStatus: StatusFailed,
+			wantReason: "Cancelled",
+		},
+		{
+			name: "Test with a PipelineRun deleted while running",
+			pipelineRun: func() *tknv1.PipelineRun {
+				pr := utils.NewPipelineRun("ns", "name", nil, false)
+				pr.DeletionTimestamp = &now
+				return pr
+			}(),
+			wantStatus: StatusAborted,
+			wantReason: ReasonDeleted,
+		},
+		{
+			name: "Test with a finished PipelineRun deleted",
+			pipelineRun: func() *tknv1.PipelineRun {
+				pr := utils.NewPipelineRun("ns", "name", nil, true)
+				pr.DeletionTimestamp = &now
+				return pr
+			}(),
+			wantStatus: StatusSucceeded,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, reason := GetPipelineRunOutcome(tt.pipelineRun)
+			if status != tt.wantStatus || reason != tt.wantReason {
+				t.Errorf("GetPipelineRunOutcome() = %v, %v, want %v, %v", status, reason, tt.wantStatus, tt.wantReason)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package throttle
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	// ErrRateLimited is returned when the token bucket of the sink type is empty
+	ErrRateLimited = errors.New("rate limit exceeded")
+	// ErrCircuitOpen is returned while the circuit breaker of the sink is open
+	ErrCircuitOpen = errors.New("circuit breaker open")
+)
+
+// RetryAfterError is returned when a delivery must not be attempted again before After
+type RetryAfterError struct {
+	After time.Duration
+	Err   error
+}
+
+func (e *RetryAfterError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", e.Err.Error(), e.After)
+}
+
+func (e *RetryAfterError) Unwrap() error {
+	return e.Err
+}
+
+// RetryAfter returns how long to wait before delivering again when the error is a RetryAfterError
+func RetryAfter(err error) (time.Duration, bool) {
+	var retryAfterErr *RetryAfterError
+	if errors.As(err, &retryAfterErr) {
+		return retryAfterErr.After, true
+	}
+	return 0, false
+}
+
+// FromGRPC wraps err in a RetryAfterError when it carries a gRPC RESOURCE_EXHAUSTED status with a retry delay
+func FromGRPC(err error) error {
+	s, ok := status.FromError(err)
+	if !ok || s.Code() != codes.ResourceExhausted {
+		return err
+	}
+	for _, detail := range s.Details() {
+		if info, ok := detail.(*errdetails.RetryInfo); ok && info.GetRetryDelay() != nil {
+			return &RetryAfterError{After: nonNegative(info.GetRetryDelay().AsDuration()), Err: err}
+		}
+	}
+	return err
+}
+
+func nonNegative(d time.Duration) time.Duration {
+	if d < 0 {
+		return 0
+	}
+	return d
+}
@@ -0,0 +1,48 @@
+package throttle
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func TestFromGRPC(t *testing.T) {
+	withRetryInfo, err := status.New(codes.ResourceExhausted, "quota exceeded").WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(10 * time.Second)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		err       error
+		want      time.Duration
+		wantFound bool
+	}{
+		{
+			name:      "Test with a retry delay",
+			err:       withRetryInfo.Err(),
+			want:      10 * time.Second,
+			wantFound: true,
+		},
+		{
+			name: "Test without a retry delay",
+			err:  status.Error(codes.ResourceExhausted, "quota exceeded"),
+		},
+		{
+			name: "Test with another error",
+			err:  errors.New("failed"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := RetryAfter(FromGRPC(tt.err))
+			if found != tt.wantFound || got != tt.want {
+				t.Errorf("FromGRPC() retry after = %v, %v, want %v, %v", got, found, tt.want, tt.wantFound)
+			}
+		})
+	}
+}
@@ -0,0 +1,224 @@
+package throttle
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/kcloutie/tekton-observer/pkg/metrics"
+	"golang.org/x/time/rate"
+)
+
+// Limiter is the token bucket shared by the sinks of a type. It is also paused by the Retry-After responses of the
+// sinks.
+type Limiter struct {
+	namespace string
+	sinkType  string
+
+	mu           sync.Mutex
+	limiter      *rate.Limiter
+	maxWait      time.Duration
+	blockedUntil time.Time
+}
+
+// Wait takes a token from the bucket, waiting for it when it is available within the maximum wait. A RetryAfterError
+// is returned when the bucket is empty for longer or the limiter is paused.
+func (l *Limiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	if now.Before(l.blockedUntil) {
+		after := l.blockedUntil.Sub(now)
+		l.mu.Unlock()
+		metrics.SinkThrottledTotal.WithLabelValues(l.namespace, l.sinkType).Inc()
+		return &RetryAfterError{After: after, Err: fmt.Errorf("the %s sinks are paused - %w", l.sinkType, ErrRateLimited)}
+	}
+	reservation := l.limiter.ReserveN(now, 1)
+	delay := reservation.DelayFrom(now)
+	if !reservation.OK() || delay > l.maxWait {
+		reservation.CancelAt(now)
+		l.mu.Unlock()
+		metrics.SinkThrottledTotal.WithLabelValues(l.namespace, l.sinkType).Inc()
+		return &RetryAfterError{After: delay, Err: fmt.Errorf("the bucket of the %s sinks is empty - %w", l.sinkType, ErrRateLimited)}
+	}
+	l.recordTokens(now)
+	l.mu.Unlock()
+
+	if delay == 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		reservation.Cancel()
+		return ctx.Err()
+	}
+}
+
+// PauseUntil stops handing out tokens until the time
+func (l *Limiter) PauseUntil(until time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until.After(l.blockedUntil) {
+		l.blockedUntil = until
+	}
+}
+
+// configure updates the limits without resetting the bucket, the caller holds the lock
+func (l *Limiter) configure(limit rate.Limit, burst int, maxWait time.Duration) {
+	if l.limiter == nil {
+		l.limiter = rate.NewLimiter(limit, burst)
+	}
+	if l.limiter.Limit() != limit {
+		l.limiter.SetLimit(limit)
+	}
+	if l.limiter.Burst() != burst {
+		l.limiter.SetBurst(burst)
+	}
+	l.maxWait = maxWait
+	l.recordTokens(time.Now())
+}
+
+// recordTokens exposes the tokens left in the bucket, the caller holds the lock
+func (l *Limiter) recordTokens(now time.Time) {
+	if l.limiter.Limit() == rate.Inf {
+		metrics.SinkRateLimitTokens.DeleteLabelValues(l.namespace, l.sinkType)
+		return
+	}
+	metrics.SinkRateLimitTokens.WithLabelValues(l.namespace, l.sinkType).Set(l.limiter.TokensAt(now))
+}
+
+// BreakerState is the state of a circuit breaker
+type BreakerState int
+
+const (
+	// BreakerClosed lets every delivery through
+	BreakerClosed BreakerState = iota
+	// BreakerOpen fails the deliveries without calling the sink until the cool-off period elapsed
+	BreakerOpen
+	// BreakerHalfOpen lets the deliveries through after the cool-off period, the first failure opens the circuit
+	// again and the first success closes it
+	BreakerHalfOpen
+)
+
+// Breaker is the circuit breaker of a sink
+type Breaker struct {
+	namespace string
+	sink      string
+
+	mu        sync.Mutex
+	threshold int
+	coolOff   time.Duration
+	state     BreakerState
+	failures  int
+	openUntil time.Time
+}
+
+// Allow returns a RetryAfterError while the circuit is open
+func (b *Breaker) Allow(now time.Time) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state != BreakerOpen {
+		return nil
+	}
+	if now.Before(b.openUntil) {
+		return &RetryAfterError{After: b.openUntil.Sub(now), Err: fmt.Errorf("the sink '%s' is failing - %w", b.sink, ErrCircuitOpen)}
+	}
+	b.setState(BreakerHalfOpen)
+	return nil
+}
+
+// Record updates the circuit with the result of a delivery. The circuit is opened for the cool-off period when the
+// failures reach the threshold, and until the requested time when the sink asked to retry later.
+func (b *Breaker) Record(now time.Time, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		b.failures = 0
+		b.setState(BreakerClosed)
+		return
+	}
+	b.failures++
+	if after, found := RetryAfter(err); found {
+		b.open(now.Add(after))
+	} else if b.threshold > 0 && (b.state == BreakerHalfOpen || b.failures >= b.threshold) {
+		b.open(now.Add(b.coolOff))
+	}
+	metrics.SinkCircuitBreakerFailures.WithLabelValues(b.namespace, b.sink).Set(float64(b.failures))
+}
+
+// State returns the state of the circuit
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// open opens the circuit until the time, the caller holds the lock
+func (b *Breaker) open(until time.Time) {
+	if b.state != BreakerOpen || until.After(b.openUntil) {
+		b.openUntil = until
+	}
+	b.setState(BreakerOpen)
+}
+
+// setState changes the state of the circuit, the caller holds the lock
+func (b *Breaker) setState(state BreakerState) {
+	b.state = state
+	metrics.SinkCircuitBreakerState.WithLabelValues(b.namespace, b.sink).Set(float64(state))
+	if state == BreakerClosed {
+		metrics.SinkCircuitBreakerFailures.WithLabelValues(b.namespace, b.sink).Set(0)
+	}
+}
+
+// Registry keeps the limiters and the circuit breakers of the sinks across the reconciles, the zero value is ready
+// to use
+type Registry struct {
+	mu       sync.Mutex
+	limiters map[string]*Limiter
+	breakers map[string]*Breaker
+}
+
+// Limiter returns the limiter of the sink type in the namespace, its limits are updated when they changed
+func (r *Registry) Limiter(namespace, sinkType string, limit rate.Limit, burst int, maxWait time.Duration) *Limiter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.limiters == nil {
+		r.limiters = map[string]*Limiter{}
+	}
+	key := namespace + "/" + sinkType
+	limiter, found := r.limiters[key]
+	if !found {
+		limiter = &Limiter{namespace: namespace, sinkType: sinkType}
+		r.limiters[key] = limiter
+	}
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	limiter.configure(limit, burst, maxWait)
+	return limiter
+}
+
+// Breaker returns the circuit breaker of the sink in the namespace. A threshold of 0 only opens the circuit when the
+// sink asked to retry later.
+func (r *Registry) Breaker(namespace, sink string, threshold int, coolOff time.Duration) *Breaker {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.breakers == nil {
+		r.breakers = map[string]*Breaker{}
+	}
+	key := namespace + "/" + sink
+	breaker, found := r.breakers[key]
+	if !found {
+		breaker = &Breaker{namespace: namespace, sink: sink}
+		r.breakers[key] = breaker
+		metrics.SinkCircuitBreakerState.WithLabelValues(namespace, sink).Set(float64(BreakerClosed))
+	}
+	breaker.mu.Lock()
+	defer breaker.mu.Unlock()
+	breaker.threshold = threshold
+	breaker.coolOff = coolOff
+	return breaker
+}
@@ -0,0 +1,147 @@
+package throttle
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestBreaker(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	failure := errors.New("failed")
+	tests := []struct {
+		name      string
+		threshold int
+		results   []error
+		at        time.Duration
+		wantState BreakerState
+		wantAllow bool
+	}{
+		{
+			name:      "Test with failures below the threshold",
+			threshold: 3,
+			results:   []error{failure, failure},
+			wantState: BreakerClosed,
+			wantAllow: true,
+		},
+		{
+			name:      "Test with failures reaching the threshold",
+			threshold: 3,
+			results:   []error{failure, failure, failure},
+			wantState: BreakerOpen,
+		},
+		{
+			name:      "Test with a success resetting the failures",
+			threshold: 2,
+			results:   []error{failure, nil, failure},
+			wantState: BreakerClosed,
+			wantAllow: true,
+		},
+		{
+			name:      "Test with an elapsed cool-off",
+			threshold: 1,
+			results:   []error{failure},
+			at:        2 * time.Minute,
+			wantState: BreakerHalfOpen,
+			wantAllow: true,
+		},
+		{
+			name:      "Test with a Retry-After and no threshold",
+			results:   []error{&RetryAfterError{After: time.Hour, Err: failure}},
+			at:        2 * time.Minute,
+			wantState: BreakerOpen,
+		},
+		{
+			name:      "Test with failures and no threshold",
+			results:   []error{failure, failure, failure},
+			wantState: BreakerClosed,
+			wantAllow: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := (&Registry{}).Breaker("test-namespace", "sink", tt.threshold, time.Minute)
+			for _, result := range tt.results {
+				b.Record(now, result)
+			}
+			err := b.Allow(now.Add(tt.at))
+			if (err == nil) != tt.wantAllow {
+				t.Errorf("Allow() error = %v, wantAllow %v", err, tt.wantAllow)
+			}
+			if err != nil && !errors.Is(err, ErrCircuitOpen) {
+				t.Errorf("Allow() error = %v, want %v", err, ErrCircuitOpen)
+			}
+			if got := b.State(); got != tt.wantState {
+				t.Errorf("State() = %v, want %v", got, tt.wantState)
+			}
+		})
+	}
+}
+
+func TestLimiter_Wait(t *testing.T) {
+	tests := []struct {
+		name      string
+		limit     rate.Limit
+		burst     int
+		maxWait   time.Duration
+		pause     time.Duration
+		waits     int
+		wantAllow int
+	}{
+		{
+			name:      "Test without a limit",
+			limit:     rate.Inf,
+			burst:     1,
+			waits:     5,
+			wantAllow: 5,
+		},
+		{
+			name:      "Test with an empty bucket",
+			limit:     rate.Every(time.Minute),
+			burst:     2,
+			waits:     5,
+			wantAllow: 2,
+		},
+		{
+			name:      "Test with a wait within the maximum wait",
+			limit:     rate.Every(10 * time.Millisecond),
+			burst:     1,
+			maxWait:   time.Second,
+			waits:     3,
+			wantAllow: 3,
+		},
+		{
+			name:      "Test with a pause",
+			limit:     rate.Inf,
+			burst:     1,
+			pause:     time.Minute,
+			waits:     2,
+			wantAllow: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := (&Registry{}).Limiter("test-namespace", "pubsub", tt.limit, tt.burst, tt.maxWait)
+			if tt.pause > 0 {
+				l.PauseUntil(time.Now().Add(tt.pause))
+			}
+			allowed := 0
+			for i := 0; i < tt.waits; i++ {
+				err := l.Wait(context.Background())
+				if err == nil {
+					allowed++
+					continue
+				}
+				if _, found := RetryAfter(err); !found || !errors.Is(err, ErrRateLimited) {
+					t.Errorf("Wait() error = %v, want a retry after of %v", err, ErrRateLimited)
+				}
+			}
+			if allowed != tt.wantAllow {
+				t.Errorf("Wait() allowed %d, want %d", allowed, tt.wantAllow)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// TracerName is the name of the tracer of the controller
+	TracerName = "github.com/kcloutie/tekton-observer/controller"
+	// DefaultServiceName is the service.name of the spans of the controller, OTEL_SERVICE_NAME overrides it
+	DefaultServiceName = "tekton-observer"
+)
+
+// Enabled returns true when the OTEL_* environment variables configure an OTLP exporter. The tracing is disabled by
+// OTEL_SDK_DISABLED=true and OTEL_TRACES_EXPORTER=none.
+func Enabled(getenv func(string) string) bool {
+	if strings.EqualFold(getenv("OTEL_SDK_DISABLED"), "true") {
+		return false
+	}
+	switch getenv("OTEL_TRACES_EXPORTER") {
+	case "otlp":
+		return true
+	case "none":
+		return false
+	}
+	return getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+}
+
+// Setup installs the tracer provider and the propagators of the controller when the OTEL_* environment variables
+// enable the tracing, the spans are not recorded otherwise. The exporter reads its endpoint, headers and TLS settings
+// from the OTEL_EXPORTER_OTLP_* variables and the sampler is set by OTEL_TRACES_SAMPLER and OTEL_TRACES_SAMPLER_ARG.
+// The returned function flushes the spans and stops the exporter.
+func Setup(ctx context.Context) (func(context.Context) error, error) {
+	if !Enabled(os.Getenv) {
+		return func(context.Context) error { return nil }, nil
+	}
+	exporter, err := newExporter(ctx, protocol(os.Getenv))
+	if err != nil {
+		return nil, err
+	}
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(DefaultServiceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the resource of the controller spans - %w", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	http.DefaultTransport = Transport(http.DefaultTransport)
+	return provider.Shutdown, nil
+}
+
+// protocol returns the OTLP protocol of the exporter, grpc or http/protobuf
+func protocol(getenv func(string) string) string {
+	if protocol := getenv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL"); protocol != "" {
+		return protocol
+	}
+	if protocol := getenv("OTEL_EXPORTER_OTLP_PROTOCOL"); protocol != "" {
+		return protocol
+	}
+	return "grpc"
+}
+
+func newExporter(ctx context.Context, protocol string) (sdktrace.SpanExporter, error) {
+	switch protocol {
+	case "grpc":
+		exporter, err := otlptracegrpc.New(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the OTLP grpc exporter of the controller spans - %w", err)
+		}
+		return exporter, nil
+	case "http/protobuf":
+		exporter, err := otlptracehttp.New(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the OTLP http exporter of the controller spans - %w", err)
+		}
+		return exporter, nil
+	}
+	return nil, fmt.Errorf("unsupported OTLP protocol '%s', the supported protocols are grpc and http/protobuf", protocol)
+}
+
+// Tracer returns the tracer of the controller, its spans are not recorded when the tracing is not enabled
+func Tracer() trace.Tracer {
+	return otel.Tracer(TracerName)
+}
+
+// Start starts a span of the controller
+func Start(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return Tracer().Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// End records the error on the span and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Reconciler traces every reconcile of the reconciler in a span of its own
+func Reconciler(name string, reconciler reconcile.Reconciler) reconcile.Reconciler {
+	return reconcile.Func(func(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+		ctx, span := Start(ctx, name,
+			semconv.K8SNamespaceName(req.Namespace),
+			attribute.String("k8s.object.name", req.Name),
+		)
+		result, err := reconciler.Reconcile(ctx, req)
+		if result.RequeueAfter > 0 {
+			span.SetAttributes(attribute.String("requeue_after", result.RequeueAfter.String()))
+		}
+		End(span, err)
+		return result, err
+	})
+}
+
+// MapFunc traces every call of the map function and records the number of requests it enqueued
+func MapFunc(name string, mapFunc handler.MapFunc) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		ctx, span := Start(ctx, name,
+			semconv.K8SNamespaceName(obj.GetNamespace()),
+			attribute.String("k8s.object.name", obj.GetName()),
+		)
+		defer span.End()
+		requests := mapFunc(ctx, obj)
+		span.SetAttributes(attribute.Int("requests", len(requests)))
+		return requests
+	}
+}
+
+// Transport propagates the span of the requests to the HTTP servers they are sent to
+func Transport(base http.RoundTripper) http.RoundTripper {
+	return otelhttp.NewTransport(base)
+}
+
+// GRPCDialOption traces the calls of a gRPC client and propagates their span to the server
+func GRPCDialOption() grpc.DialOption {
+	return grpc.WithStatsHandler(otelgrpc.NewClientHandler())
+}
@@ -0,0 +1,253 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// recordSpans installs a tracer provider recording the ended spans for the duration of the test
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+	})
+	return recorder
+}
+
+func attributeValue(span sdktrace.ReadOnlySpan, key attribute.Key) string {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value.Emit()
+		}
+	}
+	return ""
+}
+
+func TestEnabled(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want bool
+	}{
+		{
+			name: "Test with no OTEL variables",
+			want: false,
+		},
+		{
+			name: "Test with an OTLP endpoint",
+			env:  map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": "http://collector:4317"},
+			want: true,
+		},
+		{
+			name: "Test with an OTLP traces endpoint",
+			env:  map[string]string{"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT": "http://collector:4318/v1/traces"},
+			want: true,
+		},
+		{
+			name: "Test with the otlp exporter",
+			env:  map[string]string{"OTEL_TRACES_EXPORTER": "otlp"},
+			want: true,
+		},
+		{
+			name: "Test with the none exporter",
+			env:  map[string]string{"OTEL_TRACES_EXPORTER": "none", "OTEL_EXPORTER_OTLP_ENDPOINT": "http://collector:4317"},
+			want: false,
+		},
+		{
+			name: "Test with the SDK disabled",
+			env:  map[string]string{"OTEL_SDK_DISABLED": "true", "OTEL_EXPORTER_OTLP_ENDPOINT": "http://collector:4317"},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getenv := func(key string) string {
+				return tt.env[key]
+			}
+			if got := Enabled(getenv); got != tt.want {
+				t.Errorf("Enabled() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProtocol(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want string
+	}{
+		{
+			name: "Test with no protocol",
+			want: "grpc",
+		},
+		{
+			name: "Test with the protocol of every signal",
+			env:  map[string]string{"OTEL_EXPORTER_OTLP_PROTOCOL": "http/protobuf"},
+			want: "http/protobuf",
+		},
+		{
+			name: "Test with the protocol of the traces",
+			env:  map[string]string{"OTEL_EXPORTER_OTLP_PROTOCOL": "http/protobuf", "OTEL_EXPORTER_OTLP_TRACES_PROTOCOL": "grpc"},
+			want: "grpc",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getenv := func(key string) string {
+				return tt.env[key]
+			}
+			if got := protocol(getenv); got != tt.want {
+				t.Errorf("protocol() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReconciler(t *testing.T) {
+	tests := []struct {
+		name             string
+		result           reconcile.Result
+		err              error
+		wantStatus       codes.Code
+		wantRequeueAfter string
+	}{
+		{
+			name:       "Test with a successful reconcile",
+			wantStatus: codes.Unset,
+		},
+		{
+			name:       "Test with a failed reconcile",
+			err:        errors.New("failed to deliver"),
+			wantStatus: codes.Error,
+		},
+		{
+			name:             "Test with a requeued reconcile",
+			result:           reconcile.Result{RequeueAfter: time.Minute},
+			wantStatus:       codes.Unset,
+			wantRequeueAfter: "1m0s",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := recordSpans(t)
+			reconciler := Reconciler("reconcile PipelineRun", reconcile.Func(func(ctx context.Context, req reconcile.Request) (reconcile.Result, error) {
+				_, child := Start(ctx, "deliver to sink")
+				End(child, tt.err)
+				return tt.result, tt.err
+			}))
+
+			req := reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "test-namespace", Name: "build-run"}}
+			if _, err := reconciler.Reconcile(context.Background(), req); !errors.Is(err, tt.err) {
+				t.Fatalf("Reconcile() error = %v, want %v", err, tt.err)
+			}
+
+			spans := recorder.Ended()
+			if len(spans) != 2 {
+				t.Fatalf("Reconcile() recorded %d spans, want 2", len(spans))
+			}
+			child, span := spans[0], spans[1]
+			if span.Name() != "reconcile PipelineRun" || child.Parent().SpanID() != span.SpanContext().SpanID() {
+				t.Errorf("Reconcile() recorded the spans %s and %s, want the span of the reconcile as parent", span.Name(), child.Name())
+			}
+			if got := span.Status().Code; got != tt.wantStatus {
+				t.Errorf("span status = %v, want %v", got, tt.wantStatus)
+			}
+			if got := attributeValue(span, "k8s.object.name"); got != "build-run" {
+				t.Errorf("span k8s.object.name = %v, want build-run", got)
+			}
+			if got := attributeValue(span, "requeue_after"); got != tt.wantRequeueAfter {
+				t.Errorf("span requeue_after = %v, want %v", got, tt.wantRequeueAfter)
+			}
+		})
+	}
+}
+
+func TestMapFunc(t *testing.T) {
+	tests := []struct {
+		name     string
+		requests []reconcile.Request
+		want     string
+	}{
+		{
+			name: "Test with no request",
+			want: "0",
+		},
+		{
+			name: "Test with requests",
+			requests: []reconcile.Request{
+				{NamespacedName: types.NamespacedName{Namespace: "test-namespace", Name: "build-run"}},
+				{NamespacedName: types.NamespacedName{Namespace: "test-namespace", Name: "deploy-run"}},
+			},
+			want: "2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := recordSpans(t)
+			mapFunc := MapFunc("map TektonObservation to PipelineRuns", func(ctx context.Context, obj client.Object) []reconcile.Request {
+				return tt.requests
+			})
+
+			obj := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "test-namespace", Name: "observation"}}
+			if got := mapFunc(context.Background(), obj); len(got) != len(tt.requests) {
+				t.Errorf("MapFunc() returned %d requests, want %d", len(got), len(tt.requests))
+			}
+			spans := recorder.Ended()
+			if len(spans) != 1 {
+				t.Fatalf("MapFunc() recorded %d spans, want 1", len(spans))
+			}
+			if got := attributeValue(spans[0], "requests"); got != tt.want {
+				t.Errorf("span requests = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetup(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr bool
+	}{
+		{
+			name: "Test with the tracing disabled",
+		},
+		{
+			name:    "Test with an unsupported protocol",
+			env:     map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": "http://collector:4317", "OTEL_EXPORTER_OTLP_PROTOCOL": "http/json"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"OTEL_SDK_DISABLED", "OTEL_TRACES_EXPORTER", "OTEL_EXPORTER_OTLP_ENDPOINT", "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "OTEL_EXPORTER_OTLP_PROTOCOL", "OTEL_EXPORTER_OTLP_TRACES_PROTOCOL"} {
+				t.Setenv(key, tt.env[key])
+			}
+			shutdown, err := Setup(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Setup() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				if err := shutdown(context.Background()); err != nil {
+					t.Errorf("shutdown() error = %v", err)
+				}
+			}
+		})
+	}
+}
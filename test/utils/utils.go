@@ -22,8 +22,10 @@ import (
 	"os/exec"
 	"strings"
 
+	observerv1 "github.com/kcloutie/tekton-observer/api/tektonobserver/v1"
 	. "github.com/onsi/ginkgo/v2" //nolint:golint,revive
 	tknv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1"
+	coordinationv1 "k8s.io/api/coordination/v1"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -152,9 +154,12 @@ func NewFakeClient(initObjs ...runtime.Object) client.WithWatch {
 	clientBuilder := fake.ClientBuilder{}
 	tknv1.AddToScheme(scheme)
 	v1.AddToScheme(scheme)
+	observerv1.AddToScheme(scheme)
+	coordinationv1.AddToScheme(scheme)
 
 	clientBuilder.WithScheme(scheme)
 	clientBuilder.WithRuntimeObjects(initObjs...)
+	clientBuilder.WithStatusSubresource(&observerv1.ObservationDelivery{}, &observerv1.TektonObservation{})
 	return clientBuilder.Build()
 }
 